@@ -28,7 +28,7 @@ func AddProductCategory(c *gin.Context) {
 		return
 	}
 	rsp, err := logic.AddProductCategory(
-		req.ParentID, req.CategoryName, req.CategoryNameEN,
+		c.Request.Context(), req.ParentID, req.CategoryName, req.CategoryNameEN,
 		req.Image, req.Detail, req.DetailEN,
 	)
 	common.SetResponseContext(c, rsp, err)
@@ -47,7 +47,7 @@ func DeleteProductCategory(c *gin.Context) {
 		common.SetResponseContext(c, common.NewParamErrResponse(err), nil)
 		return
 	}
-	rsp, err := logic.DeleteProductCategory(req.ID)
+	rsp, err := logic.DeleteProductCategory(c.Request.Context(), req.ID)
 	common.SetResponseContext(c, rsp, err)
 	return
 }
@@ -71,7 +71,7 @@ func UpdateProductCategory(c *gin.Context) {
 		return
 	}
 	rsp, err := logic.UpdateProductCategory(
-		req.ID, req.ParentID, req.CategoryName, req.CategoryNameEN,
+		c.Request.Context(), req.ID, req.ParentID, req.CategoryName, req.CategoryNameEN,
 		req.Image, req.Detail, req.DetailEN,
 	)
 	common.SetResponseContext(c, rsp, err)
@@ -90,7 +90,7 @@ func QueryProductCategoryList(c *gin.Context) {
 		common.SetResponseContext(c, common.NewParamErrResponse(err), nil)
 		return
 	}
-	rsp, err := logic.QueryProductCategoryList(req.ParentID)
+	rsp, err := logic.QueryProductCategoryList(c.Request.Context(), req.ParentID)
 	common.SetResponseContext(c, rsp, err)
 	return
 }
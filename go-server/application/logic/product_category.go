@@ -4,14 +4,37 @@
 package logic
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"go-server/common"
+	"go-server/component"
+	"go-server/library/log"
+	"go-server/library/redis"
+	"go-server/library/trace"
 	"go-server/model"
 )
 
+const (
+	productCategoryCacheTag          = "product_category"         // 产品类目缓存标签, 类目变更时删除该标签下的所有缓存
+	productCategoryListCacheKeyFmt   = "product_category:list:%d" // 产品类目列表缓存键, 参数为父ID
+	productCategoryListCacheDuration = 10 * time.Minute           // 产品类目列表缓存时长
+)
+
+// invalidateProductCategoryCache 删除所有产品类目相关缓存, 类目的新增, 删除和更新都可能影响多个父类目下的列表,
+// 调用时数据库写入已经提交, 缓存删除失败不影响操作结果, 只记录错误日志, 残留的缓存在过期后自然失效,
+// 删除在只携带请求链路信息的ctx上执行, 不因客户端断开等请求取消而中断
+func invalidateProductCategoryCache(ctx context.Context) {
+	ctx = trace.Detach(ctx)
+	if err := component.CacheContainer.InvalidateTags(ctx, productCategoryCacheTag); err != nil {
+		component.ErrLogger.ErrorContext(ctx, log.F{"log_type": common.LogTypeForCache},
+			fmt.Errorf("component.CacheContainer.InvalidateTags[tag=%s]: %w", productCategoryCacheTag, err))
+	}
+}
+
 // AddProductCategory 新增产品类目逻辑
-func AddProductCategory(parentID int64, categoryName, categoryNameEN, image, desc, descEN string) (rsp *common.Response, err error) {
+func AddProductCategory(ctx context.Context, parentID int64, categoryName, categoryNameEN, image, desc, descEN string) (rsp *common.Response, err error) {
 	rsp = common.NewOKResponse()
 
 	cate := &model.ProductCategory{
@@ -23,7 +46,7 @@ func AddProductCategory(parentID int64, categoryName, categoryNameEN, image, des
 		DetailEN:       descEN,
 	}
 
-	id, err := model.AddProductCategory(cate)
+	id, err := model.AddProductCategory(ctx, cate)
 	if err != nil {
 		rsp.Code = common.ResponseCodeInternalErr
 		rsp.Message = "新增产品类目失败"
@@ -35,25 +58,29 @@ func AddProductCategory(parentID int64, categoryName, categoryNameEN, image, des
 		"category_id": id,
 	}
 
+	invalidateProductCategoryCache(ctx)
+
 	return
 }
 
 // DeleteProductCategory 删除产品类目逻辑
-func DeleteProductCategory(id int64) (rsp *common.Response, err error) {
+func DeleteProductCategory(ctx context.Context, id int64) (rsp *common.Response, err error) {
 	rsp = common.NewOKResponse()
 
-	if err = model.DeleteProductCategory(id); err != nil {
+	if err = model.DeleteProductCategory(ctx, id); err != nil {
 		rsp.Code = common.ResponseCodeInternalErr
 		rsp.Message = "删除产品类目失败"
 		err = fmt.Errorf("model.DeleteProductCategory[id=%d]: %w", id, err)
 		return
 	}
 
+	invalidateProductCategoryCache(ctx)
+
 	return
 }
 
 // UpdateProductCategory 更新产品类目逻辑
-func UpdateProductCategory(id, parentID int64, categoryName, categoryNameEN, image, desc, descEN string) (rsp *common.Response, err error) {
+func UpdateProductCategory(ctx context.Context, id, parentID int64, categoryName, categoryNameEN, image, desc, descEN string) (rsp *common.Response, err error) {
 	rsp = common.NewOKResponse()
 
 	cate := &model.ProductCategory{
@@ -66,25 +93,36 @@ func UpdateProductCategory(id, parentID int64, categoryName, categoryNameEN, ima
 		DetailEN:       descEN,
 	}
 
-	if err = model.UpdateProductCategory(cate); err != nil {
+	if err = model.UpdateProductCategory(ctx, cate); err != nil {
 		rsp.Code = common.ResponseCodeInternalErr
 		rsp.Message = "更新产品类目失败"
 		err = fmt.Errorf("model.UpdateProductCategory[category=%+v]: %w", *cate, err)
 		return
 	}
 
+	// 更新可能改变父ID, 新旧父类目下的列表都会受影响
+	invalidateProductCategoryCache(ctx)
+
 	return
 }
 
 // QueryProductCategoryList 查询产品类目列表逻辑
-func QueryProductCategoryList(parentID int64) (rsp *common.Response, err error) {
+func QueryProductCategoryList(ctx context.Context, parentID int64) (rsp *common.Response, err error) {
 	rsp = common.NewOKResponse()
 
-	list, err := model.QueryProductCategoryList(parentID)
-	if err != nil {
+	var list []*model.ProductCategory
+	key := fmt.Sprintf(productCategoryListCacheKeyFmt, parentID)
+	loader := func(ctx context.Context) (interface{}, error) {
+		return model.QueryProductCategoryList(ctx, parentID)
+	}
+
+	if err = component.CacheContainer.GetOrLoad(
+		ctx, key, productCategoryListCacheDuration, loader, &list,
+		redis.WithTags(productCategoryCacheTag),
+	); err != nil {
 		rsp.Code = common.ResponseCodeInternalErr
 		rsp.Message = "查询产品类目列表失败"
-		err = fmt.Errorf("model.QueryProductCategoryList[parent_id=%d]: %w", parentID, err)
+		err = fmt.Errorf("component.CacheContainer.GetOrLoad[key=%s]: %w", key, err)
 		return
 	}
 
@@ -41,10 +41,11 @@ func Log(c *gin.Context) {
 		"latency_ms": latency,
 	}
 
-	component.InfLogger.Info(fields)
+	ctx := c.Request.Context()
+	component.InfLogger.InfoContext(ctx, fields)
 
 	if err != nil {
 		fields["error"] = err
-		component.ErrLogger.Error(fields)
+		component.ErrLogger.ErrorContext(ctx, fields)
 	}
 }
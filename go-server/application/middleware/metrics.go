@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-server/common"
+	"go-server/component"
+)
+
+// Metrics 指标中间件, 按路由模板和业务响应码记录请求数和耗时, 未启用指标时不做任何处理,
+// 应注册在路由分组上, 没有匹配路由的请求不经过分组的中间件, 因此路由模板不会为空
+func Metrics(c *gin.Context) {
+	if component.Metrics == nil {
+		c.Next()
+		return
+	}
+
+	start := time.Now()
+	c.Next()
+
+	code := ""
+	if rsp, _ := common.GetResponseContext(c); rsp != nil {
+		code = strconv.Itoa(int(rsp.Code))
+	}
+
+	component.Metrics.ObserveHTTP(c.Request.Method, c.FullPath(), c.Writer.Status(), code, time.Since(start))
+}
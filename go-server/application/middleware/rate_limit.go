@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-server/common"
+	"go-server/component"
+	"go-server/library/log"
+	"go-server/library/redis"
+)
+
+const (
+	HeaderRateLimitLimit     = "X-RateLimit-Limit"
+	HeaderRateLimitRemaining = "X-RateLimit-Remaining"
+	HeaderRateLimitReset     = "X-RateLimit-Reset"
+	HeaderRetryAfter         = "Retry-After"
+)
+
+// RateLimitByUser 按登陆用户限流中间件, 需在Auth中间件之后使用
+func RateLimitByUser(c *gin.Context) {
+	uid, _ := common.GetLoginContext(c)
+	rateLimit(c, "user:"+uid, component.RateLimits.User())
+}
+
+// RateLimitByIP 按客户端IP限流中间件
+func RateLimitByIP(c *gin.Context) {
+	rateLimit(c, "ip:"+c.ClientIP(), component.RateLimits.IP())
+}
+
+// RateLimitByRoute 按路由限流中间件, 同一路由的所有请求共享额度
+func RateLimitByRoute(c *gin.Context) {
+	rateLimit(c, "route:"+c.Request.Method+":"+c.FullPath(), component.RateLimits.Route())
+}
+
+// rateLimit 按规则limit对key限流, 超出限制时以429响应, 多个限流中间件叠加时响应头保留剩余额度最少的结果,
+// 限流器自身出错时放行请求
+func rateLimit(c *gin.Context, key string, limit redis.Limit) {
+	if limit.Rate <= 0 {
+		c.Next()
+		return
+	}
+
+	rst, err := component.RateLimiter.Allow(c.Request.Context(), key, limit)
+	if err != nil {
+		component.ErrLogger.ErrorContext(c.Request.Context(), log.F{"log_type": common.LogTypeForRateLimit}, err)
+		c.Next()
+		return
+	}
+
+	setRateLimitHeader(c, rst)
+
+	if !rst.Allowed {
+		c.Header(HeaderRetryAfter, strconv.FormatInt(ceilSecond(rst.RetryAfter), 10))
+		common.SetResponseContext(c, &common.Response{
+			Code:    common.ResponseCodeRateLimited,
+			Message: "请求过于频繁, 请稍后再试",
+		}, nil)
+		c.Status(http.StatusTooManyRequests)
+		c.Abort()
+		return
+	}
+
+	c.Next()
+}
+
+func setRateLimitHeader(c *gin.Context, rst redis.LimitResult) {
+	if v := c.Writer.Header().Get(HeaderRateLimitRemaining); v != "" {
+		if remaining, err := strconv.Atoi(v); err == nil && remaining <= rst.Remaining {
+			return
+		}
+	}
+
+	c.Header(HeaderRateLimitLimit, strconv.Itoa(rst.Limit))
+	c.Header(HeaderRateLimitRemaining, strconv.Itoa(rst.Remaining))
+	c.Header(HeaderRateLimitReset, strconv.FormatInt(ceilSecond(rst.ResetAfter), 10))
+}
+
+// ceilSecond 将时长向上取整为秒数
+func ceilSecond(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
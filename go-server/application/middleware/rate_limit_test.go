@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"go-server/component"
+	"go-server/library/redis"
+)
+
+func TestRateLimit(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ct, err := redis.NewContainer(func() (*redis.ClientConf, error) {
+		return &redis.ClientConf{Addr: mr.Addr()}, nil
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer ct.Close()
+
+	limiter := component.RateLimiter
+	component.RateLimiter = ct.NewLimiter("ratelimit", nil)
+	defer func() { component.RateLimiter = limiter }()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Response, func(c *gin.Context) {
+		rateLimit(c, "k", redis.Limit{Rate: 1, Period: time.Minute})
+	})
+	router.GET("/", func(c *gin.Context) {})
+
+	do := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w
+	}
+
+	w := do()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0", w.Header().Get(HeaderRateLimitRemaining))
+
+	w = do()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get(HeaderRetryAfter))
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "application/json"))
+	assert.Contains(t, w.Body.String(), `"code":4`)
+
+	// redis不可用时降级为进程内限流, 之后的请求不再等待redis
+	mr.Close()
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		do()
+	}
+	assert.Less(t, time.Since(start), time.Second)
+}
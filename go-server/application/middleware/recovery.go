@@ -11,7 +11,7 @@ import (
 func Recovery(c *gin.Context) {
 	defer func() {
 		if err := recover(); err != nil {
-			component.ErrLogger.Error(log.F{
+			component.ErrLogger.ErrorContext(c.Request.Context(), log.F{
 				"log_type": common.LogTypeForPanic,
 				"info":     err,
 			})
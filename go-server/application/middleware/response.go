@@ -4,16 +4,14 @@
 package middleware
 
 import (
-	"net/http"
-
 	"github.com/gin-gonic/gin"
 	"go-sever/common"
 )
 
-// Response json响应中间件
+// Response json响应中间件, 后续处理未设置HTTP状态码时以200响应
 func Response(c *gin.Context) {
 	common.SetResponseContext(c, common.NewOKResponse(), nil)
 	c.Next()
 	rsp, _ := common.GetResponseContext(c)
-	c.JSON(http.StatusOK, rsp)
+	c.JSON(c.Writer.Status(), rsp)
 }
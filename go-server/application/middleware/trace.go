@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	"go-server/common"
+	"go-server/library/trace"
+)
+
+// Trace 链路追踪中间件, 以trace.Propagator从traceparent和X-Request-ID请求头继续上游的链路, 没有时开始新的链路,
+// 并为请求创建以路由模板命名的服务端span, 5xx状态码或业务逻辑返回错误时span标记为错误, 链路信息和span写入请求的context, 并通过X-Request-ID响应头返回请求ID
+func Trace(c *gin.Context) {
+	ctx := trace.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+	route := c.FullPath()
+	name := c.Request.Method
+	if route != "" {
+		name += " " + route
+	}
+
+	ctx, span := trace.StartSpan(ctx, name,
+		oteltrace.WithSpanKind(oteltrace.SpanKindServer),
+		oteltrace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest("", route, c.Request)...),
+	)
+	defer span.End()
+
+	c.Request = c.Request.WithContext(ctx)
+	c.Header(trace.HeaderRequestID, trace.RequestID(ctx))
+
+	c.Next()
+
+	status := c.Writer.Status()
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+	if code, msg := semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(status, oteltrace.SpanKindServer); code == codes.Error {
+		span.SetStatus(code, msg)
+	}
+	if ierr, ok := c.Get(common.ContextKeyForError); ok {
+		if err, ok := ierr.(error); ok && err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+}
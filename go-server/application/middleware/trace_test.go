@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	libhttp "go-server/library/http"
+	"go-server/library/trace"
+	"go-server/library/trace/tracetest"
+)
+
+func TestTrace(t *testing.T) {
+	const (
+		traceID = "0af7651916cd43dd8448eb211c80319c"
+		spanID  = "b7ad6b7169203331"
+	)
+
+	tests := []struct {
+		name        string
+		traceparent string
+		requestID   string
+	}{
+		{name: "continue upstream", traceparent: "00-" + traceID + "-" + spanID + "-01", requestID: "req-1"},
+		{name: "new trace"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := tracetest.Record(t)
+
+			var downstream http.Header
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				downstream = r.Header.Clone()
+			}))
+			defer srv.Close()
+
+			cli, err := libhttp.NewClient(&libhttp.ClientConf{})
+			assert.Nil(t, err)
+			defer cli.Close()
+
+			// 处理函数的ctx以服务端span为当前操作, 调用下游时继续同一链路
+			var sc trace.SpanContext
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(Trace)
+			router.GET("/users/:id", func(c *gin.Context) {
+				sc, _ = trace.FromContext(c.Request.Context())
+				assert.Nil(t, cli.NewRequest(c.Request.Context(), http.MethodGet, srv.URL).Do(nil))
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+			if tt.traceparent != "" {
+				req.Header.Set(trace.HeaderTraceparent, tt.traceparent)
+				req.Header.Set(trace.HeaderRequestID, tt.requestID)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			server := rec.Span("GET /users/:id")
+			client := rec.Span("GET")
+			if !assert.NotNil(t, server) || !assert.NotNil(t, client) {
+				return
+			}
+			assert.Equal(t, oteltrace.SpanKindServer, server.SpanKind())
+			assert.Equal(t, server.SpanContext().TraceID().String(), sc.TraceID)
+			assert.Equal(t, server.SpanContext().SpanID().String(), sc.SpanID)
+			assert.Equal(t, server.SpanContext().SpanID(), client.Parent().SpanID())
+			assert.Equal(t, server.SpanContext().TraceID(), client.SpanContext().TraceID())
+
+			if tt.traceparent != "" {
+				assert.Equal(t, traceID, sc.TraceID)
+				assert.Equal(t, spanID, server.Parent().SpanID().String())
+				assert.True(t, server.Parent().IsRemote())
+				assert.Equal(t, tt.requestID, sc.RequestID)
+			} else {
+				assert.False(t, server.Parent().IsValid())
+				assert.NotEmpty(t, sc.RequestID)
+			}
+
+			// 响应头返回请求ID, 下游收到以客户端span为上游的链路信息和相同的请求ID
+			assert.Equal(t, sc.RequestID, w.Header().Get(trace.HeaderRequestID))
+			assert.Equal(t, "00-"+sc.TraceID+"-"+client.SpanContext().SpanID().String()+"-01", downstream.Get(trace.HeaderTraceparent))
+			assert.Equal(t, sc.RequestID, downstream.Get(trace.HeaderRequestID))
+		})
+	}
+}
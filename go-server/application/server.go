@@ -39,6 +39,7 @@ import (
 )
 
 import (
+	_ "go-server/library/dubbo"
 	"go-server/pkg"
 )
 
@@ -24,9 +24,29 @@ func setupCmd() {
 			Flags: []cli.Flag{
 				cli.StringFlag{Name: "c", Value: ".env", Usage: "config file"},
 				cli.StringFlag{Name: "p", Value: "3000", Usage: "http listen port"},
+				cli.IntFlag{Name: "read-header-timeout", Usage: "http read header timeout in seconds, overrides HTTP_READ_HEADER_TIMEOUT"},
+				cli.IntFlag{Name: "read-timeout", Usage: "http read timeout in seconds, overrides HTTP_READ_TIMEOUT"},
+				cli.IntFlag{Name: "write-timeout", Usage: "http write timeout in seconds, overrides HTTP_WRITE_TIMEOUT"},
+				cli.IntFlag{Name: "idle-timeout", Usage: "http idle timeout in seconds, overrides HTTP_IDLE_TIMEOUT"},
+				cli.IntFlag{Name: "max-header-bytes", Usage: "http max header bytes, overrides HTTP_MAX_HEADER_BYTES"},
+				cli.StringFlag{Name: "tls-cert", Usage: "tls cert file, overrides HTTP_TLS_CERT_FILE"},
+				cli.StringFlag{Name: "tls-key", Usage: "tls key file, overrides HTTP_TLS_KEY_FILE"},
+				cli.StringFlag{Name: "tls-min-version", Usage: "min tls version, overrides HTTP_TLS_MIN_VERSION"},
+				cli.IntFlag{Name: "shutdown-timeout", Usage: "graceful shutdown timeout in seconds, overrides HTTP_SHUTDOWN_TIMEOUT"},
+				cli.StringFlag{Name: "admin-addr", Usage: "admin listen address such as 127.0.0.1:6060, overrides ADMIN_ADDR"},
 			},
 			Before: func(ctx *cli.Context) (err error) {
-				err = setupComponent(ctx.String("c"), ctx.Int("p"))
+				err = setupComponent(ctx.String("c"), ctx.Int("p"), ctx.String("admin-addr"), &component.HttpServerConfig{
+					ReadHeaderTimeoutSecond: ctx.Int("read-header-timeout"),
+					ReadTimeoutSecond:       ctx.Int("read-timeout"),
+					WriteTimeoutSecond:      ctx.Int("write-timeout"),
+					IdleTimeoutSecond:       ctx.Int("idle-timeout"),
+					MaxHeaderBytes:          ctx.Int("max-header-bytes"),
+					CertFile:                ctx.String("tls-cert"),
+					KeyFile:                 ctx.String("tls-key"),
+					MinTLSVersion:           ctx.String("tls-min-version"),
+					ShutdownTimeoutSecond:   ctx.Int("shutdown-timeout"),
+				})
 				return
 			},
 			Action: func(ctx *cli.Context) (err error) {
@@ -10,7 +10,7 @@ import (
 )
 
 // setupComponent 配置组件
-func setupComponent(conf string, port int) (err error) {
+func setupComponent(conf string, port int, adminAddr string, httpFlags *component.HttpServerConfig) (err error) {
 
 	// 配置配置组件
 	if err = component.SetupConf(conf); err != nil {
@@ -30,23 +30,71 @@ func setupComponent(conf string, port int) (err error) {
 		return
 	}
 
+	// 配置链路追踪, 需在使用链路追踪的组件之前配置
+	if err = component.SetupTracing(); err != nil {
+		err = fmt.Errorf("component.SetupTracing: %w", err)
+		return
+	}
+
 	// 配置缓存
-	//if err = component.SetupCache(); err != nil {
-	//	err = fmt.Errorf("component.SetupCache: %w", err)
-	//	return
-	//}
+	if err = component.SetupCache(); err != nil {
+		err = fmt.Errorf("component.SetupCache: %w", err)
+		return
+	}
+
+	// 配置接口限流
+	if err = component.SetupRateLimiter(); err != nil {
+		err = fmt.Errorf("component.SetupRateLimiter: %w", err)
+		return
+	}
 
 	// 配置DB
-	//if err = component.SetupDB(); err != nil {
-	//	err = fmt.Errorf("component.SetupDB: %w", err)
-	//	return
-	//}
+	if err = component.SetupDB(); err != nil {
+		err = fmt.Errorf("component.SetupDB: %w", err)
+		return
+	}
+
+	// 配置Kafka生产者
+	if err = component.SetupProducer(); err != nil {
+		err = fmt.Errorf("component.SetupProducer: %w", err)
+		return
+	}
+
+	// 配置Elasticsearch, 未配置ES_URL时不启用
+	if err = component.SetupES(); err != nil {
+		err = fmt.Errorf("component.SetupES: %w", err)
+		return
+	}
+
+	// 配置发件箱投递, 依赖DB和Kafka生产者
+	if err = component.SetupOutboxRelay(); err != nil {
+		err = fmt.Errorf("component.SetupOutboxRelay: %w", err)
+		return
+	}
 
 	// 配置HTTP服务
-	if err = component.SetupHttpServer(port); err != nil {
+	if err = component.SetupHttpServer(port, httpFlags); err != nil {
 		err = fmt.Errorf("component.SetupHttpServer(%d): %v", port, err)
 		return
 	}
 
+	// 配置健康检查, 需在依赖组件和HTTP服务之后配置
+	if err = component.SetupHealthChecker(); err != nil {
+		err = fmt.Errorf("component.SetupHealthChecker: %w", err)
+		return
+	}
+
+	// 配置服务指标, 需在依赖组件之后, 运维接口之前配置
+	if err = component.SetupMetrics(); err != nil {
+		err = fmt.Errorf("component.SetupMetrics: %w", err)
+		return
+	}
+
+	// 配置运维接口, 需在其他组件之后配置
+	if err = component.SetupAdminServer(adminAddr); err != nil {
+		err = fmt.Errorf("component.SetupAdminServer(%s): %w", adminAddr, err)
+		return
+	}
+
 	return
 }
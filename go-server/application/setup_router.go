@@ -7,6 +7,7 @@ import (
 
 	"go-server/application/controller"
 	"go-server/application/middleware"
+	"go-server/component"
 )
 
 // setupRouter 设置路由
@@ -14,7 +15,7 @@ func setupRouter() http.Handler {
 	gin.SetMode(gin.ReleaseMode)
 
 	router := gin.New()
-	router.Use(middleware.Recovery)
+	router.Use(middleware.Trace, middleware.Recovery)
 
 	// 健康检查接口
 	router.GET("/health", func(c *gin.Context) {
@@ -22,14 +23,26 @@ func setupRouter() http.Handler {
 		c.AbortWithStatus(http.StatusOK)
 	})
 
+	// 存活和就绪检查接口, 带verbose参数时返回各项检查结果, 不包含错误信息, 错误信息通过运维接口查看
+	router.GET("/livez", gin.WrapH(component.HealthChecker.LiveHandler()))
+	router.GET("/readyz", gin.WrapH(component.HealthChecker.ReadyHandler()))
+
+	// 服务指标接口, 默认只通过运维接口提供, 配置METRICS_PUBLIC后HTTP服务同时提供
+	if component.Metrics != nil && component.MetricsPublic {
+		router.GET("/metrics", gin.WrapH(component.Metrics.Handler()))
+	}
+
 	// 接口路由分组
 	api := router.Group("/api").Use(
 		middleware.Log,
+		middleware.Metrics,
 		middleware.Response,
+		middleware.RateLimitByIP,
+		middleware.RateLimitByRoute,
 	)
 
 	// 以下接口需要权限认证
-	api.Use(middleware.Auth)
+	api.Use(middleware.Auth, middleware.RateLimitByUser)
 	{
 		api.POST("/product.category.add", controller.AddProductCategory)       // 新增产品类目
 		api.POST("/product.category.delete", controller.DeleteProductCategory) // 删除产品类目
@@ -7,4 +7,9 @@ const (
 	LogTypeForHTTPRequest = "http_req"
 	LogTypeForAppStart    = "app_start"
 	LogTypeForPanic       = "panic"
+	LogTypeForCache       = "cache"
+	LogTypeForRateLimit   = "rate_limit"
+	LogTypeForQueue       = "queue"
+	LogTypeForOutbox      = "outbox"
+	LogTypeForAdmin       = "admin"
 )
@@ -17,6 +17,7 @@ const (
 	ResponseCodeRequestParamErr
 	ResponseCodeInternalErr
 	ResponseCodeAuthFailed
+	ResponseCodeRateLimited
 )
 
 func NewOKResponse() *Response {
@@ -0,0 +1,86 @@
+package component
+
+import (
+	"fmt"
+
+	"go-server/common"
+	"go-server/library/admin"
+	"go-server/library/clean"
+	"go-server/library/http"
+	"go-server/library/log"
+)
+
+var (
+	AdminServer  *http.Server
+	AdminHandler *admin.Handler
+)
+
+// AdminConfig 运维接口配置, ADMIN_ADDR为监听地址, 应绑定本机或内网地址, 如127.0.0.1:6060, 为空时不启用
+type AdminConfig struct {
+	Addr string `env:"ADMIN_ADDR,omitempty"`
+}
+
+// SetupAdminServer 启动运维接口服务, addr非空时优先于配置文件, 需在其他组件配置完成后调用以注册各组件
+func SetupAdminServer(addr string) (err error) {
+	cfg := &AdminConfig{}
+	if err = Conf.Scan(cfg, "env"); err != nil {
+		err = fmt.Errorf("Conf.Scan: %w", err)
+		return
+	}
+
+	if addr != "" {
+		cfg.Addr = addr
+	}
+	if cfg.Addr == "" {
+		return
+	}
+
+	AdminHandler = admin.NewHandler()
+	AdminHandler.SetConfig(Conf.Items)
+	AdminHandler.RegisterLogger("inf", InfLogger)
+	AdminHandler.RegisterLogger("err", ErrLogger)
+	AdminHandler.RegisterContainer("inf_logger", InfLogger)
+	AdminHandler.RegisterContainer("err_logger", ErrLogger)
+	if CacheContainer != nil {
+		AdminHandler.RegisterContainer("cache", CacheContainer)
+	}
+	if DBContainer != nil {
+		AdminHandler.RegisterContainer("db", DBContainer)
+	}
+	if ProducerContainer != nil {
+		AdminHandler.RegisterContainer("producer", ProducerContainer)
+	}
+	if ESContainer != nil {
+		AdminHandler.RegisterContainer("elasticsearch", ESContainer)
+	}
+	if HealthChecker != nil {
+		AdminHandler.Handle("/livez", HealthChecker.LiveDetailHandler())
+		AdminHandler.Handle("/readyz", HealthChecker.ReadyDetailHandler())
+	}
+	if Metrics != nil {
+		AdminHandler.Handle("/metrics", Metrics.Handler())
+	}
+
+	// pprof的profile和trace接口按请求参数持续采样, 因此不限制写超时
+	AdminServer, err = http.NewServer(&http.ServerConf{
+		Addr:                    cfg.Addr,
+		ReadHeaderTimeoutSecond: 10,
+		ShutdownTimeoutSecond:   5,
+	})
+	if err != nil {
+		err = fmt.Errorf("http.NewServer: %w", err)
+		return
+	}
+
+	err = AdminServer.Start(AdminHandler, func(err error) {
+		ErrLogger.Error(log.F{"log_type": common.LogTypeForAdmin}, err)
+	})
+	if err != nil {
+		err = fmt.Errorf("AdminServer.Start: %w", err)
+		return
+	}
+
+	clean.Push(AdminServer)
+
+	return
+}
@@ -2,27 +2,60 @@ package component
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
+	"go-server/common"
 	"go-server/library/clean"
+	"go-server/library/log"
 	"go-server/library/redis"
 )
 
 var CacheContainer *redis.ClientContainer
 
+// RedisConfig redis配置, REDIS_MODE可选standalone, sentinel, cluster, 为空时为standalone,
+// sentinel和cluster模式通过REDIS_ADDRS配置哨兵或集群节点地址, 多个用英文','号隔开,
+// standalone模式未配置REDIS_ADDRS时使用REDIS_HOST和REDIS_PORT
 type RedisConfig struct {
-	Host     string `env:"REDIS_HOST"`
-	Port     int    `env:"REDIS_PORT"`
-	Password string `env:"REDIS_PASSWORD"`
-	DB       int    `env:"REDIS_DB"`
-	PoolSize int    `env:"REDIS_POOLSIZE"`
+	Mode       string `env:"REDIS_MODE,omitempty"`
+	Addrs      string `env:"REDIS_ADDRS,omitempty"`
+	MasterName string `env:"REDIS_MASTER_NAME,omitempty"`
+	Host       string `env:"REDIS_HOST,omitempty"`
+	Port       int    `env:"REDIS_PORT,omitempty"`
+	Password   string `env:"REDIS_PASSWORD,omitempty"`
+	DB         int    `env:"REDIS_DB"`
+	PoolSize   int    `env:"REDIS_POOLSIZE"`
+}
+
+// LocalCacheConfig 进程内本地缓存配置, 未配置CACHE_LOCAL_SIZE时不启用本地缓存
+type LocalCacheConfig struct {
+	Size      int `env:"CACHE_LOCAL_SIZE,omitempty"`
+	TTLSecond int `env:"CACHE_LOCAL_TTL,omitempty"`
 }
 
 func SetupCache() (err error) {
+	localCfg := &LocalCacheConfig{}
+	if err = Conf.Scan(localCfg, "env"); err != nil {
+		err = fmt.Errorf("Conf.Scan: %w", err)
+		return
+	}
+
 	CacheContainer, err = redis.NewContainer(getRedisConf)
 	if err != nil {
 		err = fmt.Errorf("redis.NewContainer: %w", err)
 		return
 	}
+	CacheContainer.SetCacheConf(&redis.CacheConf{
+		Codec:       redis.CodecJSON,
+		Jitter:      0.1,
+		NegativeTTL: 30 * time.Second,
+		HandleErr: func(err error) {
+			ErrLogger.Error(log.F{"log_type": common.LogTypeForCache}, err)
+		},
+		LocalSize: localCfg.Size,
+		LocalTTL:  time.Duration(localCfg.TTLSecond) * time.Second,
+	})
+
 	clean.Push(CacheContainer)
 	Conf.PushUpdater(CacheContainer)
 
@@ -37,11 +70,41 @@ func getRedisConf() (cf *redis.ClientConf, err error) {
 		return
 	}
 
+	switch strings.ToLower(cfg.Mode) {
+	case "", redis.ModeStandalone:
+		if cfg.Addrs == "" && (cfg.Host == "" || cfg.Port == 0) {
+			err = fmt.Errorf("REDIS_HOST and REDIS_PORT are required for standalone mode without REDIS_ADDRS")
+			return
+		}
+	case redis.ModeSentinel:
+		if cfg.Addrs == "" || cfg.MasterName == "" {
+			err = fmt.Errorf("REDIS_ADDRS and REDIS_MASTER_NAME are required for sentinel mode")
+			return
+		}
+	case redis.ModeCluster:
+		if cfg.Addrs == "" {
+			err = fmt.Errorf("REDIS_ADDRS is required for cluster mode")
+			return
+		}
+	default:
+		err = fmt.Errorf("unsupported REDIS_MODE %q", cfg.Mode)
+		return
+	}
+
+	addr := cfg.Addrs
+	if addr == "" {
+		addr = fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+	}
+
+	// 缓存读写失败时GetOrLoad降级为直接加载, redis不作为启动的硬依赖, 其连通性由就绪检查反映
 	cf = &redis.ClientConf{
-		Addr:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
-		Password: cfg.Password,
-		DB:       cfg.DB,
-		PoolSize: cfg.PoolSize,
+		Mode:        cfg.Mode,
+		Addr:        addr,
+		MasterName:  cfg.MasterName,
+		Password:    cfg.Password,
+		DB:          cfg.DB,
+		PoolSize:    cfg.PoolSize,
+		LazyConnect: true,
 	}
 
 	return
@@ -9,15 +9,19 @@ import (
 
 var DBContainer *mysql.DBContainer
 
+// DBConfig 数据库配置, DB_HOST, DB_PORT, DB_USERNAME和DB_PASSWORD仅在使用sqlite3驱动时可以省略,
+// 使用MySQL驱动时缺少任一项将返回错误, 不会以空值连接
 type DBConfig struct {
-	Name        string `env:"DB_NAME"`
-	Host        string `env:"DB_HOST"`
-	Port        string `env:"DB_PORT"`
-	UserName    string `env:"DB_USERNAME"`
-	Password    string `env:"DB_PASSWORD"`
-	MaxLifeTime int    `env:"DB_MAX_LIFE_TIME"`
-	MaxOpenConn int    `env:"DB_MAX_OPEN_CONN"`
-	MaxIdleConn int    `env:"DB_MAX_IDLE_CONN"`
+	Driver       string `env:"DB_DRIVER,omitempty"`
+	Name         string `env:"DB_NAME"`
+	Host         string `env:"DB_HOST,omitempty"`
+	Port         string `env:"DB_PORT,omitempty"`
+	UserName     string `env:"DB_USERNAME,omitempty"`
+	Password     string `env:"DB_PASSWORD,omitempty"`
+	MaxLifeTime  int    `env:"DB_MAX_LIFE_TIME"`
+	MaxOpenConn  int    `env:"DB_MAX_OPEN_CONN"`
+	MaxIdleConn  int    `env:"DB_MAX_IDLE_CONN"`
+	MigrationDir string `env:"DB_MIGRATION_DIR,omitempty"`
 }
 
 func SetupDB() (err error) {
@@ -41,15 +45,31 @@ func getDBConf() (cf *mysql.DBConf, err error) {
 		return
 	}
 
+	if cfg.Driver == "" || cfg.Driver == mysql.DriverMySQL {
+		for _, item := range []struct{ key, val string }{
+			{"DB_HOST", cfg.Host},
+			{"DB_PORT", cfg.Port},
+			{"DB_USERNAME", cfg.UserName},
+			{"DB_PASSWORD", cfg.Password},
+		} {
+			if item.val == "" {
+				err = fmt.Errorf("%s is required for mysql driver", item.key)
+				return
+			}
+		}
+	}
+
 	cf = &mysql.DBConf{
-		Name:        cfg.Name,
-		Host:        cfg.Host,
-		Port:        cfg.Port,
-		UserName:    cfg.UserName,
-		Password:    cfg.Password,
-		MaxLifeTime: cfg.MaxLifeTime,
-		MaxOpenConn: cfg.MaxOpenConn,
-		MaxIdleConn: cfg.MaxIdleConn,
+		Driver:       cfg.Driver,
+		Name:         cfg.Name,
+		Host:         cfg.Host,
+		Port:         cfg.Port,
+		UserName:     cfg.UserName,
+		Password:     cfg.Password,
+		MaxLifeTime:  cfg.MaxLifeTime,
+		MaxOpenConn:  cfg.MaxOpenConn,
+		MaxIdleConn:  cfg.MaxIdleConn,
+		MigrationDir: cfg.MigrationDir,
 	}
 
 	return
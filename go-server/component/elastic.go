@@ -0,0 +1,56 @@
+package component
+
+import (
+	"fmt"
+
+	"go-server/library/clean"
+	"go-server/library/elastic"
+)
+
+var ESContainer *elastic.ClientContainer
+
+// ESConfig Elasticsearch配置, ES_URL为空时不启用
+type ESConfig struct {
+	URL      string `env:"ES_URL,omitempty"`
+	Username string `env:"ES_USERNAME,omitempty"`
+	Password string `env:"ES_PASSWORD,omitempty"`
+}
+
+func SetupES() (err error) {
+	cf, err := getESConf()
+	if err != nil {
+		return
+	}
+
+	if cf.URL == "" {
+		return
+	}
+
+	ESContainer, err = elastic.NewClientContainer(getESConf)
+	if err != nil {
+		err = fmt.Errorf("elastic.NewClientContainer: %w", err)
+		return
+	}
+
+	clean.Push(ESContainer)
+	Conf.PushUpdater(ESContainer)
+
+	return
+}
+
+func getESConf() (cf *elastic.ClientConf, err error) {
+	cfg := &ESConfig{}
+
+	if err = Conf.Scan(cfg, "env"); err != nil {
+		err = fmt.Errorf("Conf.Scan: %w", err)
+		return
+	}
+
+	cf = &elastic.ClientConf{
+		URL:      cfg.URL,
+		Username: cfg.Username,
+		Password: cfg.Password,
+	}
+
+	return
+}
@@ -0,0 +1,67 @@
+package component
+
+import (
+	"fmt"
+	"time"
+
+	"go-server/library/clean"
+	"go-server/library/health"
+)
+
+const (
+	defaultHealthTimeoutMS = 2000
+	defaultHealthCacheMS   = 1000
+	defaultHealthDelaySec  = 5
+)
+
+var HealthChecker *health.Checker
+
+// HealthConfig 健康检查配置, HEALTH_CHECK_TIMEOUT为每项检查的超时, 单位为毫秒, 默认2000,
+// HEALTH_CACHE_TTL为检查结果的缓存时长, 单位为毫秒, 默认1000, 为负数时不缓存,
+// HEALTH_SHUTDOWN_DELAY为开始关闭后就绪检查失败到停止HTTP服务的等待时长, 单位为秒, 默认5, 用于负载均衡摘除流量
+type HealthConfig struct {
+	TimeoutMS           int `env:"HEALTH_CHECK_TIMEOUT,omitempty"`
+	CacheTTLMS          int `env:"HEALTH_CACHE_TTL,omitempty"`
+	ShutdownDelaySecond int `env:"HEALTH_SHUTDOWN_DELAY,omitempty"`
+}
+
+// SetupHealthChecker 以已配置的组件注册就绪检查, 需在其他依赖组件之后, SetupHttpServer之后调用,
+// 以便关闭时先使就绪检查失败并等待HEALTH_SHUTDOWN_DELAY, 再关闭HTTP服务
+func SetupHealthChecker() (err error) {
+	cfg := &HealthConfig{
+		TimeoutMS:           defaultHealthTimeoutMS,
+		CacheTTLMS:          defaultHealthCacheMS,
+		ShutdownDelaySecond: defaultHealthDelaySec,
+	}
+	if err = Conf.Scan(cfg, "env"); err != nil {
+		err = fmt.Errorf("Conf.Scan: %w", err)
+		return
+	}
+
+	HealthChecker = health.NewChecker(&health.Options{
+		Timeout:  time.Duration(cfg.TimeoutMS) * time.Millisecond,
+		CacheTTL: time.Duration(cfg.CacheTTLMS) * time.Millisecond,
+	})
+
+	if DBContainer != nil {
+		HealthChecker.AddReadiness("mysql", DBContainer.Ping)
+	}
+	if CacheContainer != nil {
+		HealthChecker.AddReadiness("redis", CacheContainer.Ping)
+	}
+	if ProducerContainer != nil {
+		HealthChecker.AddReadiness("kafka", ProducerContainer.Ping)
+	}
+	if ESContainer != nil {
+		HealthChecker.AddReadiness("elasticsearch", ESContainer.Ping)
+	}
+
+	delay := time.Duration(cfg.ShutdownDelaySecond) * time.Second
+	clean.PushFunc(func() (err error) {
+		HealthChecker.StartShutdown()
+		time.Sleep(delay)
+		return
+	})
+
+	return
+}
@@ -9,12 +9,81 @@ import (
 
 var HttpServer *http.Server
 
-func SetupHttpServer(port int) (err error) {
-	HttpServer = http.NewServer(&http.ServerConf{
-		Addr: fmt.Sprintf(":%d", port),
+// HttpServerConfig HTTP服务配置, 超时单位为秒, 为0时不限制, HTTP_SHUTDOWN_TIMEOUT默认30,
+// 同时配置HTTP_TLS_CERT_FILE和HTTP_TLS_KEY_FILE时以HTTPS提供服务, 证书文件变化时自动重新加载
+type HttpServerConfig struct {
+	ReadHeaderTimeoutSecond int    `env:"HTTP_READ_HEADER_TIMEOUT,omitempty"`
+	ReadTimeoutSecond       int    `env:"HTTP_READ_TIMEOUT,omitempty"`
+	WriteTimeoutSecond      int    `env:"HTTP_WRITE_TIMEOUT,omitempty"`
+	IdleTimeoutSecond       int    `env:"HTTP_IDLE_TIMEOUT,omitempty"`
+	MaxHeaderBytes          int    `env:"HTTP_MAX_HEADER_BYTES,omitempty"`
+	CertFile                string `env:"HTTP_TLS_CERT_FILE,omitempty"`
+	KeyFile                 string `env:"HTTP_TLS_KEY_FILE,omitempty"`
+	MinTLSVersion           string `env:"HTTP_TLS_MIN_VERSION,omitempty"`
+	ShutdownTimeoutSecond   int    `env:"HTTP_SHUTDOWN_TIMEOUT,omitempty"`
+}
+
+// SetupHttpServer 以配置文件中的HTTP服务配置创建服务, flags中的非零值优先于配置文件
+func SetupHttpServer(port int, flags *HttpServerConfig) (err error) {
+	cfg := &HttpServerConfig{}
+	if err = Conf.Scan(cfg, "env"); err != nil {
+		err = fmt.Errorf("Conf.Scan: %w", err)
+		return
+	}
+
+	if flags != nil {
+		cfg.override(flags)
+	}
+
+	HttpServer, err = http.NewServer(&http.ServerConf{
+		Addr:                    fmt.Sprintf(":%d", port),
+		ReadHeaderTimeoutSecond: cfg.ReadHeaderTimeoutSecond,
+		ReadTimeoutSecond:       cfg.ReadTimeoutSecond,
+		WriteTimeoutSecond:      cfg.WriteTimeoutSecond,
+		IdleTimeoutSecond:       cfg.IdleTimeoutSecond,
+		MaxHeaderBytes:          cfg.MaxHeaderBytes,
+		CertFile:                cfg.CertFile,
+		KeyFile:                 cfg.KeyFile,
+		MinTLSVersion:           cfg.MinTLSVersion,
+		ShutdownTimeoutSecond:   cfg.ShutdownTimeoutSecond,
 	})
+	if err != nil {
+		err = fmt.Errorf("http.NewServer: %w", err)
+		return
+	}
 
 	clean.Push(HttpServer)
 
 	return
 }
+
+// override 以o中的非零值覆盖cfg
+func (cfg *HttpServerConfig) override(o *HttpServerConfig) {
+	if o.ReadHeaderTimeoutSecond != 0 {
+		cfg.ReadHeaderTimeoutSecond = o.ReadHeaderTimeoutSecond
+	}
+	if o.ReadTimeoutSecond != 0 {
+		cfg.ReadTimeoutSecond = o.ReadTimeoutSecond
+	}
+	if o.WriteTimeoutSecond != 0 {
+		cfg.WriteTimeoutSecond = o.WriteTimeoutSecond
+	}
+	if o.IdleTimeoutSecond != 0 {
+		cfg.IdleTimeoutSecond = o.IdleTimeoutSecond
+	}
+	if o.MaxHeaderBytes != 0 {
+		cfg.MaxHeaderBytes = o.MaxHeaderBytes
+	}
+	if o.CertFile != "" {
+		cfg.CertFile = o.CertFile
+	}
+	if o.KeyFile != "" {
+		cfg.KeyFile = o.KeyFile
+	}
+	if o.MinTLSVersion != "" {
+		cfg.MinTLSVersion = o.MinTLSVersion
+	}
+	if o.ShutdownTimeoutSecond != 0 {
+		cfg.ShutdownTimeoutSecond = o.ShutdownTimeoutSecond
+	}
+}
@@ -0,0 +1,71 @@
+package component
+
+import (
+	"fmt"
+
+	"go-server/library/metrics"
+)
+
+var (
+	Metrics *metrics.Metrics
+
+	// MetricsPublic 是否同时在HTTP服务上提供/metrics接口
+	MetricsPublic bool
+)
+
+// MetricsConfig 指标配置, METRICS_ENABLED为true时启用, 指标通过运维接口的/metrics暴露,
+// METRICS_PUBLIC为true时HTTP服务同时提供不需要认证的/metrics接口, 仅在HTTP服务不对外公开时使用,
+// METRICS_NAMESPACE为指标名称前缀, 可以为空
+type MetricsConfig struct {
+	Enabled   bool   `env:"METRICS_ENABLED,omitempty"`
+	Public    bool   `env:"METRICS_PUBLIC,omitempty"`
+	Namespace string `env:"METRICS_NAMESPACE,omitempty"`
+}
+
+// SetupMetrics 创建服务指标并接入已配置的组件, 需在其他依赖组件之后, SetupAdminServer之前调用
+func SetupMetrics() (err error) {
+	cfg := &MetricsConfig{}
+	if err = Conf.Scan(cfg, "env"); err != nil {
+		err = fmt.Errorf("Conf.Scan: %w", err)
+		return
+	}
+
+	if !cfg.Enabled {
+		return
+	}
+
+	m := metrics.New(cfg.Namespace)
+	Conf.RegisterReloadHook(m.ObserveReload)
+
+	containers := map[string]metrics.StatusContainer{
+		"inf_logger": InfLogger,
+		"err_logger": ErrLogger,
+	}
+	if CacheContainer != nil {
+		CacheContainer.SetObserver(m)
+		containers["cache"] = CacheContainer
+	}
+	if DBContainer != nil {
+		DBContainer.SetObserver(m)
+		containers["db"] = DBContainer
+		if err = m.RegisterDB("default", DBContainer.Stats); err != nil {
+			err = fmt.Errorf("Metrics.RegisterDB: %w", err)
+			return
+		}
+	}
+	if ProducerContainer != nil {
+		ProducerContainer.SetObserver(m)
+		containers["producer"] = ProducerContainer
+	}
+
+	for name, ct := range containers {
+		if err = m.RegisterContainer(name, ct); err != nil {
+			err = fmt.Errorf("Metrics.RegisterContainer(%s): %w", name, err)
+			return
+		}
+	}
+
+	Metrics, MetricsPublic = m, cfg.Public
+
+	return
+}
@@ -0,0 +1,51 @@
+package component
+
+import (
+	"fmt"
+	"time"
+
+	"go-server/common"
+	"go-server/library/clean"
+	"go-server/library/log"
+	"go-server/library/outbox"
+)
+
+var OutboxRelay *outbox.Relay
+
+// OutboxConfig 发件箱投递配置, OUTBOX_ENABLED为true时启用, 启用前需确保已执行t_outbox的迁移,
+// OUTBOX_POLL_INTERVAL和OUTBOX_LEASE单位为毫秒
+type OutboxConfig struct {
+	Enabled        bool `env:"OUTBOX_ENABLED,omitempty"`
+	BatchSize      int  `env:"OUTBOX_BATCH_SIZE,omitempty"`
+	PollIntervalMS int  `env:"OUTBOX_POLL_INTERVAL,omitempty"`
+	LeaseMS        int  `env:"OUTBOX_LEASE,omitempty"`
+	MaxAttempts    int  `env:"OUTBOX_MAX_ATTEMPTS,omitempty"`
+}
+
+// SetupOutboxRelay 启动发件箱投递, 需在SetupDB和SetupProducer之后调用, 未启用时OutboxRelay为nil
+func SetupOutboxRelay() (err error) {
+	cfg := &OutboxConfig{}
+	if err = Conf.Scan(cfg, "env"); err != nil {
+		err = fmt.Errorf("Conf.Scan: %w", err)
+		return
+	}
+
+	if !cfg.Enabled {
+		return
+	}
+
+	OutboxRelay = outbox.NewRelay(DBContainer, ProducerContainer, &outbox.RelayOptions{
+		BatchSize:     cfg.BatchSize,
+		PollInterval:  time.Duration(cfg.PollIntervalMS) * time.Millisecond,
+		LeaseDuration: time.Duration(cfg.LeaseMS) * time.Millisecond,
+		MaxAttempts:   cfg.MaxAttempts,
+		HandleErr: func(err error) {
+			ErrLogger.Error(log.F{"log_type": common.LogTypeForOutbox}, err)
+		},
+	})
+	OutboxRelay.Start()
+
+	clean.Push(OutboxRelay)
+
+	return
+}
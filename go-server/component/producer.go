@@ -0,0 +1,42 @@
+package component
+
+import (
+	"fmt"
+
+	"go-server/library/clean"
+	"go-server/library/kafka"
+)
+
+var ProducerContainer *kafka.SyncProducerContainer
+
+type ProducerConfig struct {
+	Brokers string `env:"KAFKA_BROKERS"`
+}
+
+func SetupProducer() (err error) {
+	ProducerContainer, err = kafka.NewSyncProducerContainer(getProducerConf)
+	if err != nil {
+		err = fmt.Errorf("kafka.NewSyncProducerContainer: %w", err)
+		return
+	}
+
+	clean.Push(ProducerContainer)
+	Conf.PushUpdater(ProducerContainer)
+
+	return
+}
+
+func getProducerConf() (cf *kafka.ProducerConf, err error) {
+	cfg := &ProducerConfig{}
+
+	if err = Conf.Scan(cfg, "env"); err != nil {
+		err = fmt.Errorf("Conf.Scan: %w", err)
+		return
+	}
+
+	cf = &kafka.ProducerConf{
+		Brokers: cfg.Brokers,
+	}
+
+	return
+}
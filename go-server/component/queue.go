@@ -0,0 +1,40 @@
+package component
+
+import (
+	"fmt"
+
+	"go-server/common"
+	"go-server/library/clean"
+	"go-server/library/log"
+	"go-server/library/redis"
+)
+
+// StartQueue 在CacheContainer上创建名称为name的任务队列并以handler开始消费,
+// 消费过程中的redis错误写入错误日志, 队列注册到clean中, 进程退出时等待处理中的任务完成,
+// 需在SetupCache之后调用, opts为nil时使用默认选项
+func StartQueue(name string, opts *redis.QueueOptions, handler redis.JobHandler) (q *redis.Queue, err error) {
+	qopts := redis.QueueOptions{}
+	if opts != nil {
+		qopts = *opts
+	}
+	if qopts.HandleErr == nil {
+		qopts.HandleErr = func(err error) {
+			ErrLogger.Error(log.F{"log_type": common.LogTypeForQueue, "queue": name}, err)
+		}
+	}
+
+	q, err = CacheContainer.NewQueue(name, &qopts)
+	if err != nil {
+		err = fmt.Errorf("CacheContainer.NewQueue(%s): %w", name, err)
+		return
+	}
+
+	if err = q.Start(handler); err != nil {
+		err = fmt.Errorf("queue.Start(%s): %w", name, err)
+		return
+	}
+
+	clean.Push(q)
+
+	return
+}
@@ -0,0 +1,114 @@
+package component
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go-server/common"
+	"go-server/library/log"
+	"go-server/library/redis"
+)
+
+const defaultRateLimitPeriodSecond = 60
+
+var (
+	RateLimiter *redis.Limiter
+	RateLimits  = &RateLimitRules{}
+)
+
+// RateLimitConfig 接口限流配置, 各维度的请求数为0时不限流,
+// RATE_LIMIT_ALGORITHM可选sliding_window, token_bucket, 为空时为sliding_window, RATE_LIMIT_PERIOD单位为秒, 默认60
+type RateLimitConfig struct {
+	Algorithm    string `env:"RATE_LIMIT_ALGORITHM,omitempty"`
+	PeriodSecond int    `env:"RATE_LIMIT_PERIOD,omitempty"`
+	Burst        int    `env:"RATE_LIMIT_BURST,omitempty"`
+	UserRate     int    `env:"RATE_LIMIT_USER_RATE,omitempty"`
+	IPRate       int    `env:"RATE_LIMIT_IP_RATE,omitempty"`
+	RouteRate    int    `env:"RATE_LIMIT_ROUTE_RATE,omitempty"`
+}
+
+// RateLimitRules 按用户, IP和路由三个维度的限流规则, 配置重载时更新
+type RateLimitRules struct {
+	mu    sync.RWMutex
+	user  redis.Limit
+	ip    redis.Limit
+	route redis.Limit
+}
+
+func SetupRateLimiter() (err error) {
+	if err = RateLimits.Update(); err != nil {
+		err = fmt.Errorf("RateLimits.Update: %w", err)
+		return
+	}
+
+	RateLimiter = CacheContainer.NewLimiter("ratelimit", func(err error) {
+		ErrLogger.Error(log.F{"log_type": common.LogTypeForRateLimit}, err)
+	})
+
+	Conf.PushUpdater(RateLimits)
+
+	return
+}
+
+// Update 从配置中重新读取限流规则
+func (rs *RateLimitRules) Update() (err error) {
+	cfg := &RateLimitConfig{}
+	if err = Conf.Scan(cfg, "env"); err != nil {
+		err = fmt.Errorf("Conf.Scan: %w", err)
+		return
+	}
+
+	if cfg.PeriodSecond <= 0 {
+		cfg.PeriodSecond = defaultRateLimitPeriodSecond
+	}
+
+	switch cfg.Algorithm {
+	case "", redis.LimitSlidingWindow, redis.LimitTokenBucket:
+	default:
+		err = fmt.Errorf("unsupported rate limit algorithm %q", cfg.Algorithm)
+		return
+	}
+
+	newLimit := func(rate int) redis.Limit {
+		return redis.Limit{
+			Algorithm: cfg.Algorithm,
+			Rate:      rate,
+			Period:    time.Duration(cfg.PeriodSecond) * time.Second,
+			Burst:     cfg.Burst,
+		}
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.user = newLimit(cfg.UserRate)
+	rs.ip = newLimit(cfg.IPRate)
+	rs.route = newLimit(cfg.RouteRate)
+
+	return
+}
+
+// User 按用户限流的规则
+func (rs *RateLimitRules) User() redis.Limit {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	return rs.user
+}
+
+// IP 按客户端IP限流的规则
+func (rs *RateLimitRules) IP() redis.Limit {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	return rs.ip
+}
+
+// Route 按路由限流的规则
+func (rs *RateLimitRules) Route() redis.Limit {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	return rs.route
+}
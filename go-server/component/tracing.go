@@ -0,0 +1,55 @@
+package component
+
+import (
+	"fmt"
+
+	"go-server/library/clean"
+	"go-server/library/trace"
+	"go-server/library/trace/tracesdk"
+)
+
+const defaultTraceSamplePercent = 100
+
+var TraceProvider *tracesdk.Provider
+
+// TracingConfig 链路追踪配置, TRACE_EXPORTER为导出器类型, 可选none, stdout, file, otlphttp, 为空或none时不导出span, 日志和向下游传递的链路信息仍包含链路ID,
+// TRACE_FILE为file导出器写入的OTLP JSON文件, TRACE_OTLP_ENDPOINT和TRACE_OTLP_INSECURE为otlphttp导出器的采集器地址和是否使用HTTP,
+// TRACE_SAMPLE_PERCENT为链路起点的采样百分比, 默认100, TRACE_SERVICE_NAME为服务名称, 为空时使用go-server
+type TracingConfig struct {
+	Exporter      string `env:"TRACE_EXPORTER,omitempty"`
+	File          string `env:"TRACE_FILE,omitempty"`
+	OTLPEndpoint  string `env:"TRACE_OTLP_ENDPOINT,omitempty"`
+	OTLPInsecure  bool   `env:"TRACE_OTLP_INSECURE,omitempty"`
+	SamplePercent int    `env:"TRACE_SAMPLE_PERCENT,omitempty"`
+	ServiceName   string `env:"TRACE_SERVICE_NAME,omitempty"`
+}
+
+// SetupTracing 按配置创建TracerProvider, 需在其他组件之前调用, 以便关闭时在其他组件之后导出剩余的span
+func SetupTracing() (err error) {
+	cfg := &TracingConfig{
+		SamplePercent: defaultTraceSamplePercent,
+		ServiceName:   trace.TracerName,
+	}
+	if err = Conf.Scan(cfg, "env"); err != nil {
+		err = fmt.Errorf("Conf.Scan: %w", err)
+		return
+	}
+
+	provider, err := tracesdk.NewProvider(&tracesdk.ProviderConf{
+		ServiceName: cfg.ServiceName,
+		Exporter:    cfg.Exporter,
+		File:        cfg.File,
+		Endpoint:    cfg.OTLPEndpoint,
+		Insecure:    cfg.OTLPInsecure,
+		SampleRatio: float64(cfg.SamplePercent) / 100,
+	})
+	if err != nil {
+		err = fmt.Errorf("tracesdk.NewProvider: %w", err)
+		return
+	}
+
+	TraceProvider = provider
+	clean.Push(TraceProvider)
+
+	return
+}
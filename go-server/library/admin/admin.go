@@ -0,0 +1,286 @@
+// admin 包提供运维接口, 包括pprof性能分析, 脱敏后的配置内容, 日志级别查看与修改, 容器状态和运行时统计,
+// 运维接口应只在本机或内网端口上提供服务
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"go-server/library/conf"
+)
+
+// Redacted 脱敏后的配置值
+const Redacted = "******"
+
+// DefaultSensitiveKeywords 配置项名称包含这些关键字(不区分大小写)时脱敏
+var DefaultSensitiveKeywords = []string{"PASSWORD", "PASSWD", "SECRET", "TOKEN", "CREDENTIAL", "PRIVATE", "DSN", "_KEY"}
+
+// LevelLogger 可查看和修改日志级别的日志工具, *log.LoggerContainer实现了该接口
+type LevelLogger interface {
+	Level() string
+	SetLevel(level string) error
+}
+
+// StatusContainer 可查看运行状态的容器, 所有基于conf.Container的容器都实现了该接口
+type StatusContainer interface {
+	Status() conf.ContainerStatus
+}
+
+// Handler 运维接口, 提供以下接口:
+//
+//	/debug/pprof/  pprof性能分析
+//	/config        GET 脱敏后的配置内容
+//	/loglevel      GET 各日志工具的级别, PUT或POST ?name=xxx&level=debug 修改日志级别
+//	/containers    GET 各容器的代数和健康状态, 有不健康的容器时状态码为503
+//	/runtime       GET 协程数, 内存和GC统计
+type Handler struct {
+	mux       *http.ServeMux
+	startTime time.Time
+
+	mu         sync.RWMutex
+	items      func() (map[string]string, error)
+	sensitive  []string
+	loggers    map[string]LevelLogger
+	containers map[string]StatusContainer
+}
+
+// NewHandler 创建运维接口
+func NewHandler() *Handler {
+	h := &Handler{
+		mux:        http.NewServeMux(),
+		startTime:  time.Now(),
+		sensitive:  DefaultSensitiveKeywords,
+		loggers:    make(map[string]LevelLogger),
+		containers: make(map[string]StatusContainer),
+	}
+
+	h.mux.HandleFunc("/debug/pprof/", pprof.Index)
+	h.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	h.mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	h.mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	h.mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	h.mux.HandleFunc("/config", h.serveConfig)
+	h.mux.HandleFunc("/loglevel", h.serveLogLevel)
+	h.mux.HandleFunc("/containers", h.serveContainers)
+	h.mux.HandleFunc("/runtime", h.serveRuntime)
+
+	return h
+}
+
+// ServeHTTP 实现http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// Handle 注册额外的运维接口
+func (h *Handler) Handle(pattern string, handler http.Handler) {
+	h.mux.Handle(pattern, handler)
+}
+
+// SetConfig 设置配置内容来源, 通常为conf.Conf.Items, keywords非空时代替DefaultSensitiveKeywords作为脱敏关键字
+func (h *Handler) SetConfig(items func() (map[string]string, error), keywords ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.items = items
+	if len(keywords) > 0 {
+		h.sensitive = keywords
+	}
+}
+
+// RegisterLogger 以name注册日志工具
+func (h *Handler) RegisterLogger(name string, logger LevelLogger) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.loggers[name] = logger
+}
+
+// RegisterContainer 以name注册容器
+func (h *Handler) RegisterContainer(name string, ct StatusContainer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.containers[name] = ct
+}
+
+func (h *Handler) serveConfig(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	h.mu.RLock()
+	items, sensitive := h.items, h.sensitive
+	h.mu.RUnlock()
+
+	if items == nil {
+		writeError(w, http.StatusNotFound, "config source not set")
+		return
+	}
+
+	values, err := items()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Redact(values, sensitive))
+}
+
+// Redact 返回items的副本, 名称包含keywords中任一关键字(不区分大小写)的非空配置值替换为Redacted
+func Redact(items map[string]string, keywords []string) map[string]string {
+	redacted := make(map[string]string, len(items))
+	for k, v := range items {
+		redacted[k] = v
+		if v == "" {
+			continue
+		}
+
+		upper := strings.ToUpper(k)
+		for _, kw := range keywords {
+			if strings.Contains(upper, strings.ToUpper(kw)) {
+				redacted[k] = Redacted
+				break
+			}
+		}
+	}
+
+	return redacted
+}
+
+func (h *Handler) serveLogLevel(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPut, http.MethodPost) {
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if r.Method != http.MethodGet {
+		name, level := r.FormValue("name"), r.FormValue("level")
+		logger, ok := h.loggers[name]
+		if !ok {
+			writeError(w, http.StatusNotFound, "logger not found: "+name)
+			return
+		}
+
+		if err := logger.SetLevel(level); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	levels := make(map[string]string, len(h.loggers))
+	for name, logger := range h.loggers {
+		levels[name] = logger.Level()
+	}
+
+	writeJSON(w, http.StatusOK, levels)
+}
+
+type containerStatus struct {
+	conf.ContainerStatus
+	Healthy bool `json:"healthy"`
+}
+
+func (h *Handler) serveContainers(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	status := http.StatusOK
+	statuses := make(map[string]containerStatus, len(h.containers))
+	for name, ct := range h.containers {
+		st := containerStatus{ContainerStatus: ct.Status()}
+		if st.Healthy = st.ContainerStatus.Healthy(); !st.Healthy {
+			status = http.StatusServiceUnavailable
+		}
+		statuses[name] = st
+	}
+
+	writeJSON(w, status, statuses)
+}
+
+// RuntimeStats 运行时统计
+type RuntimeStats struct {
+	GoVersion     string    `json:"go_version"`
+	StartTime     time.Time `json:"start_time"`
+	UptimeSecond  int64     `json:"uptime_second"`
+	NumCPU        int       `json:"num_cpu"`
+	GOMAXPROCS    int       `json:"gomaxprocs"`
+	NumGoroutine  int       `json:"num_goroutine"`
+	HeapAlloc     uint64    `json:"heap_alloc"`     // 堆上已分配且未释放的字节数
+	HeapInuse     uint64    `json:"heap_inuse"`     // 堆上使用中的span字节数
+	HeapObjects   uint64    `json:"heap_objects"`   // 堆上的对象数
+	Sys           uint64    `json:"sys"`            // 从系统获取的内存字节数
+	NumGC         uint32    `json:"num_gc"`         // 已完成的GC次数
+	LastGC        time.Time `json:"last_gc"`        // 最近一次GC完成时间
+	LastPauseNs   uint64    `json:"last_pause_ns"`  // 最近一次GC的暂停时长
+	PauseTotalNs  uint64    `json:"pause_total_ns"` // GC暂停总时长
+	GCCPUFraction float64   `json:"gc_cpu_fraction"`
+}
+
+func (h *Handler) serveRuntime(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	stats := RuntimeStats{
+		GoVersion:     runtime.Version(),
+		StartTime:     h.startTime,
+		UptimeSecond:  int64(time.Since(h.startTime) / time.Second),
+		NumCPU:        runtime.NumCPU(),
+		GOMAXPROCS:    runtime.GOMAXPROCS(0),
+		NumGoroutine:  runtime.NumGoroutine(),
+		HeapAlloc:     ms.HeapAlloc,
+		HeapInuse:     ms.HeapInuse,
+		HeapObjects:   ms.HeapObjects,
+		Sys:           ms.Sys,
+		NumGC:         ms.NumGC,
+		PauseTotalNs:  ms.PauseTotalNs,
+		GCCPUFraction: ms.GCCPUFraction,
+	}
+	if ms.NumGC > 0 {
+		stats.LastGC = time.Unix(0, int64(ms.LastGC))
+		stats.LastPauseNs = ms.PauseNs[(ms.NumGC+255)%256]
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,199 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go-server/library/conf"
+)
+
+// levelLogger 只接受debug, info, warn, error级别的日志工具
+type levelLogger struct {
+	level string
+}
+
+func (l *levelLogger) Level() string {
+	return l.level
+}
+
+func (l *levelLogger) SetLevel(level string) error {
+	switch level {
+	case "debug", "info", "warn", "error":
+		l.level = level
+		return nil
+	}
+
+	return errors.New("unknown level " + level)
+}
+
+// statusContainer 返回固定运行状态的容器
+type statusContainer struct {
+	status conf.ContainerStatus
+}
+
+func (c *statusContainer) Status() conf.ContainerStatus {
+	return c.status
+}
+
+func serve(h http.Handler, method, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+func TestRedact(t *testing.T) {
+	items := map[string]string{
+		"DB_PASSWORD":    "p",
+		"db_dsn":         "user:p@tcp(db)/app",
+		"API_KEY":        "k",
+		"KEYSPACE":       "ks",
+		"JWT_SECRET":     "",
+		"REDIS_HOST":     "127.0.0.1",
+		"OAUTH_TOKEN_ID": "t",
+	}
+
+	assert.Equal(t, map[string]string{
+		"DB_PASSWORD":    Redacted,
+		"db_dsn":         Redacted,
+		"API_KEY":        Redacted,
+		"KEYSPACE":       "ks",
+		"JWT_SECRET":     "",
+		"REDIS_HOST":     "127.0.0.1",
+		"OAUTH_TOKEN_ID": Redacted,
+	}, Redact(items, DefaultSensitiveKeywords))
+
+	// 返回副本, 不修改原配置
+	assert.Equal(t, "p", items["DB_PASSWORD"])
+	assert.Equal(t, map[string]string{"REDIS_HOST": Redacted, "DB_PASSWORD": "p"},
+		Redact(map[string]string{"REDIS_HOST": "127.0.0.1", "DB_PASSWORD": "p"}, []string{"host"}))
+}
+
+func TestConfig(t *testing.T) {
+	h := NewHandler()
+	assert.Equal(t, http.StatusNotFound, serve(h, http.MethodGet, "/config").Code)
+
+	h.SetConfig(func() (map[string]string, error) {
+		return map[string]string{"DB_PASSWORD": "p", "APP_NAME": "go-server"}, nil
+	})
+	w := serve(h, http.MethodGet, "/config")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"DB_PASSWORD":"******","APP_NAME":"go-server"}`, w.Body.String())
+
+	h.SetConfig(func() (map[string]string, error) { return nil, conf.ErrContentNotLoaded })
+	assert.Equal(t, http.StatusInternalServerError, serve(h, http.MethodGet, "/config").Code)
+}
+
+func TestLogLevel(t *testing.T) {
+	h := NewHandler()
+	h.RegisterLogger("info", &levelLogger{level: "info"})
+	h.RegisterLogger("error", &levelLogger{level: "error"})
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		status int
+		want   string // 响应体中应包含的内容
+	}{
+		{name: "get", method: http.MethodGet, target: "/loglevel", status: 200, want: `"info": "info"`},
+		{name: "put", method: http.MethodPut, target: "/loglevel?name=info&level=debug", status: 200, want: `"info": "debug"`},
+		{name: "post", method: http.MethodPost, target: "/loglevel?name=error&level=warn", status: 200, want: `"error": "warn"`},
+		{name: "unknown logger", method: http.MethodPut, target: "/loglevel?name=access&level=debug", status: 404, want: "logger not found: access"},
+		{name: "bad level", method: http.MethodPut, target: "/loglevel?name=info&level=verbose", status: 400, want: "unknown level verbose"},
+		{name: "method not allowed", method: http.MethodDelete, target: "/loglevel", status: 405, want: "method not allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(h, tt.method, tt.target)
+			assert.Equal(t, tt.status, w.Code)
+			assert.Contains(t, w.Body.String(), tt.want)
+		})
+	}
+
+	// 修改失败的请求不影响已设置的级别
+	w := serve(h, http.MethodGet, "/loglevel")
+	assert.JSONEq(t, `{"info":"debug","error":"warn"}`, w.Body.String())
+}
+
+func TestContainers(t *testing.T) {
+	tests := []struct {
+		name       string
+		containers map[string]conf.ContainerStatus
+		status     int
+	}{
+		{name: "healthy", containers: map[string]conf.ContainerStatus{"db": {Generation: 1}, "redis": {Generation: 2, Resets: 1}}, status: 200},
+		{name: "update failed", containers: map[string]conf.ContainerStatus{"db": {Generation: 1}, "redis": {Generation: 1, LastError: "dial tcp: refused"}}, status: 503},
+		{name: "closed", containers: map[string]conf.ContainerStatus{"db": {Generation: 1, Closed: true}}, status: 503},
+		{name: "none", status: 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler()
+			for name, st := range tt.containers {
+				h.RegisterContainer(name, &statusContainer{status: st})
+			}
+
+			w := serve(h, http.MethodGet, "/containers")
+			assert.Equal(t, tt.status, w.Code)
+
+			var got map[string]struct {
+				conf.ContainerStatus
+				Healthy bool `json:"healthy"`
+			}
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &got))
+			assert.Len(t, got, len(tt.containers))
+			for name, st := range tt.containers {
+				assert.Equal(t, st.Healthy(), got[name].Healthy, name)
+				assert.Equal(t, st.Generation, got[name].Generation, name)
+				assert.Equal(t, st.LastError, got[name].LastError, name)
+			}
+		})
+	}
+}
+
+func TestMethods(t *testing.T) {
+	h := NewHandler()
+	h.SetConfig(func() (map[string]string, error) { return map[string]string{}, nil })
+	h.Handle("/extra", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		method string
+		target string
+		status int
+		allow  string
+	}{
+		{method: http.MethodGet, target: "/runtime", status: 200},
+		{method: http.MethodPost, target: "/runtime", status: 405, allow: "GET"},
+		{method: http.MethodPut, target: "/config", status: 405, allow: "GET"},
+		{method: http.MethodPost, target: "/containers", status: 405, allow: "GET"},
+		{method: http.MethodPatch, target: "/loglevel", status: 405, allow: "GET, PUT, POST"},
+		{method: http.MethodGet, target: "/extra", status: 204},
+		{method: http.MethodGet, target: "/debug/pprof/", status: 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			w := serve(h, tt.method, tt.target)
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.allow, w.Header().Get("Allow"))
+			if tt.status == http.StatusMethodNotAllowed {
+				assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "application/json"))
+			}
+		})
+	}
+
+	var stats RuntimeStats
+	assert.Nil(t, json.Unmarshal(serve(h, http.MethodGet, "/runtime").Body.Bytes(), &stats))
+	assert.Greater(t, stats.NumGoroutine, 0)
+	assert.NotEmpty(t, stats.GoVersion)
+}
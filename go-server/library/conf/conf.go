@@ -32,6 +32,7 @@ type Conf struct {
 	afterLoaded       func(map[string]string) // 勾子函数, 在配置重载后调用, 可以改变配置值
 	beforeUpdateHooks []func()                // 勾子函数, 在配置重载updaters刷新前调用
 	afterUpdateHooks  []func()                // 勾子函数, 在配置重载updaters刷新后调用
+	reloadHooks       []func(error)           // 勾子函数, 在每次配置重载完成后以重载结果调用
 	herr              func(error)             // 配置监听错误处理回调
 	exit              chan struct{}           // 监听退出信道
 	loaded            bool                    // 配置内容加载状态
@@ -121,6 +122,24 @@ func (cf *Conf) Get(key string) (val string, ok bool, err error) {
 	return
 }
 
+// Items 返回Conf实例所有配置内容的副本, 实例未调用Load或Load失败时将返回内容未加载错误
+func (cf *Conf) Items() (items map[string]string, err error) {
+	cf.mutex.RLock()
+	defer cf.mutex.RUnlock()
+
+	if !cf.loaded {
+		err = ErrContentNotLoaded
+		return
+	}
+
+	items = make(map[string]string, len(cf.items))
+	for k, v := range cf.items {
+		items[k] = v
+	}
+
+	return
+}
+
 // MustGet 从配置中获取指定key的值, 如果值不存在或为空将导致panic
 func (cf *Conf) MustGet(key string) (val string) {
 	cf.mutex.RLock()
@@ -195,6 +214,15 @@ func (cf *Conf) RegisterAfterUpdateHook(hook func()) {
 	cf.afterUpdateHooks = append(cf.afterUpdateHooks, hook)
 }
 
+// RegisterReloadHook 注册配置重载结果勾子函数, 每次监听到配置文件变化并完成重载后调用,
+// 配置加载或任一updater更新失败时err为第一个错误, 否则为nil, 可用于统计重载成功与失败次数
+func (cf *Conf) RegisterReloadHook(hook func(err error)) {
+	cf.mutex.Lock()
+	defer cf.mutex.Unlock()
+
+	cf.reloadHooks = append(cf.reloadHooks, hook)
+}
+
 // SetWatchErrHandleFunc 为Conf实例注册监听错误处理函数, 用于处理监听过程中发生的所有错误
 func (cf *Conf) SetWatchErrHandleFunc(f func(error)) {
 	cf.mutex.Lock()
@@ -270,9 +298,12 @@ func watch(cf *Conf, wc *fsnotify.Watcher) {
 			if ev.Op&fsnotify.Write != fsnotify.Write && ev.Op&fsnotify.Create != fsnotify.Create {
 				continue
 			}
-			if err := cf.Load(); err != nil && cf.herr != nil {
+			if err := cf.Load(); err != nil {
 				err = fmt.Errorf("load config: %w", err)
-				cf.herr(err)
+				cf.reloaded(err)
+				if cf.herr != nil {
+					cf.herr(err)
+				}
 				break
 			}
 
@@ -284,10 +315,16 @@ func watch(cf *Conf, wc *fsnotify.Watcher) {
 					hook()
 				}
 
+				var uerr error
 				for _, updater := range cf.updaters {
-					if err := updater.Update(); err != nil && cf.herr != nil {
+					if err := updater.Update(); err != nil {
 						err = fmt.Errorf("update: %w", err)
-						cf.herr(err)
+						if uerr == nil {
+							uerr = err
+						}
+						if cf.herr != nil {
+							cf.herr(err)
+						}
 					}
 				}
 
@@ -295,6 +332,9 @@ func watch(cf *Conf, wc *fsnotify.Watcher) {
 					hook()
 				}
 
+				for _, hook := range cf.reloadHooks {
+					hook(uerr)
+				}
 			}()
 		case err := <-wc.Errors:
 			if cf.herr != nil {
@@ -310,3 +350,13 @@ func watch(cf *Conf, wc *fsnotify.Watcher) {
 		}
 	}
 }
+
+// reloaded 以配置加载失败的错误调用重载结果勾子函数
+func (cf *Conf) reloaded(err error) {
+	cf.mutex.RLock()
+	defer cf.mutex.RUnlock()
+
+	for _, hook := range cf.reloadHooks {
+		hook(err)
+	}
+}
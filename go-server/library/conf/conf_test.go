@@ -0,0 +1,129 @@
+package conf
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeEnv(t *testing.T, file, content string) {
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+}
+
+func TestItems(t *testing.T) {
+	file := filepath.Join(t.TempDir(), ".env")
+	writeEnv(t, file, "A = 1\nB = two\n")
+
+	cf, err := NewConf(file)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	_, err = cf.Items()
+	assert.True(t, errors.Is(err, ErrContentNotLoaded))
+
+	assert.Nil(t, cf.Load())
+	items, err := cf.Items()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"A": "1", "B": "two"}, items)
+
+	// 返回副本, 修改不影响配置内容
+	items["A"] = "2"
+	val, _, err := cf.Get("A")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", val)
+}
+
+// failUpdater fail为true时更新失败
+type failUpdater struct {
+	mu   sync.Mutex
+	fail bool
+}
+
+func (u *failUpdater) setFail(fail bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.fail = fail
+}
+
+func (u *failUpdater) Update() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.fail {
+		return errors.New("dial tcp: refused")
+	}
+	return nil
+}
+
+func TestReloadHook(t *testing.T) {
+	file := filepath.Join(t.TempDir(), ".env")
+	writeEnv(t, file, "A = 1\n")
+
+	cf, err := NewConf(file)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Nil(t, cf.Load())
+
+	results := make(chan error, 16)
+	u := &failUpdater{}
+	cf.PushUpdater(u)
+	cf.RegisterReloadHook(func(err error) { results <- err })
+	assert.Nil(t, cf.Watch())
+	defer cf.Close()
+
+	next := func() error {
+		select {
+		case err := <-results:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("reload hook not called")
+			return nil
+		}
+	}
+	// drain 丢弃同一次写入触发的多次重载结果
+	drain := func() {
+		for {
+			select {
+			case <-results:
+			case <-time.After(100 * time.Millisecond):
+				return
+			}
+		}
+	}
+
+	// 重载成功时以nil调用, 新配置可见
+	writeEnv(t, file, "A = 2\n")
+	assert.Nil(t, next())
+	drain()
+	val, _, _ := cf.Get("A")
+	assert.Equal(t, "2", val)
+
+	// updater更新失败时以该错误调用
+	u.setFail(true)
+	writeEnv(t, file, "A = 3\n")
+	err = next()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "refused")
+	drain()
+
+	// 配置文件无法读取时以加载错误调用, 保留已加载的配置
+	u.setFail(false)
+	assert.Nil(t, os.Remove(file))
+	assert.Nil(t, os.Mkdir(file, 0755))
+	err = next()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "load config")
+	val, _, _ = cf.Get("A")
+	assert.Equal(t, "3", val)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"time"
 )
 
 // CompareObjConfRst 定义对比对象配置结果类型
@@ -81,6 +82,32 @@ type Container struct {
 
 	// 关闭状态
 	closed bool
+
+	// 对象代数, 创建初始对象时为1, 每次替换对象加1
+	generation uint64
+
+	// 对象重置次数
+	resets uint64
+
+	// 最近一次创建, 替换或重置对象的时间
+	updatedAt time.Time
+
+	// 最近一次Update的错误, Update成功后清空
+	lastErr error
+}
+
+// ContainerStatus Container的运行状态
+type ContainerStatus struct {
+	Generation uint64    `json:"generation"`           // 对象代数, 创建初始对象时为1, 每次替换对象加1
+	Resets     uint64    `json:"resets"`               // 对象重置次数
+	UpdatedAt  time.Time `json:"updated_at"`           // 最近一次创建, 替换或重置对象的时间
+	LastError  string    `json:"last_error,omitempty"` // 最近一次Update的错误, Update成功后清空
+	Closed     bool      `json:"closed"`               // 是否已关闭
+}
+
+// Healthy 未关闭且最近一次Update成功时返回true
+func (s ContainerStatus) Healthy() bool {
+	return !s.Closed && s.LastError == ""
 }
 
 // NewContainer 根据指定要素创建&初始化Container实例, 并返回实例指针,
@@ -129,6 +156,25 @@ func (ct *Container) init() (err error) {
 	}
 
 	ct.objmus[ct.obj] = &sync.RWMutex{}
+	ct.generation, ct.updatedAt = 1, time.Now()
+
+	return
+}
+
+// Status 返回Container的运行状态
+func (ct *Container) Status() (status ContainerStatus) {
+	ct.mu.RLock()
+	defer ct.mu.RUnlock()
+
+	status = ContainerStatus{
+		Generation: ct.generation,
+		Resets:     ct.resets,
+		UpdatedAt:  ct.updatedAt,
+		Closed:     ct.closed,
+	}
+	if ct.lastErr != nil {
+		status.LastError = ct.lastErr.Error()
+	}
 
 	return
 }
@@ -174,6 +220,10 @@ func (ct *Container) Update() (err error) {
 		return
 	}
 
+	defer func() {
+		ct.lastErr = err
+	}()
+
 	if ct.compareObjConf == nil {
 		err = ErrCompareObjConfFuncIsNil
 		return
@@ -204,6 +254,8 @@ func (ct *Container) Update() (err error) {
 		}
 
 		ct.conf = nconf
+		ct.resets++
+		ct.updatedAt = time.Now()
 		return
 
 	case CompareObjConfRstNeedReplace:
@@ -227,6 +279,8 @@ func (ct *Container) Update() (err error) {
 		ct.conf = nconf
 		ct.obj = nobj
 		ct.objmus[ct.obj] = &sync.RWMutex{}
+		ct.generation++
+		ct.updatedAt = time.Now()
 
 	case CompareObjConfRstNoNeed:
 		return
@@ -0,0 +1,203 @@
+package conf
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testObj 记录是否已关闭的对象
+type testObj struct {
+	conf   testConf
+	closed int32
+}
+
+func (o *testObj) Close() error {
+	atomic.StoreInt32(&o.closed, 1)
+	return nil
+}
+
+func (o *testObj) isClosed() bool {
+	return atomic.LoadInt32(&o.closed) == 1
+}
+
+// testConf addr变化时替换对象, level变化时重置对象
+type testConf struct {
+	addr  string
+	level string
+}
+
+// testSource 可修改的配置来源, err非nil时获取配置失败, newErr非nil时创建对象失败
+type testSource struct {
+	mu       sync.Mutex
+	conf     testConf
+	err      error
+	newErr   error
+	resetErr error
+}
+
+func (s *testSource) set(f func(s *testSource)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f(s)
+}
+
+func newTestContainer(t *testing.T, src *testSource) *Container {
+	ct, err := NewContainer(
+		func() (IConf, error) {
+			src.mu.Lock()
+			defer src.mu.Unlock()
+			return src.conf, src.err
+		},
+		func(oldConf, newConf IConf) (CompareObjConfRst, error) {
+			o, n := oldConf.(testConf), newConf.(testConf)
+			switch {
+			case o.addr != n.addr:
+				return CompareObjConfRstNeedReplace, nil
+			case o.level != n.level:
+				return CompareObjConfRstNeedReset, nil
+			}
+			return CompareObjConfRstNoNeed, nil
+		},
+		func(conf IConf) (IObject, error) {
+			src.mu.Lock()
+			defer src.mu.Unlock()
+			if src.newErr != nil {
+				return nil, src.newErr
+			}
+			return &testObj{conf: conf.(testConf)}, nil
+		},
+		func(obj IObject, oldConf, newConf IConf) error {
+			src.mu.Lock()
+			defer src.mu.Unlock()
+			if src.resetErr != nil {
+				return src.resetErr
+			}
+			obj.(*testObj).conf = newConf.(testConf)
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("NewContainer: %v", err)
+	}
+
+	return ct
+}
+
+func currentObj(ct *Container) *testObj {
+	obj := ct.MustGetObj()
+	defer ct.PutObj(obj)
+
+	return obj.(*testObj)
+}
+
+func TestContainerUpdate(t *testing.T) {
+	src := &testSource{conf: testConf{addr: "a", level: "info"}}
+	ct := newTestContainer(t, src)
+
+	st := ct.Status()
+	assert.Equal(t, uint64(1), st.Generation)
+	assert.Equal(t, uint64(0), st.Resets)
+	assert.True(t, st.Healthy())
+	created := st.UpdatedAt
+	first := currentObj(ct)
+
+	// 配置不变时不更新
+	assert.Nil(t, ct.Update())
+	assert.Equal(t, st, ct.Status())
+
+	// 重置时对象不变, 重置次数加1
+	src.set(func(s *testSource) { s.conf.level = "debug" })
+	assert.Nil(t, ct.Update())
+	st = ct.Status()
+	assert.Equal(t, uint64(1), st.Generation)
+	assert.Equal(t, uint64(1), st.Resets)
+	assert.False(t, st.UpdatedAt.Before(created))
+	assert.Same(t, first, currentObj(ct))
+	assert.Equal(t, "debug", first.conf.level)
+
+	// 替换时代数加1, 旧对象异步关闭
+	src.set(func(s *testSource) { s.conf.addr = "b" })
+	assert.Nil(t, ct.Update())
+	st = ct.Status()
+	assert.Equal(t, uint64(2), st.Generation)
+	assert.Equal(t, uint64(1), st.Resets)
+	second := currentObj(ct)
+	assert.NotSame(t, first, second)
+	assert.Equal(t, "b", second.conf.addr)
+	assert.Eventually(t, first.isClosed, time.Second, 10*time.Millisecond)
+	assert.False(t, second.isClosed())
+
+	assert.Nil(t, ct.Close())
+	assert.True(t, second.isClosed())
+	assert.True(t, ct.Status().Closed)
+	assert.False(t, ct.Status().Healthy())
+	assert.True(t, errors.Is(ct.Update(), ErrContainerClosed))
+	assert.True(t, errors.Is(ct.Close(), ErrContainerClosed))
+}
+
+func TestContainerUpdateFailed(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(s *testSource)
+		replace bool // 恢复后的更新是替换还是重置
+	}{
+		{name: "get conf", change: func(s *testSource) { s.conf.addr, s.err = "b", errors.New("scan") }, replace: true},
+		{name: "new object", change: func(s *testSource) { s.conf.addr, s.newErr = "b", errors.New("dial") }, replace: true},
+		{name: "reset object", change: func(s *testSource) { s.conf.level, s.resetErr = "debug", errors.New("reset") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := &testSource{conf: testConf{addr: "a", level: "info"}}
+			ct := newTestContainer(t, src)
+			defer ct.Close()
+			first := currentObj(ct)
+
+			// 更新失败时保留当前对象, 记录错误
+			src.set(tt.change)
+			assert.NotNil(t, ct.Update())
+			st := ct.Status()
+			assert.False(t, st.Healthy())
+			assert.NotEmpty(t, st.LastError)
+			assert.Equal(t, uint64(1), st.Generation)
+			assert.Equal(t, uint64(0), st.Resets)
+			assert.Same(t, first, currentObj(ct))
+			assert.False(t, first.isClosed())
+
+			// 下一次更新成功后清空错误
+			src.set(func(s *testSource) { s.err, s.newErr, s.resetErr = nil, nil, nil })
+			assert.Nil(t, ct.Update())
+			st = ct.Status()
+			assert.True(t, st.Healthy())
+			if tt.replace {
+				assert.Equal(t, uint64(2), st.Generation)
+			} else {
+				assert.Equal(t, uint64(1), st.Resets)
+			}
+		})
+	}
+}
+
+func TestNewContainerInvalid(t *testing.T) {
+	getConf := func() (IConf, error) { return testConf{}, nil }
+	newObj := func(IConf) (IObject, error) { return &testObj{}, nil }
+
+	_, err := NewContainer(nil, nil, newObj, nil)
+	assert.True(t, errors.Is(err, ErrGetObjConfFuncIsNil))
+	_, err = NewContainer(getConf, nil, nil, nil)
+	assert.True(t, errors.Is(err, ErrNewObjFuncIsNil))
+	_, err = NewContainer(getConf, nil, func(IConf) (IObject, error) { return nil, errors.New("dial") }, nil)
+	assert.NotNil(t, err)
+
+	// 没有对比函数时更新失败
+	ct, err := NewContainer(getConf, nil, newObj, nil)
+	assert.Nil(t, err)
+	assert.True(t, errors.Is(ct.Update(), ErrCompareObjConfFuncIsNil))
+	assert.Equal(t, ErrCompareObjConfFuncIsNil.Error(), ct.Status().LastError)
+}
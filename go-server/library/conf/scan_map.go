@@ -7,8 +7,11 @@ import (
 	"strings"
 )
 
+const tagOptOmitEmpty = "omitempty"
+
 // scan 将map src中的信息扫描到结构体指针dst, 扫描时src的key与结构体成员的tag对应的值相对应,
-// 结构体类型必须是非nil的结构体指针, 且结构体成员拥有指定tag时src必须存在对应的key, 否则将返回错误
+// 结构体类型必须是非nil的结构体指针, 且结构体成员拥有指定tag时src必须存在对应的key, 否则将返回错误,
+// tag带有omitempty选项(如`env:"DB_DRIVER,omitempty"`)的成员为可选项, src中缺少对应的key时保留成员原值
 func scan(src map[string]string, dst interface{}, tag string) (err error) {
 	rv, err := muststptr(dst)
 	if err != nil {
@@ -17,7 +20,8 @@ func scan(src map[string]string, dst interface{}, tag string) (err error) {
 	}
 
 	for i := 0; i < rv.NumField(); i++ {
-		key := strings.Split(rv.Type().Field(i).Tag.Get(tag), ",")[0]
+		opts := strings.Split(rv.Type().Field(i).Tag.Get(tag), ",")
+		key := opts[0]
 
 		if key == "" {
 			continue
@@ -25,6 +29,9 @@ func scan(src map[string]string, dst interface{}, tag string) (err error) {
 
 		val := strings.TrimSpace(src[key])
 		if val == "" {
+			if hasopt(opts[1:], tagOptOmitEmpty) {
+				continue
+			}
 			err = fmt.Errorf("field %s is not present is source", key)
 			return
 		}
@@ -54,6 +61,16 @@ func scan(src map[string]string, dst interface{}, tag string) (err error) {
 	return nil
 }
 
+// hasopt 检测tag选项列表opts中是否包含指定选项opt
+func hasopt(opts []string, opt string) bool {
+	for _, o := range opts {
+		if strings.TrimSpace(o) == opt {
+			return true
+		}
+	}
+	return false
+}
+
 // muststptr 检测传入的接口值v是否是非空的结构体指针,
 // 如果是则返回该指针指向的结构的反射值rv和nil, 否则将返回不为空的错误err
 func muststptr(v interface{}) (rv reflect.Value, err error) {
@@ -0,0 +1,123 @@
+// dubbo 包提供dubbo-go的扩展, 导入后在服务和引用配置的filter中启用
+package dubbo
+
+import (
+	"context"
+
+	"github.com/apache/dubbo-go/common/extension"
+	"github.com/apache/dubbo-go/filter"
+	"github.com/apache/dubbo-go/protocol"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	"go-server/library/trace"
+)
+
+// 链路追踪过滤器名称
+const (
+	TraceConsumerFilter = "trace_consumer" // 引用端, 为调用创建客户端span并以附件向服务端传递链路信息
+	TraceProviderFilter = "trace_provider" // 服务端, 从附件继续调用方的链路并为调用创建服务端span
+)
+
+// 传递链路信息的附件, 与HTTP请求头保持一致
+const (
+	AttachmentTraceparent = trace.HeaderTraceparent
+	AttachmentRequestID   = trace.HeaderRequestID
+)
+
+func init() {
+	extension.SetFilter(TraceConsumerFilter, func() filter.Filter { return traceConsumerFilter{} })
+	extension.SetFilter(TraceProviderFilter, func() filter.Filter { return traceProviderFilter{} })
+}
+
+// traceConsumerFilter 调用的ctx携带span上下文时创建客户端span, 并以trace.Propagator将链路信息写入调用附件
+type traceConsumerFilter struct{}
+
+func (traceConsumerFilter) Invoke(ctx context.Context, invoker protocol.Invoker, invocation protocol.Invocation) protocol.Result {
+	if _, ok := trace.FromContext(ctx); !ok {
+		return invoker.Invoke(ctx, invocation)
+	}
+
+	var span oteltrace.Span
+	if trace.Traced(ctx) {
+		ctx, span = startSpan(ctx, invoker, invocation, oteltrace.SpanKindClient)
+	}
+
+	trace.Inject(ctx, attachmentCarrier{invocation: invocation})
+
+	result := invoker.Invoke(ctx, invocation)
+	if span != nil {
+		endSpan(span, result)
+	}
+
+	return result
+}
+
+func (traceConsumerFilter) OnResponse(ctx context.Context, result protocol.Result, invoker protocol.Invoker, invocation protocol.Invocation) protocol.Result {
+	return result
+}
+
+// traceProviderFilter 以调用附件中的链路信息继续调用方的链路, 没有时开始新的链路, 并为调用创建服务端span,
+// 服务方法的ctx携带链路信息和span
+type traceProviderFilter struct{}
+
+func (traceProviderFilter) Invoke(ctx context.Context, invoker protocol.Invoker, invocation protocol.Invocation) protocol.Result {
+	ctx = trace.Extract(ctx, attachmentCarrier{invocation: invocation})
+
+	ctx, span := startSpan(ctx, invoker, invocation, oteltrace.SpanKindServer)
+
+	result := invoker.Invoke(ctx, invocation)
+	endSpan(span, result)
+
+	return result
+}
+
+func (traceProviderFilter) OnResponse(ctx context.Context, result protocol.Result, invoker protocol.Invoker, invocation protocol.Invocation) protocol.Result {
+	return result
+}
+
+// attachmentCarrier 以调用附件实现trace.Carrier
+type attachmentCarrier struct {
+	invocation protocol.Invocation
+}
+
+func (c attachmentCarrier) Get(key string) string {
+	return c.invocation.AttachmentsByKey(key, "")
+}
+
+func (c attachmentCarrier) Set(key, value string) {
+	c.invocation.SetAttachments(key, value)
+}
+
+func (c attachmentCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.invocation.Attachments()))
+	for k := range c.invocation.Attachments() {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
+
+// startSpan 开始以服务和方法命名的span
+func startSpan(ctx context.Context, invoker protocol.Invoker, invocation protocol.Invocation, kind oteltrace.SpanKind) (context.Context, oteltrace.Span) {
+	url := invoker.GetUrl()
+
+	return trace.StartSpan(ctx, url.ServiceKey()+"#"+invocation.MethodName(),
+		oteltrace.WithSpanKind(kind),
+		oteltrace.WithAttributes(
+			semconv.RPCSystemKey.String("dubbo"),
+			semconv.RPCServiceKey.String(url.Service()),
+			semconv.RPCMethodKey.String(invocation.MethodName()),
+		),
+	)
+}
+
+// endSpan 以调用结果的错误设置span状态后结束span
+func endSpan(span oteltrace.Span, result protocol.Result) {
+	var err error
+	if result != nil {
+		err = result.Error()
+	}
+
+	trace.EndSpan(span, &err)
+}
@@ -0,0 +1,101 @@
+package dubbo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/apache/dubbo-go/common"
+	"github.com/apache/dubbo-go/protocol"
+	"github.com/apache/dubbo-go/protocol/invocation"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	"go-server/library/trace"
+	"go-server/library/trace/tracetest"
+)
+
+// funcInvoker 以函数实现调用的invoker
+type funcInvoker struct {
+	*protocol.BaseInvoker
+	f func(ctx context.Context, invocation protocol.Invocation) protocol.Result
+}
+
+func (i *funcInvoker) Invoke(ctx context.Context, invocation protocol.Invocation) protocol.Result {
+	return i.f(ctx, invocation)
+}
+
+func newFuncInvoker(t *testing.T, f func(ctx context.Context, invocation protocol.Invocation) protocol.Result) *funcInvoker {
+	url, err := common.NewURL("dubbo://127.0.0.1:20000/com.test.UserProvider?interface=com.test.UserProvider&group=g&version=1.0")
+	if err != nil {
+		t.Fatalf("common.NewURL: %v", err)
+	}
+
+	return &funcInvoker{BaseInvoker: protocol.NewBaseInvoker(url), f: f}
+}
+
+func TestTraceFilter(t *testing.T) {
+	rec := tracetest.Record(t)
+
+	// 服务端从附件继续调用方的链路, 服务方法的ctx以服务端span为当前操作
+	var handled trace.SpanContext
+	provider := newFuncInvoker(t, func(ctx context.Context, inv protocol.Invocation) protocol.Result {
+		handled, _ = trace.FromContext(ctx)
+		return &protocol.RPCResult{Err: errors.New("user not found")}
+	})
+
+	// 以新的调用和空的ctx模拟调用经过网络传递, 只有附件到达服务端
+	var attachments map[string]interface{}
+	consumer := newFuncInvoker(t, func(ctx context.Context, inv protocol.Invocation) protocol.Result {
+		attachments = inv.Attachments()
+		return traceProviderFilter{}.Invoke(context.Background(), provider, invocation.NewRPCInvocation(inv.MethodName(), nil, attachments))
+	})
+
+	ctx, parent := trace.StartSpan(trace.WithRequestID(context.Background(), "req-1"), "parent")
+	result := traceConsumerFilter{}.Invoke(ctx, consumer, invocation.NewRPCInvocation("GetUser", nil, nil))
+	parent.End()
+	assert.NotNil(t, result.Error())
+
+	// 服务端span先于客户端span结束, 两者都以服务和方法命名
+	spans := rec.Ended()
+	if !assert.Len(t, spans, 3) {
+		return
+	}
+	server, client := spans[0], spans[1]
+	assert.Equal(t, "g/com.test.UserProvider:1.0#GetUser", client.Name())
+	assert.Equal(t, client.Name(), server.Name())
+	assert.Equal(t, oteltrace.SpanKindServer, server.SpanKind())
+	assert.Equal(t, oteltrace.SpanKindClient, client.SpanKind())
+
+	// 父span -> 客户端span -> 服务端span
+	assert.Equal(t, parent.SpanContext().SpanID(), client.Parent().SpanID())
+	assert.Equal(t, client.SpanContext().SpanID(), server.Parent().SpanID())
+	assert.True(t, server.Parent().IsRemote())
+	assert.Equal(t, parent.SpanContext().TraceID(), server.SpanContext().TraceID())
+	assert.Equal(t, codes.Error, server.Status().Code)
+	assert.Equal(t, codes.Error, client.Status().Code)
+
+	assert.Equal(t, "00-"+client.SpanContext().TraceID().String()+"-"+client.SpanContext().SpanID().String()+"-01", attachments[AttachmentTraceparent])
+	assert.Equal(t, "req-1", attachments[AttachmentRequestID])
+	assert.Equal(t, trace.SpanContext{
+		TraceID:   server.SpanContext().TraceID().String(),
+		SpanID:    server.SpanContext().SpanID().String(),
+		RequestID: "req-1",
+	}, handled)
+}
+
+func TestTraceConsumerFilterWithoutTrace(t *testing.T) {
+	rec := tracetest.Record(t)
+
+	var attachments map[string]interface{}
+	consumer := newFuncInvoker(t, func(ctx context.Context, inv protocol.Invocation) protocol.Result {
+		attachments = inv.Attachments()
+		return &protocol.RPCResult{}
+	})
+
+	// 不属于任何链路的调用不创建span, 也不写入附件
+	traceConsumerFilter{}.Invoke(context.Background(), consumer, invocation.NewRPCInvocation("GetUser", nil, nil))
+	assert.Empty(t, rec.Ended())
+	assert.Empty(t, attachments)
+}
@@ -1,6 +1,7 @@
 package elastic
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"go-sever/library/conf"
@@ -95,3 +96,22 @@ func (ct *ClientContainer) PutClient(cli *Client) {
 
 	return
 }
+
+// Ping 检查集群健康状态, 集群状态为red时返回错误, 用于健康检查
+func (ct *ClientContainer) Ping(ctx context.Context) (err error) {
+	cli := ct.MustGetClient()
+	defer ct.PutClient(cli)
+
+	rsp, err := cli.ClusterHealth().Do(ctx)
+	if err != nil {
+		err = fmt.Errorf("cluster health: %w", err)
+		return
+	}
+
+	if rsp.Status == "red" {
+		err = fmt.Errorf("cluster %s status is red", rsp.ClusterName)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,296 @@
+// health 包汇总注册的健康检查, 提供存活(livez)和就绪(readyz)检查接口,
+// 检查并发执行, 每项检查有独立的超时并可缓存结果, 开始关闭后就绪检查失败, 以便负载均衡摘除流量
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-server/library/trace"
+)
+
+const (
+	defaultTimeout = 2 * time.Second
+
+	// CheckShutdown 开始关闭后就绪检查结果中的检查项名称
+	CheckShutdown = "shutdown"
+)
+
+var (
+	ErrShuttingDown = errors.New("server is shutting down")
+	ErrTimeout      = errors.New("health check timeout")
+)
+
+// CheckFunc 健康检查函数, 健康时返回nil, 应在ctx结束时尽快返回
+type CheckFunc func(ctx context.Context) error
+
+// Options 健康检查选项
+type Options struct {
+	Timeout  time.Duration // 每项检查的超时, 默认2秒
+	CacheTTL time.Duration // 检查结果的缓存时长, 为0时不缓存, 用于避免频繁探测给依赖服务带来压力
+}
+
+// Result 单项检查的结果
+type Result struct {
+	Name       string    `json:"name"`
+	OK         bool      `json:"ok"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	CheckedAt  time.Time `json:"checked_at"`
+	Cached     bool      `json:"cached"` // 是否为缓存的结果
+}
+
+// Report 一组检查的结果, 所有检查都通过时OK为true
+type Report struct {
+	OK     bool     `json:"ok"`
+	Checks []Result `json:"checks"`
+}
+
+type check struct {
+	name string
+	f    CheckFunc
+
+	// mu 保证同一检查同时只执行一次, 并发的探测等待执行结果或使用缓存
+	mu      sync.Mutex
+	last    Result
+	expires time.Time
+}
+
+// Checker 健康检查, 所有方法并发安全
+type Checker struct {
+	opts Options
+
+	mu        sync.RWMutex
+	liveness  []*check
+	readiness []*check
+
+	shuttingDown int32
+}
+
+// NewChecker 创建健康检查, opts为nil时使用默认选项
+func NewChecker(opts *Options) *Checker {
+	c := &Checker{}
+	if opts != nil {
+		c.opts = *opts
+	}
+	if c.opts.Timeout <= 0 {
+		c.opts.Timeout = defaultTimeout
+	}
+
+	return c
+}
+
+// AddLiveness 注册存活检查, 存活检查失败意味着进程需要重启, 不应包含外部依赖的检查
+func (c *Checker) AddLiveness(name string, f CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.liveness = append(c.liveness, &check{name: name, f: f})
+}
+
+// AddReadiness 注册就绪检查, 就绪检查失败时负载均衡不再转发流量, 通常为数据库, 缓存等外部依赖的检查
+func (c *Checker) AddReadiness(name string, f CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.readiness = append(c.readiness, &check{name: name, f: f})
+}
+
+// StartShutdown 标记开始关闭, 之后就绪检查总是失败
+func (c *Checker) StartShutdown() {
+	atomic.StoreInt32(&c.shuttingDown, 1)
+}
+
+// ShuttingDown 是否已开始关闭
+func (c *Checker) ShuttingDown() bool {
+	return atomic.LoadInt32(&c.shuttingDown) == 1
+}
+
+// Live 执行所有存活检查
+func (c *Checker) Live(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := c.liveness
+	c.mu.RUnlock()
+
+	return c.run(ctx, checks)
+}
+
+// Ready 执行所有就绪检查, 开始关闭后结果中包含失败的shutdown检查项
+func (c *Checker) Ready(ctx context.Context) (report Report) {
+	c.mu.RLock()
+	checks := c.readiness
+	c.mu.RUnlock()
+
+	report = c.run(ctx, checks)
+	if c.ShuttingDown() {
+		report.OK = false
+		report.Checks = append(report.Checks, Result{
+			Name:      CheckShutdown,
+			Error:     ErrShuttingDown.Error(),
+			CheckedAt: time.Now(),
+		})
+	}
+
+	return
+}
+
+func (c *Checker) run(ctx context.Context, checks []*check) (report Report) {
+	report = Report{OK: true, Checks: make([]Result, len(checks))}
+
+	var wg sync.WaitGroup
+	for i, ck := range checks {
+		wg.Add(1)
+		go func(i int, ck *check) {
+			defer wg.Done()
+			report.Checks[i] = c.runCheck(ctx, ck)
+		}(i, ck)
+	}
+	wg.Wait()
+
+	for _, rst := range report.Checks {
+		if !rst.OK {
+			report.OK = false
+		}
+	}
+
+	return
+}
+
+func (c *Checker) runCheck(ctx context.Context, ck *check) (rst Result) {
+	ck.mu.Lock()
+	defer ck.mu.Unlock()
+
+	if c.opts.CacheTTL > 0 && time.Now().Before(ck.expires) {
+		rst = ck.last
+		rst.Cached = true
+		return
+	}
+
+	// 检查只受Timeout限制, 不继承探测请求的取消, 避免负载均衡提前断开时缓存依赖服务并未出现的失败
+	ctx, cancel := context.WithTimeout(trace.Detach(ctx), c.opts.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- callCheck(ctx, ck.f)
+	}()
+
+	// 检查函数未响应ctx时也按超时返回
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("%w after %s", ErrTimeout, c.opts.Timeout)
+	}
+
+	rst = Result{
+		Name:       ck.name,
+		OK:         err == nil,
+		DurationMs: time.Since(start).Milliseconds(),
+		CheckedAt:  start,
+	}
+	if err != nil {
+		rst.Error = err.Error()
+	}
+
+	ck.last, ck.expires = rst, start.Add(c.opts.CacheTTL)
+
+	return
+}
+
+// callCheck 调用检查函数, 将panic转换为错误
+func callCheck(ctx context.Context, f CheckFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return f(ctx)
+}
+
+// LiveHandler 存活检查接口, 输出的检查结果不包含错误信息, 可在公开端口提供服务, 见ServeReport
+func (c *Checker) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeReport(w, r, c.Live(r.Context()).WithoutErrors())
+	})
+}
+
+// ReadyHandler 就绪检查接口, 输出的检查结果不包含错误信息, 可在公开端口提供服务, 见ServeReport
+func (c *Checker) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeReport(w, r, c.Ready(r.Context()).WithoutErrors())
+	})
+}
+
+// LiveDetailHandler 存活检查接口, 输出的检查结果包含错误信息, 错误信息可能包含依赖服务的地址等, 应只在运维端口提供服务
+func (c *Checker) LiveDetailHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeReport(w, r, c.Live(r.Context()))
+	})
+}
+
+// ReadyDetailHandler 就绪检查接口, 输出的检查结果包含错误信息, 错误信息可能包含依赖服务的地址等, 应只在运维端口提供服务
+func (c *Checker) ReadyDetailHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeReport(w, r, c.Ready(r.Context()))
+	})
+}
+
+// WithoutErrors 返回去掉各项检查错误信息的副本
+func (report Report) WithoutErrors() Report {
+	checks := make([]Result, len(report.Checks))
+	for i, rst := range report.Checks {
+		rst.Error = ""
+		checks[i] = rst
+	}
+	report.Checks = checks
+
+	return report
+}
+
+// ServeReport 输出检查结果, 全部通过时状态码为200, 否则为503,
+// 请求带verbose查询参数(值不为0或false)时输出JSON格式的各项检查结果, 否则输出ok或failed
+func ServeReport(w http.ResponseWriter, r *http.Request, report Report) {
+	status := http.StatusOK
+	if !report.OK {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	if isVerbose(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	if report.OK {
+		_, _ = w.Write([]byte("ok"))
+	} else {
+		_, _ = w.Write([]byte("failed"))
+	}
+}
+
+func isVerbose(r *http.Request) bool {
+	vs, ok := r.URL.Query()["verbose"]
+	if !ok {
+		return false
+	}
+
+	return len(vs) == 0 || (vs[0] != "0" && vs[0] != "false")
+}
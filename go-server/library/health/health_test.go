@@ -0,0 +1,209 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecker(t *testing.T) {
+	tests := []struct {
+		name    string
+		f       CheckFunc
+		ok      bool
+		wantErr string
+	}{
+		{name: "ok", f: func(context.Context) error { return nil }, ok: true},
+		{name: "error", f: func(context.Context) error { return errors.New("dial tcp 10.0.0.1:3306: refused") }, wantErr: "refused"},
+		{name: "panic", f: func(context.Context) error { panic("boom") }, wantErr: "panic: boom"},
+		{name: "timeout", f: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}, wantErr: "timeout"},
+		{name: "ignores ctx", f: func(context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		}, wantErr: "timeout"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChecker(&Options{Timeout: 50 * time.Millisecond})
+			c.AddReadiness(tt.name, tt.f)
+
+			start := time.Now()
+			report := c.Ready(context.Background())
+			assert.Less(t, int64(time.Since(start)), int64(500*time.Millisecond))
+
+			assert.Equal(t, tt.ok, report.OK)
+			if assert.Len(t, report.Checks, 1) {
+				rst := report.Checks[0]
+				assert.Equal(t, tt.name, rst.Name)
+				assert.Equal(t, tt.ok, rst.OK)
+				assert.Contains(t, rst.Error, tt.wantErr)
+				assert.False(t, rst.Cached)
+			}
+		})
+	}
+}
+
+func TestCheckerConcurrent(t *testing.T) {
+	c := NewChecker(&Options{Timeout: time.Second})
+
+	// 各项检查并发执行
+	for _, name := range []string{"a", "b", "c"} {
+		c.AddReadiness(name, func(context.Context) error {
+			time.Sleep(100 * time.Millisecond)
+			return nil
+		})
+	}
+	c.AddReadiness("d", func(context.Context) error { return errors.New("down") })
+
+	start := time.Now()
+	report := c.Ready(context.Background())
+	assert.Less(t, int64(time.Since(start)), int64(250*time.Millisecond))
+	assert.False(t, report.OK)
+
+	var names []string
+	for _, rst := range report.Checks {
+		names = append(names, rst.Name)
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d"}, names)
+}
+
+func TestCheckerCache(t *testing.T) {
+	var calls int32
+	var fail atomic.Value
+	fail.Store(false)
+
+	c := NewChecker(&Options{Timeout: time.Second, CacheTTL: 100 * time.Millisecond})
+	c.AddReadiness("db", func(context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(20 * time.Millisecond)
+		if fail.Load().(bool) {
+			return errors.New("down")
+		}
+		return nil
+	})
+
+	// 并发的探测只执行一次检查
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.True(t, c.Ready(context.Background()).OK)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	report := c.Ready(context.Background())
+	assert.True(t, report.OK)
+	assert.True(t, report.Checks[0].Cached)
+
+	// 缓存过期后重新检查
+	fail.Store(true)
+	assert.True(t, c.Ready(context.Background()).OK)
+	time.Sleep(150 * time.Millisecond)
+	report = c.Ready(context.Background())
+	assert.False(t, report.OK)
+	assert.False(t, report.Checks[0].Cached)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestCheckerCallerCancelled(t *testing.T) {
+	c := NewChecker(&Options{Timeout: time.Second, CacheTTL: time.Minute})
+	c.AddReadiness("db", func(ctx context.Context) error {
+		select {
+		case <-time.After(50 * time.Millisecond):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	// 探测请求提前断开时检查仍执行完成, 缓存的是依赖服务的真实结果
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report := c.Ready(ctx)
+	assert.True(t, report.OK)
+	assert.Empty(t, report.Checks[0].Error)
+
+	report = c.Ready(context.Background())
+	assert.True(t, report.OK)
+	assert.True(t, report.Checks[0].Cached)
+}
+
+func TestCheckerShutdown(t *testing.T) {
+	c := NewChecker(nil)
+	c.AddLiveness("goroutines", func(context.Context) error { return nil })
+	c.AddReadiness("db", func(context.Context) error { return nil })
+
+	assert.False(t, c.ShuttingDown())
+	assert.True(t, c.Ready(context.Background()).OK)
+
+	// 开始关闭后就绪检查失败, 存活检查不受影响
+	c.StartShutdown()
+	assert.True(t, c.ShuttingDown())
+
+	report := c.Ready(context.Background())
+	assert.False(t, report.OK)
+	if assert.Len(t, report.Checks, 2) {
+		assert.True(t, report.Checks[0].OK)
+		assert.Equal(t, CheckShutdown, report.Checks[1].Name)
+		assert.Equal(t, ErrShuttingDown.Error(), report.Checks[1].Error)
+	}
+	assert.True(t, c.Live(context.Background()).OK)
+}
+
+func TestHandlers(t *testing.T) {
+	c := NewChecker(nil)
+	c.AddLiveness("self", func(context.Context) error { return nil })
+	c.AddReadiness("db", func(context.Context) error { return errors.New("dial tcp 10.0.0.1:3306: refused") })
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		query   string
+		status  int
+		body    string // 非verbose时的响应体
+		errors  bool   // verbose时是否包含错误信息
+	}{
+		{name: "live", handler: c.LiveHandler(), status: 200, body: "ok"},
+		{name: "ready", handler: c.ReadyHandler(), status: 503, body: "failed"},
+		{name: "ready verbose=0", handler: c.ReadyHandler(), query: "?verbose=0", status: 503, body: "failed"},
+		{name: "ready verbose", handler: c.ReadyHandler(), query: "?verbose", status: 503},
+		{name: "ready detail verbose", handler: c.ReadyDetailHandler(), query: "?verbose=1", status: 503, errors: true},
+		{name: "live detail verbose", handler: c.LiveDetailHandler(), query: "?verbose", status: 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+tt.query, nil))
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+			if tt.body != "" {
+				assert.Equal(t, tt.body, w.Body.String())
+				return
+			}
+
+			var report Report
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &report))
+			assert.Equal(t, tt.status == 200, report.OK)
+			assert.Len(t, report.Checks, 1)
+			assert.Equal(t, tt.errors, strings.Contains(w.Body.String(), "10.0.0.1"))
+		})
+	}
+}
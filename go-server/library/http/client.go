@@ -1,12 +1,14 @@
 package http
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
 	"net/url"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,6 +18,16 @@ const (
 
 type Client struct {
 	*http.Client
+
+	policy   atomic.Value // clientPolicy
+	breakers sync.Map     // HOST到*circuitBreaker
+	tls      *tlsReloader
+
+	transport    *http.Transport
+	mu           sync.Mutex
+	base         http.RoundTripper // SetTransport设置的底层传输, 为nil时使用transport
+	interceptors []Interceptor
+	chain        atomic.Value // chainHolder, 底层传输外包装interceptors
 }
 
 type ClientConf struct {
@@ -24,98 +36,164 @@ type ClientConf struct {
 	MaxIdleConns          int // 最大空闲连接数
 	MaxIdleConnsPerHost   int // HOST最大空闲连接数
 	IdleConnTimeoutSecond int // 空闲连接超时
-}
 
-func NewClient(cf *ClientConf) (cli *Client) {
-	cli = &Client{
-		Client: &http.Client{
-			Transport: &http.Transport{
-				MaxIdleConns:        cf.MaxIdleConns,
-				MaxIdleConnsPerHost: cf.MaxIdleConnsPerHost,
-				IdleConnTimeout:     time.Duration(cf.IdleConnTimeoutSecond) * time.Second,
-				Proxy:               http.ProxyFromEnvironment,
-				DialContext: (&net.Dialer{
-					Timeout:   time.Duration(cf.DialTimeoutSecond) * time.Second,
-					KeepAlive: time.Duration(cf.DialKeepAliveSecond) * time.Second,
-				}).DialContext,
-			},
-		},
-	}
-	return
+	TimeoutSecond               int // 请求总超时, 包括连接, 重定向和读取响应体, 为0时不限制
+	ResponseHeaderTimeoutSecond int // 发送请求后等待响应头的超时, 为0时不限制
+	TLSHandshakeTimeoutSecond   int // TLS握手超时, 为0时不限制
+
+	CAFile             string // 校验服务端证书的CA证书文件, 与系统CA证书一起使用
+	CertFile           string // mTLS客户端证书文件, 需与KeyFile一起设置
+	KeyFile            string // mTLS客户端私钥文件
+	MinTLSVersion      string // 最低TLS版本, 可选1.0, 1.1, 1.2, 1.3, 为空时使用默认值
+	InsecureSkipVerify bool   // 不校验服务端证书, 仅用于测试
+
+	ProxyURL   string // 默认代理, 为空时使用环境变量HTTP_PROXY, HTTPS_PROXY和NO_PROXY
+	ProxyRules string // 按HOST选择代理的规则, 格式为"host=url,*.suffix=url,host=direct", 按顺序匹配, direct表示不使用代理
+
+	Retry   RetryPolicy   // 重试策略, 热更新时不替换客户端
+	Breaker BreakerPolicy // 熔断策略, 热更新时不替换客户端, 各HOST的熔断状态保留
 }
 
-func (cli *Client) Get(baseURL string, query url.Values, respst interface{}) (err error) {
-	u, err := url.Parse(baseURL)
+// NewClient 创建客户端, 设置了证书文件时监听文件变化并重新加载, 证书首次加载失败时返回错误
+func NewClient(cf *ClientConf) (cli *Client, err error) {
+	proxy, err := newProxyFunc(cf.ProxyURL, cf.ProxyRules)
 	if err != nil {
-		err = fmt.Errorf("url parse: %w", err)
+		err = fmt.Errorf("proxy: %w", err)
 		return
 	}
 
-	u.RawQuery = query.Encode()
-
-	resp, err := cli.Client.Get(u.String())
-	if err != nil {
-		err = fmt.Errorf("client get: %w", err)
+	tlsConf := &tls.Config{InsecureSkipVerify: cf.InsecureSkipVerify}
+	if tlsConf.MinVersion, err = parseTLSVersion(cf.MinTLSVersion); err != nil {
 		return
 	}
 
-	if err = scanresp(resp, respst); err != nil {
-		err = fmt.Errorf("scan response: %w", err)
-		return
+	c := &Client{}
+	if cf.CAFile != "" || cf.CertFile != "" || cf.KeyFile != "" {
+		if c.tls, err = newTLSReloader(cf.CAFile, cf.CertFile, cf.KeyFile); err != nil {
+			err = fmt.Errorf("tls: %w", err)
+			return
+		}
+		c.tls.apply(tlsConf)
 	}
 
-	return
-}
+	c.SetPolicy(cf.Retry, cf.Breaker)
 
-func (cli *Client) Post(baseURL string, reqdata, respst interface{}) (err error) {
-	u, err := url.Parse(baseURL)
-	if err != nil {
-		err = fmt.Errorf("url parse: %w", err)
-		return
+	dialer := &net.Dialer{
+		Timeout:   time.Duration(cf.DialTimeoutSecond) * time.Second,
+		KeepAlive: time.Duration(cf.DialKeepAliveSecond) * time.Second,
 	}
-
-	data, err := json.Marshal(reqdata)
-	if err != nil {
-		err = fmt.Errorf("json marshal: %w", err)
-		return
+	c.transport = &http.Transport{
+		MaxIdleConns:          cf.MaxIdleConns,
+		MaxIdleConnsPerHost:   cf.MaxIdleConnsPerHost,
+		IdleConnTimeout:       time.Duration(cf.IdleConnTimeoutSecond) * time.Second,
+		ResponseHeaderTimeout: time.Duration(cf.ResponseHeaderTimeoutSecond) * time.Second,
+		TLSHandshakeTimeout:   time.Duration(cf.TLSHandshakeTimeoutSecond) * time.Second,
+		TLSClientConfig:       tlsConf,
+		ForceAttemptHTTP2:     true,
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
 	}
-
-	resp, err := cli.Client.Post(u.String(), defaultContentType, bytes.NewReader(data))
-	if err != nil {
-		err = fmt.Errorf("client post: %w", err)
-		return
+	if c.tls != nil && cf.CAFile != "" && !cf.InsecureSkipVerify {
+		c.transport.DialTLSContext = c.tls.dialTLS(dialer, tlsConf, c.transport.TLSHandshakeTimeout)
 	}
+	c.chain.Store(chainHolder{c.transport})
 
-	if err = scanresp(resp, respst); err != nil {
-		err = fmt.Errorf("scan response: %w", err)
-		return
+	c.Client = &http.Client{
+		Timeout:   time.Duration(cf.TimeoutSecond) * time.Second,
+		Transport: &policyTransport{cli: c},
 	}
 
+	cli = c
+
 	return
 }
 
-func (cli *Client) Close() (err error) {
-	cli.Client.CloseIdleConnections()
-	return
+// SetPolicy 更新重试和熔断策略, 对之后发送的请求生效
+func (cli *Client) SetPolicy(retry RetryPolicy, breaker BreakerPolicy) {
+	cli.policy.Store(clientPolicy{retry: retry, breaker: breaker})
 }
 
-func scanresp(resp *http.Response, st interface{}) (err error) {
-	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf("response code %d", resp.StatusCode)
-		return
-	}
+func (cli *Client) getPolicy() clientPolicy {
+	return cli.policy.Load().(clientPolicy)
+}
 
-	if resp.Body == nil {
-		err = fmt.Errorf("body is nil")
-		return
+// Use 追加拦截器, 对之后发送的请求生效, 先追加的拦截器位于外层
+func (cli *Client) Use(interceptors ...Interceptor) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	cli.interceptors = append(cli.interceptors, interceptors...)
+	cli.rebuildChainLocked()
+}
+
+// SetTransport 替换发送请求的底层传输, 拦截器, 重试和熔断仍然生效, 主要用于测试中以模拟传输代替真实网络,
+// rt为nil时恢复使用按配置创建的传输
+func (cli *Client) SetTransport(rt http.RoundTripper) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	cli.base = rt
+	cli.rebuildChainLocked()
+}
+
+// ConfiguredTransport 返回按配置创建的底层传输, 不受SetTransport影响, 不经过拦截器, 重试和熔断
+func (cli *Client) ConfiguredTransport() http.RoundTripper {
+	return cli.transport
+}
+
+func (cli *Client) rebuildChainLocked() {
+	var base http.RoundTripper = cli.transport
+	if cli.base != nil {
+		base = cli.base
 	}
 
-	defer resp.Body.Close()
+	cli.chain.Store(chainHolder{Chain(cli.interceptors...)(base)})
+}
+
+func (cli *Client) roundTripper() http.RoundTripper {
+	return cli.chain.Load().(chainHolder).rt
+}
+
+// chainHolder 使atomic.Value中存储的类型保持一致
+type chainHolder struct {
+	rt http.RoundTripper
+}
+
+func (cli *Client) breaker(host string) *circuitBreaker {
+	b, _ := cli.breakers.LoadOrStore(host, &circuitBreaker{})
+	return b.(*circuitBreaker)
+}
 
-	if err = json.NewDecoder(resp.Body).Decode(st); err != nil {
-		err = fmt.Errorf("json decode: %w", err)
+// Get 发送GET请求, query替换baseURL中的查询参数, 状态码为200时将JSON响应体解码到respst,
+// 其他状态码(包括200以外的2xx)返回*StatusError, 响应体为空时返回错误, 需要其他行为时使用NewRequest
+func (cli *Client) Get(baseURL string, query url.Values, respst interface{}) (err error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		err = fmt.Errorf("url parse: %w", err)
 		return
 	}
 
+	u.RawQuery = query.Encode()
+
+	req := cli.NewRequest(context.Background(), http.MethodGet, u.String())
+	req.okOnly = true
+
+	return req.Do(respst)
+}
+
+// Post 以reqdata的JSON编码作为请求体发送POST请求, 状态码为200时将JSON响应体解码到respst,
+// 其他状态码(包括200以外的2xx)返回*StatusError, 响应体为空时返回错误, 需要其他行为时使用NewRequest
+func (cli *Client) Post(baseURL string, reqdata, respst interface{}) (err error) {
+	req := cli.NewRequest(context.Background(), http.MethodPost, baseURL).JSON(reqdata)
+	req.okOnly = true
+
+	return req.Do(respst)
+}
+
+func (cli *Client) Close() (err error) {
+	cli.Client.CloseIdleConnections()
+	if cli.tls != nil {
+		err = cli.tls.Close()
+	}
 	return
 }
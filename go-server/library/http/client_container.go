@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"go-sever/library/conf"
+	"net/http"
+	"sync"
 )
 
 var (
@@ -12,11 +14,15 @@ var (
 
 type ClientContainer struct {
 	*conf.Container
+
+	mu        sync.Mutex
+	transport http.RoundTripper // SetTransport设置的底层传输, 替换客户端后仍然生效
 }
 
 type GetClientConfFunc func() (*ClientConf, error)
 
-func NewClientContainer(getCliConf GetClientConfFunc) (ct *ClientContainer, err error) {
+// NewClientContainer 创建客户端容器, interceptors在每次创建客户端时追加到客户端, 配置变化替换客户端后仍然生效
+func NewClientContainer(getCliConf GetClientConfFunc, interceptors ...Interceptor) (ct *ClientContainer, err error) {
 	if getCliConf == nil {
 		err = ErrGetClientConfFuncIsNil
 		return
@@ -31,19 +37,60 @@ func NewClientContainer(getCliConf GetClientConfFunc) (ct *ClientContainer, err
 		return
 	}
 
-	ict, err := conf.NewContainer(getObjConf, compareClientConf, newClientObj, nil)
+	c := &ClientContainer{}
+	newObj := func(icf conf.IConf) (iobj conf.IObject, err error) {
+		if iobj, err = newClientObj(icf); err != nil {
+			return
+		}
+
+		cli := iobj.(*Client)
+		cli.Use(interceptors...)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.transport != nil {
+			cli.SetTransport(c.transport)
+		}
+
+		return
+	}
+
+	ict, err := conf.NewContainer(getObjConf, compareClientConf, newObj, resetClientObj)
 	if err != nil {
 		err = fmt.Errorf("new conf container: %w", err)
 		return
 	}
 
-	ct = &ClientContainer{
-		Container: ict,
-	}
+	c.Container = ict
+	ct = c
 
 	return
 }
 
+// SetTransport 替换容器中客户端的底层传输, 之后因配置变化创建的客户端同样使用rt,
+// 用于在测试中不修改业务代码而以模拟传输代替真实网络, rt为nil时恢复使用按配置创建的传输
+func (ct *ClientContainer) SetTransport(rt http.RoundTripper) {
+	ct.mu.Lock()
+	ct.transport = rt
+	ct.mu.Unlock()
+
+	cli := ct.MustGetClient()
+	defer ct.PutClient(cli)
+
+	cli.SetTransport(rt)
+}
+
+// ConfiguredTransport 返回以容器中当前客户端按配置创建的底层传输发送请求的传输, 不受SetTransport影响,
+// 配置变化替换客户端后使用新客户端的传输, 用于在替换的传输中发送真实请求, 如录制回放传输的录制模式
+func (ct *ClientContainer) ConfiguredTransport() http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		cli := ct.MustGetClient()
+		defer ct.PutClient(cli)
+
+		return cli.ConfiguredTransport().RoundTrip(req)
+	})
+}
+
 func newClientObj(icf conf.IConf) (iobj conf.IObject, err error) {
 	cf, ok := icf.(*ClientConf)
 	if !ok {
@@ -51,7 +98,31 @@ func newClientObj(icf conf.IConf) (iobj conf.IObject, err error) {
 		return
 	}
 
-	iobj = NewClient(cf)
+	cli, err := NewClient(cf)
+	if err != nil {
+		err = fmt.Errorf("new client: %w", err)
+		return
+	}
+
+	iobj = cli
+
+	return
+}
+
+func resetClientObj(iobj conf.IObject, iocf, incf conf.IConf) (err error) {
+	cli, ok := iobj.(*Client)
+	if !ok {
+		err = conf.ErrInvalidObjectType
+		return
+	}
+
+	ncf, ok := incf.(*ClientConf)
+	if !ok {
+		err = conf.ErrInvalidConfType
+		return
+	}
+
+	cli.SetPolicy(ncf.Retry, ncf.Breaker)
 
 	return
 }
@@ -69,11 +140,20 @@ func compareClientConf(iocf, incf conf.IConf) (rst conf.CompareObjConfRst, err e
 		return
 	}
 
-	if *ocf != *ncf {
+	// 仅重试和熔断策略变化时重置策略, 保留连接池和熔断状态
+	ot, nt := *ocf, *ncf
+	ot.Retry, ot.Breaker = RetryPolicy{}, BreakerPolicy{}
+	nt.Retry, nt.Breaker = RetryPolicy{}, BreakerPolicy{}
+	if ot != nt {
 		rst = conf.CompareObjConfRstNeedReplace
 		return
 	}
 
+	if *ocf != *ncf {
+		rst = conf.CompareObjConfRstNeedReset
+		return
+	}
+
 	rst = conf.CompareObjConfRstNoNeed
 
 	return
@@ -3,7 +3,11 @@
 
 package http
 
-import "net/url"
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
 
 func (ct *ClientContainer) Get(baseURL string, query url.Values, respst interface{}) (err error) {
 	client := ct.MustGetClient()
@@ -18,3 +22,19 @@ func (ct *ClientContainer) Post(baseURL string, reqdata, respst interface{}) (er
 
 	return client.Post(baseURL, reqdata, respst)
 }
+
+// NewRequest 创建请求构造器, 请求在发送时从容器获取当前客户端
+func (ct *ClientContainer) NewRequest(ctx context.Context, method, rawURL string) *Request {
+	return newRequest(containerDoer{ct}, ctx, method, rawURL)
+}
+
+type containerDoer struct {
+	ct *ClientContainer
+}
+
+func (d containerDoer) Do(req *http.Request) (*http.Response, error) {
+	client := d.ct.MustGetClient()
+	defer d.ct.PutClient(client)
+
+	return client.Client.Do(req)
+}
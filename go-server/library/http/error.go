@@ -0,0 +1,81 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+const (
+	// maxErrorBodyLen StatusError中保留的响应体最大字节数
+	maxErrorBodyLen = 4096
+
+	// maxErrorMessageBodyLen 错误信息中包含的响应体最大字节数
+	maxErrorMessageBodyLen = 256
+)
+
+// StatusError 响应状态码不在2xx范围内时返回的错误, Client.Get和Client.Post中状态码不为200时也返回该错误
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Header     http.Header
+	Body       []byte // 响应体的前4096字节
+}
+
+func (e *StatusError) Error() string {
+	body := e.Body
+	if len(body) > maxErrorMessageBodyLen {
+		body = body[:maxErrorMessageBodyLen]
+	}
+
+	return fmt.Sprintf("%s %s: response code %d: %s", e.Method, e.URL, e.StatusCode, body)
+}
+
+// StatusCode 返回err中StatusError的状态码, err不是StatusError时返回0
+func StatusCode(err error) int {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode
+	}
+
+	return 0
+}
+
+// checkStatus 状态码不在2xx范围内(okOnly为true时不为200)时读取部分响应体并返回*StatusError, 剩余部分丢弃以便复用连接,
+// 响应未关联请求时以method和rawURL标识请求
+func checkStatus(resp *http.Response, method, rawURL string, okOnly bool) (err error) {
+	if okOnly && resp.StatusCode == http.StatusOK || !okOnly && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return
+	}
+
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodyLen))
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxErrorBodyLen))
+
+	if resp.Request != nil && resp.Request.URL != nil {
+		method, rawURL = resp.Request.Method, resp.Request.URL.Redacted()
+	}
+
+	err = &StatusError{
+		Method:     method,
+		URL:        rawURL,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+	}
+
+	return
+}
+
+// redactURL 返回隐藏了密码的URL, 用于错误信息, 无法解析时原样返回
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	return u.Redacted()
+}
@@ -0,0 +1,45 @@
+package httptest
+
+import (
+	"testing"
+
+	libhttp "go-server/library/http"
+)
+
+// Mock 以新的模拟传输替换ct的底层传输, 测试结束时恢复, 并对没有匹配路由的请求报告测试失败
+func Mock(tb testing.TB, ct *libhttp.ClientContainer) *Transport {
+	tb.Helper()
+
+	t := NewTransport()
+	ct.SetTransport(t)
+	tb.Cleanup(func() {
+		ct.SetTransport(nil)
+		for _, req := range t.Unmatched() {
+			tb.Errorf("unmatched request: %s %s", req.Method, req.URL)
+		}
+	})
+
+	return t
+}
+
+// Record 以录制回放传输替换ct的底层传输, 工作模式由环境变量HTTPTEST_MODE决定, 见RecordMode,
+// 录制模式下以ct按配置创建的传输发送真实请求, 代理, CA证书和客户端证书等配置仍然生效,
+// 录制模式下测试结束时保存录制文件, 测试结束时恢复ct的底层传输
+func Record(tb testing.TB, ct *libhttp.ClientContainer, file string) *Recorder {
+	tb.Helper()
+
+	r, err := NewRecorder(file, RecordMode(), ct.ConfiguredTransport())
+	if err != nil {
+		tb.Fatalf("httptest.NewRecorder: %v", err)
+	}
+
+	ct.SetTransport(r)
+	tb.Cleanup(func() {
+		ct.SetTransport(nil)
+		if err := r.Save(); err != nil {
+			tb.Errorf("save fixture: %v", err)
+		}
+	})
+
+	return r
+}
@@ -0,0 +1,306 @@
+package httptest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+var (
+	ErrInteractionNotFound = errors.New("recorded interaction not found")
+)
+
+// Mode 录制回放传输的工作模式
+type Mode int
+
+const (
+	ModeReplay Mode = iota // 只从录制文件返回响应, 没有匹配的录制时返回ErrInteractionNotFound
+	ModeRecord             // 发送真实请求并录制, Save时覆盖录制文件
+)
+
+// RecordModeEnv 设置为record时RecordMode返回ModeRecord
+const RecordModeEnv = "HTTPTEST_MODE"
+
+// RecordMode 根据环境变量HTTPTEST_MODE返回工作模式, 默认为ModeReplay,
+// 以便测试默认离线运行, 需要更新录制文件时以HTTPTEST_MODE=record运行测试
+func RecordMode() Mode {
+	if os.Getenv(RecordModeEnv) == "record" {
+		return ModeRecord
+	}
+
+	return ModeReplay
+}
+
+// redactedHeaders 录制时不保存的请求头和响应头
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// redactedParams 录制时以redactedValue代替值的查询参数, 不区分大小写
+var redactedParams = []string{"access_token", "refresh_token", "token", "api_key", "apikey", "key", "secret", "client_secret", "password", "signature", "sig"}
+
+const redactedValue = "REDACTED"
+
+// Interaction 一次录制的请求和响应
+type Interaction struct {
+	Method            string      `json:"method"`
+	URL               string      `json:"url"` // 不含密码, 敏感查询参数的值为REDACTED
+	RequestHeader     http.Header `json:"request_header,omitempty"`
+	RequestBody       string      `json:"request_body,omitempty"`
+	RequestBodyBase64 bool        `json:"request_body_base64,omitempty"` // 请求体不是有效的UTF-8时以base64保存
+
+	Status     int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 bool        `json:"body_base64,omitempty"` // 响应体不是有效的UTF-8时以base64保存
+}
+
+// Recorder 录制回放传输, 实现了http.RoundTripper, 以方法, 脱敏后的URL和请求体匹配录制的请求,
+// 相同的请求按录制顺序依次返回, 录制的响应用完后重复返回最后一个, 所有方法并发安全
+type Recorder struct {
+	mu           sync.Mutex
+	mode         Mode
+	file         string
+	real         http.RoundTripper
+	interactions []Interaction
+	used         []bool
+}
+
+// NewRecorder 创建录制回放传输, file为录制文件路径, real为录制模式下发送真实请求的传输, 为nil时使用http.DefaultTransport,
+// 通过Record替换http.ClientContainer的传输时real为容器按配置创建的传输,
+// 回放模式下录制文件不存在时返回错误
+func NewRecorder(file string, mode Mode, real http.RoundTripper) (r *Recorder, err error) {
+	if real == nil {
+		real = http.DefaultTransport
+	}
+
+	r = &Recorder{
+		mode: mode,
+		file: file,
+		real: real,
+	}
+
+	if mode == ModeRecord {
+		return
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		err = fmt.Errorf("read fixture: %w", err)
+		return
+	}
+
+	if err = json.Unmarshal(data, &r.interactions); err != nil {
+		err = fmt.Errorf("json unmarshal %s: %w", file, err)
+		return
+	}
+	r.used = make([]bool, len(r.interactions))
+
+	return
+}
+
+// Mode 返回工作模式
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// Interactions 返回已加载或已录制的所有交互
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Interaction(nil), r.interactions...)
+}
+
+// RoundTrip 实现http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	body, err := readBody(req)
+	if err != nil {
+		return
+	}
+
+	if r.mode == ModeRecord {
+		return r.record(req, body)
+	}
+
+	return r.replay(req, body)
+}
+
+func (r *Recorder) replay(req *http.Request, body []byte) (resp *http.Response, err error) {
+	reqURL := redactURL(req.URL)
+	reqBody, reqBodyBase64 := encodeBody(body)
+
+	r.mu.Lock()
+	found, last := -1, -1
+	for i, it := range r.interactions {
+		if it.Method != req.Method || it.URL != reqURL ||
+			it.RequestBody != reqBody || it.RequestBodyBase64 != reqBodyBase64 {
+			continue
+		}
+		last = i
+		if !r.used[i] {
+			found = i
+			break
+		}
+	}
+	if found < 0 {
+		found = last
+	}
+	if found >= 0 {
+		r.used[found] = true
+	}
+	r.mu.Unlock()
+
+	if found < 0 {
+		err = fmt.Errorf("%s %s: %w", req.Method, reqURL, ErrInteractionNotFound)
+		return
+	}
+
+	it := r.interactions[found]
+	respBody, err := decodeBody(it.Body, it.BodyBase64)
+	if err != nil {
+		err = fmt.Errorf("decode recorded body: %w", err)
+		return
+	}
+
+	resp = NewResponse(req, it.Status, it.Header, respBody)
+
+	return
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (resp *http.Response, err error) {
+	out := req.Clone(req.Context())
+	out.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if body == nil {
+		out.Body = nil
+	}
+
+	if resp, err = r.real.RoundTrip(out); err != nil {
+		return
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		err = fmt.Errorf("read response body: %w", err)
+		return
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	it := Interaction{
+		Method:        req.Method,
+		URL:           redactURL(req.URL),
+		RequestHeader: redact(req.Header),
+		Status:        resp.StatusCode,
+		Header:        redact(resp.Header),
+	}
+	it.RequestBody, it.RequestBodyBase64 = encodeBody(body)
+	it.Body, it.BodyBase64 = encodeBody(respBody)
+
+	r.mu.Lock()
+	r.interactions = append(r.interactions, it)
+	r.used = append(r.used, false)
+	r.mu.Unlock()
+
+	return
+}
+
+// Save 录制模式下将录制的交互写入录制文件, 回放模式下不做任何操作
+func (r *Recorder) Save() (err error) {
+	if r.mode != ModeRecord {
+		return
+	}
+
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.interactions, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		err = fmt.Errorf("json marshal: %w", err)
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Dir(r.file), 0755); err != nil {
+		err = fmt.Errorf("mkdir: %w", err)
+		return
+	}
+
+	tmp := r.file + ".tmp"
+	if err = ioutil.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		err = fmt.Errorf("write fixture: %w", err)
+		return
+	}
+
+	if err = os.Rename(tmp, r.file); err != nil {
+		err = fmt.Errorf("rename fixture: %w", err)
+		return
+	}
+
+	return
+}
+
+func redact(header http.Header) http.Header {
+	if len(header) == 0 {
+		return nil
+	}
+
+	header = header.Clone()
+	for _, k := range redactedHeaders {
+		header.Del(k)
+	}
+
+	return header
+}
+
+// redactURL 去掉URL中的密码并替换敏感查询参数的值, 保持查询参数的顺序以免改变其他请求的URL
+func redactURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Redacted()
+	}
+
+	pairs := strings.Split(u.RawQuery, "&")
+	for i, pair := range pairs {
+		k := strings.SplitN(pair, "=", 2)[0]
+		if name, err := url.QueryUnescape(k); err == nil && isRedactedParam(name) {
+			pairs[i] = k + "=" + redactedValue
+		}
+	}
+
+	out := *u
+	out.RawQuery = strings.Join(pairs, "&")
+
+	return out.Redacted()
+}
+
+func isRedactedParam(name string) bool {
+	for _, p := range redactedParams {
+		if strings.EqualFold(p, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func encodeBody(body []byte) (s string, isBase64 bool) {
+	if utf8.Valid(body) {
+		return string(body), false
+	}
+
+	return base64.StdEncoding.EncodeToString(body), true
+}
+
+func decodeBody(s string, isBase64 bool) ([]byte, error) {
+	if isBase64 {
+		return base64.StdEncoding.DecodeString(s)
+	}
+
+	return []byte(s), nil
+}
@@ -0,0 +1,383 @@
+// httptest 包提供用于测试library/http客户端调用代码的模拟传输和录制回放传输,
+// 通过http.ClientContainer.SetTransport替换底层传输, 无需修改业务代码即可在离线环境中测试
+package httptest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrNoRouteMatched = errors.New("no mock route matched")
+)
+
+// RecordedRequest 模拟传输收到的请求
+type RecordedRequest struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+	Body   []byte
+	Route  *Route // 匹配的路由, 没有匹配时为nil
+}
+
+// Transport 模拟传输, 实现了http.RoundTripper, 按添加顺序匹配路由并返回预设的响应, 所有方法并发安全
+type Transport struct {
+	mu       sync.Mutex
+	routes   []*Route
+	requests []RecordedRequest
+}
+
+// NewTransport 创建模拟传输
+func NewTransport() *Transport {
+	return &Transport{}
+}
+
+// On 添加路由, method为空或*时匹配所有方法, pattern为路径或完整URL,
+// 为完整URL时同时匹配scheme和HOST, 路径中{name}匹配一段路径并可通过Params获取, 以/*结尾时匹配任意后续路径,
+// 未设置响应的路由返回200和空响应体
+func (t *Transport) On(method, pattern string) *Route {
+	r := newRoute(t, method, pattern)
+
+	t.mu.Lock()
+	t.routes = append(t.routes, r)
+	t.mu.Unlock()
+
+	return r
+}
+
+// Reset 清除所有路由和已收到的请求
+func (t *Transport) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.routes, t.requests = nil, nil
+}
+
+// Requests 返回已收到的所有请求, 包括没有匹配路由的请求
+func (t *Transport) Requests() []RecordedRequest {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]RecordedRequest(nil), t.requests...)
+}
+
+// Unmatched 返回没有匹配任何路由的请求
+func (t *Transport) Unmatched() (reqs []RecordedRequest) {
+	for _, req := range t.Requests() {
+		if req.Route == nil {
+			reqs = append(reqs, req)
+		}
+	}
+
+	return
+}
+
+// RoundTrip 实现http.RoundTripper, 没有匹配的路由时返回ErrNoRouteMatched
+func (t *Transport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	body, err := readBody(req)
+	if err != nil {
+		return
+	}
+
+	// 匹配函数和响应函数看到的请求体可再次读取
+	req = req.Clone(req.Context())
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	t.mu.Lock()
+	routes := append([]*Route(nil), t.routes...)
+	t.mu.Unlock()
+
+	// 匹配函数由调用者提供, 不持有锁调用, 以免匹配函数中调用Requests或Calls时死锁
+	var matched *Route
+	var params map[string]string
+	for _, r := range routes {
+		var ok bool
+		if params, ok = r.match(req, body); ok && r.take() {
+			matched = r
+			break
+		}
+	}
+
+	t.mu.Lock()
+	t.requests = append(t.requests, RecordedRequest{
+		Method: req.Method,
+		URL:    req.URL,
+		Header: req.Header.Clone(),
+		Body:   body,
+		Route:  matched,
+	})
+	t.mu.Unlock()
+
+	if matched == nil {
+		err = fmt.Errorf("%s %s: %w", req.Method, req.URL, ErrNoRouteMatched)
+		return
+	}
+
+	req = req.WithContext(context.WithValue(req.Context(), paramsKey{}, params))
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return matched.respond(req)
+}
+
+type paramsKey struct{}
+
+// Params 返回路由路径中{name}匹配的参数, 仅在ReplyFunc的函数中可用
+func Params(req *http.Request) map[string]string {
+	params, _ := req.Context().Value(paramsKey{}).(map[string]string)
+	return params
+}
+
+// Route 模拟路由, 设置方法返回自身以便链式调用, 应在开始发送请求前设置完成
+type Route struct {
+	t       *Transport
+	method  string
+	scheme  string
+	host    string
+	path    []string
+	matches []func(req *http.Request, body []byte) bool
+	times   int
+	calls   int // 由Transport.mu保护
+
+	respond func(req *http.Request) (*http.Response, error)
+}
+
+func newRoute(t *Transport, method, pattern string) *Route {
+	r := &Route{t: t, method: strings.ToUpper(method)}
+	if r.method == "*" {
+		r.method = ""
+	}
+
+	if u, err := url.Parse(pattern); err == nil && u.Host != "" {
+		r.scheme, r.host, pattern = u.Scheme, u.Host, u.Path
+	}
+	r.path = splitPath(pattern)
+
+	r.respond = func(req *http.Request) (*http.Response, error) {
+		return NewResponse(req, http.StatusOK, nil, nil), nil
+	}
+
+	return r
+}
+
+// WithQuery 要求请求包含查询参数key=value
+func (r *Route) WithQuery(key, value string) *Route {
+	return r.Match(func(req *http.Request, _ []byte) bool {
+		for _, v := range req.URL.Query()[key] {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// WithHeader 要求请求头key的值为value
+func (r *Route) WithHeader(key, value string) *Route {
+	return r.Match(func(req *http.Request, _ []byte) bool {
+		return req.Header.Get(key) == value
+	})
+}
+
+// WithJSON 要求请求体与v的JSON编码等价, 忽略字段顺序和空白
+func (r *Route) WithJSON(v interface{}) *Route {
+	want, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Errorf("json marshal: %w", err))
+	}
+
+	return r.Match(func(_ *http.Request, body []byte) bool {
+		return jsonEqual(want, body)
+	})
+}
+
+// Match 添加自定义匹配函数, body为请求体
+func (r *Route) Match(f func(req *http.Request, body []byte) bool) *Route {
+	r.matches = append(r.matches, f)
+	return r
+}
+
+// Times 限制路由最多匹配n次, 之后的请求继续匹配后续路由, 可用于模拟先失败后成功的调用
+func (r *Route) Times(n int) *Route {
+	r.times = n
+	return r
+}
+
+// Once 等价于Times(1)
+func (r *Route) Once() *Route {
+	return r.Times(1)
+}
+
+// Reply 返回状态码为status, 响应体为body的响应
+func (r *Route) Reply(status int, body string) *Route {
+	return r.ReplyFunc(func(req *http.Request) (*http.Response, error) {
+		return NewResponse(req, status, nil, []byte(body)), nil
+	})
+}
+
+// ReplyJSON 返回状态码为status, 响应体为v的JSON编码的响应
+func (r *Route) ReplyJSON(status int, v interface{}) *Route {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Errorf("json marshal: %w", err))
+	}
+
+	header := http.Header{"Content-Type": []string{"application/json"}}
+
+	return r.ReplyFunc(func(req *http.Request) (*http.Response, error) {
+		return NewResponse(req, status, header, data), nil
+	})
+}
+
+// ReplyError 以err模拟连接错误
+func (r *Route) ReplyError(err error) *Route {
+	return r.ReplyFunc(func(*http.Request) (*http.Response, error) {
+		return nil, err
+	})
+}
+
+// ReplyFunc 以f生成响应, f中可通过Params获取路径参数
+func (r *Route) ReplyFunc(f func(req *http.Request) (*http.Response, error)) *Route {
+	r.respond = f
+	return r
+}
+
+// Calls 返回路由已匹配的次数
+func (r *Route) Calls() int {
+	r.t.mu.Lock()
+	defer r.t.mu.Unlock()
+
+	return r.calls
+}
+
+// take 在未达到Times限制时增加匹配次数并返回true
+func (r *Route) take() bool {
+	r.t.mu.Lock()
+	defer r.t.mu.Unlock()
+
+	if r.times > 0 && r.calls >= r.times {
+		return false
+	}
+	r.calls++
+
+	return true
+}
+
+// match 检查请求是否匹配路由, 已达到Times限制时跳过自定义匹配函数, 由take在加锁后再次检查
+func (r *Route) match(req *http.Request, body []byte) (params map[string]string, ok bool) {
+	if r.times > 0 && r.Calls() >= r.times {
+		return
+	}
+	if r.method != "" && r.method != req.Method {
+		return
+	}
+	if r.scheme != "" && r.scheme != req.URL.Scheme {
+		return
+	}
+	if r.host != "" && r.host != req.URL.Host {
+		return
+	}
+
+	if params, ok = matchPath(r.path, splitPath(req.URL.Path)); !ok {
+		return
+	}
+
+	for _, f := range r.matches {
+		if !f(req, body) {
+			return nil, false
+		}
+	}
+
+	return
+}
+
+func splitPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+
+	return strings.Split(p, "/")
+}
+
+func matchPath(pattern, path []string) (params map[string]string, ok bool) {
+	params = make(map[string]string)
+	for i, seg := range pattern {
+		if seg == "*" && i == len(pattern)-1 {
+			return params, true
+		}
+		if i >= len(path) {
+			return nil, false
+		}
+
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			params[seg[1:len(seg)-1]] = path[i]
+			continue
+		}
+		if seg != path[i] {
+			return nil, false
+		}
+	}
+
+	if len(path) != len(pattern) {
+		return nil, false
+	}
+
+	return params, true
+}
+
+// NewResponse 创建以req为请求的响应, 可用于ReplyFunc
+func NewResponse(req *http.Request, status int, header http.Header, body []byte) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	} else {
+		header = header.Clone()
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// readBody 读取并关闭请求体
+func readBody(req *http.Request) (body []byte, err error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+
+	body, err = ioutil.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		err = fmt.Errorf("read request body: %w", err)
+		return
+	}
+
+	return
+}
+
+func jsonEqual(a, b []byte) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+
+	na, _ := json.Marshal(va)
+	nb, _ := json.Marshal(vb)
+
+	return bytes.Equal(na, nb)
+}
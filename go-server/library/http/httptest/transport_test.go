@@ -0,0 +1,160 @@
+package httptest
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	libhttp "go-server/library/http"
+)
+
+func newTestContainer(t *testing.T, cf *libhttp.ClientConf) *libhttp.ClientContainer {
+	ct, err := libhttp.NewClientContainer(func() (*libhttp.ClientConf, error) {
+		return cf, nil
+	})
+	if err != nil {
+		t.Fatalf("libhttp.NewClientContainer: %v", err)
+	}
+
+	return ct
+}
+
+func TestTransport(t *testing.T) {
+	tr := NewTransport()
+	tr.On("GET", "/users/{id}").ReplyFunc(func(req *http.Request) (*http.Response, error) {
+		return NewResponse(req, http.StatusOK, nil, []byte("user "+Params(req)["id"])), nil
+	})
+	tr.On("*", "https://api.test/files/*").Reply(http.StatusOK, "file")
+	tr.On("GET", "/search").WithQuery("q", "go").WithHeader("X-A", "v").Reply(http.StatusOK, "found")
+	tr.On("POST", "/orders").WithJSON(map[string]int{"a": 1, "b": 2}).ReplyJSON(http.StatusCreated, map[string]int{"id": 1})
+	tr.On("GET", "/flaky").Once().Reply(http.StatusServiceUnavailable, "down")
+	tr.On("GET", "/flaky").Reply(http.StatusOK, "up")
+	tr.On("GET", "/broken").ReplyError(errors.New("connection reset"))
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		header string
+		body   string
+		status int
+		want   string
+		err    bool
+	}{
+		{name: "path param", method: "GET", url: "http://a.test/users/42", status: 200, want: "user 42"},
+		{name: "full url wildcard", method: "PUT", url: "https://api.test/files/a/b", status: 200, want: "file"},
+		{name: "other host", method: "PUT", url: "https://other.test/files/a", err: true},
+		{name: "query and header", method: "GET", url: "http://a.test/search?q=go", header: "v", status: 200, want: "found"},
+		{name: "query without header", method: "GET", url: "http://a.test/search?q=go", err: true},
+		{name: "json ignores order", method: "POST", url: "http://a.test/orders", body: `{"b": 2, "a": 1}`, status: 201, want: `{"id":1}`},
+		{name: "json mismatch", method: "POST", url: "http://a.test/orders", body: `{"a":2}`, err: true},
+		{name: "once", method: "GET", url: "http://a.test/flaky", status: 503, want: "down"},
+		{name: "after once", method: "GET", url: "http://a.test/flaky", status: 200, want: "up"},
+		{name: "reply error", method: "GET", url: "http://a.test/broken", err: true},
+		{name: "method mismatch", method: "DELETE", url: "http://a.test/users/1", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if tt.header != "" {
+				req.Header.Set("X-A", tt.header)
+			}
+
+			resp, err := tr.RoundTrip(req)
+			if tt.err {
+				assert.NotNil(t, err)
+				return
+			}
+			if assert.Nil(t, err) {
+				data, _ := ioutil.ReadAll(resp.Body)
+				assert.Equal(t, tt.status, resp.StatusCode)
+				assert.Equal(t, tt.want, string(data))
+			}
+		})
+	}
+
+	assert.Len(t, tr.Requests(), len(tests))
+	assert.Len(t, tr.Unmatched(), 4)
+
+	tr.Reset()
+	assert.Empty(t, tr.Requests())
+}
+
+func TestTransportMatchWithoutLock(t *testing.T) {
+	tr := NewTransport()
+
+	// 匹配函数中可以调用Transport和Route的方法
+	var r *Route
+	var seen []int
+	r = tr.On("GET", "/a").Match(func(*http.Request, []byte) bool {
+		seen = append(seen, len(tr.Requests()))
+		return r.Calls() < 2
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			req, _ := http.NewRequest(http.MethodGet, "http://a.test/a", nil)
+			_, _ = tr.RoundTrip(req)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("RoundTrip deadlocked")
+	}
+	assert.Equal(t, 2, r.Calls())
+	assert.Equal(t, []int{0, 1, 2}, seen)
+	assert.Len(t, tr.Unmatched(), 1)
+}
+
+func TestTransportTimesConcurrent(t *testing.T) {
+	tr := NewTransport()
+	once := tr.On("GET", "/a").Once()
+	rest := tr.On("GET", "/a")
+
+	errs := make(chan error, 20)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			req, _ := http.NewRequest(http.MethodGet, "http://a.test/a", nil)
+			_, err := tr.RoundTrip(req)
+			errs <- err
+		}()
+	}
+	for i := 0; i < cap(errs); i++ {
+		assert.Nil(t, <-errs)
+	}
+
+	// 并发请求时Times限制仍然准确
+	assert.Equal(t, 1, once.Calls())
+	assert.Equal(t, cap(errs)-1, rest.Calls())
+}
+
+func TestMock(t *testing.T) {
+	ct := newTestContainer(t, &libhttp.ClientConf{})
+	defer ct.Close()
+
+	t.Run("mock", func(t *testing.T) {
+		tr := Mock(t, ct)
+		tr.On("GET", "/users/{id}").ReplyJSON(http.StatusOK, map[string]string{"name": "a"})
+
+		var got map[string]string
+		assert.Nil(t, ct.NewRequest(context.Background(), http.MethodGet, "http://a.test/users/1").Do(&got))
+		assert.Equal(t, "a", got["name"])
+		assert.Len(t, tr.Requests(), 1)
+	})
+
+	// 测试结束后恢复真实传输
+	err := ct.NewRequest(context.Background(), http.MethodGet, "http://127.0.0.1:1/users/1").Do(nil)
+	assert.NotNil(t, err)
+	assert.False(t, errors.Is(err, ErrNoRouteMatched))
+}
@@ -0,0 +1,104 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go-server/library/log"
+)
+
+// RoundTripperFunc 以函数实现http.RoundTripper
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Interceptor 客户端拦截器, 包装next返回新的http.RoundTripper, 用于请求签名, 日志, 指标等出站的通用处理,
+// 拦截器位于重试和熔断之内, 每次重试都会经过拦截器. 链路追踪不属于拦截器链, 客户端总是在重试之外为每次调用创建span
+// 并写入链路请求头, 无需也无法通过拦截器开启或关闭
+type Interceptor func(next http.RoundTripper) http.RoundTripper
+
+// Chain 将多个拦截器组合为一个, 第一个拦截器位于最外层
+func Chain(interceptors ...Interceptor) Interceptor {
+	return func(next http.RoundTripper) http.RoundTripper {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			next = interceptors[i](next)
+		}
+		return next
+	}
+}
+
+type routeKey struct{}
+
+// WithRoute 返回携带路由模板的ctx, 如/users/{id}, 用于按路由聚合日志和指标, 避免以原始路径作为指标标签
+func WithRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, routeKey{}, route)
+}
+
+// Route 返回请求的路由模板, 未设置时返回空字符串
+func Route(req *http.Request) string {
+	route, _ := req.Context().Value(routeKey{}).(string)
+	return route
+}
+
+// LogInterceptor 记录每次请求的方法, HOST, 路径, 路由模板, 状态码和耗时, fields为附加的日志字段,
+// 请求失败或状态码为5xx时以Error级别记录, 否则以Info级别记录, 不记录查询参数和请求体以免泄露敏感信息
+func LogInterceptor(logger *log.LoggerContainer, fields log.F) Interceptor {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (resp *http.Response, err error) {
+			start := time.Now()
+			resp, err = next.RoundTrip(req)
+
+			f := log.F{
+				"method":     req.Method,
+				"host":       req.URL.Host,
+				"path":       req.URL.Path,
+				"route":      Route(req),
+				"latency_ms": time.Since(start).Milliseconds(),
+			}
+			for k, v := range fields {
+				f[k] = v
+			}
+
+			ctx := req.Context()
+			switch {
+			case err != nil:
+				f["error"] = err.Error()
+				logger.ErrorContext(ctx, f, "http client request failed")
+			case resp.StatusCode >= http.StatusInternalServerError:
+				f["status"] = resp.StatusCode
+				logger.ErrorContext(ctx, f, "http client request failed")
+			default:
+				f["status"] = resp.StatusCode
+				logger.InfoContext(ctx, f, "http client request")
+			}
+
+			return
+		})
+	}
+}
+
+// MetricsInterceptor 以请求的HOST, 路由模板, 方法和状态码记录每次请求的耗时到recorder, 请求失败时状态码为0
+func MetricsInterceptor(recorder MetricsRecorder) Interceptor {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (resp *http.Response, err error) {
+			start := time.Now()
+			resp, err = next.RoundTrip(req)
+
+			status := 0
+			if err == nil {
+				status = resp.StatusCode
+			}
+			recorder.ObserveRequest(RequestLabels{
+				Host:   req.URL.Host,
+				Route:  Route(req),
+				Method: req.Method,
+				Status: status,
+			}, time.Since(start))
+
+			return
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go-server/library/log"
+)
+
+// recordInterceptor 记录经过的拦截器名称, 并以名称为值追加X-Chain请求头
+func recordInterceptor(name string, order *[]string) Interceptor {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			*order = append(*order, name)
+			req = req.Clone(req.Context())
+			req.Header.Add("X-Chain", name)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+func TestInterceptors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Chain", strings.Join(r.Header.Values("X-Chain"), ","))
+		if strings.HasPrefix(r.URL.Path, "/fail") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name      string
+		use       [][]string // 每次调用Use追加的拦截器
+		wantOrder string
+	}{
+		{name: "none", wantOrder: ""},
+		{name: "single", use: [][]string{{"a"}}, wantOrder: "a"},
+		{name: "first is outermost", use: [][]string{{"a", "b", "c"}}, wantOrder: "a,b,c"},
+		{name: "later use is inner", use: [][]string{{"a"}, {"b"}}, wantOrder: "a,b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := newTestClient(t)
+
+			var order []string
+			for _, names := range tt.use {
+				var interceptors []Interceptor
+				for _, name := range names {
+					interceptors = append(interceptors, recordInterceptor(name, &order))
+				}
+				cli.Use(interceptors...)
+			}
+
+			resp, err := cli.NewRequest(context.Background(), http.MethodGet, srv.URL).Send()
+			if assert.Nil(t, err) {
+				_ = resp.Body.Close()
+				assert.Equal(t, tt.wantOrder, resp.Header.Get("X-Chain"))
+			}
+			assert.Equal(t, tt.wantOrder, strings.Join(order, ","))
+		})
+	}
+
+	// 每次重试都经过拦截器
+	cli, err := NewClient(&ClientConf{Retry: RetryPolicy{MaxRetries: 2, BackoffMillisecond: 1}})
+	assert.Nil(t, err)
+	defer cli.Close()
+
+	var order []string
+	cli.Use(recordInterceptor("a", &order))
+	assert.Equal(t, 503, StatusCode(cli.NewRequest(context.Background(), http.MethodGet, srv.URL+"/fail").Do(nil)))
+	assert.Equal(t, []string{"a", "a", "a"}, order)
+}
+
+func TestMetricsInterceptor(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	m := NewLatencyMetrics()
+	cli := newTestClient(t)
+	cli.Use(MetricsInterceptor(m))
+
+	ctx := context.Background()
+	assert.Nil(t, cli.NewRequest(ctx, http.MethodGet, srv.URL+"/users/1").Route("/users/{id}").Do(nil))
+	assert.Nil(t, cli.NewRequest(ctx, http.MethodGet, srv.URL+"/users/2").Route("/users/{id}").Do(nil))
+	assert.NotNil(t, cli.NewRequest(ctx, http.MethodPost, srv.URL+"/fail").Do(nil))
+
+	// 请求失败时状态码为0
+	cli.SetTransport(RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}))
+	assert.NotNil(t, cli.NewRequest(ctx, http.MethodGet, srv.URL+"/down").Do(nil))
+
+	host := strings.TrimPrefix(srv.URL, "http://")
+	var labels []RequestLabels
+	var counts []int64
+	for _, st := range m.Snapshot() {
+		labels = append(labels, st.RequestLabels)
+		counts = append(counts, st.Count)
+		assert.Equal(t, st.Count, st.Counts[len(st.Counts)-1])
+	}
+	assert.Equal(t, []RequestLabels{
+		{Host: host, Route: "", Method: http.MethodGet, Status: 0},
+		{Host: host, Route: "", Method: http.MethodPost, Status: 503},
+		{Host: host, Route: "/users/{id}", Method: http.MethodGet, Status: 200},
+	}, labels)
+	assert.Equal(t, []int64{1, 1, 2}, counts)
+
+	m.Reset()
+	assert.Empty(t, m.Snapshot())
+}
+
+func TestLogInterceptor(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	file := filepath.Join(t.TempDir(), "client.log")
+	logger, err := log.NewLoggerContainer(func() (*log.LoggerConf, error) {
+		return &log.LoggerConf{Level: "info", Output: file}, nil
+	})
+	if err != nil {
+		t.Fatalf("log.NewLoggerContainer: %v", err)
+	}
+	defer logger.Close()
+
+	cli := newTestClient(t)
+	cli.Use(LogInterceptor(logger, log.F{"client": "test"}))
+
+	ctx := context.Background()
+	assert.Nil(t, cli.NewRequest(ctx, http.MethodGet, srv.URL+"/ok?token=secret").Do(nil))
+	assert.NotNil(t, cli.NewRequest(ctx, http.MethodGet, srv.URL+"/fail").Route("/fail").Do(nil))
+
+	data, err := ioutil.ReadFile(file)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "secret")
+
+	tests := []struct {
+		level  string
+		path   string
+		route  string
+		status float64
+	}{
+		{level: "info", path: "/ok", status: 200},
+		{level: "error", path: "/fail", route: "/fail", status: 500},
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if assert.Len(t, lines, len(tests)) {
+		for i, tt := range tests {
+			var entry map[string]interface{}
+			assert.Nil(t, json.Unmarshal([]byte(lines[i]), &entry))
+			assert.Equal(t, tt.level, entry["level"])
+			assert.Equal(t, tt.path, entry["path"])
+			assert.Equal(t, tt.route, entry["route"])
+			assert.Equal(t, tt.status, entry["status"])
+			assert.Equal(t, "test", entry["client"])
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package http
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// RequestLabels 请求指标的标签
+type RequestLabels struct {
+	Host   string // 请求的HOST, 包括端口
+	Route  string // 路由模板, 见WithRoute
+	Method string // 请求方法
+	Status int    // 响应状态码, 请求失败时为0
+}
+
+// MetricsRecorder 记录请求指标, 实现需并发安全
+type MetricsRecorder interface {
+	ObserveRequest(labels RequestLabels, elapsed time.Duration)
+}
+
+// DefaultLatencyBuckets 默认的耗时分桶上限
+var DefaultLatencyBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// LatencyStat 一组标签下的耗时统计
+type LatencyStat struct {
+	RequestLabels
+
+	Count   int64           // 请求数
+	Sum     time.Duration   // 耗时总和
+	Max     time.Duration   // 最大耗时
+	Buckets []time.Duration // 分桶上限
+	Counts  []int64         // 各分桶中耗时不超过上限的请求数(累计), 超出最后一个上限的请求只计入Count
+}
+
+// LatencyMetrics 进程内按标签聚合的耗时指标, 实现了MetricsRecorder, 用于测试或不接入Prometheus的场景,
+// 以Prometheus格式暴露时使用metrics.Metrics作为MetricsRecorder
+type LatencyMetrics struct {
+	mu      sync.Mutex
+	buckets []time.Duration
+	stats   map[RequestLabels]*LatencyStat
+}
+
+// NewLatencyMetrics 创建耗时指标, buckets为升序的分桶上限, 为空时使用DefaultLatencyBuckets
+func NewLatencyMetrics(buckets ...time.Duration) *LatencyMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+
+	return &LatencyMetrics{
+		buckets: append([]time.Duration(nil), buckets...),
+		stats:   make(map[RequestLabels]*LatencyStat),
+	}
+}
+
+func (m *LatencyMetrics) ObserveRequest(labels RequestLabels, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st, ok := m.stats[labels]
+	if !ok {
+		st = &LatencyStat{
+			RequestLabels: labels,
+			Buckets:       m.buckets,
+			Counts:        make([]int64, len(m.buckets)),
+		}
+		m.stats[labels] = st
+	}
+
+	st.Count++
+	st.Sum += elapsed
+	if elapsed > st.Max {
+		st.Max = elapsed
+	}
+	for i, b := range m.buckets {
+		if elapsed <= b {
+			st.Counts[i]++
+		}
+	}
+}
+
+// Snapshot 返回当前所有标签的统计副本, 按HOST, 路由模板, 方法和状态码排序
+func (m *LatencyMetrics) Snapshot() (stats []LatencyStat) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, st := range m.stats {
+		cp := *st
+		cp.Counts = append([]int64(nil), st.Counts...)
+		stats = append(stats, cp)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := stats[i].RequestLabels, stats[j].RequestLabels
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Route != b.Route {
+			return a.Route < b.Route
+		}
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		return a.Status < b.Status
+	})
+
+	return
+}
+
+// Reset 清空所有统计
+func (m *LatencyMetrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stats = make(map[RequestLabels]*LatencyStat)
+}
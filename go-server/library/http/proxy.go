@@ -0,0 +1,103 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// proxyDirect 代理规则中表示不使用代理的取值
+const proxyDirect = "direct"
+
+type proxyRule struct {
+	host  string   // 完整HOST, 或以*.开头匹配子域名, 为*时匹配所有HOST
+	proxy *url.URL // 为nil时不使用代理
+}
+
+func (r proxyRule) match(host string) bool {
+	switch {
+	case r.host == "*":
+		return true
+	case strings.HasPrefix(r.host, "*."):
+		return strings.HasSuffix(host, r.host[1:])
+	default:
+		return host == r.host
+	}
+}
+
+// parseProxyRules 解析"host=url,*.suffix=url,host=direct"格式的代理规则
+func parseProxyRules(rules string) (rs []proxyRule, err error) {
+	for _, item := range strings.Split(rules, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			err = fmt.Errorf("invalid proxy rule %q", item)
+			return
+		}
+
+		r := proxyRule{host: strings.ToLower(strings.TrimSpace(kv[0]))}
+		if v := strings.TrimSpace(kv[1]); !strings.EqualFold(v, proxyDirect) {
+			if r.proxy, err = parseProxyURL(v); err != nil {
+				return
+			}
+		}
+
+		rs = append(rs, r)
+	}
+
+	return
+}
+
+func parseProxyURL(v string) (u *url.URL, err error) {
+	if u, err = url.Parse(v); err != nil {
+		err = fmt.Errorf("parse proxy url %q: %w", v, err)
+		return
+	}
+	if u.Scheme == "" || u.Host == "" {
+		err = fmt.Errorf("invalid proxy url %q", v)
+		return
+	}
+
+	return
+}
+
+// newProxyFunc 返回http.Transport的Proxy函数, 按顺序匹配rules, 都不匹配时使用defaultProxy,
+// defaultProxy为空时使用环境变量HTTP_PROXY, HTTPS_PROXY和NO_PROXY
+func newProxyFunc(defaultProxy, rules string) (f func(*http.Request) (*url.URL, error), err error) {
+	rs, err := parseProxyRules(rules)
+	if err != nil {
+		return
+	}
+
+	fallback := http.ProxyFromEnvironment
+	if defaultProxy != "" {
+		var u *url.URL
+		if u, err = parseProxyURL(defaultProxy); err != nil {
+			return
+		}
+		fallback = http.ProxyURL(u)
+	}
+
+	if len(rs) == 0 {
+		f = fallback
+		return
+	}
+
+	f = func(req *http.Request) (*url.URL, error) {
+		host := strings.ToLower(req.URL.Hostname())
+		for _, r := range rs {
+			if r.match(host) {
+				return r.proxy, nil
+			}
+		}
+
+		return fallback(req)
+	}
+
+	return
+}
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyRules(t *testing.T) {
+	f, err := newProxyFunc("http://default:3128", "a.com=http://pa:1, *.b.com=direct, C.com=http://pc:2, *.com=http://wild:3")
+	assert.Nil(t, err)
+
+	tests := []struct {
+		url  string
+		want string // 为空时不使用代理
+	}{
+		{url: "http://a.com:8080/x", want: "http://pa:1"},
+		{url: "https://x.b.com", want: ""},
+		{url: "https://y.x.b.com", want: ""},
+		{url: "http://c.com", want: "http://pc:2"},
+		{url: "http://other.com", want: "http://wild:3"},
+		{url: "http://b.com", want: "http://wild:3"},
+		{url: "http://z.org", want: "http://default:3128"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+			proxy, err := f(req)
+			assert.Nil(t, err)
+
+			var got string
+			if proxy != nil {
+				got = proxy.String()
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestProxyRulesInvalid(t *testing.T) {
+	tests := []struct {
+		name         string
+		defaultProxy string
+		rules        string
+	}{
+		{name: "missing proxy", rules: "a.com"},
+		{name: "missing host", rules: "=http://p:1"},
+		{name: "proxy without scheme", rules: "a.com=p:1"},
+		{name: "invalid default proxy", defaultProxy: "p"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newProxyFunc(tt.defaultProxy, tt.rules)
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestClientProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("proxied " + r.URL.Host))
+	}))
+	defer proxy.Close()
+
+	cli, err := NewClient(&ClientConf{ProxyRules: "target.test=" + proxy.URL})
+	assert.Nil(t, err)
+	defer cli.Close()
+
+	// 匹配规则的请求经代理发送
+	data, err := cli.NewRequest(context.Background(), http.MethodGet, "http://target.test/x").Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, "proxied target.test", string(data))
+}
@@ -0,0 +1,312 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Doer 发送HTTP请求, *http.Client实现了该接口
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// MultipartFile multipart请求中的文件
+type MultipartFile struct {
+	FieldName   string    // 表单字段名
+	FileName    string    // 文件名
+	ContentType string    // 文件内容类型, 为空时使用application/octet-stream
+	Reader      io.Reader // 文件内容
+}
+
+// Request HTTP请求构造器, 各设置方法返回自身以便链式调用, 构造过程中的错误在发送时返回,
+// 同一Request只应发送一次
+type Request struct {
+	doer   Doer
+	ctx    context.Context
+	method string
+	url    string
+	query  url.Values
+	header http.Header
+	body   func() (body io.Reader, contentType string, err error)
+
+	// okOnly 仅状态码200视为成功且响应体不能为空, 用于保持Client.Get和Client.Post原有的行为
+	okOnly bool
+}
+
+// NewRequest 创建请求构造器, method为http.MethodGet等请求方法, ctx为nil时使用context.Background()
+func (cli *Client) NewRequest(ctx context.Context, method, rawURL string) *Request {
+	return newRequest(cli.Client, ctx, method, rawURL)
+}
+
+func newRequest(doer Doer, ctx context.Context, method, rawURL string) *Request {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return &Request{
+		doer:   doer,
+		ctx:    ctx,
+		method: method,
+		url:    rawURL,
+		query:  url.Values{},
+		header: http.Header{},
+	}
+}
+
+// Query 追加查询参数, 与URL中已有的查询参数合并
+func (r *Request) Query(key, value string) *Request {
+	r.query.Add(key, value)
+	return r
+}
+
+// SetQuery 追加多个查询参数
+func (r *Request) SetQuery(query url.Values) *Request {
+	for k, vs := range query {
+		for _, v := range vs {
+			r.query.Add(k, v)
+		}
+	}
+
+	return r
+}
+
+// Header 设置请求头, 覆盖同名请求头
+func (r *Request) Header(key, value string) *Request {
+	r.header.Set(key, value)
+	return r
+}
+
+// SetHeader 设置多个请求头, 覆盖同名请求头
+func (r *Request) SetHeader(header http.Header) *Request {
+	for k, vs := range header {
+		r.header[http.CanonicalHeaderKey(k)] = append([]string(nil), vs...)
+	}
+
+	return r
+}
+
+// Route 设置路由模板, 如/users/{id}, 用于按路由聚合日志和指标, 见WithRoute
+func (r *Request) Route(route string) *Request {
+	r.ctx = WithRoute(r.ctx, route)
+	return r
+}
+
+// BasicAuth 设置HTTP基本认证
+func (r *Request) BasicAuth(username, password string) *Request {
+	auth := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	return r.Header("Authorization", "Basic "+auth)
+}
+
+// BearerToken 设置Bearer令牌认证
+func (r *Request) BearerToken(token string) *Request {
+	return r.Header("Authorization", "Bearer "+token)
+}
+
+// Body 以reader作为请求体, contentType为空时不设置Content-Type
+func (r *Request) Body(reader io.Reader, contentType string) *Request {
+	r.body = func() (io.Reader, string, error) {
+		return reader, contentType, nil
+	}
+
+	return r
+}
+
+// JSON 以v的JSON编码作为请求体
+func (r *Request) JSON(v interface{}) *Request {
+	r.body = func() (body io.Reader, contentType string, err error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			err = fmt.Errorf("json marshal: %w", err)
+			return
+		}
+
+		return bytes.NewReader(data), defaultContentType, nil
+	}
+
+	return r
+}
+
+// Form 以URL编码的表单作为请求体
+func (r *Request) Form(values url.Values) *Request {
+	r.body = func() (io.Reader, string, error) {
+		return strings.NewReader(values.Encode()), "application/x-www-form-urlencoded", nil
+	}
+
+	return r
+}
+
+// Multipart 以multipart/form-data表单作为请求体, 表单在发送前完整写入内存
+func (r *Request) Multipart(fields url.Values, files ...MultipartFile) *Request {
+	r.body = func() (body io.Reader, contentType string, err error) {
+		buf := &bytes.Buffer{}
+		mw := multipart.NewWriter(buf)
+
+		for k, vs := range fields {
+			for _, v := range vs {
+				if err = mw.WriteField(k, v); err != nil {
+					err = fmt.Errorf("write field %s: %w", k, err)
+					return
+				}
+			}
+		}
+
+		for _, f := range files {
+			if err = writeMultipartFile(mw, f); err != nil {
+				err = fmt.Errorf("write file %s: %w", f.FieldName, err)
+				return
+			}
+		}
+
+		if err = mw.Close(); err != nil {
+			err = fmt.Errorf("multipart close: %w", err)
+			return
+		}
+
+		return bytes.NewReader(buf.Bytes()), mw.FormDataContentType(), nil
+	}
+
+	return r
+}
+
+func writeMultipartFile(mw *multipart.Writer, f MultipartFile) (err error) {
+	contentType := f.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	h := make(map[string][]string)
+	h["Content-Disposition"] = []string{fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		escapeQuotes(f.FieldName), escapeQuotes(f.FileName))}
+	h["Content-Type"] = []string{contentType}
+
+	w, err := mw.CreatePart(h)
+	if err != nil {
+		return
+	}
+
+	_, err = io.Copy(w, f.Reader)
+
+	return
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+// Build 构造*http.Request, 链路请求头由客户端发送请求时写入
+func (r *Request) Build() (req *http.Request, err error) {
+	u, err := url.Parse(r.url)
+	if err != nil {
+		err = fmt.Errorf("url parse: %w", err)
+		return
+	}
+
+	if len(r.query) > 0 {
+		q := u.Query()
+		for k, vs := range r.query {
+			for _, v := range vs {
+				q.Add(k, v)
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+
+	var body io.Reader
+	var contentType string
+	if r.body != nil {
+		if body, contentType, err = r.body(); err != nil {
+			return
+		}
+	}
+
+	if req, err = http.NewRequestWithContext(r.ctx, r.method, u.String(), body); err != nil {
+		err = fmt.Errorf("http.NewRequestWithContext: %w", err)
+		return
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for k, vs := range r.header {
+		req.Header[k] = vs
+	}
+
+	return
+}
+
+// Send 发送请求并返回原始响应, 不检查状态码, 调用方负责关闭响应体
+func (r *Request) Send() (resp *http.Response, err error) {
+	req, err := r.Build()
+	if err != nil {
+		err = fmt.Errorf("build request: %w", err)
+		return
+	}
+
+	if resp, err = r.doer.Do(req); err != nil {
+		err = fmt.Errorf("%s %s: %w", r.method, redactURL(r.url), err)
+		return
+	}
+
+	return
+}
+
+// Do 发送请求, 状态码为2xx时将JSON响应体解码到respst, respst为nil或响应体为空时不解码,
+// 其他状态码返回*StatusError, 响应体总是在返回前关闭
+func (r *Request) Do(respst interface{}) (err error) {
+	resp, err := r.Send()
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if err = checkStatus(resp, r.method, redactURL(r.url), r.okOnly); err != nil {
+		return
+	}
+
+	if respst == nil || resp.StatusCode == http.StatusNoContent {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(respst); err != nil {
+		if err == io.EOF && !r.okOnly {
+			err = nil
+			return
+		}
+		err = fmt.Errorf("json decode: %w", err)
+		return
+	}
+
+	return
+}
+
+// Bytes 发送请求, 状态码为2xx时返回响应体, 其他状态码返回*StatusError
+func (r *Request) Bytes() (data []byte, err error) {
+	resp, err := r.Send()
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if err = checkStatus(resp, r.method, redactURL(r.url), r.okOnly); err != nil {
+		return
+	}
+
+	if data, err = ioutil.ReadAll(resp.Body); err != nil {
+		err = fmt.Errorf("read body: %w", err)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,214 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// echo 以JSON返回请求的方法, 查询参数, 认证信息, X-A请求头, Content-Type和表单内容
+func echo(w http.ResponseWriter, r *http.Request) {
+	user, pass, _ := r.BasicAuth()
+
+	var form string
+	switch ct := r.Header.Get("Content-Type"); {
+	case strings.HasPrefix(ct, "multipart/"):
+		_ = r.ParseMultipartForm(1 << 20)
+		f, fh, _ := r.FormFile("f")
+		data, _ := ioutil.ReadAll(f)
+		form = r.FormValue("a") + "," + fh.Filename + "," + string(data)
+	case ct == "application/x-www-form-urlencoded":
+		_ = r.ParseForm()
+		form = r.PostForm.Get("x")
+	default:
+		data, _ := ioutil.ReadAll(r.Body)
+		form = string(data)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"method": r.Method,
+		"query":  r.URL.RawQuery,
+		"auth":   user + ":" + pass,
+		"header": r.Header.Get("X-A"),
+		"type":   strings.SplitN(r.Header.Get("Content-Type"), ";", 2)[0],
+		"body":   form,
+	})
+}
+
+func newTestClient(t *testing.T) *Client {
+	cli, err := NewClient(&ClientConf{})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cli.Close()
+	})
+
+	return cli
+}
+
+func TestRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(echo))
+	defer srv.Close()
+
+	cli := newTestClient(t)
+
+	tests := []struct {
+		name string
+		req  *Request
+		want map[string]string
+	}{
+		{
+			name: "query and headers",
+			req: cli.NewRequest(context.Background(), http.MethodPatch, srv.URL+"?z=1").
+				Query("a", "b").SetQuery(url.Values{"c": {"d"}}).BasicAuth("u", "p").Header("X-A", "v"),
+			want: map[string]string{"method": "PATCH", "query": "a=b&c=d&z=1", "auth": "u:p", "header": "v"},
+		},
+		{
+			name: "json",
+			req:  cli.NewRequest(context.Background(), http.MethodPut, srv.URL).JSON(map[string]int{"a": 1}),
+			want: map[string]string{"method": "PUT", "type": "application/json", "body": `{"a":1}`},
+		},
+		{
+			name: "form",
+			req:  cli.NewRequest(nil, http.MethodPost, srv.URL).Form(url.Values{"x": {"y"}}),
+			want: map[string]string{"type": "application/x-www-form-urlencoded", "body": "y"},
+		},
+		{
+			name: "multipart",
+			req: cli.NewRequest(nil, http.MethodPost, srv.URL).Multipart(url.Values{"a": {"1"}},
+				MultipartFile{FieldName: "f", FileName: "n.txt", Reader: strings.NewReader("data")}),
+			want: map[string]string{"type": "multipart/form-data", "body": "1,n.txt,data"},
+		},
+		{
+			name: "body and bearer token",
+			req: cli.NewRequest(nil, http.MethodDelete, srv.URL).
+				Body(strings.NewReader("raw"), "text/plain").BearerToken("t").Header("X-A", "w"),
+			want: map[string]string{"method": "DELETE", "type": "text/plain", "body": "raw", "header": "w"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]string
+			assert.Nil(t, tt.req.Do(&got))
+			for k, v := range tt.want {
+				assert.Equal(t, v, got[k], k)
+			}
+		})
+	}
+}
+
+func TestRequestStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/created":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":1}`))
+		case "/empty":
+		case "/nocontent":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(strings.Repeat("x", 2*maxErrorBodyLen)))
+		}
+	}))
+	defer srv.Close()
+
+	cli := newTestClient(t)
+
+	tests := []struct {
+		name   string
+		path   string
+		status int // 期望的StatusError状态码, 0表示成功
+	}{
+		{name: "created", path: "/created"},
+		{name: "empty body", path: "/empty"},
+		{name: "no content", path: "/nocontent"},
+		{name: "unavailable", path: "/unavailable", status: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]int
+			err := cli.NewRequest(context.Background(), http.MethodGet, srv.URL+tt.path).Do(&got)
+			assert.Equal(t, tt.status, StatusCode(err))
+			if tt.status == 0 {
+				assert.Nil(t, err)
+				return
+			}
+
+			var se *StatusError
+			if assert.True(t, errors.As(err, &se)) {
+				assert.Equal(t, http.MethodGet, se.Method)
+				assert.Equal(t, srv.URL+tt.path, se.URL)
+				assert.Len(t, se.Body, maxErrorBodyLen)
+				assert.Less(t, len(se.Error()), maxErrorMessageBodyLen+len(se.URL)+64)
+			}
+		})
+	}
+}
+
+func TestClientGetPost(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/created":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{}`))
+		case "/empty":
+		default:
+			echo(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	cli := newTestClient(t)
+
+	// Get的query替换URL中已有的查询参数
+	var got map[string]string
+	assert.Nil(t, cli.Get(srv.URL+"?z=1", url.Values{"a": {"b"}}, &got))
+	assert.Equal(t, "a=b", got["query"])
+
+	assert.Nil(t, cli.Post(srv.URL, map[string]int{"a": 1}, &got))
+	assert.Equal(t, "POST", got["method"])
+	assert.Equal(t, `{"a":1}`, got["body"])
+
+	// 200以外的2xx状态码和空响应体均返回错误
+	err := cli.Get(srv.URL+"/created", nil, &got)
+	assert.Equal(t, http.StatusCreated, StatusCode(err))
+	err = cli.Post(srv.URL+"/created", nil, &got)
+	assert.Equal(t, http.StatusCreated, StatusCode(err))
+	assert.NotNil(t, cli.Get(srv.URL+"/empty", nil, &got))
+}
+
+func TestRequestRedactURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	u, _ := url.Parse(srv.URL)
+	u.User = url.UserPassword("user", "secret")
+	rawURL := u.String()
+
+	cli := newTestClient(t)
+
+	// 响应的错误信息中不包含密码
+	err := cli.NewRequest(context.Background(), http.MethodGet, rawURL).Do(nil)
+	assert.Equal(t, http.StatusForbidden, StatusCode(err))
+	assert.NotContains(t, err.Error(), "secret")
+	assert.Contains(t, err.Error(), "user")
+
+	// 发送失败的错误信息中不包含密码
+	srv.Close()
+	_, err = cli.NewRequest(context.Background(), http.MethodGet, rawURL).Send()
+	assert.NotNil(t, err)
+	assert.NotContains(t, err.Error(), "secret")
+}
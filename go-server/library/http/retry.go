@@ -0,0 +1,332 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+var (
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+)
+
+const (
+	defaultRetryBackoff    = 100 * time.Millisecond
+	defaultRetryMaxBackoff = 10 * time.Second
+	defaultBreakerOpen     = 30 * time.Second
+
+	// maxDrainBodyLen 重试前读取并丢弃的响应体最大字节数, 超出时直接关闭连接
+	maxDrainBodyLen = 64 << 10
+)
+
+// RetryPolicy 请求重试策略, 仅重试幂等请求(GET, HEAD, OPTIONS, TRACE, PUT, DELETE或带Idempotency-Key请求头的请求),
+// 在连接错误或响应状态码为429, 5xx(501除外)时按指数退避加随机抖动等待后重试, 响应带Retry-After时按其等待
+type RetryPolicy struct {
+	MaxRetries            int // 最大重试次数, 为0时不重试
+	BackoffMillisecond    int // 首次重试前的最大等待时长, 之后每次加倍, 默认100
+	MaxBackoffMillisecond int // 等待时长上限, Retry-After超过该值时不再重试, 默认10000
+}
+
+// BreakerPolicy 按请求的HOST熔断的策略, 连续失败(连接错误或5xx响应)达到阈值后熔断,
+// 熔断期间请求直接返回ErrCircuitOpen, 熔断时长过后进入半开状态, 放行一个探测请求, 成功时恢复, 失败时重新熔断
+type BreakerPolicy struct {
+	FailureThreshold int // 熔断的连续失败次数阈值, 为0时不熔断
+	OpenSecond       int // 熔断时长, 默认30
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	base := time.Duration(p.BackoffMillisecond) * time.Millisecond
+	if base <= 0 {
+		base = defaultRetryBackoff
+	}
+
+	max := p.maxBackoff()
+	d := base << uint(attempt)
+	if d <= 0 || d > max {
+		d = max
+	}
+
+	// 在[d/2, d)内随机等待, 避免多个客户端同时重试
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func (p RetryPolicy) maxBackoff() time.Duration {
+	if p.MaxBackoffMillisecond <= 0 {
+		return defaultRetryMaxBackoff
+	}
+
+	return time.Duration(p.MaxBackoffMillisecond) * time.Millisecond
+}
+
+func (p BreakerPolicy) openDuration() time.Duration {
+	if p.OpenSecond <= 0 {
+		return defaultBreakerOpen
+	}
+
+	return time.Duration(p.OpenSecond) * time.Second
+}
+
+type clientPolicy struct {
+	retry   RetryPolicy
+	breaker BreakerPolicy
+}
+
+// policyTransport 在拦截器链之上实现重试和熔断, 策略和拦截器从Client读取, 因此可以在不替换客户端的情况下更新
+type policyTransport struct {
+	cli *Client
+}
+
+// RoundTrip 按策略发送请求, 请求context携带span上下文时为请求创建一个包含所有重试的客户端span, 并写入链路请求头
+func (t *policyTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	p := t.cli.getPolicy()
+
+	req, span := startClientSpan(req)
+	if span != nil {
+		defer func() { endClientSpan(span, resp, err) }()
+	}
+
+	canRetry := p.retry.MaxRetries > 0 && isIdempotent(req) &&
+		(req.Body == nil || req.Body == http.NoBody || req.GetBody != nil)
+
+	for attempt := 0; ; attempt++ {
+		r := req
+		if attempt > 0 {
+			if r, err = rewindRequest(req); err != nil {
+				return
+			}
+			if span != nil {
+				span.AddEvent("retry", oteltrace.WithAttributes(attribute.Int("attempt", attempt)))
+			}
+		}
+
+		resp, err = t.roundTrip(r, p.breaker)
+		if !canRetry || attempt >= p.retry.MaxRetries || !shouldRetry(req, resp, err) {
+			return
+		}
+
+		wait := p.retry.backoff(attempt)
+		if resp != nil {
+			if after, ok := retryAfter(resp); ok {
+				if after > p.retry.maxBackoff() {
+					return
+				}
+				wait = after
+			}
+			drainBody(resp)
+		}
+
+		if serr := sleepContext(req.Context(), wait); serr != nil {
+			if err != nil {
+				err = fmt.Errorf("%v, retry aborted: %w", err, serr)
+			} else {
+				err = serr
+			}
+			resp = nil
+			return
+		}
+	}
+}
+
+// CloseIdleConnections 关闭底层连接池的空闲连接, 使http.Client.CloseIdleConnections生效
+func (t *policyTransport) CloseIdleConnections() {
+	t.cli.transport.CloseIdleConnections()
+}
+
+// roundTrip 经熔断器发送一次请求
+func (t *policyTransport) roundTrip(req *http.Request, p BreakerPolicy) (resp *http.Response, err error) {
+	next := t.cli.roundTripper()
+	if p.FailureThreshold <= 0 {
+		return next.RoundTrip(req)
+	}
+
+	b := t.cli.breaker(req.URL.Host)
+	allowed, probe := b.allow(p)
+	if !allowed {
+		err = fmt.Errorf("host %s: %w", req.URL.Host, ErrCircuitOpen)
+		return
+	}
+
+	resp, err = next.RoundTrip(req)
+
+	switch {
+	case err != nil && req.Context().Err() != nil:
+		// 调用方取消的请求不计入成败
+		b.record(p, probe, breakerUnknown)
+	case err != nil || resp.StatusCode >= http.StatusInternalServerError:
+		b.record(p, probe, breakerFailure)
+	default:
+		b.record(p, probe, breakerSuccess)
+	}
+
+	return
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+func shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		return req.Context().Err() == nil && !errors.Is(err, ErrCircuitOpen)
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode >= http.StatusInternalServerError && resp.StatusCode != http.StatusNotImplemented)
+}
+
+// rewindRequest 以GetBody重新获取请求体, 返回可再次发送的请求副本
+func rewindRequest(req *http.Request) (r *http.Request, err error) {
+	r = req.Clone(req.Context())
+	if req.GetBody == nil {
+		return
+	}
+
+	if r.Body, err = req.GetBody(); err != nil {
+		err = fmt.Errorf("get body: %w", err)
+		return
+	}
+
+	return
+}
+
+// retryAfter 解析Retry-After响应头, 支持秒数和HTTP日期两种格式
+func retryAfter(resp *http.Response) (d time.Duration, ok bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return
+	}
+
+	if sec, err := strconv.Atoi(v); err == nil {
+		if sec < 0 {
+			sec = 0
+		}
+		return time.Duration(sec) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d = time.Until(t); d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+
+	return
+}
+
+// drainBody 读取并关闭响应体以便复用连接
+func drainBody(resp *http.Response) {
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxDrainBodyLen))
+	_ = resp.Body.Close()
+}
+
+func sleepContext(ctx context.Context, d time.Duration) (err error) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timer.C:
+	}
+
+	return
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+type breakerResult int
+
+const (
+	breakerSuccess breakerResult = iota
+	breakerFailure
+	breakerUnknown
+)
+
+// circuitBreaker 单个HOST的熔断器
+type circuitBreaker struct {
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// allow 返回是否放行请求以及放行的是否为探测请求, 半开状态下同时只放行一个探测请求
+func (b *circuitBreaker) allow(p BreakerPolicy) (allowed, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < p.openDuration() {
+			return
+		}
+		b.state = breakerHalfOpen
+	case breakerHalfOpen:
+	default:
+		allowed = true
+		return
+	}
+
+	if b.probing {
+		return
+	}
+	b.probing = true
+
+	return true, true
+}
+
+// record 记录请求结果, 半开状态下仅探测请求的结果决定恢复或重新熔断
+func (b *circuitBreaker) record(p BreakerPolicy, probe bool, rst breakerResult) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// 熔断前发出的请求在熔断后完成时不影响状态
+	if b.state == breakerOpen {
+		return
+	}
+
+	if b.state == breakerHalfOpen {
+		if !probe {
+			return
+		}
+		b.probing = false
+		switch rst {
+		case breakerSuccess:
+			b.state, b.failures = breakerClosed, 0
+		case breakerFailure:
+			b.state, b.openedAt = breakerOpen, time.Now()
+		}
+		return
+	}
+
+	switch rst {
+	case breakerSuccess:
+		b.failures = 0
+	case breakerFailure:
+		if b.failures++; b.failures >= p.FailureThreshold {
+			b.state, b.openedAt = breakerOpen, time.Now()
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// scriptedResponse 测试服务依次返回的响应
+type scriptedResponse struct {
+	status     int
+	retryAfter string
+}
+
+// scriptedServer 依次返回responses中的响应, 超出后重复最后一个, 并记录每次请求的请求体
+type scriptedServer struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	responses []scriptedResponse
+	bodies    []string
+}
+
+func newScriptedServer(t *testing.T, responses ...scriptedResponse) *scriptedServer {
+	s := &scriptedServer{responses: responses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		s.mu.Lock()
+		i := len(s.bodies)
+		s.bodies = append(s.bodies, string(body))
+		if i >= len(s.responses) {
+			i = len(s.responses) - 1
+		}
+		resp := s.responses[i]
+		s.mu.Unlock()
+
+		if resp.retryAfter != "" {
+			w.Header().Set("Retry-After", resp.retryAfter)
+		}
+		w.WriteHeader(resp.status)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *scriptedServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.bodies...)
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		idempotent bool   // 是否带Idempotency-Key请求头
+		body       string // 为空时不带请求体
+		responses  []scriptedResponse
+		wantCalls  int
+		wantStatus int // 期望的StatusError状态码, 0表示成功
+	}{
+		{
+			name:      "retry until success and rewind body",
+			method:    http.MethodPut,
+			body:      `{"a":1}`,
+			responses: []scriptedResponse{{status: 503}, {status: 429, retryAfter: "0"}, {status: 200}},
+			wantCalls: 3,
+		},
+		{
+			name:       "give up after max retries",
+			method:     http.MethodGet,
+			responses:  []scriptedResponse{{status: 500}},
+			wantCalls:  4,
+			wantStatus: 500,
+		},
+		{
+			name:       "post is not retried",
+			method:     http.MethodPost,
+			body:       "x",
+			responses:  []scriptedResponse{{status: 500}},
+			wantCalls:  1,
+			wantStatus: 500,
+		},
+		{
+			name:       "post with idempotency key is retried",
+			method:     http.MethodPost,
+			idempotent: true,
+			body:       "x",
+			responses:  []scriptedResponse{{status: 502}, {status: 500}},
+			wantCalls:  4,
+			wantStatus: 500,
+		},
+		{
+			name:       "not implemented is not retried",
+			method:     http.MethodGet,
+			responses:  []scriptedResponse{{status: 501}},
+			wantCalls:  1,
+			wantStatus: 501,
+		},
+		{
+			name:       "client error is not retried",
+			method:     http.MethodGet,
+			responses:  []scriptedResponse{{status: 404}},
+			wantCalls:  1,
+			wantStatus: 404,
+		},
+		{
+			name:       "retry after longer than max backoff",
+			method:     http.MethodGet,
+			responses:  []scriptedResponse{{status: 503, retryAfter: "100"}},
+			wantCalls:  1,
+			wantStatus: 503,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newScriptedServer(t, tt.responses...)
+
+			cli, err := NewClient(&ClientConf{Retry: RetryPolicy{MaxRetries: 3, BackoffMillisecond: 1, MaxBackoffMillisecond: 1000}})
+			assert.Nil(t, err)
+			defer cli.Close()
+
+			req := cli.NewRequest(context.Background(), tt.method, srv.URL)
+			if tt.body != "" {
+				req.Body(strings.NewReader(tt.body), "text/plain")
+			}
+			if tt.idempotent {
+				req.Header("Idempotency-Key", "k")
+			}
+
+			err = req.Do(nil)
+			assert.Equal(t, tt.wantStatus, StatusCode(err))
+			if tt.wantStatus == 0 {
+				assert.Nil(t, err)
+			}
+
+			bodies := srv.requests()
+			assert.Len(t, bodies, tt.wantCalls)
+			for _, body := range bodies {
+				assert.Equal(t, tt.body, body)
+			}
+		})
+	}
+}
+
+func TestRetryCanceled(t *testing.T) {
+	srv := newScriptedServer(t, scriptedResponse{status: 503})
+
+	cli, err := NewClient(&ClientConf{Retry: RetryPolicy{MaxRetries: 5, BackoffMillisecond: 10000}})
+	assert.Nil(t, err)
+	defer cli.Close()
+
+	// 等待重试期间ctx结束时立即返回
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = cli.NewRequest(ctx, http.MethodGet, srv.URL).Do(nil)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+	assert.Len(t, srv.requests(), 1)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := RetryPolicy{BackoffMillisecond: 100, MaxBackoffMillisecond: 1000}
+
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{attempt: 0, max: 100 * time.Millisecond},
+		{attempt: 1, max: 200 * time.Millisecond},
+		{attempt: 3, max: 800 * time.Millisecond},
+		{attempt: 4, max: time.Second},
+		{attempt: 100, max: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.attempt), func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				d := p.backoff(tt.attempt)
+				assert.GreaterOrEqual(t, int64(d), int64(tt.max/2))
+				assert.LessOrEqual(t, int64(d), int64(tt.max))
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{name: "missing"},
+		{name: "seconds", header: "2", want: 2 * time.Second, ok: true},
+		{name: "negative", header: "-1", want: 0, ok: true},
+		{name: "past date", header: time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), want: 0, ok: true},
+		{name: "invalid", header: "soon"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tt.header != "" {
+				resp.Header.Set("Retry-After", tt.header)
+			}
+
+			d, ok := retryAfter(resp)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, d)
+		})
+	}
+
+	// HTTP日期格式按距当前的时长等待
+	resp := &http.Response{Header: http.Header{"Retry-After": {time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)}}}
+	d, ok := retryAfter(resp)
+	assert.True(t, ok)
+	assert.InDelta(t, float64(time.Minute), float64(d), float64(2*time.Second))
+}
+
+func TestBreaker(t *testing.T) {
+	var (
+		status  int32 = http.StatusInternalServerError
+		calls   int32
+		release = make(chan struct{})
+		block   int32
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&block) == 1 {
+			<-release
+		}
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer srv.Close()
+
+	cli, err := NewClient(&ClientConf{Breaker: BreakerPolicy{FailureThreshold: 2, OpenSecond: 60}})
+	assert.Nil(t, err)
+	defer cli.Close()
+
+	get := func() error {
+		return cli.NewRequest(context.Background(), http.MethodGet, srv.URL).Do(nil)
+	}
+	u, _ := url.Parse(srv.URL)
+	expire := func() {
+		b := cli.breaker(u.Host)
+		b.mu.Lock()
+		b.openedAt = time.Now().Add(-time.Hour)
+		b.mu.Unlock()
+	}
+
+	// 连续失败达到阈值后熔断, 熔断期间不发送请求
+	assert.Equal(t, 500, StatusCode(get()))
+	assert.Equal(t, 500, StatusCode(get()))
+	assert.True(t, errors.Is(get(), ErrCircuitOpen))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// 半开状态下探测失败时重新熔断
+	expire()
+	assert.Equal(t, 500, StatusCode(get()))
+	assert.True(t, errors.Is(get(), ErrCircuitOpen))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	// 半开状态下只放行一个探测请求, 探测成功后恢复
+	expire()
+	atomic.StoreInt32(&status, http.StatusOK)
+	atomic.StoreInt32(&block, 1)
+	probe := make(chan error, 1)
+	go func() {
+		probe <- get()
+	}()
+	for atomic.LoadInt32(&calls) < 4 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.True(t, errors.Is(get(), ErrCircuitOpen))
+
+	atomic.StoreInt32(&block, 0)
+	close(release)
+	assert.Nil(t, <-probe)
+	assert.Nil(t, get())
+	assert.Equal(t, int32(5), atomic.LoadInt32(&calls))
+
+	// 客户端错误不计入失败
+	atomic.StoreInt32(&status, http.StatusNotFound)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, 404, StatusCode(get()))
+	}
+}
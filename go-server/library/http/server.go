@@ -2,30 +2,92 @@ package http
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"time"
+)
+
+const (
+	defaultShutdownTimeout = 30 * time.Second
 )
 
 type Server struct {
 	*http.Server
+
+	tls             *tlsReloader
+	shutdownTimeout time.Duration
 }
 
 type ServerConf struct {
 	Addr string
+
+	ReadHeaderTimeoutSecond int // 读取请求头超时, 为0时不限制
+	ReadTimeoutSecond       int // 读取整个请求超时, 包括请求体, 为0时不限制
+	WriteTimeoutSecond      int // 从读取完请求头到写完响应的超时, 为0时不限制
+	IdleTimeoutSecond       int // 长连接等待下一个请求的超时, 为0时使用ReadTimeoutSecond
+	MaxHeaderBytes          int // 请求头最大字节数, 为0时为1MB
+
+	CertFile      string // 服务端证书文件, 与KeyFile同时设置时以HTTPS提供服务, 文件变化时重新加载
+	KeyFile       string // 服务端私钥文件
+	MinTLSVersion string // 最低TLS版本, 可选1.0, 1.1, 1.2, 1.3, 为空时使用默认值
+
+	ShutdownTimeoutSecond int // 优雅关闭时等待处理中的请求完成的最长时长, 超时后强制关闭连接, 默认30
 }
 
-func NewServer(cf *ServerConf) (svr *Server) {
-	return &Server{
+// NewServer 创建服务, 设置了证书文件时证书首次加载失败返回错误
+func NewServer(cf *ServerConf) (svr *Server, err error) {
+	s := &Server{
 		Server: &http.Server{
-			Addr: cf.Addr,
+			Addr:              cf.Addr,
+			ReadHeaderTimeout: time.Duration(cf.ReadHeaderTimeoutSecond) * time.Second,
+			ReadTimeout:       time.Duration(cf.ReadTimeoutSecond) * time.Second,
+			WriteTimeout:      time.Duration(cf.WriteTimeoutSecond) * time.Second,
+			IdleTimeout:       time.Duration(cf.IdleTimeoutSecond) * time.Second,
+			MaxHeaderBytes:    cf.MaxHeaderBytes,
 		},
+		shutdownTimeout: time.Duration(cf.ShutdownTimeoutSecond) * time.Second,
 	}
+	if s.shutdownTimeout <= 0 {
+		s.shutdownTimeout = defaultShutdownTimeout
+	}
+
+	if cf.CertFile != "" || cf.KeyFile != "" {
+		if cf.CertFile == "" || cf.KeyFile == "" {
+			err = errors.New("cert file and key file must be set together")
+			return
+		}
+
+		tlsConf := &tls.Config{}
+		if tlsConf.MinVersion, err = parseTLSVersion(cf.MinTLSVersion); err != nil {
+			return
+		}
+
+		if s.tls, err = newTLSReloader("", cf.CertFile, cf.KeyFile); err != nil {
+			err = fmt.Errorf("tls: %w", err)
+			return
+		}
+		tlsConf.GetCertificate = s.tls.getCertificate
+		s.TLSConfig = tlsConf
+	}
+
+	svr = s
+
+	return
 }
 
+// Run 以handler处理请求并阻塞直到服务关闭, 调用Close关闭服务时返回nil
 func (svr *Server) Run(handler http.Handler) (err error) {
 	svr.Handler = handler
 
-	err = svr.Server.ListenAndServe()
+	if svr.tls != nil {
+		// 证书由TLSConfig.GetCertificate提供
+		err = svr.Server.ListenAndServeTLS("", "")
+	} else {
+		err = svr.Server.ListenAndServe()
+	}
 
 	if err == http.ErrServerClosed {
 		err = nil
@@ -38,8 +100,45 @@ func (svr *Server) Run(handler http.Handler) (err error) {
 	return
 }
 
+// Start 监听地址后在新的协程中以handler处理请求, 监听失败时返回错误,
+// 之后服务异常退出的错误由handleErr处理, handleErr可以为nil
+func (svr *Server) Start(handler http.Handler, handleErr func(error)) (err error) {
+	svr.Handler = handler
+
+	l, err := net.Listen("tcp", svr.Addr)
+	if err != nil {
+		err = fmt.Errorf("net.Listen: %w", err)
+		return
+	}
+
+	go func() {
+		var serr error
+		if svr.tls != nil {
+			serr = svr.Server.ServeTLS(l, "", "")
+		} else {
+			serr = svr.Server.Serve(l)
+		}
+
+		if serr != nil && serr != http.ErrServerClosed && handleErr != nil {
+			handleErr(fmt.Errorf("http.Server.Serve: %w", serr))
+		}
+	}()
+
+	return
+}
+
+// Close 优雅关闭服务, 停止接受新连接并关闭空闲连接, 等待处理中的请求完成,
+// 超过ShutdownTimeoutSecond时强制关闭剩余连接并返回错误
 func (svr *Server) Close() (err error) {
-	if err = svr.Shutdown(context.TODO()); err != nil {
+	if svr.tls != nil {
+		defer svr.tls.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), svr.shutdownTimeout)
+	defer cancel()
+
+	if err = svr.Shutdown(ctx); err != nil {
+		_ = svr.Server.Close()
 		err = fmt.Errorf("server shutdown: %w", err)
 		return
 	}
@@ -0,0 +1,167 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// freeAddr 返回一个当前空闲的本地地址
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	defer l.Close()
+
+	return l.Addr().String()
+}
+
+func TestServerConfInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		conf ServerConf
+	}{
+		{name: "cert without key", conf: ServerConf{CertFile: "server.pem"}},
+		{name: "key without cert", conf: ServerConf{KeyFile: "server.key"}},
+		{name: "missing files", conf: ServerConf{CertFile: "missing.pem", KeyFile: "missing.key"}},
+		{name: "invalid version", conf: ServerConf{CertFile: "server.pem", KeyFile: "server.key", MinTLSVersion: "2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewServer(&tt.conf)
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	tests := []struct {
+		name      string
+		handle    time.Duration // 处理请求的耗时
+		wantBody  string        // 为空时期望请求失败
+		wantClose bool          // Close是否返回nil
+	}{
+		{name: "wait for in-flight request", handle: 200 * time.Millisecond, wantBody: "ok", wantClose: true},
+		{name: "force close after timeout", handle: 3 * time.Second, wantClose: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := freeAddr(t)
+			svr, err := NewServer(&ServerConf{Addr: addr, ShutdownTimeoutSecond: 1})
+			assert.Nil(t, err)
+
+			started := make(chan struct{})
+			release := make(chan struct{})
+			defer close(release)
+			assert.Nil(t, svr.Start(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				select {
+				case <-time.After(tt.handle):
+				case <-release:
+				}
+				_, _ = w.Write([]byte("ok"))
+			}), nil))
+
+			body := make(chan string, 1)
+			go func() {
+				resp, err := http.Get("http://" + addr)
+				if err != nil {
+					body <- ""
+					return
+				}
+				defer resp.Body.Close()
+				data, _ := ioutil.ReadAll(resp.Body)
+				body <- string(data)
+			}()
+			<-started
+
+			start := time.Now()
+			err = svr.Close()
+			assert.Equal(t, tt.wantClose, err == nil, err)
+			assert.Less(t, int64(time.Since(start)), int64(2*time.Second))
+			assert.Equal(t, tt.wantBody, <-body)
+
+			// 关闭后不再接受新连接
+			_, err = net.DialTimeout("tcp", addr, time.Second)
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestServerRun(t *testing.T) {
+	addr := freeAddr(t)
+	svr, err := NewServer(&ServerConf{Addr: addr})
+	assert.Nil(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- svr.Run(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("ok"))
+		}))
+	}()
+
+	assert.Eventually(t, func() bool {
+		resp, err := http.Get("http://" + addr)
+		if err != nil {
+			return false
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Close后Run返回nil
+	assert.Nil(t, svr.Close())
+	assert.Nil(t, <-done)
+}
+
+func TestServerTLSReload(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key")
+	certPEM, keyPEM := ca.issue(t, 5, "127.0.0.1")
+	replaceFile(t, certFile, certPEM)
+	replaceFile(t, keyFile, keyPEM)
+
+	addr := freeAddr(t)
+	svr, err := NewServer(&ServerConf{Addr: addr, CertFile: certFile, KeyFile: keyFile, MinTLSVersion: "1.2"})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer svr.Close()
+	assert.Nil(t, svr.Start(http.NotFoundHandler(), nil))
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	serial := func() int64 {
+		conn, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: pool})
+		if err != nil {
+			return 0
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+	}
+
+	assert.Equal(t, int64(5), serial())
+
+	// 证书更新后新连接使用新证书
+	certPEM, keyPEM = ca.issue(t, 6, "127.0.0.1")
+	replaceFile(t, certFile, certPEM)
+	replaceFile(t, keyFile, keyPEM)
+	assert.Eventually(t, func() bool {
+		return serial() == 6
+	}, 5*time.Second, 20*time.Millisecond)
+
+	// 低于最低版本的连接被拒绝
+	_, err = tls.Dial("tcp", addr, &tls.Config{RootCAs: pool, MaxVersion: tls.VersionTLS11})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,242 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// parseTLSVersion 解析最低TLS版本, 为空时使用Go的默认值
+func parseTLSVersion(v string) (version uint16, err error) {
+	switch strings.TrimPrefix(strings.ToLower(v), "tls") {
+	case "":
+	case "1.0":
+		version = tls.VersionTLS10
+	case "1.1":
+		version = tls.VersionTLS11
+	case "1.2":
+		version = tls.VersionTLS12
+	case "1.3":
+		version = tls.VersionTLS13
+	default:
+		err = fmt.Errorf("unsupported tls version %q", v)
+	}
+
+	return
+}
+
+// tlsReloader 持有从文件加载的CA证书和证书, 监听文件所在目录, 文件变化时重新加载,
+// 重新加载失败(如证书与私钥尚未全部写入)时继续使用之前的证书, 下次文件变化时再次加载
+type tlsReloader struct {
+	caFile   string
+	certFile string
+	keyFile  string
+
+	roots atomic.Value // *x509.CertPool
+	cert  atomic.Value // *tls.Certificate
+
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+}
+
+func newTLSReloader(caFile, certFile, keyFile string) (r *tlsReloader, err error) {
+	if (certFile == "") != (keyFile == "") {
+		err = errors.New("cert file and key file must be set together")
+		return
+	}
+
+	r = &tlsReloader{
+		caFile:   caFile,
+		certFile: certFile,
+		keyFile:  keyFile,
+		done:     make(chan struct{}),
+	}
+
+	if err = r.load(); err != nil {
+		return
+	}
+
+	if r.watcher, err = fsnotify.NewWatcher(); err != nil {
+		err = fmt.Errorf("fsnotify.NewWatcher: %w", err)
+		return
+	}
+
+	// 监听目录而不是文件, 以便感知先写临时文件再重命名或替换符号链接的更新方式
+	dirs := make(map[string]bool)
+	for _, f := range []string{caFile, certFile, keyFile} {
+		if f == "" {
+			continue
+		}
+		dir := filepath.Dir(f)
+		if dirs[dir] {
+			continue
+		}
+		dirs[dir] = true
+
+		if err = r.watcher.Add(dir); err != nil {
+			_ = r.watcher.Close()
+			err = fmt.Errorf("watch %s: %w", dir, err)
+			return
+		}
+	}
+
+	go r.watch()
+
+	return
+}
+
+func (r *tlsReloader) load() (err error) {
+	if r.caFile != "" {
+		pem, rerr := ioutil.ReadFile(r.caFile)
+		if rerr != nil {
+			err = fmt.Errorf("read ca file: %w", rerr)
+			return
+		}
+
+		roots, perr := x509.SystemCertPool()
+		if perr != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			err = fmt.Errorf("no certificates found in %s", r.caFile)
+			return
+		}
+
+		r.roots.Store(roots)
+	}
+
+	if r.certFile != "" {
+		cert, lerr := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if lerr != nil {
+			err = fmt.Errorf("tls.LoadX509KeyPair: %w", lerr)
+			return
+		}
+
+		r.cert.Store(&cert)
+	}
+
+	return
+}
+
+func (r *tlsReloader) watch() {
+	for {
+		select {
+		case <-r.done:
+			return
+		case _, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			_ = r.load()
+		case _, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// apply 将证书配置到cfg, 配置了CA证书时以自定义校验代替默认校验, 以便CA证书更新后对新连接立即生效,
+// 自定义校验只用于经HTTP代理建立的HTTPS连接, 其他连接由dialTLS以当前CA证书按默认方式校验
+func (r *tlsReloader) apply(cfg *tls.Config) {
+	if r.certFile != "" {
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return r.cert.Load().(*tls.Certificate), nil
+		}
+	}
+
+	if r.caFile != "" && !cfg.InsecureSkipVerify {
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = r.verifyConnection
+	}
+}
+
+// dialTLS 返回http.Transport的DialTLSContext, 以当前CA证书和拨号地址中的主机名校验服务端证书,
+// 目标为IP时tls不发送SNI, 握手结果中的ServerName为空, 因此不能依赖verifyConnection校验IP SAN
+func (r *tlsReloader) dialTLS(dialer *net.Dialer, cfg *tls.Config, handshakeTimeout time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (conn net.Conn, err error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return
+		}
+
+		// cfg可能被http.Transport追加了NextProtos, 每次拨号时复制
+		c := cfg.Clone()
+		c.InsecureSkipVerify = false
+		c.VerifyConnection = nil
+		c.RootCAs = r.roots.Load().(*x509.CertPool)
+		if c.ServerName == "" {
+			c.ServerName = host
+		}
+
+		raw, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return
+		}
+
+		if handshakeTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, handshakeTimeout)
+			defer cancel()
+		}
+
+		tc := tls.Client(raw, c)
+		if err = tc.HandshakeContext(ctx); err != nil {
+			_ = raw.Close()
+			return
+		}
+		conn = tc
+
+		return
+	}
+}
+
+// getCertificate 返回当前证书, 用于服务端
+func (r *tlsReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load().(*tls.Certificate), nil
+}
+
+// verifyConnection 以当前CA证书校验服务端证书链和主机名, 无法得知主机名(如经代理访问IP)时拒绝连接
+func (r *tlsReloader) verifyConnection(cs tls.ConnectionState) (err error) {
+	if len(cs.PeerCertificates) == 0 {
+		err = errors.New("tls: server did not provide a certificate")
+		return
+	}
+	if cs.ServerName == "" {
+		err = errors.New("tls: cannot verify server certificate without server name")
+		return
+	}
+
+	opts := x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         r.roots.Load().(*x509.CertPool),
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+
+	if _, err = cs.PeerCertificates[0].Verify(opts); err != nil {
+		err = fmt.Errorf("tls: %w", err)
+		return
+	}
+
+	return
+}
+
+// Close 实现io.Closer接口, 停止监听证书文件
+func (r *tlsReloader) Close() (err error) {
+	close(r.done)
+	err = r.watcher.Close()
+	return
+}
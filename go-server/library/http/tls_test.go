@@ -0,0 +1,276 @@
+package http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testCA 测试用的自签名CA
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue 签发证书, hosts中的IP写入IP SAN, 其他写入DNS SAN
+func (ca *testCA) issue(t *testing.T, serial int64, hosts ...string) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tpl.IPAddresses = append(tpl.IPAddresses, ip)
+		} else {
+			tpl.DNSNames = append(tpl.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	kb, _ := x509.MarshalECPrivateKey(key)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb})
+}
+
+// newTLSServer 启动使用ca签发的hosts证书的HTTPS服务, clientCA不为nil时要求客户端证书, 响应客户端证书的序列号
+func newTLSServer(t *testing.T, ca *testCA, clientCA *testCA, hosts ...string) *httptest.Server {
+	certPEM, keyPEM := ca.issue(t, 2, hosts...)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("tls.X509KeyPair: %v", err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].SerialNumber.String()))
+		}
+	}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	if clientCA != nil {
+		pool := x509.NewCertPool()
+		pool.AddCert(clientCA.cert)
+		srv.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+		srv.TLS.ClientCAs = pool
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// replaceFile 先写临时文件再重命名, 与常见的证书更新方式一致
+func replaceFile(t *testing.T, path string, data []byte) {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+}
+
+func TestParseTLSVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    uint16
+		err     bool
+	}{
+		{version: "", want: 0},
+		{version: "1.0", want: tls.VersionTLS10},
+		{version: "1.2", want: tls.VersionTLS12},
+		{version: "TLS1.3", want: tls.VersionTLS13},
+		{version: "1.5", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got, err := parseTLSVersion(tt.version)
+			assert.Equal(t, tt.err, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTLSConfInvalid(t *testing.T) {
+	dir := t.TempDir()
+	garbage := filepath.Join(dir, "garbage.pem")
+	replaceFile(t, garbage, []byte("garbage"))
+
+	tests := []struct {
+		name string
+		conf ClientConf
+	}{
+		{name: "cert without key", conf: ClientConf{CertFile: garbage}},
+		{name: "missing ca file", conf: ClientConf{CAFile: filepath.Join(dir, "missing.pem")}},
+		{name: "invalid ca file", conf: ClientConf{CAFile: garbage}},
+		{name: "invalid key pair", conf: ClientConf{CertFile: garbage, KeyFile: garbage}},
+		{name: "invalid version", conf: ClientConf{MinTLSVersion: "1.5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewClient(&tt.conf)
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestTLSReload(t *testing.T) {
+	serverCA, clientCA := newTestCA(t, "server-ca"), newTestCA(t, "client-ca")
+	srv := newTLSServer(t, serverCA, clientCA, "127.0.0.1")
+
+	dir := t.TempDir()
+	caFile, certFile, keyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	replaceFile(t, caFile, serverCA.pem)
+	certPEM, keyPEM := clientCA.issue(t, 10)
+	replaceFile(t, certFile, certPEM)
+	replaceFile(t, keyFile, keyPEM)
+
+	cli, err := NewClient(&ClientConf{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, MinTLSVersion: "1.2", TimeoutSecond: 5})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	defer cli.Close()
+
+	get := func() (serial string, err error) {
+		cli.CloseIdleConnections()
+		data, err := cli.NewRequest(context.Background(), http.MethodGet, srv.URL).Bytes()
+		return string(data), err
+	}
+
+	serial, err := get()
+	assert.Nil(t, err)
+	assert.Equal(t, "10", serial)
+
+	// 客户端证书更新后新连接使用新证书
+	certPEM, keyPEM = clientCA.issue(t, 11)
+	replaceFile(t, certFile, certPEM)
+	replaceFile(t, keyFile, keyPEM)
+	assert.Eventually(t, func() bool {
+		serial, err := get()
+		return err == nil && serial == "11"
+	}, 5*time.Second, 20*time.Millisecond)
+
+	// CA证书更新后不再信任原CA签发的服务端证书
+	replaceFile(t, caFile, newTestCA(t, "other-ca").pem)
+	assert.Eventually(t, func() bool {
+		_, err := get()
+		return err != nil
+	}, 5*time.Second, 20*time.Millisecond)
+
+	// 加载失败时继续使用之前的CA证书
+	replaceFile(t, caFile, []byte("garbage"))
+	time.Sleep(100 * time.Millisecond)
+	_, err = get()
+	assert.NotNil(t, err)
+
+	replaceFile(t, caFile, serverCA.pem)
+	assert.Eventually(t, func() bool {
+		_, err := get()
+		return err == nil
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestTLSVerifyIP(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	replaceFile(t, caFile, ca.pem)
+
+	tests := []struct {
+		name  string
+		hosts []string // 服务端证书的SAN
+		ok    bool
+	}{
+		{name: "ip san", hosts: []string{"127.0.0.1"}, ok: true},
+		{name: "dns san only", hosts: []string{"example.test"}},
+		{name: "other ip", hosts: []string{"10.0.0.1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTLSServer(t, ca, nil, tt.hosts...)
+
+			cli, err := NewClient(&ClientConf{CAFile: caFile, TimeoutSecond: 5})
+			if err != nil {
+				t.Fatalf("NewClient: %v", err)
+			}
+			defer cli.Close()
+
+			// 以IP访问时同样校验证书的SAN
+			_, err = cli.NewRequest(context.Background(), http.MethodGet, srv.URL).Bytes()
+			assert.Equal(t, tt.ok, err == nil, err)
+		})
+	}
+}
+
+func TestTLSVerifyWithoutServerName(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	replaceFile(t, caFile, ca.pem)
+
+	r, err := newTLSReloader(caFile, "", "")
+	if err != nil {
+		t.Fatalf("newTLSReloader: %v", err)
+	}
+	defer r.Close()
+
+	certPEM, _ := ca.issue(t, 2, "127.0.0.1")
+	block, _ := pem.Decode(certPEM)
+	cert, _ := x509.ParseCertificate(block.Bytes)
+
+	// 无法得知主机名时拒绝连接, 而不是只校验证书链
+	assert.NotNil(t, r.verifyConnection(tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}))
+	assert.Nil(t, r.verifyConnection(tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, ServerName: "127.0.0.1"}))
+}
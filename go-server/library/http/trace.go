@@ -0,0 +1,53 @@
+package http
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	"go-server/library/trace"
+)
+
+// startClientSpan 请求context携带span上下文时为请求创建客户端span, 并以trace.Propagator将span上下文和请求ID写入请求头,
+// 这是客户端唯一写入链路请求头的位置, 覆盖请求中已有的同名请求头, 返回context携带span的请求副本,
+// 没有span上下文时span为nil, 请求context没有链路信息时返回原请求
+func startClientSpan(req *http.Request) (*http.Request, oteltrace.Span) {
+	ctx := req.Context()
+	if _, ok := trace.FromContext(ctx); !ok {
+		return req, nil
+	}
+
+	var span oteltrace.Span
+	if trace.Traced(ctx) {
+		name := req.Method
+		if route := Route(req); route != "" {
+			name += " " + route
+		}
+
+		ctx, span = trace.StartSpan(ctx, name,
+			oteltrace.WithSpanKind(oteltrace.SpanKindClient),
+			oteltrace.WithAttributes(semconv.HTTPClientAttributesFromHTTPRequest(req)...),
+		)
+	}
+
+	// RoundTripper不应修改传入的请求
+	req = req.Clone(ctx)
+	trace.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	return req, span
+}
+
+// endClientSpan 以响应状态码或请求错误设置span状态后结束span, 状态码为4xx或5xx时span标记为错误
+func endClientSpan(span oteltrace.Span, resp *http.Response, err error) {
+	if resp != nil {
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(resp.StatusCode)...)
+		if code, msg := semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(resp.StatusCode, oteltrace.SpanKindClient); code == codes.Error {
+			span.SetStatus(code, msg)
+		}
+	}
+
+	trace.EndSpan(span, &err)
+}
@@ -0,0 +1,125 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	"go-server/library/trace"
+	"go-server/library/trace/tracetest"
+)
+
+// headerServer 依次返回statuses中的状态码, 超出后返回200, 并记录每次请求的链路请求头
+type headerServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	headers  []http.Header
+}
+
+func newHeaderServer(t *testing.T, statuses ...int) *headerServer {
+	s := &headerServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		i := len(s.headers)
+		s.headers = append(s.headers, r.Header.Clone())
+		s.mu.Unlock()
+
+		if i < len(s.statuses) {
+			w.WriteHeader(s.statuses[i])
+		}
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *headerServer) received() []http.Header {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]http.Header(nil), s.headers...)
+}
+
+func TestClientTrace(t *testing.T) {
+	rec := tracetest.Record(t)
+	srv := newHeaderServer(t, http.StatusServiceUnavailable)
+
+	cli, err := NewClient(&ClientConf{Retry: RetryPolicy{MaxRetries: 1, BackoffMillisecond: 1}})
+	assert.Nil(t, err)
+	defer cli.Close()
+
+	// 拦截器看到的请求已经带有链路请求头
+	var seen []string
+	cli.Use(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			seen = append(seen, req.Header.Get(trace.HeaderTraceparent))
+			return next.RoundTrip(req)
+		})
+	})
+
+	ctx, parent := trace.StartSpan(trace.WithRequestID(context.Background(), "req-1"), "parent")
+	err = cli.NewRequest(ctx, http.MethodGet, srv.URL+"/users/1").
+		Route("/users/{id}").
+		Header(trace.HeaderTraceparent, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01").
+		Do(nil)
+	assert.Nil(t, err)
+	parent.End()
+
+	// 包含所有重试的客户端span是父span的子span
+	span := rec.Span("GET /users/{id}")
+	if !assert.NotNil(t, span) {
+		return
+	}
+	assert.Equal(t, oteltrace.SpanKindClient, span.SpanKind())
+	assert.Equal(t, parent.SpanContext().TraceID(), span.SpanContext().TraceID())
+	assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+	assert.Len(t, span.Events(), 1)
+	assert.Equal(t, codes.Unset, span.Status().Code)
+
+	// 每次重试都以客户端span为下游的上游操作, 覆盖调用方设置的traceparent
+	want := "00-" + span.SpanContext().TraceID().String() + "-" + span.SpanContext().SpanID().String() + "-01"
+	headers := srv.received()
+	assert.Len(t, headers, 2)
+	for _, h := range headers {
+		assert.Equal(t, []string{want}, h.Values(trace.HeaderTraceparent))
+		assert.Equal(t, "req-1", h.Get(trace.HeaderRequestID))
+	}
+	assert.Equal(t, []string{want, want}, seen)
+}
+
+func TestClientTraceWithoutSpan(t *testing.T) {
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		requestID string
+	}{
+		{name: "no trace", ctx: context.Background()},
+		{name: "request id only", ctx: trace.WithRequestID(context.Background(), "req-1"), requestID: "req-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := tracetest.Record(t)
+			srv := newHeaderServer(t)
+
+			cli := newTestClient(t)
+			assert.Nil(t, cli.NewRequest(tt.ctx, http.MethodGet, srv.URL).Do(nil))
+
+			// 不属于任何链路的请求不创建span, 只传递请求ID
+			assert.Empty(t, rec.Ended())
+			headers := srv.received()
+			if assert.Len(t, headers, 1) {
+				assert.Empty(t, headers[0].Get(trace.HeaderTraceparent))
+				assert.Equal(t, tt.requestID, headers[0].Get(trace.HeaderRequestID))
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+var (
+	ErrProducerClosed = errors.New("producer closed")
+)
+
+// DeliveryFunc 消息投递结果回调函数, 投递成功时err为nil
+type DeliveryFunc func(msg *sarama.ProducerMessage, err error)
+
+// AsyncProducerConf 定义异步生产者配置类型
+type AsyncProducerConf struct {
+	// Kafka集群节点地址, 多个用英文','号隔开
+	Brokers string
+
+	// 批量发送的字节数阈值, 为0时使用sarama默认值
+	FlushBytes int
+
+	// 批量发送的消息数阈值, 为0时使用sarama默认值
+	FlushMessages int
+
+	// 消息在本地缓冲的最长时间, 达到后即使未满足批量阈值也会发送, 为0时使用sarama默认值
+	Linger time.Duration
+
+	// 压缩算法, 可选none, gzip, snappy, lz4, 为空时不压缩
+	Compression string
+
+	// 未指定回调的消息的投递结果回调, 可以为nil
+	OnDelivery DeliveryFunc
+
+	// 扩展配置, 需要覆盖sarama默认配置时使用
+	Ext *sarama.Config
+}
+
+// AsyncProducer 定义异步生产者类型, 内部持续消费sarama的成功和错误信道,
+// 并将投递结果回调给发送时指定的DeliveryFunc
+type AsyncProducer struct {
+	sarama.AsyncProducer
+
+	onDelivery DeliveryFunc
+
+	// 投递观测者, 存储observerHolder
+	observer atomic.Value
+
+	mu      sync.RWMutex
+	closed  bool
+	sending sync.WaitGroup // 已通过关闭检查但尚未写入Input的发送, Close需等待其完成后才能关闭sarama生产者
+	done    sync.WaitGroup
+}
+
+// asyncMetadata 发送时包装在消息Metadata上, 投递完成后还原
+type asyncMetadata struct {
+	callback DeliveryFunc
+	metadata interface{}
+}
+
+// NewAsyncProducer 以指定异步生产者配置创建异步生产者实例并返回实例地址
+// 创建生产者失败时将返回错误
+func NewAsyncProducer(cf *AsyncProducerConf) (pdr *AsyncProducer, err error) {
+	// 在副本上修改, 不修改cf及调用方传入的Ext, 保证容器对比新旧配置时结果不受创建过程影响
+	ext := sarama.NewConfig()
+	if cf.Ext != nil {
+		*ext = *cf.Ext
+	} else {
+		ext.Version = sarama.V2_6_0_0
+	}
+
+	if cf.FlushBytes > 0 {
+		ext.Producer.Flush.Bytes = cf.FlushBytes
+	}
+	if cf.FlushMessages > 0 {
+		ext.Producer.Flush.Messages = cf.FlushMessages
+	}
+	if cf.Linger > 0 {
+		ext.Producer.Flush.Frequency = cf.Linger
+	}
+	if ext.Producer.Compression, err = parseCompression(cf.Compression); err != nil {
+		return
+	}
+
+	// 投递结果回调必须配置开启
+	ext.Producer.Return.Successes = true
+	ext.Producer.Return.Errors = true
+
+	apdr, err := sarama.NewAsyncProducer(strings.Split(cf.Brokers, ","), ext)
+	if err != nil {
+		err = fmt.Errorf("sarama.NewAsyncProducer: %w", err)
+		return
+	}
+
+	pdr = WrapAsyncProducer(apdr, cf.OnDelivery)
+
+	return
+}
+
+// WrapAsyncProducer 以已创建的sarama异步生产者创建异步生产者, apdr需开启Return.Successes和Return.Errors,
+// 用于接入kafkatest等自定义的生产者实现
+func WrapAsyncProducer(apdr sarama.AsyncProducer, onDelivery DeliveryFunc) (pdr *AsyncProducer) {
+	pdr = &AsyncProducer{
+		AsyncProducer: apdr,
+		onDelivery:    onDelivery,
+	}
+
+	pdr.done.Add(2)
+	go func() {
+		defer pdr.done.Done()
+		for msg := range apdr.Successes() {
+			pdr.deliver(msg, nil)
+		}
+	}()
+	go func() {
+		defer pdr.done.Done()
+		for perr := range apdr.Errors() {
+			pdr.deliver(perr.Msg, perr.Err)
+		}
+	}()
+
+	return
+}
+
+// Send 异步发送消息, 投递完成后以投递结果调用callback, callback为nil时调用配置的OnDelivery,
+// 回调在内部协程中执行, 应避免阻塞, 生产者已关闭时返回ErrProducerClosed
+func (pdr *AsyncProducer) Send(msg *sarama.ProducerMessage, callback DeliveryFunc) (err error) {
+	// 写入Input可能因缓冲已满而阻塞, 不能在持有锁时进行, 否则Close将一直等待锁
+	pdr.mu.RLock()
+	if pdr.closed {
+		pdr.mu.RUnlock()
+		err = ErrProducerClosed
+		return
+	}
+	pdr.sending.Add(1)
+	pdr.mu.RUnlock()
+
+	defer pdr.sending.Done()
+
+	if callback != nil {
+		msg.Metadata = &asyncMetadata{
+			callback: callback,
+			metadata: msg.Metadata,
+		}
+	}
+
+	pdr.Input() <- msg
+
+	return
+}
+
+// SendChan 异步发送消息, 返回的信道在投递完成后接收投递结果
+func (pdr *AsyncProducer) SendChan(msg *sarama.ProducerMessage) <-chan error {
+	ch := make(chan error, 1)
+
+	err := pdr.Send(msg, func(_ *sarama.ProducerMessage, err error) {
+		ch <- err
+	})
+	if err != nil {
+		ch <- err
+	}
+
+	return ch
+}
+
+// SetObserver 设置投递观测者, 为nil时不再观测
+func (pdr *AsyncProducer) SetObserver(obs Observer) {
+	pdr.observer.Store(observerHolder{obs: obs})
+}
+
+// Close 实现io.Closer接口, 停止接收新消息, 等待缓冲中的消息全部投递并完成回调后关闭生产者
+func (pdr *AsyncProducer) Close() (err error) {
+	pdr.mu.Lock()
+	if pdr.closed {
+		pdr.mu.Unlock()
+		return
+	}
+	pdr.closed = true
+	pdr.mu.Unlock()
+
+	// sarama关闭后不能再写入Input
+	pdr.sending.Wait()
+	pdr.AsyncProducer.AsyncClose()
+	pdr.done.Wait()
+
+	return
+}
+
+func (pdr *AsyncProducer) deliver(msg *sarama.ProducerMessage, err error) {
+	if obs := loadObserver(pdr.observer.Load()); obs != nil {
+		obs.ObserveProduce(msg.Topic, err)
+	}
+
+	callback := pdr.onDelivery
+
+	if meta, ok := msg.Metadata.(*asyncMetadata); ok {
+		callback = meta.callback
+		msg.Metadata = meta.metadata
+	}
+
+	if callback != nil {
+		callback(msg, err)
+	}
+}
+
+func parseCompression(name string) (codec sarama.CompressionCodec, err error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		codec = sarama.CompressionNone
+	case "gzip":
+		codec = sarama.CompressionGZIP
+	case "snappy":
+		codec = sarama.CompressionSnappy
+	case "lz4":
+		codec = sarama.CompressionLZ4
+	default:
+		err = fmt.Errorf("unsupported compression %q", name)
+	}
+
+	return
+}
@@ -0,0 +1,141 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"go-server/library/conf"
+)
+
+var (
+	ErrGetAsyncProducerConfFuncIsNil = errors.New("get async producer conf func is nil")
+)
+
+// AsyncProducerContainer 异步生产者容器, 配置更新替换生产者时,
+// 旧生产者在所有引用释放后关闭, 关闭前会投递完缓冲中的消息并完成回调
+type AsyncProducerContainer struct {
+	*conf.Container
+
+	mu       sync.Mutex
+	observer Observer // SetObserver设置的观测者, 替换生产者后仍然生效
+}
+
+type GetAsyncProducerConfFunc func() (*AsyncProducerConf, error)
+
+func NewAsyncProducerContainer(getPdrCf GetAsyncProducerConfFunc) (ct *AsyncProducerContainer, err error) {
+	if getPdrCf == nil {
+		err = ErrGetAsyncProducerConfFuncIsNil
+		return
+	}
+
+	getObjConf := func() (icf conf.IConf, err error) {
+		icf, err = getPdrCf()
+		if err != nil {
+			err = fmt.Errorf("get async producer conf: %w", err)
+			return
+		}
+
+		return
+	}
+
+	c := &AsyncProducerContainer{}
+	newObj := func(icf conf.IConf) (iobj conf.IObject, err error) {
+		if iobj, err = newAsyncProducerObj(icf); err != nil {
+			return
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.observer != nil {
+			iobj.(*AsyncProducer).SetObserver(c.observer)
+		}
+
+		return
+	}
+
+	ict, err := conf.NewContainer(getObjConf, compareAsyncProducerConf, newObj, nil)
+	if err != nil {
+		err = fmt.Errorf("new conf container: %w", err)
+		return
+	}
+
+	c.Container = ict
+	ct = c
+
+	return
+}
+
+// SetObserver 设置容器中生产者的投递观测者, 之后因配置变化创建的生产者同样使用obs
+func (ct *AsyncProducerContainer) SetObserver(obs Observer) {
+	ct.mu.Lock()
+	ct.observer = obs
+	ct.mu.Unlock()
+
+	pdr := ct.MustGetProducer()
+	defer ct.PutProducer(pdr)
+
+	pdr.SetObserver(obs)
+}
+
+func newAsyncProducerObj(icf conf.IConf) (iobj conf.IObject, err error) {
+	cf, ok := icf.(*AsyncProducerConf)
+	if !ok {
+		err = conf.ErrInvalidConfType
+		return
+	}
+
+	iobj, err = NewAsyncProducer(cf)
+	if err != nil {
+		err = fmt.Errorf("new async producer: %w", err)
+		return
+	}
+
+	return
+}
+
+func compareAsyncProducerConf(iocf, incf conf.IConf) (rst conf.CompareObjConfRst, err error) {
+	ocf, ok := iocf.(*AsyncProducerConf)
+	if !ok {
+		err = conf.ErrInvalidConfType
+		return
+	}
+
+	ncf, ok := incf.(*AsyncProducerConf)
+	if !ok {
+		err = conf.ErrInvalidConfType
+		return
+	}
+
+	switch {
+	case ocf.Brokers != ncf.Brokers,
+		ocf.FlushBytes != ncf.FlushBytes,
+		ocf.FlushMessages != ncf.FlushMessages,
+		ocf.Linger != ncf.Linger,
+		ocf.Compression != ncf.Compression,
+		!equalSaramaConfig(ocf.Ext, ncf.Ext):
+		rst = conf.CompareObjConfRstNeedReplace
+		return
+	}
+
+	rst = conf.CompareObjConfRstNoNeed
+
+	return
+}
+
+func (ct *AsyncProducerContainer) MustGetProducer() (pdr *AsyncProducer) {
+	obj := ct.MustGetObj()
+
+	pdr, ok := obj.(*AsyncProducer)
+	if !ok {
+		panic(conf.ErrInvalidObjectType)
+	}
+
+	return
+}
+
+func (ct *AsyncProducerContainer) PutProducer(pdr *AsyncProducer) {
+	ct.PutObj(pdr)
+
+	return
+}
@@ -0,0 +1,21 @@
+package kafka
+
+import (
+	"github.com/Shopify/sarama"
+)
+
+func (ct *AsyncProducerContainer) Send(msg *sarama.ProducerMessage, callback DeliveryFunc) (err error) {
+	pdr := ct.MustGetProducer()
+	defer ct.PutProducer(pdr)
+
+	err = pdr.Send(msg, callback)
+
+	return
+}
+
+func (ct *AsyncProducerContainer) SendChan(msg *sarama.ProducerMessage) <-chan error {
+	pdr := ct.MustGetProducer()
+	defer ct.PutProducer(pdr)
+
+	return pdr.SendChan(msg)
+}
@@ -0,0 +1,169 @@
+package kafka
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+
+	"go-server/library/conf"
+)
+
+func TestAsyncProducer(t *testing.T) {
+	b := sarama.NewMockBroker(t, 1)
+	defer b.Close()
+	b.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(b.Addr(), b.BrokerID()).
+			SetLeader("t", 0, b.BrokerID()),
+		"ProduceRequest": sarama.NewMockProduceResponse(t),
+	})
+
+	ext := sarama.NewConfig()
+	var callbacks, defaults int32
+	pdr, err := NewAsyncProducer(&AsyncProducerConf{
+		Brokers:       b.Addr(),
+		FlushMessages: 10,
+		Linger:        50 * time.Millisecond,
+		Compression:   "gzip",
+		OnDelivery: func(msg *sarama.ProducerMessage, err error) {
+			atomic.AddInt32(&defaults, 1)
+		},
+		Ext: ext,
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// 创建过程不修改调用方的配置
+	assert.False(t, ext.Producer.Return.Successes)
+	assert.Equal(t, sarama.CompressionNone, ext.Producer.Compression)
+	assert.Zero(t, ext.Producer.Flush.Messages)
+	assert.Zero(t, ext.Producer.Flush.Frequency)
+
+	for i := 0; i < 5; i++ {
+		err = pdr.Send(&sarama.ProducerMessage{Topic: "t", Value: sarama.StringEncoder("x"), Metadata: i},
+			func(msg *sarama.ProducerMessage, err error) {
+				assert.Nil(t, err)
+				assert.IsType(t, 0, msg.Metadata)
+				atomic.AddInt32(&callbacks, 1)
+			})
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, pdr.Send(&sarama.ProducerMessage{Topic: "t", Value: sarama.StringEncoder("y")}, nil))
+	ch := pdr.SendChan(&sarama.ProducerMessage{Topic: "t", Value: sarama.StringEncoder("z")})
+
+	assert.Nil(t, pdr.Close())
+	assert.Equal(t, int32(5), callbacks)
+	assert.Equal(t, int32(1), defaults)
+	assert.Nil(t, <-ch)
+	assert.True(t, errors.Is(<-pdr.SendChan(&sarama.ProducerMessage{Topic: "t"}), ErrProducerClosed))
+}
+
+// blockingProducer 在release关闭前不读取Input的sarama异步生产者
+type blockingProducer struct {
+	input     chan *sarama.ProducerMessage
+	successes chan *sarama.ProducerMessage
+	errors    chan *sarama.ProducerError
+	release   chan struct{}
+}
+
+func newBlockingProducer() *blockingProducer {
+	p := &blockingProducer{
+		input:     make(chan *sarama.ProducerMessage),
+		successes: make(chan *sarama.ProducerMessage, 16),
+		errors:    make(chan *sarama.ProducerError, 16),
+		release:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(p.successes)
+		defer close(p.errors)
+
+		<-p.release
+		for msg := range p.input {
+			p.successes <- msg
+		}
+	}()
+
+	return p
+}
+
+func (p *blockingProducer) AsyncClose()                               { close(p.input) }
+func (p *blockingProducer) Close() error                              { p.AsyncClose(); return nil }
+func (p *blockingProducer) Input() chan<- *sarama.ProducerMessage     { return p.input }
+func (p *blockingProducer) Successes() <-chan *sarama.ProducerMessage { return p.successes }
+func (p *blockingProducer) Errors() <-chan *sarama.ProducerError      { return p.errors }
+
+func TestAsyncProducerCloseWhileSending(t *testing.T) {
+	bp := newBlockingProducer()
+
+	var delivered int32
+	pdr := WrapAsyncProducer(bp, func(msg *sarama.ProducerMessage, err error) {
+		atomic.AddInt32(&delivered, 1)
+	})
+
+	sent := make(chan error, 1)
+	go func() {
+		sent <- pdr.Send(&sarama.ProducerMessage{Topic: "t"}, nil)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- pdr.Close()
+	}()
+
+	// 阻塞中的发送不妨碍Close标记关闭, 之后的发送立即返回而不会等待锁
+	assert.Eventually(t, func() bool {
+		return errors.Is(pdr.Send(&sarama.ProducerMessage{Topic: "t"}, nil), ErrProducerClosed)
+	}, time.Second, 10*time.Millisecond)
+
+	// Close等待阻塞中的发送完成后才关闭sarama生产者
+	select {
+	case <-closed:
+		t.Fatal("closed before pending send finished")
+	default:
+	}
+
+	close(bp.release)
+	assert.Nil(t, <-sent)
+	assert.Nil(t, <-closed)
+	assert.Equal(t, int32(1), delivered)
+}
+
+func TestCompareAsyncProducerConf(t *testing.T) {
+	base := func() *AsyncProducerConf {
+		ext := sarama.NewConfig()
+		ext.Version = sarama.V2_6_0_0
+		return &AsyncProducerConf{Brokers: "a:9092", FlushMessages: 10, Linger: time.Second, Compression: "gzip", Ext: ext}
+	}
+
+	tests := []struct {
+		name   string
+		change func(cf *AsyncProducerConf)
+		want   conf.CompareObjConfRst
+	}{
+		{name: "same", change: func(cf *AsyncProducerConf) {}, want: conf.CompareObjConfRstNoNeed},
+		{name: "callback", change: func(cf *AsyncProducerConf) { cf.OnDelivery = func(*sarama.ProducerMessage, error) {} }, want: conf.CompareObjConfRstNoNeed},
+		{name: "brokers", change: func(cf *AsyncProducerConf) { cf.Brokers = "b:9092" }, want: conf.CompareObjConfRstNeedReplace},
+		{name: "linger", change: func(cf *AsyncProducerConf) { cf.Linger = 2 * time.Second }, want: conf.CompareObjConfRstNeedReplace},
+		{name: "compression", change: func(cf *AsyncProducerConf) { cf.Compression = "lz4" }, want: conf.CompareObjConfRstNeedReplace},
+		{name: "ext override", change: func(cf *AsyncProducerConf) { cf.Ext.Producer.MaxMessageBytes = 1 << 20 }, want: conf.CompareObjConfRstNeedReplace},
+		{name: "ext removed", change: func(cf *AsyncProducerConf) { cf.Ext = nil }, want: conf.CompareObjConfRstNeedReplace},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ncf := base()
+			tt.change(ncf)
+
+			rst, err := compareAsyncProducerConf(base(), ncf)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, rst)
+		})
+	}
+}
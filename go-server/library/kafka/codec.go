@@ -0,0 +1,181 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"google.golang.org/protobuf/proto"
+)
+
+// 消息内容类型
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeHessian  = "application/x-hessian"
+)
+
+// Codec 消息编解码器
+type Codec interface {
+	// ContentType 返回写入content-type消息头的内容类型
+	ContentType() string
+
+	// Marshal 编码v
+	Marshal(v interface{}) ([]byte, error)
+
+	// Unmarshal 将data解码到v, v必须为指针
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	// JSONCodec 使用encoding/json编解码
+	JSONCodec Codec = jsonCodec{}
+
+	// ProtobufCodec 使用protobuf编解码, 值必须实现proto.Message
+	ProtobufCodec Codec = protobufCodec{}
+
+	// HessianCodec 使用hessian2编解码, 结构体需实现hessian.POJO并通过hessian.RegisterPOJO注册
+	HessianCodec Codec = hessianCodec{}
+)
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{
+		ContentTypeJSON:     JSONCodec,
+		ContentTypeProtobuf: ProtobufCodec,
+		ContentTypeHessian:  HessianCodec,
+	}
+)
+
+// RegisterCodec 注册编解码器, 已存在相同内容类型的编解码器时替换,
+// 消费时按消息的content-type消息头选择已注册的编解码器
+func RegisterCodec(codec Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	codecs[codec.ContentType()] = codec
+}
+
+// CodecByContentType 返回内容类型对应的已注册编解码器
+func CodecByContentType(contentType string) (codec Codec, ok bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	codec, ok = codecs[contentType]
+	return
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type protobufCodec struct{}
+
+func (protobufCodec) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (protobufCodec) Marshal(v interface{}) (b []byte, err error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		err = fmt.Errorf("%T does not implement proto.Message", v)
+		return
+	}
+
+	return proto.Marshal(m)
+}
+
+func (protobufCodec) Unmarshal(data []byte, v interface{}) (err error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		err = fmt.Errorf("%T does not implement proto.Message", v)
+		return
+	}
+
+	return proto.Unmarshal(data, m)
+}
+
+type hessianCodec struct{}
+
+func (hessianCodec) ContentType() string {
+	return ContentTypeHessian
+}
+
+func (hessianCodec) Marshal(v interface{}) (b []byte, err error) {
+	enc := hessian.NewEncoder()
+	if err = enc.Encode(v); err != nil {
+		err = fmt.Errorf("hessian encode: %w", err)
+		return
+	}
+
+	b = enc.Buffer()
+
+	return
+}
+
+// Unmarshal hessian解码得到的是注册的POJO指针或基本类型值, 将其赋值给v指向的值
+func (hessianCodec) Unmarshal(data []byte, v interface{}) (err error) {
+	obj, err := hessian.NewDecoder(data).Decode()
+	if err != nil {
+		err = fmt.Errorf("hessian decode: %w", err)
+		return
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		err = fmt.Errorf("unmarshal into non-pointer %T", v)
+		return
+	}
+	target := rv.Elem()
+
+	if obj == nil {
+		target.Set(reflect.Zero(target.Type()))
+		return
+	}
+
+	ov := reflect.ValueOf(obj)
+	switch {
+	case ov.Type().AssignableTo(target.Type()):
+		target.Set(ov)
+	case ov.Kind() == reflect.Ptr && ov.Elem().Type().AssignableTo(target.Type()):
+		target.Set(ov.Elem())
+	case convertible(ov.Type(), target.Type()):
+		target.Set(ov.Convert(target.Type()))
+	default:
+		err = fmt.Errorf("cannot assign hessian value %T to %s", obj, target.Type())
+	}
+
+	return
+}
+
+// convertible 只允许数值之间和相同种类的非指针类型之间转换, 避免整数被转换为对应码点的字符串
+func convertible(from, to reflect.Type) bool {
+	if from.Kind() == reflect.Ptr || !from.ConvertibleTo(to) {
+		return false
+	}
+
+	return from.Kind() == to.Kind() || (isNumeric(from.Kind()) && isNumeric(to.Kind()))
+}
+
+func isNumeric(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,192 @@
+package kafka
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// testOrder 测试用的hessian POJO
+type testOrder struct {
+	ID   int64
+	Name string
+}
+
+func (testOrder) JavaClassName() string {
+	return "com.test.Order"
+}
+
+func init() {
+	hessian.RegisterPOJO(&testOrder{})
+}
+
+type orderName string
+
+func TestCodecRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec Codec
+		in    interface{}
+		out   func() interface{} // 返回解码目标的指针
+		want  interface{}
+	}{
+		{name: "json", codec: JSONCodec, in: testOrder{ID: 1, Name: "a"}, out: func() interface{} { return &testOrder{} }, want: &testOrder{ID: 1, Name: "a"}},
+		{name: "protobuf", codec: ProtobufCodec, in: wrapperspb.String("a"), out: func() interface{} { return &wrapperspb.StringValue{} }, want: wrapperspb.String("a")},
+		{name: "hessian pojo", codec: HessianCodec, in: &testOrder{ID: 1, Name: "a"}, out: func() interface{} { return &testOrder{} }, want: &testOrder{ID: 1, Name: "a"}},
+		{name: "hessian string", codec: HessianCodec, in: "a", out: func() interface{} { return new(string) }, want: func() *string { s := "a"; return &s }()},
+		{name: "hessian named string", codec: HessianCodec, in: "a", out: func() interface{} { return new(orderName) }, want: func() *orderName { s := orderName("a"); return &s }()},
+		{name: "hessian int to int64", codec: HessianCodec, in: int32(65), out: func() interface{} { return new(int64) }, want: func() *int64 { n := int64(65); return &n }()},
+		{name: "hessian int to float", codec: HessianCodec, in: int32(65), out: func() interface{} { return new(float64) }, want: func() *float64 { n := 65.0; return &n }()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.codec.Marshal(tt.in)
+			if !assert.Nil(t, err) {
+				return
+			}
+
+			out := tt.out()
+			assert.Nil(t, tt.codec.Unmarshal(data, out))
+			if m, ok := out.(proto.Message); ok {
+				assert.True(t, proto.Equal(tt.want.(proto.Message), m))
+				return
+			}
+			assert.Equal(t, tt.want, out)
+		})
+	}
+}
+
+func TestCodecMismatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec Codec
+		in    interface{}
+		out   interface{}
+	}{
+		{name: "json type mismatch", codec: JSONCodec, in: map[string]string{"ID": "x"}, out: &testOrder{}},
+		{name: "protobuf non message", codec: ProtobufCodec, in: wrapperspb.String("a"), out: new(string)},
+		{name: "hessian int to string", codec: HessianCodec, in: int32(65), out: new(string)},
+		{name: "hessian string to int", codec: HessianCodec, in: "65", out: new(int64)},
+		{name: "hessian bool to int", codec: HessianCodec, in: true, out: new(int64)},
+		{name: "hessian pojo to string", codec: HessianCodec, in: &testOrder{ID: 1}, out: new(string)},
+		{name: "hessian non pointer", codec: HessianCodec, in: "a", out: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.codec.Marshal(tt.in)
+			if !assert.Nil(t, err) {
+				return
+			}
+
+			assert.NotNil(t, tt.codec.Unmarshal(data, tt.out))
+		})
+	}
+
+	_, err := ProtobufCodec.Marshal("a")
+	assert.NotNil(t, err)
+}
+
+func TestTypedMessage(t *testing.T) {
+	reg, err := NewFileSchemaRegistry(t.TempDir(), CompatibilityBackward)
+	if !assert.Nil(t, err) {
+		return
+	}
+	schema, err := SchemaOf(testOrder{})
+	assert.Nil(t, err)
+	_, err = reg.Register("orders", schema)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name        string
+		encode      []TypedOption
+		decode      []TypedOption
+		contentType string
+		version     string
+		headers     func(msg *sarama.ConsumerMessage) // 消费前修改消息头
+		wantErr     error                             // 期望的错误, 为nil时只检查是否出错
+		err         bool
+	}{
+		{name: "json default", contentType: ContentTypeJSON},
+		{name: "hessian by header", encode: []TypedOption{WithCodec(HessianCodec)}, contentType: ContentTypeHessian},
+		{name: "schema", encode: []TypedOption{WithSchema(reg, "orders")}, decode: []TypedOption{WithSchema(reg, "orders")}, contentType: ContentTypeJSON, version: "1"},
+		{
+			name: "unregistered version", encode: []TypedOption{WithSchema(reg, "orders")}, decode: []TypedOption{WithSchema(reg, "orders")},
+			version: "1", headers: func(msg *sarama.ConsumerMessage) { setHeader(msg, HeaderSchemaVersion, "2") },
+			wantErr: ErrSchemaNotFound, err: true,
+		},
+		{
+			name: "invalid version", encode: []TypedOption{WithSchema(reg, "orders")}, decode: []TypedOption{WithSchema(reg, "orders")},
+			version: "1", headers: func(msg *sarama.ConsumerMessage) { setHeader(msg, HeaderSchemaVersion, "x") },
+			err: true,
+		},
+		{name: "missing schema headers", decode: []TypedOption{WithSchema(reg, "orders")}, err: true},
+		{name: "subject mismatch", encode: []TypedOption{WithSchema(reg, "orders")}, decode: []TypedOption{WithSchema(reg, "payments")}, version: "1", err: true},
+		{
+			name:    "unknown content type",
+			headers: func(msg *sarama.ConsumerMessage) { setHeader(msg, HeaderContentType, "text/plain") },
+			err:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := &testOrder{ID: 1, Name: "a"}
+			pmsg, err := EncodeMessage("orders", "k", in, tt.encode...)
+			if !assert.Nil(t, err) {
+				return
+			}
+
+			msg := consumerMessage(pmsg)
+			if tt.contentType != "" {
+				assert.Equal(t, tt.contentType, headerValue(msg, HeaderContentType))
+			}
+			assert.Equal(t, tt.version, headerValue(msg, HeaderSchemaVersion))
+			if tt.headers != nil {
+				tt.headers(msg)
+			}
+
+			out, err := DecodeMessage[*testOrder](msg, tt.decode...)
+			if tt.err {
+				assert.NotNil(t, err)
+				if tt.wantErr != nil {
+					assert.True(t, errors.Is(err, tt.wantErr), err)
+				}
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, in, out)
+		})
+	}
+
+	// 主题没有注册模式时无法编码
+	_, err = EncodeMessage("orders", "", testOrder{}, WithSchema(reg, "payments"))
+	assert.True(t, errors.Is(err, ErrSchemaNotFound), err)
+}
+
+// consumerMessage 返回生产消息对应的消费消息
+func consumerMessage(msg *sarama.ProducerMessage) *sarama.ConsumerMessage {
+	value, _ := msg.Value.Encode()
+	cmsg := &sarama.ConsumerMessage{Topic: msg.Topic, Value: value}
+	for i := range msg.Headers {
+		cmsg.Headers = append(cmsg.Headers, &sarama.RecordHeader{Key: msg.Headers[i].Key, Value: msg.Headers[i].Value})
+	}
+
+	return cmsg
+}
+
+func setHeader(msg *sarama.ConsumerMessage, key, value string) {
+	for _, h := range msg.Headers {
+		if string(h.Key) == key {
+			h.Value = []byte(value)
+			return
+		}
+	}
+	msg.Headers = append(msg.Headers, &sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
@@ -15,10 +15,15 @@ import (
 type GroupConsumer struct {
 	mu               sync.Mutex
 	consumerGroup    sarama.ConsumerGroup
+	group            string
 	topics           []string
 	handleMessage    MessageHandleFunc
 	handleConsumeErr ConsumeErrHandleFunc
+	retryPolicy      RetryPolicy
+	concurrency      Concurrency
+	observer         Observer
 	clean            func()
+	closed           bool
 }
 
 type ConsumeErrHandleFunc func(error)
@@ -39,27 +44,36 @@ type GroupConsumerConf struct {
 }
 
 func NewGroupConsumer(cf *GroupConsumerConf) (consumer *GroupConsumer, err error) {
-	if cf.Ext == nil {
-		cf.Ext = sarama.NewConfig()
-		cf.Ext.Version = sarama.V2_6_0_0
+	// 不修改cf, 保证容器对比新旧配置时未配置Ext的两份配置相等
+	ext := cf.Ext
+	if ext == nil {
+		ext = sarama.NewConfig()
+		ext.Version = sarama.V2_6_0_0
 	}
 
-	cg, err := sarama.NewConsumerGroup(strings.Split(cf.Brokers, ","), cf.GroupID, cf.Ext)
+	cg, err := sarama.NewConsumerGroup(strings.Split(cf.Brokers, ","), cf.GroupID, ext)
 
 	if err != nil {
 		err = fmt.Errorf("sarama.NewConsumerGroup: %w", err)
 		return
 	}
 
-	consumer = &GroupConsumer{
+	consumer = WrapGroupConsumer(cg, strings.Split(cf.Topics, ","))
+	consumer.group = cf.GroupID
+
+	return
+}
+
+// WrapGroupConsumer 以已创建的sarama消费组创建分组消费者, 用于接入kafkatest等自定义的消费组实现
+func WrapGroupConsumer(cg sarama.ConsumerGroup, topics []string) *GroupConsumer {
+	return &GroupConsumer{
 		consumerGroup: cg,
-		topics:        strings.Split(cf.Topics, ","),
-		handleMessage: func(message *sarama.ConsumerMessage) {
+		topics:        topics,
+		handleMessage: func(ctx context.Context, message *sarama.ConsumerMessage) error {
 			// 默认处理函数不会对消息做任何处理
+			return nil
 		},
 	}
-
-	return
 }
 
 // 需要在Run前调用, fn不能为nil
@@ -77,12 +91,41 @@ func (gc *GroupConsumer) SetConsumeErrHandleFunc(f ConsumeErrHandleFunc) {
 	gc.handleConsumeErr = f
 }
 
-// Start 启动分组消费, 调用前应先设置消息处理函数, 否则将返回错误
+// SetRetryPolicy 设置消息处理失败时的重试策略, 需要在Start前调用
+func (gc *GroupConsumer) SetRetryPolicy(policy RetryPolicy) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	gc.retryPolicy = policy
+}
+
+// SetConcurrency 设置消息并发处理配置, 需要在Start前调用
+func (gc *GroupConsumer) SetConcurrency(concurrency Concurrency) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	gc.concurrency = concurrency
+}
+
+// SetObserver 设置消费观测者, 需要在Start前调用
+func (gc *GroupConsumer) SetObserver(obs Observer) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	gc.observer = obs
+}
+
+// Start 启动分组消费, 调用前应先设置消息处理函数, 重复调用或关闭后调用时不做任何处理
 func (gc *GroupConsumer) Start() {
 	gc.mu.Lock()
 	defer gc.mu.Unlock()
 
-	handler := NewGroupConsumerHandler(gc.handleMessage)
+	if gc.clean != nil || gc.closed {
+		return
+	}
+
+	handler := NewGroupConsumerHandler(gc.handleMessage, gc.retryPolicy, gc.concurrency, gc.handleConsumeErr)
+	handler.group, handler.observer = gc.group, gc.observer
 	ctx, cancel := context.WithCancel(context.Background())
 	wg := sync.WaitGroup{}
 
@@ -111,11 +154,14 @@ func (gc *GroupConsumer) Close() (err error) {
 	gc.mu.Lock()
 	defer gc.mu.Unlock()
 
-	if gc.clean == nil {
+	if gc.closed {
 		return
 	}
+	gc.closed = true
 
-	gc.clean()
+	if gc.clean != nil {
+		gc.clean()
+	}
 
 	if err = gc.consumerGroup.Close(); err != nil {
 		err = fmt.Errorf("sarama.ConsumerGroup.Close: %w", err)
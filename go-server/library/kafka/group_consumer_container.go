@@ -6,25 +6,54 @@ package kafka
 import (
 	"errors"
 	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
 
 	"go-server/library/conf"
 )
 
 var (
 	ErrGetGroupConsumerConfFuncIsNil = errors.New("get group consumer conf func is nil")
+	ErrNewGroupConsumerFuncIsNil     = errors.New("new group consumer func is nil")
 )
 
+// GroupConsumerContainer 分组消费者容器, 消息处理函数等设置注册在容器上,
+// 配置更新替换消费者时, 新消费者沿用容器上的设置, 若容器已启动则先启动新消费者, 再异步关闭旧消费者并等待其处理中的消息完成
 type GroupConsumerContainer struct {
 	*conf.Container
+
+	mu               sync.Mutex
+	handleMessage    MessageHandleFunc
+	handleConsumeErr ConsumeErrHandleFunc
+	retryPolicy      RetryPolicy
+	concurrency      Concurrency
+	observer         Observer
+	started          bool
+
+	newConsumer NewGroupConsumerFunc
 }
 
 type GetGroupConsumerConfFunc func() (*GroupConsumerConf, error)
 
+// NewGroupConsumerFunc 以配置创建分组消费者
+type NewGroupConsumerFunc func(cf *GroupConsumerConf) (*GroupConsumer, error)
+
 func NewGroupConsumerContainer(getGroupConsumerCf GetGroupConsumerConfFunc) (ct *GroupConsumerContainer, err error) {
+	return NewGroupConsumerContainerWithFunc(getGroupConsumerCf, NewGroupConsumer)
+}
+
+// NewGroupConsumerContainerWithFunc 创建以newConsumer创建消费者的分组消费者容器, 用于接入kafkatest等自定义的消费组实现
+func NewGroupConsumerContainerWithFunc(getGroupConsumerCf GetGroupConsumerConfFunc, newConsumer NewGroupConsumerFunc) (ct *GroupConsumerContainer, err error) {
 	if getGroupConsumerCf == nil {
 		err = ErrGetGroupConsumerConfFuncIsNil
 		return
 	}
+	if newConsumer == nil {
+		err = ErrNewGroupConsumerFuncIsNil
+		return
+	}
 
 	getObjConf := func() (icf conf.IConf, err error) {
 		icf, err = getGroupConsumerCf()
@@ -36,36 +65,117 @@ func NewGroupConsumerContainer(getGroupConsumerCf GetGroupConsumerConfFunc) (ct
 		return
 	}
 
-	ict, err := conf.NewContainer(getObjConf, compareClientConf, newClientObj, nil)
+	ct = &GroupConsumerContainer{newConsumer: newConsumer}
+
+	ict, err := conf.NewContainer(getObjConf, compareGroupConsumerConf, ct.newGroupConsumerObj, nil)
 	if err != nil {
+		ct = nil
 		err = fmt.Errorf("new conf container: %w", err)
 		return
 	}
 
-	ct = &GroupConsumerContainer{
-		Container: ict,
-	}
+	ct.Container = ict
 
 	return
 }
 
-func newClientObj(icf conf.IConf) (iobj conf.IObject, err error) {
+// SetMessageHandleFunc 设置消息处理函数, 需要在Start前调用
+func (ct *GroupConsumerContainer) SetMessageHandleFunc(f MessageHandleFunc) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	ct.handleMessage = f
+}
+
+// SetConsumeErrHandleFunc 设置消费错误处理函数, 需要在Start前调用
+func (ct *GroupConsumerContainer) SetConsumeErrHandleFunc(f ConsumeErrHandleFunc) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	ct.handleConsumeErr = f
+}
+
+// SetRetryPolicy 设置消息处理失败时的重试策略, 需要在Start前调用
+func (ct *GroupConsumerContainer) SetRetryPolicy(policy RetryPolicy) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	ct.retryPolicy = policy
+}
+
+// SetConcurrency 设置消息并发处理配置, 需要在Start前调用
+func (ct *GroupConsumerContainer) SetConcurrency(concurrency Concurrency) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	ct.concurrency = concurrency
+}
+
+// SetObserver 设置消费观测者, 需要在Start前调用
+func (ct *GroupConsumerContainer) SetObserver(obs Observer) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	ct.observer = obs
+}
+
+// Start 以容器上的设置启动当前消费者, 之后配置更新替换的消费者将自动启动
+func (ct *GroupConsumerContainer) Start() {
+	ct.mu.Lock()
+	ct.started = true
+	ct.mu.Unlock()
+
+	// 不持有ct.mu获取消费者, 避免与Update中调用newGroupConsumerObj形成锁顺序反转,
+	// 期间若发生替换, 新消费者已在newGroupConsumerObj中启动, 这里的Start不会重复启动
+	consumer := ct.MustGetGroupConsumer()
+	defer ct.PutGroupConsumer(consumer)
+
+	ct.setup(consumer)
+	consumer.Start()
+}
+
+// newGroupConsumerObj 创建消费者并应用容器上的设置, 容器已启动时立即启动消费者
+func (ct *GroupConsumerContainer) newGroupConsumerObj(icf conf.IConf) (iobj conf.IObject, err error) {
 	cf, ok := icf.(*GroupConsumerConf)
 	if !ok {
 		err = conf.ErrInvalidConfType
 		return
 	}
 
-	iobj, err = NewGroupConsumer(cf)
+	consumer, err := ct.newConsumer(cf)
 	if err != nil {
 		err = fmt.Errorf("new group consumer: %w", err)
 		return
 	}
 
+	if ct.setup(consumer) {
+		consumer.Start()
+	}
+
+	iobj = consumer
+
+	return
+}
+
+// setup 将容器上的设置应用到消费者, 返回容器是否已启动
+func (ct *GroupConsumerContainer) setup(consumer *GroupConsumer) (started bool) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	if ct.handleMessage != nil {
+		consumer.SetMessageHandleFunc(ct.handleMessage)
+	}
+	consumer.SetConsumeErrHandleFunc(ct.handleConsumeErr)
+	consumer.SetRetryPolicy(ct.retryPolicy)
+	consumer.SetConcurrency(ct.concurrency)
+	consumer.SetObserver(ct.observer)
+
+	started = ct.started
+
 	return
 }
 
-func compareClientConf(iocf, incf conf.IConf) (rst conf.CompareObjConfRst, err error) {
+func compareGroupConsumerConf(iocf, incf conf.IConf) (rst conf.CompareObjConfRst, err error) {
 	ocf, ok := iocf.(*GroupConsumerConf)
 	if !ok {
 		err = conf.ErrInvalidConfType
@@ -81,7 +191,8 @@ func compareClientConf(iocf, incf conf.IConf) (rst conf.CompareObjConfRst, err e
 	switch {
 	case ocf.Brokers != ncf.Brokers,
 		ocf.GroupID != ncf.GroupID,
-		ocf.Topics != ncf.Topics:
+		ocf.Topics != ncf.Topics,
+		!equalSaramaConfig(ocf.Ext, ncf.Ext):
 		rst = conf.CompareObjConfRstNeedReplace
 		return
 
@@ -107,3 +218,137 @@ func (ct *GroupConsumerContainer) PutGroupConsumer(consumer *GroupConsumer) {
 	ct.PutObj(consumer)
 	return
 }
+
+// equalSaramaConfig 对比两份sarama配置中可通过Ext覆盖的值类型字段, 见saramaOverrides
+func equalSaramaConfig(a, b *sarama.Config) bool {
+	if a == b {
+		return true
+	}
+	if a == nil || b == nil {
+		return false
+	}
+
+	return overridesOf(a) == overridesOf(b)
+}
+
+// saramaOverrides sarama配置中参与对比的字段, 不包含TLS配置, 分区器, 本地地址和指标注册表等指针, 函数成员,
+// 再均衡策略以名称对比, 这些成员变化时需同时修改其他字段才会替换对象
+type saramaOverrides struct {
+	AdminTimeout time.Duration
+
+	NetMaxOpenRequests int
+	NetDialTimeout     time.Duration
+	NetReadTimeout     time.Duration
+	NetWriteTimeout    time.Duration
+	NetKeepAlive       time.Duration
+	NetTLSEnable       bool
+	NetSASLEnable      bool
+	NetSASLHandshake   bool
+	NetSASLUser        string
+	NetSASLPassword    string
+
+	MetadataRetryMax         int
+	MetadataRetryBackoff     time.Duration
+	MetadataRefreshFrequency time.Duration
+	MetadataFull             bool
+
+	ProducerMaxMessageBytes  int
+	ProducerRequiredAcks     sarama.RequiredAcks
+	ProducerTimeout          time.Duration
+	ProducerCompression      sarama.CompressionCodec
+	ProducerCompressionLevel int
+	ProducerReturnSuccesses  bool
+	ProducerReturnErrors     bool
+	ProducerFlushBytes       int
+	ProducerFlushMessages    int
+	ProducerFlushFrequency   time.Duration
+	ProducerFlushMaxMessages int
+	ProducerRetryMax         int
+	ProducerRetryBackoff     time.Duration
+
+	ConsumerSessionTimeout        time.Duration
+	ConsumerHeartbeatInterval     time.Duration
+	ConsumerRebalanceStrategy     string
+	ConsumerRebalanceTimeout      time.Duration
+	ConsumerRebalanceRetryMax     int
+	ConsumerRebalanceRetryBackoff time.Duration
+	ConsumerMemberUserData        string
+	ConsumerRetryBackoff          time.Duration
+	ConsumerFetchMin              int32
+	ConsumerFetchDefault          int32
+	ConsumerFetchMax              int32
+	ConsumerMaxWaitTime           time.Duration
+	ConsumerMaxProcessingTime     time.Duration
+	ConsumerReturnErrors          bool
+	ConsumerOffsetsCommitInterval time.Duration
+	ConsumerOffsetsInitial        int64
+	ConsumerOffsetsRetention      time.Duration
+	ConsumerOffsetsRetryMax       int
+
+	ClientID          string
+	ChannelBufferSize int
+	Version           string
+}
+
+func overridesOf(c *sarama.Config) (o saramaOverrides) {
+	o = saramaOverrides{
+		AdminTimeout: c.Admin.Timeout,
+
+		NetMaxOpenRequests: c.Net.MaxOpenRequests,
+		NetDialTimeout:     c.Net.DialTimeout,
+		NetReadTimeout:     c.Net.ReadTimeout,
+		NetWriteTimeout:    c.Net.WriteTimeout,
+		NetKeepAlive:       c.Net.KeepAlive,
+		NetTLSEnable:       c.Net.TLS.Enable,
+		NetSASLEnable:      c.Net.SASL.Enable,
+		NetSASLHandshake:   c.Net.SASL.Handshake,
+		NetSASLUser:        c.Net.SASL.User,
+		NetSASLPassword:    c.Net.SASL.Password,
+
+		MetadataRetryMax:         c.Metadata.Retry.Max,
+		MetadataRetryBackoff:     c.Metadata.Retry.Backoff,
+		MetadataRefreshFrequency: c.Metadata.RefreshFrequency,
+		MetadataFull:             c.Metadata.Full,
+
+		ProducerMaxMessageBytes:  c.Producer.MaxMessageBytes,
+		ProducerRequiredAcks:     c.Producer.RequiredAcks,
+		ProducerTimeout:          c.Producer.Timeout,
+		ProducerCompression:      c.Producer.Compression,
+		ProducerCompressionLevel: c.Producer.CompressionLevel,
+		ProducerReturnSuccesses:  c.Producer.Return.Successes,
+		ProducerReturnErrors:     c.Producer.Return.Errors,
+		ProducerFlushBytes:       c.Producer.Flush.Bytes,
+		ProducerFlushMessages:    c.Producer.Flush.Messages,
+		ProducerFlushFrequency:   c.Producer.Flush.Frequency,
+		ProducerFlushMaxMessages: c.Producer.Flush.MaxMessages,
+		ProducerRetryMax:         c.Producer.Retry.Max,
+		ProducerRetryBackoff:     c.Producer.Retry.Backoff,
+
+		ConsumerSessionTimeout:        c.Consumer.Group.Session.Timeout,
+		ConsumerHeartbeatInterval:     c.Consumer.Group.Heartbeat.Interval,
+		ConsumerRebalanceTimeout:      c.Consumer.Group.Rebalance.Timeout,
+		ConsumerRebalanceRetryMax:     c.Consumer.Group.Rebalance.Retry.Max,
+		ConsumerRebalanceRetryBackoff: c.Consumer.Group.Rebalance.Retry.Backoff,
+		ConsumerMemberUserData:        string(c.Consumer.Group.Member.UserData),
+		ConsumerRetryBackoff:          c.Consumer.Retry.Backoff,
+		ConsumerFetchMin:              c.Consumer.Fetch.Min,
+		ConsumerFetchDefault:          c.Consumer.Fetch.Default,
+		ConsumerFetchMax:              c.Consumer.Fetch.Max,
+		ConsumerMaxWaitTime:           c.Consumer.MaxWaitTime,
+		ConsumerMaxProcessingTime:     c.Consumer.MaxProcessingTime,
+		ConsumerReturnErrors:          c.Consumer.Return.Errors,
+		ConsumerOffsetsCommitInterval: c.Consumer.Offsets.CommitInterval,
+		ConsumerOffsetsInitial:        c.Consumer.Offsets.Initial,
+		ConsumerOffsetsRetention:      c.Consumer.Offsets.Retention,
+		ConsumerOffsetsRetryMax:       c.Consumer.Offsets.Retry.Max,
+
+		ClientID:          c.ClientID,
+		ChannelBufferSize: c.ChannelBufferSize,
+		Version:           c.Version.String(),
+	}
+	if c.Consumer.Group.Rebalance.Strategy != nil {
+		o.ConsumerRebalanceStrategy = c.Consumer.Group.Rebalance.Strategy.Name()
+	}
+
+	return
+}
@@ -0,0 +1,82 @@
+package kafka_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+
+	"go-server/library/kafka"
+	"go-server/library/kafka/kafkatest"
+)
+
+func TestGroupConsumerContainerReplace(t *testing.T) {
+	b := kafkatest.NewBroker()
+
+	var mu sync.Mutex
+	cf := kafka.GroupConsumerConf{Brokers: "a:9092", GroupID: "g", Topics: "t1"}
+	set := func(f func(cf *kafka.GroupConsumerConf)) {
+		mu.Lock()
+		defer mu.Unlock()
+		f(&cf)
+	}
+	ct := kafkatest.NewGroupConsumerContainer(t, b, func() (*kafka.GroupConsumerConf, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		c := cf
+		return &c, nil
+	})
+
+	// 处理函数只在容器上注册一次
+	var h handled
+	ct.SetMessageHandleFunc(func(ctx context.Context, msg *sarama.ConsumerMessage) error {
+		h.add(msg)
+		return nil
+	})
+	ct.Start()
+
+	produce(t, b, "t1", "a")
+	waitCommitted(t, b, "g", "t1", 1)
+	assert.Equal(t, []string{"t1"}, h.get("a"))
+
+	consumer := ct.MustGetGroupConsumer()
+	ct.PutGroupConsumer(consumer)
+
+	// 主题变化时替换消费者, 新消费者沿用容器上的处理函数并已启动
+	set(func(cf *kafka.GroupConsumerConf) { cf.Topics = "t2" })
+	assert.Nil(t, ct.Update())
+	replaced := ct.MustGetGroupConsumer()
+	ct.PutGroupConsumer(replaced)
+	assert.NotSame(t, consumer, replaced)
+	assert.Equal(t, uint64(2), ct.Status().Generation)
+
+	produce(t, b, "t2", "b")
+	waitCommitted(t, b, "g", "t2", 1)
+	assert.Equal(t, []string{"t2"}, h.get("b"))
+
+	// 旧消费者关闭后退出消费组
+	assert.Eventually(t, func() bool { return len(b.Assignment("g")) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// 只修改sarama覆盖配置时同样替换
+	set(func(cf *kafka.GroupConsumerConf) {
+		cf.Ext = sarama.NewConfig()
+		cf.Ext.Consumer.Offsets.Initial = sarama.OffsetOldest
+	})
+	assert.Nil(t, ct.Update())
+	assert.Equal(t, uint64(3), ct.Status().Generation)
+
+	// 内容相同的另一份覆盖配置不替换
+	set(func(cf *kafka.GroupConsumerConf) {
+		cf.Ext = sarama.NewConfig()
+		cf.Ext.Consumer.Offsets.Initial = sarama.OffsetOldest
+	})
+	assert.Nil(t, ct.Update())
+	assert.Equal(t, uint64(3), ct.Status().Generation)
+
+	produce(t, b, "t2", "c")
+	waitCommitted(t, b, "g", "t2", 2)
+	assert.Equal(t, []string{"t2"}, h.get("c"))
+}
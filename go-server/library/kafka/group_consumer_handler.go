@@ -3,36 +3,224 @@
 
 package kafka
 
-import "github.com/Shopify/sarama"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
 
-type MessageHandleFunc func(message *sarama.ConsumerMessage)
+	"github.com/Shopify/sarama"
+
+	"go-server/library/trace"
+)
+
+// ErrConsumeStalled 消息处理失败且无法转发到重试或死信主题, 分区阻塞在该消息上, 交由消费错误处理函数时以此包装
+var ErrConsumeStalled = errors.New("consume stalled")
+
+// MessageHandleFunc 消息处理函数, 返回错误或panic时按重试策略处理, ctx在消费组再均衡或关闭时取消,
+// 并携带从消息头中提取的链路信息和处理消息的span, 可通过trace.FromContext获取
+type MessageHandleFunc func(ctx context.Context, message *sarama.ConsumerMessage) error
+
+func NewGroupConsumerHandler(handleMessage MessageHandleFunc, policy RetryPolicy, concurrency Concurrency, handleErr ConsumeErrHandleFunc) *GroupConsumerHandler {
+	if concurrency.MaxInFlight <= 0 {
+		concurrency.MaxInFlight = concurrency.Workers
+	}
 
-func NewGroupConsumerHandler(handleMessage MessageHandleFunc) *GroupConsumerHandler {
 	return &GroupConsumerHandler{
 		handleMessage: handleMessage,
+		policy:        policy.withDefault(),
+		concurrency:   concurrency,
+		handleErr:     handleErr,
 	}
 }
 
+// GroupConsumerHandler 以至少一次语义消费消息, 仅在消息处理成功或转发到重试主题, 死信主题后提交偏移量,
+// 配置了多个处理协程时, 同一分区内键相同的消息按顺序处理, 偏移量在其之前的所有消息都处理完成后才提交
 type GroupConsumerHandler struct {
-	handleMessage func(message *sarama.ConsumerMessage)
+	handleMessage MessageHandleFunc
+	policy        RetryPolicy
+	concurrency   Concurrency
+	handleErr     ConsumeErrHandleFunc
+
+	// 消费观测者及观测时使用的消费组ID, 未设置观测者时为nil
+	group    string
+	observer Observer
+
+	// 当前会话的工作池, 串行处理时为nil
+	pool *workerPool
 }
 
+// Setup 在每次再均衡后的会话开始时创建工作池
 func (cgh *GroupConsumerHandler) Setup(session sarama.ConsumerGroupSession) error {
+	if cgh.concurrency.Workers > 1 {
+		cgh.pool = newWorkerPool(cgh.concurrency.Workers, cgh.concurrency.MaxInFlight, cgh.process)
+	}
+
 	return nil
 }
 
+// Cleanup 在会话的所有ConsumeClaim返回后关闭工作池, 此时各分区处理中的消息均已完成,
+// 观测者实现了RevokeObserver时通知会话中的分区已释放
 func (cgh *GroupConsumerHandler) Cleanup(sess sarama.ConsumerGroupSession) error {
+	if cgh.pool != nil {
+		cgh.pool.close()
+		cgh.pool = nil
+	}
+
+	if obs, ok := cgh.observer.(RevokeObserver); ok {
+		for topic, partitions := range sess.Claims() {
+			for _, partition := range partitions {
+				obs.ObserveRevoke(cgh.group, topic, partition)
+			}
+		}
+	}
+
 	return nil
 }
 
+// ConsumeClaim 消息处理失败且无法转发时不提交偏移量并在原地重新处理, 会话因再均衡或关闭结束时返回错误, 消息将在再均衡后重新投递
 func (cgh *GroupConsumerHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) (err error) {
+	if cgh.pool != nil {
+		err = cgh.consumeConcurrently(sess, claim)
+	} else {
+		err = cgh.consume(sess, claim)
+	}
+
+	if err != nil && cgh.handleErr != nil {
+		cgh.handleErr(err)
+	}
+
+	return
+}
+
+func (cgh *GroupConsumerHandler) consume(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) (err error) {
 	for message := range claim.Messages() {
-		if cgh.handleMessage != nil {
-			cgh.handleMessage(message)
+		err = cgh.process(sess.Context(), message)
+		cgh.observe(claim, message, err)
+		if err != nil {
+			return
 		}
 
 		sess.MarkMessage(message, "")
 	}
 
-	return nil
+	return
+}
+
+// consumeConcurrently 将分区消息分发到工作池, 处理中的消息达到MaxInFlight时暂停读取,
+// 分区被回收或有消息处理失败时停止分发并取消该分区的ctx, 已分发但尚未开始处理的消息将被跳过,
+// 避免键相同的后续消息越过失败的消息被处理, 这些消息在分区重新分配后从未提交的偏移量处重新投递
+func (cgh *GroupConsumerHandler) consumeConcurrently(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) (err error) {
+	ctx, cancel := context.WithCancel(sess.Context())
+	defer cancel()
+
+	tracker := newOffsetTracker(sess, claim.Topic(), claim.Partition())
+	inflight := make(chan struct{}, cgh.concurrency.MaxInFlight)
+	failed := make(chan struct{})
+
+	var (
+		wg       sync.WaitGroup
+		failOnce sync.Once
+	)
+
+	done := func(message *sarama.ConsumerMessage) func(error) {
+		return func(perr error) {
+			defer wg.Done()
+			defer func() { <-inflight }()
+
+			// 已有消息失败后被跳过或随之取消的消息不再观测
+			if perr != nil {
+				select {
+				case <-failed:
+					return
+				default:
+				}
+			}
+
+			cgh.observe(claim, message, perr)
+
+			if perr != nil {
+				failOnce.Do(func() {
+					err = perr
+					close(failed)
+					cancel()
+				})
+				return
+			}
+
+			tracker.complete(message.Offset)
+		}
+	}
+
+dispatch:
+	for {
+		select {
+		case <-failed:
+			break dispatch
+		case message, ok := <-claim.Messages():
+			if !ok {
+				break dispatch
+			}
+
+			select {
+			case <-failed:
+				break dispatch
+			case inflight <- struct{}{}:
+			}
+
+			tracker.add(message.Offset)
+			wg.Add(1)
+			cgh.pool.submit(&poolTask{
+				ctx:     ctx,
+				message: message,
+				done:    done(message),
+			})
+		}
+	}
+
+	wg.Wait()
+
+	return
+}
+
+// observe 设置了观测者时观测消息的处理结果和分区积压
+func (cgh *GroupConsumerHandler) observe(claim sarama.ConsumerGroupClaim, message *sarama.ConsumerMessage, err error) {
+	if cgh.observer != nil {
+		cgh.observer.ObserveConsume(cgh.group, message.Topic, message.Partition, consumerLag(claim, message), err)
+	}
+}
+
+// process 处理一条消息, 返回nil时可以提交该消息的偏移量, 处理函数的ctx携带消息头中的链路信息和处理消息的span,
+// 按重试策略处理失败且无法转发时, 报告分区阻塞并在MaxBackoff后重新处理, 直到成功或ctx取消, ctx取消时返回错误
+func (cgh *GroupConsumerHandler) process(ctx context.Context, message *sarama.ConsumerMessage) (err error) {
+	if cgh.handleMessage == nil {
+		return
+	}
+
+	ctx, span := startConsumeSpan(ExtractTrace(ctx, message), cgh.group, message)
+	defer trace.EndSpan(span, &err)
+
+	for {
+		if err = cgh.policy.handle(ctx, cgh.handleMessage, message); err == nil || ctx.Err() != nil {
+			return
+		}
+
+		cgh.stall(message, err)
+
+		if werr := sleepContext(ctx, cgh.policy.MaxBackoff); werr != nil {
+			err = fmt.Errorf("%v, retry aborted: %w", err, werr)
+			return
+		}
+	}
+}
+
+// stall 报告分区因message无法处理或转发而阻塞
+func (cgh *GroupConsumerHandler) stall(message *sarama.ConsumerMessage, err error) {
+	if cgh.handleErr != nil {
+		cgh.handleErr(fmt.Errorf("%w at %s/%d/%d: %v", ErrConsumeStalled, message.Topic, message.Partition, message.Offset, err))
+	}
+
+	if cgh.observer != nil {
+		cgh.observer.ObserveStall(cgh.group, message.Topic, message.Partition)
+	}
 }
@@ -0,0 +1,302 @@
+package kafka_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"go-server/library/kafka"
+	"go-server/library/kafka/kafkatest"
+	"go-server/library/metrics"
+)
+
+// stallObserver 记录分区阻塞的次数
+type stallObserver struct {
+	mu     sync.Mutex
+	stalls int
+}
+
+func (o *stallObserver) ObserveProduce(topic string, err error) {}
+
+func (o *stallObserver) ObserveConsume(group, topic string, partition int32, lag int64, err error) {}
+
+func (o *stallObserver) ObserveStall(group, topic string, partition int32) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.stalls++
+}
+
+func (o *stallObserver) count() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.stalls
+}
+
+// handled 记录处理过的消息值及其所在主题
+type handled struct {
+	mu     sync.Mutex
+	values map[string][]string
+}
+
+func (h *handled) add(msg *sarama.ConsumerMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.values == nil {
+		h.values = make(map[string][]string)
+	}
+	h.values[string(msg.Value)] = append(h.values[string(msg.Value)], msg.Topic)
+}
+
+func (h *handled) get(value string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]string(nil), h.values[value]...)
+}
+
+func produce(t *testing.T, b *kafkatest.Broker, topic string, values ...string) {
+	for _, v := range values {
+		_, _, err := b.SendMessage(&sarama.ProducerMessage{Topic: topic, Value: sarama.StringEncoder(v)})
+		assert.Nil(t, err)
+	}
+}
+
+func waitCommitted(t *testing.T, b *kafkatest.Broker, group, topic string, offset int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := b.WaitCommitted(ctx, group, topic, 0, offset); err != nil {
+		t.Fatalf("wait committed %s %d: %v, committed %d", topic, offset, err, b.CommittedOffset(group, topic, 0))
+	}
+}
+
+func TestGroupConsumerStall(t *testing.T) {
+	b := kafkatest.NewBroker()
+	produce(t, b, "t", "a", "poison", "b")
+
+	var (
+		h      handled
+		healed = make(chan struct{})
+		errmu  sync.Mutex
+		errs   []error
+		obs    = &stallObserver{}
+	)
+
+	consumer := kafkatest.NewGroupConsumer(t, b, "g", "t")
+	consumer.SetMessageHandleFunc(func(ctx context.Context, msg *sarama.ConsumerMessage) error {
+		h.add(msg)
+		if string(msg.Value) == "poison" {
+			select {
+			case <-healed:
+			default:
+				return errors.New("boom")
+			}
+		}
+		return nil
+	})
+	consumer.SetConsumeErrHandleFunc(func(err error) {
+		errmu.Lock()
+		defer errmu.Unlock()
+		errs = append(errs, err)
+	})
+	consumer.SetRetryPolicy(kafka.RetryPolicy{MaxAttempts: 1, MaxBackoff: 10 * time.Millisecond})
+	consumer.SetObserver(obs)
+	consumer.Start()
+
+	// 无法转发的消息阻塞分区, 不提交偏移量, 每次重新处理失败都报告阻塞
+	assert.Eventually(t, func() bool { return obs.count() >= 3 }, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, int64(1), b.CommittedOffset("g", "t", 0))
+	assert.Empty(t, h.get("b"))
+
+	errmu.Lock()
+	if assert.NotEmpty(t, errs) {
+		assert.True(t, errors.Is(errs[0], kafka.ErrConsumeStalled))
+		assert.Contains(t, errs[0].Error(), "t/0/1")
+	}
+	errmu.Unlock()
+
+	// 恢复后继续处理并提交
+	close(healed)
+	waitCommitted(t, b, "g", "t", 3)
+	assert.Equal(t, []string{"t"}, h.get("a"))
+	assert.Equal(t, []string{"t"}, h.get("b"))
+}
+
+func TestGroupConsumerDeadLetter(t *testing.T) {
+	b := kafkatest.NewBroker()
+	produce(t, b, "t", "a", "poison", "b")
+
+	// 死信主题暂时不可写时分区阻塞, 可写后转发并提交
+	produceErr := errors.New("dead letter topic unavailable")
+	var unavailable = true
+	var mu sync.Mutex
+	b.SetProduceErrFunc(func(msg *sarama.ProducerMessage) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if msg.Topic == "dead" && unavailable {
+			return produceErr
+		}
+		return nil
+	})
+
+	var h handled
+	obs := &stallObserver{}
+	consumer := kafkatest.NewGroupConsumer(t, b, "g", "t")
+	consumer.SetMessageHandleFunc(func(ctx context.Context, msg *sarama.ConsumerMessage) error {
+		h.add(msg)
+		if string(msg.Value) == "poison" {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	consumer.SetRetryPolicy(kafka.RetryPolicy{
+		MaxAttempts:     2,
+		Backoff:         time.Millisecond,
+		MaxBackoff:      10 * time.Millisecond,
+		DeadLetterTopic: "dead",
+		Producer:        b,
+	})
+	consumer.SetObserver(obs)
+	consumer.Start()
+
+	assert.Eventually(t, func() bool { return obs.count() >= 1 }, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, int64(1), b.CommittedOffset("g", "t", 0))
+
+	mu.Lock()
+	unavailable = false
+	mu.Unlock()
+
+	waitCommitted(t, b, "g", "t", 3)
+	dead := b.Messages("dead")
+	if assert.Len(t, dead, 1) {
+		assert.Equal(t, "poison", string(dead[0].Value))
+	}
+	assert.Equal(t, []string{"t"}, h.get("b"))
+}
+
+func TestGroupConsumerRedelivery(t *testing.T) {
+	b := kafkatest.NewBroker()
+	produce(t, b, "t", "poison", "flaky", "ok")
+
+	var h handled
+	consumer := kafkatest.NewGroupConsumer(t, b, "g", "t", "t.retry")
+	consumer.SetMessageHandleFunc(func(ctx context.Context, msg *sarama.ConsumerMessage) error {
+		h.add(msg)
+		switch {
+		case string(msg.Value) == "poison":
+			return errors.New("boom")
+		case string(msg.Value) == "flaky" && msg.Topic == "t":
+			return errors.New("boom")
+		}
+		return nil
+	})
+	consumer.SetRetryPolicy(kafka.RetryPolicy{
+		MaxAttempts:     1,
+		RetryTopic:      "t.retry",
+		MaxRedeliveries: 2,
+		DeadLetterTopic: "dead",
+		Producer:        b,
+	})
+	consumer.Start()
+
+	// 原主题在转发后立即提交, 不被重试阻塞
+	waitCommitted(t, b, "g", "t", 3)
+	waitCommitted(t, b, "g", "t.retry", 3)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	dead, err := b.WaitMessages(ctx, "dead", 1)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "poison", string(dead[0].Value))
+	}
+
+	assert.Equal(t, []string{"t", "t.retry", "t.retry"}, h.get("poison"))
+	assert.Equal(t, []string{"t", "t.retry"}, h.get("flaky"))
+	assert.Equal(t, []string{"t"}, h.get("ok"))
+}
+
+func TestGroupConsumerConcurrentOrderAfterFailure(t *testing.T) {
+	b := kafkatest.NewBroker()
+	for _, v := range []string{"1", "2", "3"} {
+		_, _, err := b.SendMessage(&sarama.ProducerMessage{Topic: "t", Key: sarama.StringEncoder("k"), Value: sarama.StringEncoder(v)})
+		assert.Nil(t, err)
+	}
+
+	var (
+		mu      sync.Mutex
+		order   []string
+		first   = true
+		started = make(chan struct{})
+	)
+	consumer := kafkatest.NewGroupConsumer(t, b, "g", "t")
+	consumer.SetMessageHandleFunc(func(ctx context.Context, msg *sarama.ConsumerMessage) error {
+		mu.Lock()
+		order = append(order, string(msg.Value))
+		block := first && string(msg.Value) == "1"
+		if block {
+			first = false
+		}
+		mu.Unlock()
+
+		// 首次处理第一条消息时阻塞到会话结束, 使其以失败告终
+		if block {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	})
+	consumer.SetConcurrency(kafka.Concurrency{Workers: 2, MaxInFlight: 3})
+	consumer.Start()
+
+	<-started
+	time.Sleep(20 * time.Millisecond) // 等待后续消息分发到同一协程排队
+	b.Rebalance("g")
+
+	waitCommitted(t, b, "g", "t", 3)
+
+	// 排队中的后续消息在第一条消息失败后被跳过, 分区重新分配后按顺序重新处理
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"1", "1", "2", "3"}, order)
+}
+
+func TestGroupConsumerRevoke(t *testing.T) {
+	b := kafkatest.NewBroker()
+	produce(t, b, "t", "a")
+
+	m := metrics.New("")
+	lags := func() int {
+		n, _ := testutil.GatherAndCount(m.Registry(), "kafka_consumer_lag")
+		return n
+	}
+
+	first := kafkatest.NewGroupConsumer(t, b, "g", "t")
+	first.SetMessageHandleFunc(func(ctx context.Context, msg *sarama.ConsumerMessage) error { return nil })
+	first.SetObserver(m)
+	first.Start()
+
+	waitCommitted(t, b, "g", "t", 1)
+	assert.Equal(t, 1, lags())
+
+	// 新成员加入引起再均衡后删除原会话分区的积压, 分区再次处理消息后重新记录
+	second := kafkatest.NewGroupConsumer(t, b, "g", "t")
+	second.SetMessageHandleFunc(func(ctx context.Context, msg *sarama.ConsumerMessage) error { return nil })
+	second.SetObserver(m)
+	second.Start()
+
+	assert.Eventually(t, func() bool { return lags() == 0 }, 5*time.Second, 5*time.Millisecond)
+
+	produce(t, b, "t", "b")
+	waitCommitted(t, b, "g", "t", 2)
+	assert.Eventually(t, func() bool { return lags() == 1 }, 5*time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,258 @@
+// kafkatest 包提供进程内的Kafka代理模拟实现, 用于在没有Kafka集群时测试基于library/kafka的生产和消费代码,
+// 支持主题分区, 消费组偏移量和再均衡, 并可注入错误和查看已生产的消息
+package kafkatest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// Broker 进程内的Kafka代理, 所有方法并发安全,
+// 生产到不存在的主题或订阅不存在的主题时以默认分区数自动创建主题
+type Broker struct {
+	mu sync.Mutex
+
+	// changed 在任何消息, 偏移量或成员变化时关闭并替换, 用于唤醒等待者
+	changed chan struct{}
+
+	topics            map[string][][]*sarama.ConsumerMessage
+	roundRobin        map[string]int32
+	groups            map[string]*group
+	defaultPartitions int32
+	initialOffset     int64
+	produceErr        func(msg *sarama.ProducerMessage) error
+	memberSeq         int
+}
+
+// NewBroker 创建代理, 自动创建的主题默认1个分区, 没有已提交偏移量的消费组从最早的消息开始消费
+func NewBroker() *Broker {
+	return &Broker{
+		changed:           make(chan struct{}),
+		topics:            make(map[string][][]*sarama.ConsumerMessage),
+		roundRobin:        make(map[string]int32),
+		groups:            make(map[string]*group),
+		defaultPartitions: 1,
+		initialOffset:     sarama.OffsetOldest,
+	}
+}
+
+// SetDefaultPartitions 设置自动创建主题的分区数
+func (b *Broker) SetDefaultPartitions(partitions int32) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.defaultPartitions = partitions
+}
+
+// SetInitialOffset 设置消费组没有已提交偏移量时的起始位置, 可选sarama.OffsetOldest, sarama.OffsetNewest
+func (b *Broker) SetInitialOffset(offset int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.initialOffset = offset
+}
+
+// SetProduceErrFunc 设置生产消息前调用的函数, 返回非nil错误时该消息生产失败且不写入主题, f为nil时取消
+func (b *Broker) SetProduceErrFunc(f func(msg *sarama.ProducerMessage) error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.produceErr = f
+}
+
+// CreateTopic 创建主题, 主题已存在且分区数较少时增加分区
+func (b *Broker) CreateTopic(topic string, partitions int32) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for int32(len(b.topics[topic])) < partitions {
+		b.topics[topic] = append(b.topics[topic], nil)
+	}
+	b.notifyLocked()
+}
+
+// SendMessage 生产一条消息, 消息键非空时按sarama的默认哈希分区器选择分区, 否则轮流选择分区,
+// 成功时设置msg的Partition, Offset和Timestamp, Broker因此也实现了kafka.MessageSender
+func (b *Broker) SendMessage(msg *sarama.ProducerMessage) (partition int32, offset int64, err error) {
+	b.mu.Lock()
+	produceErr := b.produceErr
+	b.mu.Unlock()
+
+	// 在锁外调用, 允许f中调用Broker的方法
+	if produceErr != nil {
+		if err = produceErr(msg); err != nil {
+			return
+		}
+	}
+
+	cm := &sarama.ConsumerMessage{
+		Topic:     msg.Topic,
+		Timestamp: msg.Timestamp,
+	}
+	if cm.Timestamp.IsZero() {
+		cm.Timestamp = time.Now()
+	}
+	if msg.Key != nil {
+		if cm.Key, err = msg.Key.Encode(); err != nil {
+			err = fmt.Errorf("encode key: %w", err)
+			return
+		}
+	}
+	if msg.Value != nil {
+		if cm.Value, err = msg.Value.Encode(); err != nil {
+			err = fmt.Errorf("encode value: %w", err)
+			return
+		}
+	}
+	for i := range msg.Headers {
+		h := msg.Headers[i]
+		cm.Headers = append(cm.Headers, &h)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	partitions := b.partitionsLocked(msg.Topic)
+	if msg.Key != nil {
+		if partition, err = sarama.NewHashPartitioner(msg.Topic).Partition(msg, partitions); err != nil {
+			err = fmt.Errorf("partition: %w", err)
+			return
+		}
+	} else {
+		partition = b.roundRobin[msg.Topic] % partitions
+		b.roundRobin[msg.Topic]++
+	}
+
+	offset = int64(len(b.topics[msg.Topic][partition]))
+	cm.Partition, cm.Offset = partition, offset
+	b.topics[msg.Topic][partition] = append(b.topics[msg.Topic][partition], cm)
+
+	msg.Partition, msg.Offset, msg.Timestamp = partition, offset, cm.Timestamp
+	b.notifyLocked()
+
+	return
+}
+
+// SendMessages 依次生产多条消息, 部分失败时返回sarama.ProducerErrors
+func (b *Broker) SendMessages(msgs []*sarama.ProducerMessage) (err error) {
+	var errs sarama.ProducerErrors
+	for _, msg := range msgs {
+		if _, _, perr := b.SendMessage(msg); perr != nil {
+			errs = append(errs, &sarama.ProducerError{Msg: msg, Err: perr})
+		}
+	}
+
+	if len(errs) > 0 {
+		err = errs
+	}
+
+	return
+}
+
+// Messages 返回主题中的所有消息, 按分区和偏移量排序
+func (b *Broker) Messages(topic string) (messages []*sarama.ConsumerMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, partition := range b.topics[topic] {
+		messages = append(messages, partition...)
+	}
+
+	return
+}
+
+// PartitionMessages 返回主题指定分区中的所有消息
+func (b *Broker) PartitionMessages(topic string, partition int32) (messages []*sarama.ConsumerMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if partition >= 0 && int(partition) < len(b.topics[topic]) {
+		messages = append(messages, b.topics[topic][partition]...)
+	}
+
+	return
+}
+
+// WaitMessages 等待主题中至少有n条消息, 用于测试异步生产, ctx结束时返回ctx的错误
+func (b *Broker) WaitMessages(ctx context.Context, topic string, n int) (messages []*sarama.ConsumerMessage, err error) {
+	err = b.wait(ctx, func() bool {
+		messages = b.Messages(topic)
+		return len(messages) >= n
+	})
+
+	return
+}
+
+// CommittedOffset 返回消费组在分区上已提交的偏移量, 即下一条待消费消息的偏移量, 未提交时返回-1
+func (b *Broker) CommittedOffset(groupID, topic string, partition int32) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if g, ok := b.groups[groupID]; ok {
+		if offset, ok := g.offsets[topicPartition{topic, partition}]; ok {
+			return offset
+		}
+	}
+
+	return -1
+}
+
+// WaitCommitted 等待消费组在分区上已提交的偏移量不小于offset, ctx结束时返回ctx的错误
+func (b *Broker) WaitCommitted(ctx context.Context, groupID, topic string, partition int32, offset int64) error {
+	return b.wait(ctx, func() bool {
+		return b.CommittedOffset(groupID, topic, partition) >= offset
+	})
+}
+
+// wait 在cond返回true或ctx结束前阻塞, cond在锁外调用
+func (b *Broker) wait(ctx context.Context, cond func() bool) (err error) {
+	for {
+		b.mu.Lock()
+		changed := b.changed
+		b.mu.Unlock()
+
+		if cond() {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		case <-changed:
+		}
+	}
+}
+
+func (b *Broker) notifyLocked() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// partitionsLocked 返回主题的分区数, 主题不存在时自动创建
+func (b *Broker) partitionsLocked(topic string) int32 {
+	if _, ok := b.topics[topic]; !ok {
+		b.topics[topic] = make([][]*sarama.ConsumerMessage, b.defaultPartitions)
+	}
+
+	return int32(len(b.topics[topic]))
+}
+
+// readLocked 返回分区中从offset开始的消息
+func (b *Broker) readLocked(topic string, partition int32, offset int64) []*sarama.ConsumerMessage {
+	messages := b.topics[topic][partition]
+	if offset < 0 || offset >= int64(len(messages)) {
+		return nil
+	}
+
+	return messages[offset:]
+}
+
+type topicPartition struct {
+	topic     string
+	partition int32
+}
@@ -0,0 +1,93 @@
+package kafkatest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker(t *testing.T) {
+	b := NewBroker()
+	b.CreateTopic("t", 3)
+
+	// 键相同的消息总是进入同一分区, 偏移量按分区递增
+	var partitions []int32
+	for i := 0; i < 3; i++ {
+		partition, offset, err := b.SendMessage(&sarama.ProducerMessage{Topic: "t", Key: sarama.StringEncoder("k"), Value: sarama.StringEncoder("v")})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(i), offset)
+		partitions = append(partitions, partition)
+	}
+	assert.Equal(t, []int32{partitions[0], partitions[0], partitions[0]}, partitions)
+	assert.Len(t, b.PartitionMessages("t", partitions[0]), 3)
+
+	// 没有键的消息轮流选择分区
+	seen := make(map[int32]bool)
+	for i := 0; i < 3; i++ {
+		partition, _, err := b.SendMessage(&sarama.ProducerMessage{Topic: "t", Value: sarama.StringEncoder("v")})
+		assert.Nil(t, err)
+		seen[partition] = true
+	}
+	assert.Len(t, seen, 3)
+	assert.Len(t, b.Messages("t"), 6)
+
+	msg := b.PartitionMessages("t", partitions[0])[0]
+	assert.Equal(t, "k", string(msg.Key))
+	assert.Equal(t, "v", string(msg.Value))
+	assert.False(t, msg.Timestamp.IsZero())
+}
+
+func TestBrokerProduceErr(t *testing.T) {
+	b := NewBroker()
+	b.SetProduceErrFunc(func(msg *sarama.ProducerMessage) error {
+		if msg.Topic == "bad" {
+			return errors.New("broker unavailable")
+		}
+		return nil
+	})
+
+	// 失败的消息不写入主题
+	_, _, err := b.SendMessage(&sarama.ProducerMessage{Topic: "bad"})
+	assert.NotNil(t, err)
+	assert.Empty(t, b.Messages("bad"))
+
+	err = b.SendMessages([]*sarama.ProducerMessage{{Topic: "bad"}, {Topic: "ok"}})
+	var perrs sarama.ProducerErrors
+	if assert.True(t, errors.As(err, &perrs)) {
+		assert.Len(t, perrs, 1)
+		assert.Equal(t, "bad", perrs[0].Msg.Topic)
+	}
+	assert.Len(t, b.Messages("ok"), 1)
+
+	b.SetProduceErrFunc(nil)
+	_, _, err = b.SendMessage(&sarama.ProducerMessage{Topic: "bad"})
+	assert.Nil(t, err)
+	assert.Len(t, b.Messages("bad"), 1)
+}
+
+func TestBrokerWaitMessages(t *testing.T) {
+	b := NewBroker()
+
+	go func() {
+		for i := 0; i < 2; i++ {
+			_, _, _ = b.SendMessage(&sarama.ProducerMessage{Topic: "t"})
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	messages, err := b.WaitMessages(ctx, "t", 2)
+	assert.Nil(t, err)
+	assert.Len(t, messages, 2)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = b.WaitMessages(ctx, "t", 3)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
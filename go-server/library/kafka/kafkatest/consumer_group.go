@@ -0,0 +1,478 @@
+package kafkatest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+// group 消费组状态, 成员加入, 离开或调用Rebalance时进入新的一代,
+// 新一代的会话在上一代的所有会话结束后才开始, 保证同一分区不会同时被两个成员消费
+type group struct {
+	offsets     map[topicPartition]int64
+	members     map[string][]string // 成员ID到订阅主题
+	generation  int32
+	stop        chan struct{} // 当前一代结束时关闭
+	running     map[int32]int // 各代仍在运行的会话数
+	consumeErrs []error       // 注入的Consume错误, 按顺序返回
+}
+
+func (b *Broker) groupLocked(groupID string) *group {
+	g, ok := b.groups[groupID]
+	if !ok {
+		g = &group{
+			offsets: make(map[topicPartition]int64),
+			members: make(map[string][]string),
+			stop:    make(chan struct{}),
+			running: make(map[int32]int),
+		}
+		b.groups[groupID] = g
+	}
+
+	return g
+}
+
+// rebalanceLocked 结束当前一代, 正在运行的会话的ctx将被取消
+func (b *Broker) rebalanceLocked(g *group) {
+	g.generation++
+	close(g.stop)
+	g.stop = make(chan struct{})
+	b.notifyLocked()
+}
+
+// assignLocked 按分区号轮流将订阅主题的分区分配给订阅了该主题的成员
+func (b *Broker) assignLocked(g *group, memberID string) (claims map[string][]int32) {
+	claims = make(map[string][]int32)
+	ids := sortedMemberIDs(g.members)
+
+	for _, topic := range g.members[memberID] {
+		var subscribers []string
+		for _, id := range ids {
+			for _, t := range g.members[id] {
+				if t == topic {
+					subscribers = append(subscribers, id)
+					break
+				}
+			}
+		}
+
+		for p := int32(0); p < b.partitionsLocked(topic); p++ {
+			if subscribers[int(p)%len(subscribers)] == memberID {
+				claims[topic] = append(claims[topic], p)
+			}
+		}
+	}
+
+	return
+}
+
+// ConsumerGroup 创建加入消费组groupID的成员, 成员在首次调用Consume时加入消费组, 在Close或Consume的ctx取消后离开
+func (b *Broker) ConsumerGroup(groupID string) sarama.ConsumerGroup {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.memberSeq++
+
+	return &consumerGroup{
+		b:        b,
+		groupID:  groupID,
+		memberID: fmt.Sprintf("%s-%d", groupID, b.memberSeq),
+		errors:   make(chan error, 64),
+		closing:  make(chan struct{}),
+	}
+}
+
+// Rebalance 触发消费组再均衡, 当前会话结束后各成员重新分配分区
+func (b *Broker) Rebalance(groupID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rebalanceLocked(b.groupLocked(groupID))
+}
+
+// FailNextConsume 使消费组的下一次Consume调用直接返回err, 多次调用时按顺序依次返回
+func (b *Broker) FailNextConsume(groupID string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g := b.groupLocked(groupID)
+	g.consumeErrs = append(g.consumeErrs, err)
+}
+
+// Generation 返回消费组当前的代数, 每次再均衡加1
+func (b *Broker) Generation(groupID string) int32 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.groupLocked(groupID).generation
+}
+
+// Assignment 返回消费组当前一代中各成员分配到的分区
+func (b *Broker) Assignment(groupID string) (assignment map[string]map[string][]int32) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g := b.groupLocked(groupID)
+	assignment = make(map[string]map[string][]int32, len(g.members))
+	for id := range g.members {
+		assignment[id] = b.assignLocked(g, id)
+	}
+
+	return
+}
+
+func (b *Broker) commit(groupID string, tp topicPartition, offset int64, force bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g := b.groupLocked(groupID)
+	if current, ok := g.offsets[tp]; ok && offset <= current && !force {
+		return
+	}
+	g.offsets[tp] = offset
+	b.notifyLocked()
+}
+
+// consumerGroup 实现sarama.ConsumerGroup, 与sarama一致, 同一成员的Consume调用串行执行
+type consumerGroup struct {
+	b        *Broker
+	groupID  string
+	memberID string
+	errors   chan error
+
+	mu        sync.Mutex
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+func (cg *consumerGroup) Consume(ctx context.Context, topics []string, handler sarama.ConsumerGroupHandler) (err error) {
+	cg.mu.Lock()
+	defer cg.mu.Unlock()
+
+	select {
+	case <-cg.closing:
+		err = sarama.ErrClosedConsumerGroup
+		return
+	default:
+	}
+
+	if len(topics) == 0 {
+		err = fmt.Errorf("no topics provided")
+		return
+	}
+
+	b := cg.b
+	b.mu.Lock()
+	g := b.groupLocked(cg.groupID)
+	if len(g.consumeErrs) > 0 {
+		err = g.consumeErrs[0]
+		g.consumeErrs = g.consumeErrs[1:]
+		b.mu.Unlock()
+		return
+	}
+	if !equalTopics(g.members[cg.memberID], topics) {
+		g.members[cg.memberID] = append([]string(nil), topics...)
+		b.rebalanceLocked(g)
+	}
+	b.mu.Unlock()
+
+	// 等待上一代的会话全部结束后在当前一代开始会话
+	var (
+		generation int32
+		stop       chan struct{}
+		claims     map[string][]int32
+	)
+	joinCtx, cancel := mergeDone(ctx, cg.closing)
+	werr := b.wait(joinCtx, func() bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		for gen, n := range g.running {
+			if gen < g.generation && n > 0 {
+				return false
+			}
+		}
+
+		generation, stop = g.generation, g.stop
+		claims = b.assignLocked(g, cg.memberID)
+		g.running[generation]++
+
+		return true
+	})
+	cancel()
+	if werr != nil {
+		cg.leave()
+		return
+	}
+
+	cg.runSession(ctx, generation, stop, claims, handler)
+
+	b.mu.Lock()
+	if g.running[generation]--; g.running[generation] == 0 {
+		delete(g.running, generation)
+	}
+	b.notifyLocked()
+	b.mu.Unlock()
+
+	if ctx.Err() != nil {
+		cg.leave()
+	}
+
+	return
+}
+
+// runSession 运行一个会话直到再均衡, ctx取消, 成员关闭或任一分区的ConsumeClaim返回, 与sarama一致
+func (cg *consumerGroup) runSession(ctx context.Context, generation int32, stop chan struct{}, claims map[string][]int32, handler sarama.ConsumerGroupHandler) {
+	sessCtx, cancel := mergeDone(ctx, cg.closing, stop)
+	defer cancel()
+
+	sess := &session{
+		cg:         cg,
+		ctx:        sessCtx,
+		generation: generation,
+		claims:     claims,
+	}
+
+	if err := handler.Setup(sess); err != nil {
+		cg.handleError(err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for topic, partitions := range claims {
+		for _, partition := range partitions {
+			claim := cg.newClaim(topic, partition)
+
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				claim.feed(sessCtx)
+			}()
+			go func() {
+				defer wg.Done()
+				defer cancel()
+				if err := handler.ConsumeClaim(sess, claim); err != nil {
+					cg.handleError(err)
+				}
+			}()
+		}
+	}
+
+	<-sessCtx.Done()
+	wg.Wait()
+
+	if err := handler.Cleanup(sess); err != nil {
+		cg.handleError(err)
+	}
+}
+
+func (cg *consumerGroup) newClaim(topic string, partition int32) *claim {
+	b := cg.b
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	offset, ok := b.groupLocked(cg.groupID).offsets[topicPartition{topic, partition}]
+	if !ok {
+		offset = 0
+		if b.initialOffset == sarama.OffsetNewest {
+			offset = int64(len(b.topics[topic][partition]))
+		}
+	}
+
+	return &claim{
+		b:         b,
+		topic:     topic,
+		partition: partition,
+		offset:    offset,
+		messages:  make(chan *sarama.ConsumerMessage, 256),
+	}
+}
+
+func (cg *consumerGroup) handleError(err error) {
+	select {
+	case cg.errors <- err:
+	default:
+	}
+}
+
+// leave 离开消费组, 其他成员再均衡
+func (cg *consumerGroup) leave() {
+	b := cg.b
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g := b.groupLocked(cg.groupID)
+	if _, ok := g.members[cg.memberID]; ok {
+		delete(g.members, cg.memberID)
+		b.rebalanceLocked(g)
+	}
+}
+
+// Errors 返回会话中Setup, ConsumeClaim和Cleanup返回的错误, 缓冲满时丢弃
+func (cg *consumerGroup) Errors() <-chan error {
+	return cg.errors
+}
+
+// Close 结束当前会话并等待Consume返回后离开消费组
+func (cg *consumerGroup) Close() (err error) {
+	first := false
+	cg.closeOnce.Do(func() {
+		first = true
+		close(cg.closing)
+	})
+	if !first {
+		return
+	}
+
+	cg.mu.Lock()
+	defer cg.mu.Unlock()
+
+	cg.leave()
+	close(cg.errors)
+
+	return
+}
+
+// session 实现sarama.ConsumerGroupSession, 标记的偏移量立即提交
+type session struct {
+	cg         *consumerGroup
+	ctx        context.Context
+	generation int32
+	claims     map[string][]int32
+}
+
+func (s *session) Claims() map[string][]int32 {
+	return s.claims
+}
+
+func (s *session) MemberID() string {
+	return s.cg.memberID
+}
+
+func (s *session) GenerationID() int32 {
+	return s.generation
+}
+
+// MarkOffset 与sarama一致, 仅当offset大于已提交的偏移量时提交
+func (s *session) MarkOffset(topic string, partition int32, offset int64, metadata string) {
+	s.cg.b.commit(s.cg.groupID, topicPartition{topic, partition}, offset, false)
+}
+
+// ResetOffset 提交offset, 允许小于已提交的偏移量
+func (s *session) ResetOffset(topic string, partition int32, offset int64, metadata string) {
+	s.cg.b.commit(s.cg.groupID, topicPartition{topic, partition}, offset, true)
+}
+
+func (s *session) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.MarkOffset(msg.Topic, msg.Partition, msg.Offset+1, metadata)
+}
+
+func (s *session) Context() context.Context {
+	return s.ctx
+}
+
+// claim 实现sarama.ConsumerGroupClaim
+type claim struct {
+	b         *Broker
+	topic     string
+	partition int32
+	offset    int64
+	messages  chan *sarama.ConsumerMessage
+}
+
+func (c *claim) Topic() string {
+	return c.topic
+}
+
+func (c *claim) Partition() int32 {
+	return c.partition
+}
+
+func (c *claim) InitialOffset() int64 {
+	return c.offset
+}
+
+func (c *claim) HighWaterMarkOffset() int64 {
+	c.b.mu.Lock()
+	defer c.b.mu.Unlock()
+
+	return int64(len(c.b.topics[c.topic][c.partition]))
+}
+
+func (c *claim) Messages() <-chan *sarama.ConsumerMessage {
+	return c.messages
+}
+
+// feed 持续将分区中的新消息发送到消息信道, ctx结束时关闭信道,
+// 每次投递的是消息的副本, 处理函数修改消息不会影响其他消费组
+func (c *claim) feed(ctx context.Context) {
+	defer close(c.messages)
+
+	offset := c.offset
+	for {
+		c.b.mu.Lock()
+		changed := c.b.changed
+		messages := c.b.readLocked(c.topic, c.partition, offset)
+		c.b.mu.Unlock()
+
+		for _, m := range messages {
+			cp := *m
+			select {
+			case <-ctx.Done():
+				return
+			case c.messages <- &cp:
+				offset++
+			}
+		}
+
+		if len(messages) == 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-changed:
+			}
+		}
+	}
+}
+
+func equalTopics(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// mergeDone 返回在ctx结束或任一chans关闭时结束的ctx
+func mergeDone(ctx context.Context, chans ...chan struct{}) (context.Context, context.CancelFunc) {
+	merged, cancel := context.WithCancel(ctx)
+	for _, ch := range chans {
+		go func(ch chan struct{}) {
+			select {
+			case <-ch:
+				cancel()
+			case <-merged.Done():
+			}
+		}(ch)
+	}
+
+	return merged, cancel
+}
+
+// sortedMemberIDs 按成员ID排序, 保证同一代的各成员计算出相同的分配结果
+func sortedMemberIDs(members map[string][]string) (ids []string) {
+	for id := range members {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return
+}
@@ -0,0 +1,176 @@
+package kafkatest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+// produce 向主题生产n条消息, 消息内容从start开始编号
+func produce(t *testing.T, b *Broker, topic string, start, n int) {
+	t.Helper()
+
+	for i := start; i < start+n; i++ {
+		if _, _, err := b.SendMessage(&sarama.ProducerMessage{Topic: topic, Value: sarama.StringEncoder(fmt.Sprint(i))}); err != nil {
+			t.Fatalf("send message: %v", err)
+		}
+	}
+}
+
+// waitCommitted 等待消费组提交主题所有分区的全部消息
+func waitCommitted(t *testing.T, b *Broker, group, topic string, partitions int32) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for p := int32(0); p < partitions; p++ {
+		if err := b.WaitCommitted(ctx, group, topic, p, int64(len(b.PartitionMessages(topic, p)))); err != nil {
+			t.Fatalf("wait committed partition %d: %v", p, err)
+		}
+	}
+}
+
+func TestConsumerGroup(t *testing.T) {
+	b := NewBroker()
+	b.CreateTopic("t", 4)
+	produce(t, b, "t", 0, 40)
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[string]bool)
+	)
+	handle := func(ctx context.Context, msg *sarama.ConsumerMessage) error {
+		mu.Lock()
+		defer mu.Unlock()
+		seen[string(msg.Value)] = true
+		return nil
+	}
+
+	c1 := NewGroupConsumer(t, b, "g", "t")
+	c1.SetMessageHandleFunc(handle)
+	c1.Start()
+	c2 := NewGroupConsumer(t, b, "g", "t")
+	c2.SetMessageHandleFunc(handle)
+	c2.Start()
+
+	waitCommitted(t, b, "g", "t", 4)
+
+	// 两个成员各分配到不重叠的两个分区
+	assignment := b.Assignment("g")
+	if assert.Len(t, assignment, 2) {
+		var partitions []int32
+		for _, claims := range assignment {
+			assert.Len(t, claims["t"], 2)
+			partitions = append(partitions, claims["t"]...)
+		}
+		assert.ElementsMatch(t, []int32{0, 1, 2, 3}, partitions)
+	}
+
+	// 成员离开后剩余成员接管所有分区
+	generation := b.Generation("g")
+	assert.Nil(t, c2.Close())
+	assert.Greater(t, b.Generation("g"), generation)
+
+	produce(t, b, "t", 40, 20)
+	waitCommitted(t, b, "g", "t", 4)
+
+	assignment = b.Assignment("g")
+	if assert.Len(t, assignment, 1) {
+		for _, claims := range assignment {
+			assert.ElementsMatch(t, []int32{0, 1, 2, 3}, claims["t"])
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, seen, 60)
+}
+
+func TestConsumerGroupRedelivery(t *testing.T) {
+	b := NewBroker()
+	b.CreateTopic("t", 1)
+	produce(t, b, "t", 0, 3)
+
+	// 处理失败的消息重试成功后才提交偏移量, 后续消息不会越过失败的消息
+	var (
+		mu       sync.Mutex
+		attempts = make(map[string]int)
+	)
+	c := NewGroupConsumer(t, b, "g", "t")
+	c.SetMessageHandleFunc(func(ctx context.Context, msg *sarama.ConsumerMessage) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[string(msg.Value)]++
+		if string(msg.Value) == "1" && attempts["1"] == 1 {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	c.Start()
+
+	waitCommitted(t, b, "g", "t", 1)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, attempts["0"])
+	assert.Equal(t, 2, attempts["1"])
+	assert.Equal(t, 1, attempts["2"])
+}
+
+// sessionHandler 记录每个会话的代数, 不处理消息
+type sessionHandler struct {
+	generations chan int32
+}
+
+func (h *sessionHandler) Setup(sess sarama.ConsumerGroupSession) error {
+	h.generations <- sess.GenerationID()
+	return nil
+}
+
+func (h *sessionHandler) Cleanup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (h *sessionHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	<-sess.Context().Done()
+	return nil
+}
+
+func TestConsumerGroupRebalance(t *testing.T) {
+	b := NewBroker()
+	b.CreateTopic("t", 1)
+
+	cg := b.ConsumerGroup("g")
+	defer cg.Close()
+
+	// 注入的错误由下一次Consume直接返回
+	injected := errors.New("injected")
+	b.FailNextConsume("g", injected)
+	assert.Equal(t, injected, cg.Consume(context.Background(), []string{"t"}, &sessionHandler{}))
+
+	h := &sessionHandler{generations: make(chan int32, 2)}
+	done := make(chan error, 1)
+	go func() {
+		for i := 0; i < 2; i++ {
+			if err := cg.Consume(context.Background(), []string{"t"}, h); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	// Rebalance结束当前会话, 下一次Consume在新的一代开始会话
+	first := <-h.generations
+	b.Rebalance("g")
+	assert.Equal(t, first+1, <-h.generations)
+	b.Rebalance("g")
+	assert.Nil(t, <-done)
+}
@@ -0,0 +1,62 @@
+package kafkatest
+
+import (
+	"strings"
+	"testing"
+
+	"go-server/library/kafka"
+)
+
+// NewSyncProducer 创建生产到代理b的同步生产者
+func NewSyncProducer(tb testing.TB, b *Broker) *kafka.SyncProducer {
+	tb.Helper()
+
+	pdr := &kafka.SyncProducer{SyncProducer: b.SyncProducer()}
+	tb.Cleanup(func() {
+		_ = pdr.Close()
+	})
+
+	return pdr
+}
+
+// NewAsyncProducer 创建生产到代理b的异步生产者, 测试结束时关闭
+func NewAsyncProducer(tb testing.TB, b *Broker, onDelivery kafka.DeliveryFunc) *kafka.AsyncProducer {
+	tb.Helper()
+
+	pdr := kafka.WrapAsyncProducer(b.AsyncProducer(), onDelivery)
+	tb.Cleanup(func() {
+		_ = pdr.Close()
+	})
+
+	return pdr
+}
+
+// NewGroupConsumer 创建代理b上消费组groupID的分组消费者, 设置处理函数后调用Start开始消费, 测试结束时关闭
+func NewGroupConsumer(tb testing.TB, b *Broker, groupID string, topics ...string) *kafka.GroupConsumer {
+	tb.Helper()
+
+	consumer := kafka.WrapGroupConsumer(b.ConsumerGroup(groupID), topics)
+	tb.Cleanup(func() {
+		_ = consumer.Close()
+	})
+
+	return consumer
+}
+
+// NewGroupConsumerContainer 创建消费代理b的分组消费者容器, 消费者按getCf返回配置中的GroupID和Topics创建,
+// Brokers和Ext只参与配置对比, 测试结束时关闭
+func NewGroupConsumerContainer(tb testing.TB, b *Broker, getCf kafka.GetGroupConsumerConfFunc) *kafka.GroupConsumerContainer {
+	tb.Helper()
+
+	ct, err := kafka.NewGroupConsumerContainerWithFunc(getCf, func(cf *kafka.GroupConsumerConf) (*kafka.GroupConsumer, error) {
+		return kafka.WrapGroupConsumer(b.ConsumerGroup(cf.GroupID), strings.Split(cf.Topics, ",")), nil
+	})
+	if err != nil {
+		tb.Fatalf("new group consumer container: %v", err)
+	}
+	tb.Cleanup(func() {
+		_ = ct.Close()
+	})
+
+	return ct
+}
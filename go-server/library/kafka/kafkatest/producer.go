@@ -0,0 +1,101 @@
+package kafkatest
+
+import (
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+// SyncProducer 返回生产到代理的sarama.SyncProducer
+func (b *Broker) SyncProducer() sarama.SyncProducer {
+	return &syncProducer{b}
+}
+
+type syncProducer struct {
+	*Broker
+}
+
+func (p *syncProducer) Close() error {
+	return nil
+}
+
+// AsyncProducer 返回生产到代理的sarama.AsyncProducer, 每条消息的结果都会发送到Successes或Errors信道,
+// 与开启了Return.Successes和Return.Errors的sarama生产者一致, 调用方需要持续读取这两个信道
+func (b *Broker) AsyncProducer() sarama.AsyncProducer {
+	p := &asyncProducer{
+		b:         b,
+		input:     make(chan *sarama.ProducerMessage, 256),
+		successes: make(chan *sarama.ProducerMessage, 256),
+		errors:    make(chan *sarama.ProducerError, 256),
+	}
+
+	go p.run()
+
+	return p
+}
+
+type asyncProducer struct {
+	b         *Broker
+	input     chan *sarama.ProducerMessage
+	successes chan *sarama.ProducerMessage
+	errors    chan *sarama.ProducerError
+	closeOnce sync.Once
+}
+
+func (p *asyncProducer) run() {
+	defer close(p.errors)
+	defer close(p.successes)
+
+	for msg := range p.input {
+		if _, _, err := p.b.SendMessage(msg); err != nil {
+			p.errors <- &sarama.ProducerError{Msg: msg, Err: err}
+			continue
+		}
+		p.successes <- msg
+	}
+}
+
+func (p *asyncProducer) AsyncClose() {
+	p.closeOnce.Do(func() {
+		close(p.input)
+	})
+}
+
+// Close 停止接收消息, 等待缓冲中的消息生产完成, 返回生产失败的消息
+func (p *asyncProducer) Close() (err error) {
+	p.AsyncClose()
+
+	var errs sarama.ProducerErrors
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for range p.successes {
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for perr := range p.errors {
+			errs = append(errs, perr)
+		}
+	}()
+	wg.Wait()
+
+	if len(errs) > 0 {
+		err = errs
+	}
+
+	return
+}
+
+func (p *asyncProducer) Input() chan<- *sarama.ProducerMessage {
+	return p.input
+}
+
+func (p *asyncProducer) Successes() <-chan *sarama.ProducerMessage {
+	return p.successes
+}
+
+func (p *asyncProducer) Errors() <-chan *sarama.ProducerError {
+	return p.errors
+}
@@ -0,0 +1,50 @@
+package kafkatest
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncProducer(t *testing.T) {
+	b := NewBroker()
+	pdr := NewSyncProducer(t, b)
+
+	_, offset, err := pdr.SendMessage(&sarama.ProducerMessage{Topic: "t", Value: sarama.StringEncoder("v")})
+	assert.Nil(t, err)
+	assert.Zero(t, offset)
+	assert.Len(t, b.Messages("t"), 1)
+}
+
+func TestAsyncProducer(t *testing.T) {
+	b := NewBroker()
+	b.SetProduceErrFunc(func(msg *sarama.ProducerMessage) error {
+		if msg.Topic == "bad" {
+			return sarama.ErrNotLeaderForPartition
+		}
+		return nil
+	})
+
+	var (
+		mu      sync.Mutex
+		results = make(map[string]error)
+	)
+	pdr := NewAsyncProducer(t, b, func(msg *sarama.ProducerMessage, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[msg.Topic] = err
+	})
+
+	assert.Nil(t, pdr.Send(&sarama.ProducerMessage{Topic: "bad"}, nil))
+	assert.Nil(t, <-pdr.SendChan(&sarama.ProducerMessage{Topic: "ok"}))
+	assert.Nil(t, pdr.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Nil(t, results["ok"])
+	assert.Equal(t, sarama.ErrNotLeaderForPartition, results["bad"])
+	assert.Empty(t, b.Messages("bad"))
+	assert.Len(t, b.Messages("ok"), 1)
+}
@@ -0,0 +1,64 @@
+package kafka
+
+import (
+	"errors"
+
+	"github.com/Shopify/sarama"
+)
+
+// Observer 观测消息的生产和消费, 用于统计, 实现需并发安全
+type Observer interface {
+	// ObserveProduce 每条消息投递完成后调用, 投递成功时err为nil
+	ObserveProduce(topic string, err error)
+
+	// ObserveConsume 每条消息处理完成后调用, lag为此时分区高水位与该消息之间的消息数, 即消费组在该分区的积压,
+	// 处理失败且无法转发到重试或死信主题时err不为nil
+	ObserveConsume(group, topic string, partition int32, lag int64, err error)
+
+	// ObserveStall 消息处理失败且无法转发, 分区因此阻塞在该消息上时调用, 阻塞期间每次重新处理失败都会调用一次
+	ObserveStall(group, topic string, partition int32)
+}
+
+// RevokeObserver 观测分区的释放, Observer同时实现该接口时, 在消费组会话结束时对会话中的每个分区调用,
+// 分区在再均衡后可能分配给其他成员, 实现应删除该分区的积压等状态, 分区再次分配给当前成员后由ObserveConsume重新记录
+type RevokeObserver interface {
+	ObserveRevoke(group, topic string, partition int32)
+}
+
+// observerHolder 使atomic.Value中存储的类型保持一致
+type observerHolder struct {
+	obs Observer
+}
+
+func loadObserver(holder interface{}) Observer {
+	h, _ := holder.(observerHolder)
+	return h.obs
+}
+
+// observeMessages 观测批量发送的结果, err为sarama.ProducerErrors时仅其中的消息视为投递失败
+func observeMessages(obs Observer, msgs []*sarama.ProducerMessage, err error) {
+	var perrs sarama.ProducerErrors
+	if err == nil || !errors.As(err, &perrs) {
+		for _, msg := range msgs {
+			obs.ObserveProduce(msg.Topic, err)
+		}
+		return
+	}
+
+	failed := make(map[*sarama.ProducerMessage]error, len(perrs))
+	for _, perr := range perrs {
+		failed[perr.Msg] = perr.Err
+	}
+	for _, msg := range msgs {
+		obs.ObserveProduce(msg.Topic, failed[msg])
+	}
+}
+
+// consumerLag 返回处理完message后分区的积压消息数
+func consumerLag(claim sarama.ConsumerGroupClaim, message *sarama.ConsumerMessage) (lag int64) {
+	if lag = claim.HighWaterMarkOffset() - message.Offset - 1; lag < 0 {
+		lag = 0
+	}
+
+	return
+}
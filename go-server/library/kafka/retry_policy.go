@@ -0,0 +1,234 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// 消息转发到重试主题或死信主题时附加的消息头
+const (
+	HeaderRetryCount        = "x-retry-count"        // 经重试主题重新投递的次数
+	HeaderRetryNotBefore    = "x-retry-not-before"   // 重试主题中的消息最早可被处理的时间, unix毫秒
+	HeaderError             = "x-error"              // 最后一次处理失败的错误信息
+	HeaderFailedAt          = "x-failed-at"          // 最后一次处理失败的时间, unix毫秒
+	HeaderOriginalTopic     = "x-original-topic"     // 消息最初所在的主题
+	HeaderOriginalPartition = "x-original-partition" // 消息最初所在的分区
+	HeaderOriginalOffset    = "x-original-offset"    // 消息最初所在的偏移量
+)
+
+const (
+	defaultRetryMaxAttempts = 3
+	defaultRetryBackoff     = 100 * time.Millisecond
+	defaultRetryMaxBackoff  = 10 * time.Second
+)
+
+// MessageSender 用于转发消息到重试主题和死信主题的同步发送者, SyncProducer和SyncProducerContainer均实现了该接口
+type MessageSender interface {
+	SendMessage(msg *sarama.ProducerMessage) (partition int32, offset int64, err error)
+}
+
+// RetryPolicy 消息处理失败时的重试策略, 零值成员使用默认值,
+// 消息在进程内最多尝试MaxAttempts次, 仍失败时若配置了RetryTopic且重新投递次数小于MaxRedeliveries则转发到重试主题,
+// 否则若配置了DeadLetterTopic则转发到死信主题, 转发成功后才提交偏移量,
+// 两者都未配置或转发失败时不提交偏移量, 该分区阻塞在这条消息上: 每次失败都交由消费错误处理函数并通知观测者,
+// 以MaxBackoff为间隔重新处理, 直到处理或转发成功, 或再均衡后消息被重新投递,
+// 因此一条始终处理失败的消息(毒消息)会阻塞其所在分区的后续消息, 生产环境应配置死信主题
+type RetryPolicy struct {
+	MaxAttempts     int           // 进程内最大尝试次数, 默认3
+	Backoff         time.Duration // 首次重试前的等待时长, 之后每次加倍, 默认100ms
+	MaxBackoff      time.Duration // 重试等待时长上限, 默认10s
+	RetryTopic      string        // 重试主题, 消费组需同时订阅该主题, 为空时不经重试主题重新投递
+	MaxRedeliveries int           // 经重试主题重新投递的最大次数
+	RedeliveryDelay time.Duration // 重试主题中的消息在转发后至少等待该时长才被处理
+	DeadLetterTopic string        // 死信主题, 为空时不转发死信
+	Producer        MessageSender // 转发消息的生产者, 配置了重试主题或死信主题时不能为nil
+}
+
+func (p RetryPolicy) withDefault() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaultRetryMaxAttempts
+	}
+	if p.Backoff <= 0 {
+		p.Backoff = defaultRetryBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = defaultRetryMaxBackoff
+	}
+	if p.MaxBackoff < p.Backoff {
+		p.MaxBackoff = p.Backoff
+	}
+
+	return p
+}
+
+// handle 按重试策略处理消息, 返回nil表示消息已处理成功或已转发, 可以提交偏移量
+func (p RetryPolicy) handle(ctx context.Context, handleMessage MessageHandleFunc, msg *sarama.ConsumerMessage) (err error) {
+	if err = waitNotBefore(ctx, msg); err != nil {
+		return
+	}
+
+	backoff := p.Backoff
+	for attempt := 1; ; attempt++ {
+		if err = callMessageHandler(ctx, handleMessage, msg); err == nil {
+			return
+		}
+
+		if attempt >= p.MaxAttempts {
+			break
+		}
+
+		if werr := sleepContext(ctx, backoff); werr != nil {
+			err = fmt.Errorf("%v, retry aborted: %w", err, werr)
+			return
+		}
+
+		if backoff *= 2; backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+
+	return p.forward(msg, err)
+}
+
+// forward 将处理失败的消息转发到重试主题或死信主题
+func (p RetryPolicy) forward(msg *sarama.ConsumerMessage, cause error) (err error) {
+	redeliveries := headerInt(msg, HeaderRetryCount)
+
+	var topic string
+	var headers []sarama.RecordHeader
+	switch {
+	case p.RetryTopic != "" && redeliveries < int64(p.MaxRedeliveries):
+		topic = p.RetryTopic
+		headers = forwardHeaders(msg, cause, redeliveries+1, time.Now().Add(p.RedeliveryDelay))
+	case p.DeadLetterTopic != "":
+		topic = p.DeadLetterTopic
+		headers = forwardHeaders(msg, cause, redeliveries, time.Time{})
+	default:
+		err = fmt.Errorf("handle message %s/%d/%d: %w", msg.Topic, msg.Partition, msg.Offset, cause)
+		return
+	}
+
+	if p.Producer == nil {
+		err = fmt.Errorf("forward message %s/%d/%d to %s: producer is nil, cause: %w", msg.Topic, msg.Partition, msg.Offset, topic, cause)
+		return
+	}
+
+	pmsg := &sarama.ProducerMessage{
+		Topic:   topic,
+		Value:   sarama.ByteEncoder(msg.Value),
+		Headers: headers,
+	}
+	if msg.Key != nil {
+		pmsg.Key = sarama.ByteEncoder(msg.Key)
+	}
+
+	if _, _, err = p.Producer.SendMessage(pmsg); err != nil {
+		err = fmt.Errorf("forward message %s/%d/%d to %s: %v, cause: %w", msg.Topic, msg.Partition, msg.Offset, topic, err, cause)
+		return
+	}
+
+	return
+}
+
+// forwardHeaders 保留消息原有的消息头, 并覆盖写入错误信息和原始位置, 原始位置在多次转发时保持为首次失败的位置
+func forwardHeaders(msg *sarama.ConsumerMessage, cause error, redeliveries int64, notBefore time.Time) (headers []sarama.RecordHeader) {
+	now := time.Now()
+	set := map[string]string{
+		HeaderError:    cause.Error(),
+		HeaderFailedAt: strconv.FormatInt(msec(now), 10),
+	}
+	if redeliveries > 0 {
+		set[HeaderRetryCount] = strconv.FormatInt(redeliveries, 10)
+	}
+	if !notBefore.IsZero() {
+		set[HeaderRetryNotBefore] = strconv.FormatInt(msec(notBefore), 10)
+	}
+	if headerValue(msg, HeaderOriginalTopic) == "" {
+		set[HeaderOriginalTopic] = msg.Topic
+		set[HeaderOriginalPartition] = strconv.FormatInt(int64(msg.Partition), 10)
+		set[HeaderOriginalOffset] = strconv.FormatInt(msg.Offset, 10)
+	}
+
+	for _, h := range msg.Headers {
+		if h == nil {
+			continue
+		}
+		if _, ok := set[string(h.Key)]; ok || string(h.Key) == HeaderRetryNotBefore {
+			continue
+		}
+		headers = append(headers, *h)
+	}
+
+	for _, key := range []string{
+		HeaderRetryCount, HeaderRetryNotBefore, HeaderError, HeaderFailedAt,
+		HeaderOriginalTopic, HeaderOriginalPartition, HeaderOriginalOffset,
+	} {
+		if v, ok := set[key]; ok {
+			headers = append(headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(v)})
+		}
+	}
+
+	return
+}
+
+// waitNotBefore 重试主题中的消息未到可处理时间时等待
+func waitNotBefore(ctx context.Context, msg *sarama.ConsumerMessage) (err error) {
+	notBefore := headerInt(msg, HeaderRetryNotBefore)
+	if notBefore <= 0 {
+		return
+	}
+
+	if d := time.Until(time.Unix(0, notBefore*int64(time.Millisecond))); d > 0 {
+		err = sleepContext(ctx, d)
+	}
+
+	return
+}
+
+// callMessageHandler 调用消息处理函数, 将panic转换为错误
+func callMessageHandler(ctx context.Context, handleMessage MessageHandleFunc, msg *sarama.ConsumerMessage) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return handleMessage(ctx, msg)
+}
+
+// headerValue 返回消息中键为key的最后一个消息头的值, 不存在时返回空字符串
+func headerValue(msg *sarama.ConsumerMessage, key string) (val string) {
+	for _, h := range msg.Headers {
+		if h != nil && string(h.Key) == key {
+			val = string(h.Value)
+		}
+	}
+
+	return
+}
+
+func headerInt(msg *sarama.ConsumerMessage, key string) (n int64) {
+	n, _ = strconv.ParseInt(headerValue(msg, key), 10, 64)
+	return
+}
+
+func sleepContext(ctx context.Context, d time.Duration) (err error) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timer.C:
+	}
+
+	return
+}
+
+func msec(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
@@ -0,0 +1,136 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+// captureSender 记录转发的消息, err不为nil时转发失败
+type captureSender struct {
+	msgs []*sarama.ProducerMessage
+	err  error
+}
+
+func (s *captureSender) SendMessage(msg *sarama.ProducerMessage) (partition int32, offset int64, err error) {
+	if s.err != nil {
+		err = s.err
+		return
+	}
+
+	s.msgs = append(s.msgs, msg)
+
+	return
+}
+
+// consumed 将转发的消息转换为从主题中消费到的消息
+func consumed(msg *sarama.ProducerMessage) *sarama.ConsumerMessage {
+	cm := &sarama.ConsumerMessage{Topic: msg.Topic}
+	cm.Value, _ = msg.Value.Encode()
+	for i := range msg.Headers {
+		cm.Headers = append(cm.Headers, &msg.Headers[i])
+	}
+
+	return cm
+}
+
+func TestRetryPolicy(t *testing.T) {
+	sender := &captureSender{}
+	policy := RetryPolicy{
+		Backoff:         time.Millisecond,
+		RetryTopic:      "retry",
+		MaxRedeliveries: 1,
+		RedeliveryDelay: 30 * time.Millisecond,
+		DeadLetterTopic: "dead",
+		Producer:        sender,
+	}.withDefault()
+
+	var calls int
+	failing := func(ctx context.Context, msg *sarama.ConsumerMessage) error {
+		calls++
+		return errors.New("boom")
+	}
+
+	msg := &sarama.ConsumerMessage{
+		Topic:     "t",
+		Partition: 2,
+		Offset:    7,
+		Value:     []byte("v"),
+		Headers:   []*sarama.RecordHeader{{Key: []byte("trace"), Value: []byte("abc")}},
+	}
+
+	// 进程内重试MaxAttempts次后转发到重试主题
+	assert.Nil(t, policy.handle(context.Background(), failing, msg))
+	assert.Equal(t, 3, calls)
+	if !assert.Len(t, sender.msgs, 1) {
+		return
+	}
+	retried := consumed(sender.msgs[0])
+	assert.Equal(t, "retry", retried.Topic)
+	assert.Equal(t, "abc", headerValue(retried, "trace"))
+	assert.Equal(t, "1", headerValue(retried, HeaderRetryCount))
+	assert.Equal(t, "boom", headerValue(retried, HeaderError))
+	assert.Equal(t, "t", headerValue(retried, HeaderOriginalTopic))
+	assert.Equal(t, "2", headerValue(retried, HeaderOriginalPartition))
+	assert.Equal(t, "7", headerValue(retried, HeaderOriginalOffset))
+
+	// 重新投递的消息等待RedeliveryDelay后处理, 超过MaxRedeliveries后转发到死信主题
+	start := time.Now()
+	assert.Nil(t, policy.handle(context.Background(), failing, retried))
+	assert.True(t, time.Since(start) >= 20*time.Millisecond)
+	if !assert.Len(t, sender.msgs, 2) {
+		return
+	}
+	dead := consumed(sender.msgs[1])
+	assert.Equal(t, "dead", dead.Topic)
+	assert.Equal(t, "1", headerValue(dead, HeaderRetryCount))
+	assert.Equal(t, "", headerValue(dead, HeaderRetryNotBefore))
+	assert.Equal(t, "t", headerValue(dead, HeaderOriginalTopic))
+	assert.Equal(t, "7", headerValue(dead, HeaderOriginalOffset))
+
+	// 重试中成功时不转发
+	calls = 0
+	flaky := func(ctx context.Context, msg *sarama.ConsumerMessage) error {
+		if calls++; calls < 2 {
+			return errors.New("boom")
+		}
+		return nil
+	}
+	assert.Nil(t, policy.handle(context.Background(), flaky, msg))
+	assert.Equal(t, 2, calls)
+	assert.Len(t, sender.msgs, 2)
+}
+
+func TestRetryPolicyNotForwarded(t *testing.T) {
+	panicking := func(ctx context.Context, msg *sarama.ConsumerMessage) error {
+		panic("boom")
+	}
+	msg := &sarama.ConsumerMessage{Topic: "t"}
+
+	// 未配置重试和死信主题
+	err := RetryPolicy{MaxAttempts: 1}.withDefault().handle(context.Background(), panicking, msg)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "panic: boom")
+	}
+
+	// 转发失败
+	sender := &captureSender{err: errors.New("broker down")}
+	policy := RetryPolicy{MaxAttempts: 1, DeadLetterTopic: "dead", Producer: sender}.withDefault()
+	err = policy.handle(context.Background(), panicking, msg)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "broker down")
+	}
+
+	// 重试等待期间ctx取消
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	policy = RetryPolicy{Backoff: time.Minute, DeadLetterTopic: "dead", Producer: &captureSender{}}.withDefault()
+	err = policy.handle(ctx, func(ctx context.Context, msg *sarama.ConsumerMessage) error {
+		return errors.New("boom")
+	}, msg)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
@@ -0,0 +1,453 @@
+package kafka
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrSchemaNotFound     = errors.New("schema not found")
+	ErrIncompatibleSchema = errors.New("incompatible schema")
+)
+
+// 字段类型, 与JSON的值类型对应
+const (
+	SchemaTypeString  = "string"
+	SchemaTypeInteger = "integer"
+	SchemaTypeNumber  = "number"
+	SchemaTypeBoolean = "boolean"
+	SchemaTypeArray   = "array"
+	SchemaTypeObject  = "object"
+)
+
+// Compatibility 注册新版本模式时的兼容性检查级别
+type Compatibility string
+
+const (
+	// CompatibilityNone 不检查
+	CompatibilityNone Compatibility = "NONE"
+
+	// CompatibilityBackward 新版本可以读取上一版本写入的消息: 新版本的必填字段在上一版本中也必填, 同名字段类型不变
+	CompatibilityBackward Compatibility = "BACKWARD"
+
+	// CompatibilityForward 上一版本可以读取新版本写入的消息: 上一版本的必填字段在新版本中也必填, 同名字段类型不变
+	CompatibilityForward Compatibility = "FORWARD"
+
+	// CompatibilityFull 同时满足BACKWARD和FORWARD
+	CompatibilityFull Compatibility = "FULL"
+)
+
+// Schema 消息模式, 描述消息顶层字段的名称, 类型和是否必填
+type Schema struct {
+	Fields []SchemaField `json:"fields"`
+}
+
+// SchemaField 消息字段
+type SchemaField struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Required bool   `json:"required,omitempty"`
+}
+
+// SchemaRegistry 模式注册中心, 生产时查询主题最新版本写入x-schema-version消息头, 消费时校验该版本已注册
+type SchemaRegistry interface {
+	// Register 注册模式并返回版本号, 与已注册的某个版本相同时返回该版本号, 与最新版本不兼容时返回ErrIncompatibleSchema
+	Register(subject string, schema *Schema) (version int, err error)
+
+	// Latest 返回主题的最新版本, 主题不存在时返回ErrSchemaNotFound
+	Latest(subject string) (version int, schema *Schema, err error)
+
+	// Lookup 返回主题的指定版本, 不存在时返回ErrSchemaNotFound
+	Lookup(subject string, version int) (schema *Schema, err error)
+}
+
+var subjectPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// FileSchemaRegistry 基于本地文件的模式注册中心, 用于开发测试时代替远程的注册中心,
+// 每个主题保存为目录下的<subject>.json, 文件中按顺序记录各版本的模式,
+// 已读取的主题缓存在内存中, 其他进程注册的新版本在Register或Lookup未命中时重新读取文件后可见
+type FileSchemaRegistry struct {
+	dir           string
+	compatibility Compatibility
+
+	mu       sync.Mutex
+	subjects map[string][]*schemaVersion
+}
+
+type schemaVersion struct {
+	Version      int       `json:"version"`
+	Schema       *Schema   `json:"schema"`
+	RegisteredAt time.Time `json:"registered_at"`
+}
+
+// NewFileSchemaRegistry 以目录dir创建注册中心, 目录不存在时创建, compatibility为空时使用BACKWARD
+func NewFileSchemaRegistry(dir string, compatibility Compatibility) (reg *FileSchemaRegistry, err error) {
+	if compatibility == "" {
+		compatibility = CompatibilityBackward
+	}
+	switch compatibility {
+	case CompatibilityNone, CompatibilityBackward, CompatibilityForward, CompatibilityFull:
+	default:
+		err = fmt.Errorf("unsupported compatibility %q", compatibility)
+		return
+	}
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		err = fmt.Errorf("os.MkdirAll: %w", err)
+		return
+	}
+
+	reg = &FileSchemaRegistry{
+		dir:           dir,
+		compatibility: compatibility,
+		subjects:      make(map[string][]*schemaVersion),
+	}
+
+	return
+}
+
+func (reg *FileSchemaRegistry) Register(subject string, schema *Schema) (version int, err error) {
+	if err = validateSchema(schema); err != nil {
+		return
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	versions, err := reg.load(subject)
+	if err != nil {
+		return
+	}
+
+	for _, sv := range versions {
+		if equalSchema(sv.Schema, schema) {
+			version = sv.Version
+			return
+		}
+	}
+
+	if len(versions) > 0 {
+		latest := versions[len(versions)-1]
+		if err = checkCompatibility(reg.compatibility, latest.Schema, schema); err != nil {
+			err = fmt.Errorf("subject %s version %d: %w", subject, latest.Version, err)
+			return
+		}
+		version = latest.Version + 1
+	} else {
+		version = 1
+	}
+
+	versions = append(versions, &schemaVersion{
+		Version:      version,
+		Schema:       schema,
+		RegisteredAt: time.Now(),
+	})
+	if err = reg.save(subject, versions); err != nil {
+		version = 0
+		return
+	}
+	reg.subjects[subject] = versions
+
+	return
+}
+
+func (reg *FileSchemaRegistry) Latest(subject string) (version int, schema *Schema, err error) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	versions, ok := reg.subjects[subject]
+	if !ok {
+		if versions, err = reg.load(subject); err != nil {
+			return
+		}
+	}
+
+	if len(versions) == 0 {
+		err = fmt.Errorf("subject %s: %w", subject, ErrSchemaNotFound)
+		return
+	}
+
+	latest := versions[len(versions)-1]
+	version, schema = latest.Version, latest.Schema
+
+	return
+}
+
+func (reg *FileSchemaRegistry) Lookup(subject string, version int) (schema *Schema, err error) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	find := func(versions []*schemaVersion) *Schema {
+		for _, sv := range versions {
+			if sv.Version == version {
+				return sv.Schema
+			}
+		}
+		return nil
+	}
+
+	if schema = find(reg.subjects[subject]); schema != nil {
+		return
+	}
+
+	versions, err := reg.load(subject)
+	if err != nil {
+		return
+	}
+
+	if schema = find(versions); schema == nil {
+		err = fmt.Errorf("subject %s version %d: %w", subject, version, ErrSchemaNotFound)
+	}
+
+	return
+}
+
+// load 从文件读取主题的所有版本并更新缓存, 文件不存在时返回空列表
+func (reg *FileSchemaRegistry) load(subject string) (versions []*schemaVersion, err error) {
+	if !subjectPattern.MatchString(subject) {
+		err = fmt.Errorf("invalid subject %q", subject)
+		return
+	}
+
+	b, err := os.ReadFile(reg.path(subject))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+			return
+		}
+		err = fmt.Errorf("os.ReadFile: %w", err)
+		return
+	}
+
+	if err = json.Unmarshal(b, &versions); err != nil {
+		err = fmt.Errorf("unmarshal subject %s: %w", subject, err)
+		return
+	}
+	reg.subjects[subject] = versions
+
+	return
+}
+
+// save 先写临时文件再重命名, 避免其他进程读到写了一半的文件
+func (reg *FileSchemaRegistry) save(subject string, versions []*schemaVersion) (err error) {
+	b, err := json.MarshalIndent(versions, "", "  ")
+	if err != nil {
+		err = fmt.Errorf("marshal subject %s: %w", subject, err)
+		return
+	}
+
+	f, err := os.CreateTemp(reg.dir, "."+subject+".*.tmp")
+	if err != nil {
+		err = fmt.Errorf("os.CreateTemp: %w", err)
+		return
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = f.Write(b); err != nil {
+		_ = f.Close()
+		err = fmt.Errorf("write %s: %w", f.Name(), err)
+		return
+	}
+	if err = f.Close(); err != nil {
+		err = fmt.Errorf("close %s: %w", f.Name(), err)
+		return
+	}
+
+	if err = os.Rename(f.Name(), reg.path(subject)); err != nil {
+		err = fmt.Errorf("os.Rename: %w", err)
+		return
+	}
+
+	return
+}
+
+func (reg *FileSchemaRegistry) path(subject string) string {
+	return filepath.Join(reg.dir, subject+".json")
+}
+
+func validateSchema(schema *Schema) (err error) {
+	if schema == nil {
+		err = errors.New("schema is nil")
+		return
+	}
+
+	names := make(map[string]bool, len(schema.Fields))
+	for _, f := range schema.Fields {
+		if f.Name == "" {
+			err = errors.New("schema field name is empty")
+			return
+		}
+		if names[f.Name] {
+			err = fmt.Errorf("duplicate schema field %q", f.Name)
+			return
+		}
+		names[f.Name] = true
+
+		switch f.Type {
+		case SchemaTypeString, SchemaTypeInteger, SchemaTypeNumber, SchemaTypeBoolean, SchemaTypeArray, SchemaTypeObject:
+		default:
+			err = fmt.Errorf("schema field %q has unsupported type %q", f.Name, f.Type)
+			return
+		}
+	}
+
+	return
+}
+
+// equalSchema 字段顺序不影响比较结果
+func equalSchema(a, b *Schema) bool {
+	if len(a.Fields) != len(b.Fields) {
+		return false
+	}
+
+	fields := schemaFields(a)
+	for _, f := range b.Fields {
+		if af, ok := fields[f.Name]; !ok || af != f {
+			return false
+		}
+	}
+
+	return true
+}
+
+func checkCompatibility(compatibility Compatibility, prev, next *Schema) (err error) {
+	switch compatibility {
+	case CompatibilityBackward:
+		err = checkReadable(next, prev)
+	case CompatibilityForward:
+		err = checkReadable(prev, next)
+	case CompatibilityFull:
+		if err = checkReadable(next, prev); err == nil {
+			err = checkReadable(prev, next)
+		}
+	}
+
+	return
+}
+
+// checkReadable 检查以reader模式能否读取以writer模式写入的消息
+func checkReadable(reader, writer *Schema) (err error) {
+	written := schemaFields(writer)
+
+	var problems []string
+	for _, f := range reader.Fields {
+		wf, ok := written[f.Name]
+		switch {
+		case !ok && f.Required:
+			problems = append(problems, fmt.Sprintf("required field %q is missing in writer schema", f.Name))
+		case !ok:
+		case wf.Type != f.Type:
+			problems = append(problems, fmt.Sprintf("field %q changed type from %s to %s", f.Name, wf.Type, f.Type))
+		case f.Required && !wf.Required:
+			problems = append(problems, fmt.Sprintf("field %q is required but optional in writer schema", f.Name))
+		}
+	}
+
+	if len(problems) > 0 {
+		err = fmt.Errorf("%w: %s", ErrIncompatibleSchema, strings.Join(problems, "; "))
+	}
+
+	return
+}
+
+func schemaFields(schema *Schema) map[string]SchemaField {
+	fields := make(map[string]SchemaField, len(schema.Fields))
+	for _, f := range schema.Fields {
+		fields[f.Name] = f
+	}
+
+	return fields
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// SchemaOf 按结构体字段的json标签生成模式, v为结构体或结构体指针,
+// 指针字段和带omitempty的字段为选填, 匿名嵌入且无json标签的结构体字段展开到上层
+func SchemaOf(v interface{}) (schema *Schema, err error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		err = fmt.Errorf("SchemaOf: %T is not a struct", v)
+		return
+	}
+
+	schema = &Schema{Fields: structFields(t)}
+
+	return
+}
+
+func structFields(t reflect.Type) (fields []SchemaField) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		ft := sf.Type
+		optional := strings.Contains(","+opts+",", ",omitempty,")
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+			optional = true
+		}
+
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			fields = append(fields, structFields(ft)...)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		fields = append(fields, SchemaField{
+			Name:     name,
+			Type:     schemaType(ft),
+			Required: !optional,
+		})
+	}
+
+	return
+}
+
+func schemaType(t reflect.Type) string {
+	if t == timeType {
+		return SchemaTypeString
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return SchemaTypeString
+	case reflect.Bool:
+		return SchemaTypeBoolean
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return SchemaTypeInteger
+	case reflect.Float32, reflect.Float64:
+		return SchemaTypeNumber
+	case reflect.Slice:
+		// []byte按base64编码为字符串
+		if t.Elem().Kind() == reflect.Uint8 {
+			return SchemaTypeString
+		}
+		return SchemaTypeArray
+	case reflect.Array:
+		return SchemaTypeArray
+	default:
+		return SchemaTypeObject
+	}
+}
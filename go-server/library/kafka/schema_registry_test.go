@@ -0,0 +1,164 @@
+package kafka
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSchemaRegistryCompatibility(t *testing.T) {
+	v1 := &Schema{Fields: []SchemaField{
+		{Name: "id", Type: SchemaTypeInteger, Required: true},
+		{Name: "name", Type: SchemaTypeString, Required: true},
+		{Name: "note", Type: SchemaTypeString},
+	}}
+	with := func(fields ...SchemaField) *Schema {
+		return &Schema{Fields: fields}
+	}
+	id, name, note := v1.Fields[0], v1.Fields[1], v1.Fields[2]
+
+	tests := []struct {
+		name string
+		next *Schema
+		ok   map[Compatibility]bool // 各兼容性级别下是否可以注册
+	}{
+		{
+			name: "add optional field",
+			next: with(id, name, note, SchemaField{Name: "tag", Type: SchemaTypeString}),
+			ok:   map[Compatibility]bool{CompatibilityBackward: true, CompatibilityForward: true, CompatibilityFull: true},
+		},
+		{
+			name: "add required field",
+			next: with(id, name, note, SchemaField{Name: "tag", Type: SchemaTypeString, Required: true}),
+			ok:   map[Compatibility]bool{CompatibilityForward: true},
+		},
+		{
+			name: "remove required field",
+			next: with(id, note),
+			ok:   map[Compatibility]bool{CompatibilityBackward: true},
+		},
+		{
+			name: "remove optional field",
+			next: with(id, name),
+			ok:   map[Compatibility]bool{CompatibilityBackward: true, CompatibilityForward: true, CompatibilityFull: true},
+		},
+		{
+			name: "make optional field required",
+			next: with(id, name, SchemaField{Name: "note", Type: SchemaTypeString, Required: true}),
+			ok:   map[Compatibility]bool{CompatibilityForward: true},
+		},
+		{
+			name: "change field type",
+			next: with(SchemaField{Name: "id", Type: SchemaTypeString, Required: true}, name, note),
+			ok:   map[Compatibility]bool{},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, c := range []Compatibility{CompatibilityNone, CompatibilityBackward, CompatibilityForward, CompatibilityFull} {
+			t.Run(tt.name+"/"+string(c), func(t *testing.T) {
+				reg, err := NewFileSchemaRegistry(t.TempDir(), c)
+				if !assert.Nil(t, err) {
+					return
+				}
+				version, err := reg.Register("orders", v1)
+				assert.Nil(t, err)
+				assert.Equal(t, 1, version)
+
+				version, err = reg.Register("orders", tt.next)
+				if c == CompatibilityNone || tt.ok[c] {
+					assert.Nil(t, err)
+					assert.Equal(t, 2, version)
+					return
+				}
+				assert.True(t, errors.Is(err, ErrIncompatibleSchema), err)
+
+				// 不兼容的版本不会注册
+				version, _, err = reg.Latest("orders")
+				assert.Nil(t, err)
+				assert.Equal(t, 1, version)
+			})
+		}
+	}
+}
+
+func TestFileSchemaRegistry(t *testing.T) {
+	dir := t.TempDir()
+	reg, err := NewFileSchemaRegistry(dir, "")
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	schema := &Schema{Fields: []SchemaField{
+		{Name: "id", Type: SchemaTypeInteger, Required: true},
+		{Name: "name", Type: SchemaTypeString},
+	}}
+	version, err := reg.Register("orders", schema)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, version)
+
+	// 与已注册的版本相同时返回该版本, 字段顺序不影响
+	version, err = reg.Register("orders", &Schema{Fields: []SchemaField{schema.Fields[1], schema.Fields[0]}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, version)
+
+	// 其他实例可以读取已注册的版本
+	other, err := NewFileSchemaRegistry(dir, CompatibilityBackward)
+	assert.Nil(t, err)
+	got, err := other.Lookup("orders", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, schema, got)
+
+	_, err = other.Lookup("orders", 2)
+	assert.True(t, errors.Is(err, ErrSchemaNotFound), err)
+	_, _, err = other.Latest("payments")
+	assert.True(t, errors.Is(err, ErrSchemaNotFound), err)
+
+	invalid := []struct {
+		name    string
+		subject string
+		schema  *Schema
+	}{
+		{name: "invalid subject", subject: "../orders", schema: schema},
+		{name: "nil schema", subject: "orders"},
+		{name: "empty field name", subject: "orders", schema: &Schema{Fields: []SchemaField{{Type: SchemaTypeString}}}},
+		{name: "duplicate field", subject: "orders", schema: &Schema{Fields: []SchemaField{schema.Fields[0], schema.Fields[0]}}},
+		{name: "unsupported type", subject: "orders", schema: &Schema{Fields: []SchemaField{{Name: "id", Type: "int"}}}},
+	}
+	for _, tt := range invalid {
+		_, err = reg.Register(tt.subject, tt.schema)
+		assert.NotNil(t, err, tt.name)
+	}
+
+	_, err = NewFileSchemaRegistry(dir, "LATEST")
+	assert.NotNil(t, err)
+}
+
+func TestSchemaOf(t *testing.T) {
+	type base struct {
+		CreatedAt string `json:"created_at"`
+	}
+	type order struct {
+		base
+		ID     int64    `json:"id"`
+		Name   *string  `json:"name"`
+		Tags   []string `json:"tags,omitempty"`
+		Data   []byte   `json:"data"`
+		Ignore int      `json:"-"`
+		secret int
+	}
+
+	schema, err := SchemaOf(&order{})
+	assert.Nil(t, err)
+	assert.Equal(t, []SchemaField{
+		{Name: "created_at", Type: SchemaTypeString, Required: true},
+		{Name: "id", Type: SchemaTypeInteger, Required: true},
+		{Name: "name", Type: SchemaTypeString},
+		{Name: "tags", Type: SchemaTypeArray},
+		{Name: "data", Type: SchemaTypeString, Required: true},
+	}, schema.Fields)
+
+	_, err = SchemaOf(1)
+	assert.NotNil(t, err)
+}
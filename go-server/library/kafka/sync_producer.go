@@ -4,8 +4,11 @@
 package kafka
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"sync/atomic"
 
 	"github.com/Shopify/sarama"
 )
@@ -13,6 +16,12 @@ import (
 // SyncProducer 定义同步生产者类型
 type SyncProducer struct {
 	sarama.SyncProducer
+
+	// client 生产者使用的客户端, 用于获取集群元数据, 包装其他实现时为nil
+	client sarama.Client
+
+	// 投递观测者, 存储observerHolder
+	observer atomic.Value
 }
 
 // ProducerConf 定义同步生产者配置类型
@@ -35,14 +44,79 @@ func NewSyncProducer(cf *ProducerConf) (pdr *SyncProducer, err error) {
 	// 同步生产者必须配置开启
 	cf.Ext.Producer.Return.Successes = true
 
-	spdr, err := sarama.NewSyncProducer(strings.Split(cf.Brokers, ","), cf.Ext)
+	client, err := sarama.NewClient(strings.Split(cf.Brokers, ","), cf.Ext)
+	if err != nil {
+		err = fmt.Errorf("sarama.NewClient: %w", err)
+		return
+	}
+
+	spdr, err := sarama.NewSyncProducerFromClient(client)
 	if err != nil {
-		err = fmt.Errorf("sarama.NewSyncProducer: %w", err)
+		_ = client.Close()
+		err = fmt.Errorf("sarama.NewSyncProducerFromClient: %w", err)
 		return
 	}
 
 	pdr = &SyncProducer{
 		SyncProducer: spdr,
+		client:       client,
+	}
+
+	return
+}
+
+// SetObserver 设置投递观测者, 为nil时不再观测
+func (prd *SyncProducer) SetObserver(obs Observer) {
+	prd.observer.Store(observerHolder{obs: obs})
+}
+
+// SendMessage 发送消息, 设置了观测者时观测投递结果
+func (prd *SyncProducer) SendMessage(msg *sarama.ProducerMessage) (partition int32, offset int64, err error) {
+	partition, offset, err = prd.SyncProducer.SendMessage(msg)
+	if obs := loadObserver(prd.observer.Load()); obs != nil {
+		obs.ObserveProduce(msg.Topic, err)
+	}
+
+	return
+}
+
+// SendMessages 批量发送消息, 设置了观测者时观测各消息的投递结果
+func (prd *SyncProducer) SendMessages(msgs []*sarama.ProducerMessage) (err error) {
+	err = prd.SyncProducer.SendMessages(msgs)
+	if obs := loadObserver(prd.observer.Load()); obs != nil {
+		observeMessages(obs, msgs, err)
+	}
+
+	return
+}
+
+// Ping 刷新集群元数据以检查集群是否可用, 用于健康检查, 生产者不是由NewSyncProducer创建时不做检查
+func (prd *SyncProducer) Ping(ctx context.Context) (err error) {
+	if prd.client == nil {
+		return
+	}
+
+	// RefreshMetadata不支持ctx, 超时后返回, 刷新在后台继续
+	done := make(chan error, 1)
+	go func() {
+		done <- prd.client.RefreshMetadata()
+	}()
+
+	select {
+	case <-ctx.Done():
+		err = fmt.Errorf("refresh metadata: %w", ctx.Err())
+		return
+	case err = <-done:
+	}
+
+	if err != nil {
+		err = fmt.Errorf("refresh metadata: %w", err)
+		return
+	}
+
+	if len(prd.client.Brokers()) == 0 {
+		err = errors.New("no available brokers")
+		return
 	}
 
 	return
@@ -51,5 +125,13 @@ func NewSyncProducer(cf *ProducerConf) (pdr *SyncProducer, err error) {
 // Close 实现io.Closer接口, 关闭生产者, 清理打开的系统资源
 func (prd *SyncProducer) Close() (err error) {
 	err = prd.SyncProducer.Close()
+
+	// NewSyncProducerFromClient创建的生产者关闭时不关闭客户端
+	if prd.client != nil {
+		if cerr := prd.client.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
 	return
 }
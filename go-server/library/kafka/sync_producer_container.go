@@ -6,6 +6,7 @@ package kafka
 import (
 	"errors"
 	"fmt"
+	"sync"
 
 	"go-server/library/conf"
 )
@@ -16,6 +17,9 @@ var (
 
 type SyncProducerContainer struct {
 	*conf.Container
+
+	mu       sync.Mutex
+	observer Observer // SetObserver设置的观测者, 替换生产者后仍然生效
 }
 
 type GetProducerConfFunc func() (*ProducerConf, error)
@@ -36,19 +40,45 @@ func NewSyncProducerContainer(getPdrCf GetProducerConfFunc) (ct *SyncProducerCon
 		return
 	}
 
-	ict, err := conf.NewContainer(getObjConf, compareProducerConf, newSyncProducerObj, nil)
+	c := &SyncProducerContainer{}
+	newObj := func(icf conf.IConf) (iobj conf.IObject, err error) {
+		if iobj, err = newSyncProducerObj(icf); err != nil {
+			return
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.observer != nil {
+			iobj.(*SyncProducer).SetObserver(c.observer)
+		}
+
+		return
+	}
+
+	ict, err := conf.NewContainer(getObjConf, compareProducerConf, newObj, nil)
 	if err != nil {
 		err = fmt.Errorf("new conf container: %w", err)
 		return
 	}
 
-	ct = &SyncProducerContainer{
-		Container: ict,
-	}
+	c.Container = ict
+	ct = c
 
 	return
 }
 
+// SetObserver 设置容器中生产者的投递观测者, 之后因配置变化创建的生产者同样使用obs
+func (ct *SyncProducerContainer) SetObserver(obs Observer) {
+	ct.mu.Lock()
+	ct.observer = obs
+	ct.mu.Unlock()
+
+	pdr := ct.MustGetProducer()
+	defer ct.PutProducer(pdr)
+
+	pdr.SetObserver(obs)
+}
+
 func newSyncProducerObj(icf conf.IConf) (iobj conf.IObject, err error) {
 	cf, ok := icf.(*ProducerConf)
 	if !ok {
@@ -4,7 +4,12 @@
 package kafka
 
 import (
+	"context"
+
 	"github.com/Shopify/sarama"
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	"go-server/library/trace"
 )
 
 func (ct *SyncProducerContainer) SendMessage(msg *sarama.ProducerMessage) (partition int32, offset int64, err error) {
@@ -24,3 +29,39 @@ func (ct *SyncProducerContainer) SendMessages(msgs []*sarama.ProducerMessage) (e
 
 	return
 }
+
+// SendMessageContext 将ctx携带的链路信息写入消息头后发送消息, ctx携带span上下文时为发送创建span
+func (ct *SyncProducerContainer) SendMessageContext(ctx context.Context, msg *sarama.ProducerMessage) (partition int32, offset int64, err error) {
+	ctx, span := startProduceSpan(ctx, msg.Topic, 1)
+	if span != nil {
+		defer trace.EndSpan(span, &err)
+	}
+
+	InjectTrace(ctx, msg)
+
+	return ct.SendMessage(msg)
+}
+
+// SendMessagesContext 将ctx携带的链路信息写入各消息的消息头后批量发送消息, ctx携带span上下文时为整批发送创建span
+func (ct *SyncProducerContainer) SendMessagesContext(ctx context.Context, msgs []*sarama.ProducerMessage) (err error) {
+	if len(msgs) > 0 {
+		var span oteltrace.Span
+		if ctx, span = startProduceSpan(ctx, msgs[0].Topic, len(msgs)); span != nil {
+			defer trace.EndSpan(span, &err)
+		}
+	}
+
+	for _, msg := range msgs {
+		InjectTrace(ctx, msg)
+	}
+
+	return ct.SendMessages(msgs)
+}
+
+// Ping 检查Kafka集群是否可用, 用于健康检查
+func (ct *SyncProducerContainer) Ping(ctx context.Context) (err error) {
+	pdr := ct.MustGetProducer()
+	defer ct.PutProducer(pdr)
+
+	return pdr.Ping(ctx)
+}
@@ -0,0 +1,136 @@
+package kafka
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	"go-server/library/trace"
+)
+
+// span属性
+const (
+	messagingSystem     = "kafka"
+	attributeBatchCount = "messaging.batch.message_count"
+	attributeOffset     = "messaging.kafka.message.offset"
+)
+
+// 传递链路信息的消息头
+var (
+	HeaderTraceparent = strings.ToLower(trace.HeaderTraceparent)
+	HeaderRequestID   = strings.ToLower(trace.HeaderRequestID)
+)
+
+// InjectTrace 以trace.Propagator将ctx的span上下文和请求ID写入消息头, 覆盖消息中已有的同名消息头,
+// ctx未携带链路信息时不做任何处理
+func InjectTrace(ctx context.Context, msg *sarama.ProducerMessage) {
+	if _, ok := trace.FromContext(ctx); !ok {
+		return
+	}
+
+	trace.Inject(ctx, producerCarrier{msg: msg})
+}
+
+// ExtractTrace 返回携带消息头中链路信息的ctx, 处理消息的span以其为远程父span, 消息没有链路信息时开始新的链路
+func ExtractTrace(ctx context.Context, message *sarama.ConsumerMessage) context.Context {
+	return trace.Extract(ctx, consumerCarrier{message: message})
+}
+
+// producerCarrier 以发送消息的消息头实现trace.Carrier, 键使用小写形式
+type producerCarrier struct {
+	msg *sarama.ProducerMessage
+}
+
+func (c producerCarrier) Get(key string) string {
+	key = strings.ToLower(key)
+	for _, h := range c.msg.Headers {
+		if string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+
+	return ""
+}
+
+func (c producerCarrier) Set(key, value string) {
+	key = strings.ToLower(key)
+
+	headers := c.msg.Headers[:0:0]
+	for _, h := range c.msg.Headers {
+		if string(h.Key) != key {
+			headers = append(headers, h)
+		}
+	}
+	c.msg.Headers = append(headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+func (c producerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.msg.Headers))
+	for _, h := range c.msg.Headers {
+		keys = append(keys, string(h.Key))
+	}
+
+	return keys
+}
+
+// consumerCarrier 以接收消息的消息头实现trace.Carrier, 只读
+type consumerCarrier struct {
+	message *sarama.ConsumerMessage
+}
+
+func (c consumerCarrier) Get(key string) string {
+	return headerValue(c.message, strings.ToLower(key))
+}
+
+func (c consumerCarrier) Set(key, value string) {}
+
+func (c consumerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.message.Headers))
+	for _, h := range c.message.Headers {
+		if h != nil {
+			keys = append(keys, string(h.Key))
+		}
+	}
+
+	return keys
+}
+
+// startProduceSpan ctx携带span上下文时开始发送消息的span, 否则返回的span为nil
+func startProduceSpan(ctx context.Context, topic string, count int) (sctx context.Context, span oteltrace.Span) {
+	sctx = ctx
+	if !trace.Traced(ctx) {
+		return
+	}
+
+	sctx, span = trace.StartSpan(ctx, topic+" send",
+		oteltrace.WithSpanKind(oteltrace.SpanKindProducer),
+		oteltrace.WithAttributes(
+			semconv.MessagingSystemKey.String(messagingSystem),
+			semconv.MessagingDestinationKindTopic,
+			semconv.MessagingDestinationKey.String(topic),
+			attribute.Int(attributeBatchCount, count),
+		),
+	)
+
+	return
+}
+
+// startConsumeSpan 开始处理消息的span, ctx携带从消息头中提取的链路信息
+func startConsumeSpan(ctx context.Context, group string, message *sarama.ConsumerMessage) (context.Context, oteltrace.Span) {
+	return trace.StartSpan(ctx, message.Topic+" process",
+		oteltrace.WithSpanKind(oteltrace.SpanKindConsumer),
+		oteltrace.WithAttributes(
+			semconv.MessagingSystemKey.String(messagingSystem),
+			semconv.MessagingDestinationKindTopic,
+			semconv.MessagingDestinationKey.String(message.Topic),
+			semconv.MessagingOperationProcess,
+			semconv.MessagingKafkaConsumerGroupKey.String(group),
+			semconv.MessagingKafkaPartitionKey.Int64(int64(message.Partition)),
+			attribute.Int64(attributeOffset, message.Offset),
+		),
+	)
+}
@@ -0,0 +1,90 @@
+package kafka_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	"go-server/library/kafka"
+	"go-server/library/kafka/kafkatest"
+	"go-server/library/trace"
+	"go-server/library/trace/tracetest"
+)
+
+func TestInjectTrace(t *testing.T) {
+	tracetest.Record(t)
+	ctx, span := trace.StartSpan(trace.WithRequestID(context.Background(), "req-1"), "parent")
+	defer span.End()
+
+	// 覆盖已有的链路消息头, 其他消息头保持不变
+	msg := &sarama.ProducerMessage{Topic: "t", Headers: []sarama.RecordHeader{
+		{Key: []byte("k"), Value: []byte("v")},
+		{Key: []byte(kafka.HeaderTraceparent), Value: []byte("stale")},
+	}}
+	kafka.InjectTrace(ctx, msg)
+	kafka.InjectTrace(ctx, msg)
+
+	headers := make(map[string][]string)
+	for _, h := range msg.Headers {
+		headers[string(h.Key)] = append(headers[string(h.Key)], string(h.Value))
+	}
+	sc := span.SpanContext()
+	assert.Equal(t, map[string][]string{
+		"k":                     {"v"},
+		kafka.HeaderTraceparent: {"00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-01"},
+		kafka.HeaderRequestID:   {"req-1"},
+	}, headers)
+
+	// ctx未携带链路信息时不写入
+	msg = &sarama.ProducerMessage{Topic: "t"}
+	kafka.InjectTrace(context.Background(), msg)
+	assert.Empty(t, msg.Headers)
+}
+
+func TestConsumeTrace(t *testing.T) {
+	rec := tracetest.Record(t)
+	b := kafkatest.NewBroker()
+
+	ctx, parent := trace.StartSpan(trace.WithRequestID(context.Background(), "req-1"), "parent")
+	msg := &sarama.ProducerMessage{Topic: "t", Value: sarama.StringEncoder("x")}
+	kafka.InjectTrace(ctx, msg)
+	parent.End()
+	_, _, err := b.SendMessage(msg)
+	assert.Nil(t, err)
+
+	got := make(chan trace.SpanContext, 1)
+	consumer := kafkatest.NewGroupConsumer(t, b, "g", "t")
+	consumer.SetMessageHandleFunc(func(ctx context.Context, msg *sarama.ConsumerMessage) error {
+		sc, _ := trace.FromContext(ctx)
+		got <- sc
+		return nil
+	})
+	consumer.Start()
+
+	var sc trace.SpanContext
+	select {
+	case sc = <-got:
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not handled")
+	}
+	waitCommitted(t, b, "g", "t", 1)
+
+	// 处理消息的span以发送方的span为远程父span, 处理函数的ctx以其为当前操作
+	span := rec.Span("t process")
+	if !assert.NotNil(t, span) {
+		return
+	}
+	assert.Equal(t, oteltrace.SpanKindConsumer, span.SpanKind())
+	assert.Equal(t, parent.SpanContext().TraceID(), span.SpanContext().TraceID())
+	assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+	assert.True(t, span.Parent().IsRemote())
+	assert.Equal(t, trace.SpanContext{
+		TraceID:   span.SpanContext().TraceID().String(),
+		SpanID:    span.SpanContext().SpanID().String(),
+		RequestID: "req-1",
+	}, sc)
+}
@@ -0,0 +1,175 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/Shopify/sarama"
+)
+
+// 类型化消息附加的消息头
+const (
+	HeaderContentType   = "content-type"     // 消息内容类型, 消费时据此选择编解码器
+	HeaderSchemaSubject = "x-schema-subject" // 消息模式的主题
+	HeaderSchemaVersion = "x-schema-version" // 消息模式的版本
+)
+
+// TypedOption 类型化消息的生产和消费选项
+type TypedOption func(*typedOptions)
+
+type typedOptions struct {
+	codec    Codec
+	registry SchemaRegistry
+	subject  string
+}
+
+// WithCodec 指定编解码器, 默认为JSONCodec, 消费时消息带有content-type消息头的以消息头为准
+func WithCodec(codec Codec) TypedOption {
+	return func(o *typedOptions) {
+		o.codec = codec
+	}
+}
+
+// WithSchema 指定模式注册中心和主题, 生产时写入主题最新版本号, 消费时要求消息的模式版本已在该主题下注册
+func WithSchema(registry SchemaRegistry, subject string) TypedOption {
+	return func(o *typedOptions) {
+		o.registry = registry
+		o.subject = subject
+	}
+}
+
+func newTypedOptions(opts []TypedOption) *typedOptions {
+	o := &typedOptions{codec: JSONCodec}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// TypedHandleFunc 类型化消息处理函数, v为解码后的消息内容
+type TypedHandleFunc[T any] func(ctx context.Context, v T, message *sarama.ConsumerMessage) error
+
+// EncodeMessage 编码v并返回带content-type和模式消息头的生产消息, key为空时不设置消息键,
+// 可用于AsyncProducer.Send等需要自行发送的场景
+func EncodeMessage[T any](topic, key string, v T, opts ...TypedOption) (msg *sarama.ProducerMessage, err error) {
+	o := newTypedOptions(opts)
+
+	headers := []sarama.RecordHeader{
+		{Key: []byte(HeaderContentType), Value: []byte(o.codec.ContentType())},
+	}
+
+	if o.registry != nil {
+		version, _, lerr := o.registry.Latest(o.subject)
+		if lerr != nil {
+			err = fmt.Errorf("registry.Latest: %w", lerr)
+			return
+		}
+		headers = append(headers,
+			sarama.RecordHeader{Key: []byte(HeaderSchemaSubject), Value: []byte(o.subject)},
+			sarama.RecordHeader{Key: []byte(HeaderSchemaVersion), Value: []byte(strconv.Itoa(version))},
+		)
+	}
+
+	b, err := o.codec.Marshal(v)
+	if err != nil {
+		err = fmt.Errorf("marshal %T: %w", v, err)
+		return
+	}
+
+	msg = &sarama.ProducerMessage{
+		Topic:   topic,
+		Value:   sarama.ByteEncoder(b),
+		Headers: headers,
+	}
+	if key != "" {
+		msg.Key = sarama.StringEncoder(key)
+	}
+
+	return
+}
+
+// Publish 编码v后通过sender同步发送到topic
+func Publish[T any](sender MessageSender, topic, key string, v T, opts ...TypedOption) (partition int32, offset int64, err error) {
+	msg, err := EncodeMessage(topic, key, v, opts...)
+	if err != nil {
+		return
+	}
+
+	if partition, offset, err = sender.SendMessage(msg); err != nil {
+		err = fmt.Errorf("send message: %w", err)
+		return
+	}
+
+	return
+}
+
+// DecodeMessage 按消息的content-type消息头选择编解码器解码消息内容,
+// 配置了模式时校验消息的模式主题和版本, T为指针类型时解码到新分配的值
+func DecodeMessage[T any](message *sarama.ConsumerMessage, opts ...TypedOption) (v T, err error) {
+	o := newTypedOptions(opts)
+
+	codec := o.codec
+	if ct := headerValue(message, HeaderContentType); ct != "" {
+		var ok bool
+		if codec, ok = CodecByContentType(ct); !ok {
+			err = fmt.Errorf("unsupported content type %q", ct)
+			return
+		}
+	}
+
+	if o.registry != nil {
+		if err = checkMessageSchema(message, o.registry, o.subject); err != nil {
+			return
+		}
+	}
+
+	rt := reflect.TypeOf((*T)(nil)).Elem()
+	if rt.Kind() == reflect.Ptr {
+		v = reflect.New(rt.Elem()).Interface().(T)
+		err = codec.Unmarshal(message.Value, v)
+	} else {
+		err = codec.Unmarshal(message.Value, &v)
+	}
+	if err != nil {
+		err = fmt.Errorf("unmarshal %s: %w", rt, err)
+		return
+	}
+
+	return
+}
+
+// Subscribe 将类型化消息处理函数包装为MessageHandleFunc, 解码或模式校验失败时返回错误, 按重试策略处理
+func Subscribe[T any](handle TypedHandleFunc[T], opts ...TypedOption) MessageHandleFunc {
+	return func(ctx context.Context, message *sarama.ConsumerMessage) (err error) {
+		v, err := DecodeMessage[T](message, opts...)
+		if err != nil {
+			err = fmt.Errorf("decode message %s/%d/%d: %w", message.Topic, message.Partition, message.Offset, err)
+			return
+		}
+
+		return handle(ctx, v, message)
+	}
+}
+
+func checkMessageSchema(message *sarama.ConsumerMessage, registry SchemaRegistry, subject string) (err error) {
+	if s := headerValue(message, HeaderSchemaSubject); s != subject {
+		err = fmt.Errorf("schema subject %q mismatch, expected %q", s, subject)
+		return
+	}
+
+	version, err := strconv.Atoi(headerValue(message, HeaderSchemaVersion))
+	if err != nil {
+		err = fmt.Errorf("invalid schema version %q", headerValue(message, HeaderSchemaVersion))
+		return
+	}
+
+	if _, err = registry.Lookup(subject, version); err != nil {
+		err = fmt.Errorf("registry.Lookup: %w", err)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,130 @@
+package kafka
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Shopify/sarama"
+)
+
+// Concurrency 消息并发处理配置
+type Concurrency struct {
+	// 处理消息的协程数, 所有分区共享, 不大于1时每个分区串行处理
+	Workers int
+
+	// 每个分区同时处理中的最大消息数, 达到后暂停读取该分区, 默认为Workers
+	MaxInFlight int
+}
+
+// workerPool 按消息键分配处理协程的工作池, 键相同的消息总是分配到同一协程按到达顺序处理,
+// 键为空的消息轮流分配, 不保证顺序
+type workerPool struct {
+	lanes []chan *poolTask
+	next  uint32
+	wg    sync.WaitGroup
+}
+
+// poolTask 待处理的消息, ctx已取消时不再处理, 直接以ctx的错误调用done
+type poolTask struct {
+	ctx     context.Context
+	message *sarama.ConsumerMessage
+	done    func(err error)
+}
+
+// newWorkerPool 创建workers个处理协程, 每个协程最多缓冲buffer个待处理任务
+func newWorkerPool(workers, buffer int, process func(ctx context.Context, message *sarama.ConsumerMessage) error) (pool *workerPool) {
+	pool = &workerPool{
+		lanes: make([]chan *poolTask, workers),
+	}
+
+	pool.wg.Add(workers)
+	for i := range pool.lanes {
+		lane := make(chan *poolTask, buffer)
+		pool.lanes[i] = lane
+
+		go func() {
+			defer pool.wg.Done()
+			for task := range lane {
+				if err := task.ctx.Err(); err != nil {
+					task.done(err)
+					continue
+				}
+				task.done(process(task.ctx, task.message))
+			}
+		}()
+	}
+
+	return
+}
+
+// submit 将任务分配到消息键对应的协程, 协程繁忙时阻塞
+func (pool *workerPool) submit(task *poolTask) {
+	var i uint32
+	if task.message.Key == nil {
+		i = atomic.AddUint32(&pool.next, 1)
+	} else {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(task.message.Topic))
+		_, _ = h.Write(task.message.Key)
+		i = h.Sum32()
+	}
+
+	pool.lanes[i%uint32(len(pool.lanes))] <- task
+}
+
+// close 停止所有协程, 调用前应确保不会再提交任务
+func (pool *workerPool) close() {
+	for _, lane := range pool.lanes {
+		close(lane)
+	}
+
+	pool.wg.Wait()
+}
+
+// offsetTracker 跟踪一个分区中处理中的消息, 仅当某偏移量及之前的所有消息都处理完成后才提交该偏移量
+type offsetTracker struct {
+	sess      sarama.ConsumerGroupSession
+	topic     string
+	partition int32
+
+	mu      sync.Mutex
+	pending []int64 // 按分发顺序排列的处理中偏移量, 即升序
+	done    map[int64]bool
+}
+
+func newOffsetTracker(sess sarama.ConsumerGroupSession, topic string, partition int32) *offsetTracker {
+	return &offsetTracker{
+		sess:      sess,
+		topic:     topic,
+		partition: partition,
+		done:      make(map[int64]bool),
+	}
+}
+
+func (ot *offsetTracker) add(offset int64) {
+	ot.mu.Lock()
+	defer ot.mu.Unlock()
+
+	ot.pending = append(ot.pending, offset)
+}
+
+// complete 标记消息处理完成, 并提交连续完成的最大偏移量
+func (ot *offsetTracker) complete(offset int64) {
+	ot.mu.Lock()
+	defer ot.mu.Unlock()
+
+	ot.done[offset] = true
+
+	committed := int64(-1)
+	for len(ot.pending) > 0 && ot.done[ot.pending[0]] {
+		committed = ot.pending[0]
+		delete(ot.done, committed)
+		ot.pending = ot.pending[1:]
+	}
+
+	if committed >= 0 {
+		ot.sess.MarkOffset(ot.topic, ot.partition, committed+1, "")
+	}
+}
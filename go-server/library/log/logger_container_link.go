@@ -4,7 +4,11 @@
 package log
 
 import (
+	"context"
+
 	"github.com/sirupsen/logrus"
+
+	"go-server/library/trace"
 )
 
 func (ct *LoggerContainer) Info(fields F, args ...interface{}) {
@@ -42,3 +46,46 @@ func (ct *LoggerContainer) Panic(fields F, args ...interface{}) {
 	defer ct.PutLogger(logger)
 	logger.WithFields(logrus.Fields(fields)).Panic(args...)
 }
+
+// ContextFields 返回附加了ctx携带的链路ID和请求ID的字段副本, fields中的同名字段优先
+func ContextFields(ctx context.Context, fields F) F {
+	merged := F(trace.LogFields(ctx))
+	if merged == nil {
+		merged = make(F, len(fields))
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+
+	return merged
+}
+
+func (ct *LoggerContainer) InfoContext(ctx context.Context, fields F, args ...interface{}) {
+	ct.Info(ContextFields(ctx, fields), args...)
+}
+
+func (ct *LoggerContainer) WarnContext(ctx context.Context, fields F, args ...interface{}) {
+	ct.Warn(ContextFields(ctx, fields), args...)
+}
+
+func (ct *LoggerContainer) ErrorContext(ctx context.Context, fields F, args ...interface{}) {
+	ct.Error(ContextFields(ctx, fields), args...)
+}
+
+func (ct *LoggerContainer) DebugContext(ctx context.Context, fields F, args ...interface{}) {
+	ct.Debug(ContextFields(ctx, fields), args...)
+}
+
+// Level 返回当前日志级别
+func (ct *LoggerContainer) Level() string {
+	logger := ct.MustGetLogger()
+	defer ct.PutLogger(logger)
+	return logger.GetLevel().String()
+}
+
+// SetLevel 修改当前日志级别, 配置文件中的日志级别变化时将再次按配置文件设置
+func (ct *LoggerContainer) SetLevel(level string) (err error) {
+	logger := ct.MustGetLogger()
+	defer ct.PutLogger(logger)
+	return logger.SetLevel(&LoggerConf{Level: level})
+}
@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go-server/library/conf"
+)
+
+// StatusContainer 可查看运行状态的容器, 所有基于conf.Container的容器都实现了该接口
+type StatusContainer interface {
+	Status() conf.ContainerStatus
+}
+
+// RegisterDB 以name注册数据库连接池统计, stats在每次拉取指标时调用, 通常为mysql.DBContainer.Stats,
+// 配置变化替换连接池后统计从新连接池重新开始
+func (m *Metrics) RegisterDB(name string, stats func() sql.DBStats) error {
+	return m.registry.Register(newDBStatsCollector(m.namespace, name, stats))
+}
+
+// RegisterContainer 以name注册容器的替换次数, 重置次数和健康状态
+func (m *Metrics) RegisterContainer(name string, ct StatusContainer) error {
+	return m.registry.Register(newContainerCollector(m.namespace, name, ct))
+}
+
+type dbStatsCollector struct {
+	stats func() sql.DBStats
+
+	maxOpen           *prometheus.Desc
+	open              *prometheus.Desc
+	inUse             *prometheus.Desc
+	idle              *prometheus.Desc
+	waitCount         *prometheus.Desc
+	waitDuration      *prometheus.Desc
+	maxIdleClosed     *prometheus.Desc
+	maxLifetimeClosed *prometheus.Desc
+}
+
+func newDBStatsCollector(namespace, name string, stats func() sql.DBStats) *dbStatsCollector {
+	labels := prometheus.Labels{"db": name}
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "mysql", metric), help, nil, labels)
+	}
+
+	return &dbStatsCollector{
+		stats:             stats,
+		maxOpen:           desc("max_open_connections", "Maximum number of open connections to the database."),
+		open:              desc("open_connections", "Number of established connections both in use and idle."),
+		inUse:             desc("in_use_connections", "Number of connections currently in use."),
+		idle:              desc("idle_connections", "Number of idle connections."),
+		waitCount:         desc("wait_count_total", "Total number of connections waited for."),
+		waitDuration:      desc("wait_duration_seconds_total", "Total time blocked waiting for a new connection."),
+		maxIdleClosed:     desc("max_idle_closed_total", "Total number of connections closed due to SetMaxIdleConns."),
+		maxLifetimeClosed: desc("max_lifetime_closed_total", "Total number of connections closed due to SetConnMaxLifetime."),
+	}
+}
+
+func (c *dbStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpen
+	ch <- c.open
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waitCount
+	ch <- c.waitDuration
+	ch <- c.maxIdleClosed
+	ch <- c.maxLifetimeClosed
+}
+
+func (c *dbStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	st := c.stats()
+
+	ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(st.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(st.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(st.InUse))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(st.Idle))
+	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(st.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, st.WaitDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.maxIdleClosed, prometheus.CounterValue, float64(st.MaxIdleClosed))
+	ch <- prometheus.MustNewConstMetric(c.maxLifetimeClosed, prometheus.CounterValue, float64(st.MaxLifetimeClosed))
+}
+
+type containerCollector struct {
+	ct StatusContainer
+
+	replacements *prometheus.Desc
+	resets       *prometheus.Desc
+	healthy      *prometheus.Desc
+}
+
+func newContainerCollector(namespace, name string, ct StatusContainer) *containerCollector {
+	labels := prometheus.Labels{"container": name}
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "container", metric), help, nil, labels)
+	}
+
+	return &containerCollector{
+		ct:           ct,
+		replacements: desc("replacements_total", "Total number of object replacements caused by config changes."),
+		resets:       desc("resets_total", "Total number of in-place object resets caused by config changes."),
+		healthy:      desc("healthy", "Whether the container is open and its last update succeeded."),
+	}
+}
+
+func (c *containerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.replacements
+	ch <- c.resets
+	ch <- c.healthy
+}
+
+func (c *containerCollector) Collect(ch chan<- prometheus.Metric) {
+	st := c.ct.Status()
+
+	// 代数在创建初始对象时为1, 之后每次替换加1
+	var replacements uint64
+	if st.Generation > 0 {
+		replacements = st.Generation - 1
+	}
+
+	var healthy float64
+	if st.Healthy() {
+		healthy = 1
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.replacements, prometheus.CounterValue, float64(replacements))
+	ch <- prometheus.MustNewConstMetric(c.resets, prometheus.CounterValue, float64(st.Resets))
+	ch <- prometheus.MustNewConstMetric(c.healthy, prometheus.GaugeValue, healthy)
+}
@@ -0,0 +1,193 @@
+// metrics 包以Prometheus格式提供服务指标, 包括HTTP请求, HTTP客户端请求, 数据库操作与连接池, redis命令, Kafka生产与消费,
+// 配置重载和容器替换, 指标由服务自身的/metrics接口暴露, 由Prometheus拉取, 不依赖任何外部服务,
+// Metrics实现了mysql.QueryObserver, redis.CommandObserver和kafka.Observer, 通过各容器的SetObserver接入,
+// 同时实现了http.MetricsRecorder, 通过http.MetricsInterceptor接入HTTP客户端
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	libhttp "go-server/library/http"
+)
+
+// 指标中status标签的取值
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+// DefaultLatencyBuckets 默认的耗时分桶上限, 单位为秒
+var DefaultLatencyBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics 服务指标, 所有方法并发安全
+type Metrics struct {
+	namespace string
+	registry  *prometheus.Registry
+
+	httpRequests  *prometheus.CounterVec
+	httpLatency   *prometheus.HistogramVec
+	clientLatency *prometheus.HistogramVec
+	dbLatency     *prometheus.HistogramVec
+	redisLatency  *prometheus.HistogramVec
+	produced      *prometheus.CounterVec
+	consumed      *prometheus.CounterVec
+	consumerLag   *prometheus.GaugeVec
+	stalls        *prometheus.CounterVec
+	confReloads   *prometheus.CounterVec
+}
+
+// New 创建服务指标, namespace为指标名称前缀, 可以为空, 同时注册Go运行时和进程指标
+func New(namespace string) (m *Metrics) {
+	m = &Metrics{
+		namespace: namespace,
+		registry:  prometheus.NewRegistry(),
+
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Total number of HTTP requests by route and response code.",
+		}, []string{"method", "route", "status", "code"}),
+		httpLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route and response code.",
+			Buckets:   DefaultLatencyBuckets,
+		}, []string{"method", "route", "code"}),
+		clientLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_client_request_duration_seconds",
+			Help:      "Outgoing HTTP request latency by host, route template, method and status, status is 0 when the request failed.",
+			Buckets:   DefaultLatencyBuckets,
+		}, []string{"host", "route", "method", "status"}),
+		dbLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "mysql_query_duration_seconds",
+			Help:      "MySQL operation latency by operation and result.",
+			Buckets:   DefaultLatencyBuckets,
+		}, []string{"op", "status"}),
+		redisLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "redis_command_duration_seconds",
+			Help:      "Redis command latency by command and result.",
+			Buckets:   DefaultLatencyBuckets,
+		}, []string{"command", "status"}),
+		produced: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "kafka_produced_messages_total",
+			Help:      "Total number of Kafka messages delivered by topic and result.",
+		}, []string{"topic", "status"}),
+		consumed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "kafka_consumed_messages_total",
+			Help:      "Total number of Kafka messages handled by consumer group, topic and result.",
+		}, []string{"group", "topic", "status"}),
+		consumerLag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "kafka_consumer_lag",
+			Help:      "Number of messages between the partition high water mark and the last handled message.",
+		}, []string{"group", "topic", "partition"}),
+		stalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "kafka_consumer_stalls_total",
+			Help:      "Total number of failed handling rounds of messages that could not be forwarded and block their partition.",
+		}, []string{"group", "topic", "partition"}),
+		confReloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "conf_reloads_total",
+			Help:      "Total number of config reloads by result.",
+		}, []string{"status"}),
+	}
+
+	m.registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{Namespace: namespace}),
+		m.httpRequests,
+		m.httpLatency,
+		m.clientLatency,
+		m.dbLatency,
+		m.redisLatency,
+		m.produced,
+		m.consumed,
+		m.consumerLag,
+		m.stalls,
+		m.confReloads,
+	)
+
+	return
+}
+
+// Registry 返回指标注册表, 可用于注册业务自定义的指标
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler 返回以Prometheus文本格式输出所有指标的接口
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveHTTP 记录一次HTTP请求, route为路由模板, code为响应体中的业务响应码
+func (m *Metrics) ObserveHTTP(method, route string, status int, code string, elapsed time.Duration) {
+	m.httpRequests.WithLabelValues(method, route, strconv.Itoa(status), code).Inc()
+	m.httpLatency.WithLabelValues(method, route, code).Observe(elapsed.Seconds())
+}
+
+// ObserveRequest 实现http.MetricsRecorder, 记录一次HTTP客户端请求
+func (m *Metrics) ObserveRequest(labels libhttp.RequestLabels, elapsed time.Duration) {
+	m.clientLatency.WithLabelValues(labels.Host, labels.Route, labels.Method, strconv.Itoa(labels.Status)).Observe(elapsed.Seconds())
+}
+
+// ObserveQuery 实现mysql.QueryObserver, 查询单行没有结果不视为错误
+func (m *Metrics) ObserveQuery(op string, elapsed time.Duration, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+
+	m.dbLatency.WithLabelValues(op, status(err)).Observe(elapsed.Seconds())
+}
+
+// ObserveCommand 实现redis.CommandObserver
+func (m *Metrics) ObserveCommand(cmd string, elapsed time.Duration, err error) {
+	m.redisLatency.WithLabelValues(cmd, status(err)).Observe(elapsed.Seconds())
+}
+
+// ObserveProduce 实现kafka.Observer
+func (m *Metrics) ObserveProduce(topic string, err error) {
+	m.produced.WithLabelValues(topic, status(err)).Inc()
+}
+
+// ObserveConsume 实现kafka.Observer
+func (m *Metrics) ObserveConsume(group, topic string, partition int32, lag int64, err error) {
+	m.consumed.WithLabelValues(group, topic, status(err)).Inc()
+	m.consumerLag.WithLabelValues(group, topic, strconv.Itoa(int(partition))).Set(float64(lag))
+}
+
+// ObserveStall 实现kafka.Observer
+func (m *Metrics) ObserveStall(group, topic string, partition int32) {
+	m.stalls.WithLabelValues(group, topic, strconv.Itoa(int(partition))).Inc()
+}
+
+// ObserveRevoke 实现kafka.RevokeObserver, 删除分区的积压指标, 以免分区分配给其他成员后仍然输出过时的积压
+func (m *Metrics) ObserveRevoke(group, topic string, partition int32) {
+	m.consumerLag.DeleteLabelValues(group, topic, strconv.Itoa(int(partition)))
+}
+
+// ObserveReload 记录一次配置重载的结果, 可注册为conf.Conf的重载结果勾子函数
+func (m *Metrics) ObserveReload(err error) {
+	m.confReloads.WithLabelValues(status(err)).Inc()
+}
+
+func status(err error) string {
+	if err != nil {
+		return StatusError
+	}
+
+	return StatusOK
+}
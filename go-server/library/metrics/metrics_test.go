@@ -0,0 +1,252 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"go-server/library/conf"
+	libhttp "go-server/library/http"
+)
+
+// statusContainer 返回固定运行状态的容器
+type statusContainer struct {
+	status conf.ContainerStatus
+}
+
+func (c *statusContainer) Status() conf.ContainerStatus {
+	return c.status
+}
+
+func TestObserve(t *testing.T) {
+	m := New("test")
+
+	m.ObserveHTTP(http.MethodGet, "/api/users", 200, "0", 10*time.Millisecond)
+	m.ObserveHTTP(http.MethodGet, "/api/users", 200, "0", 20*time.Millisecond)
+	m.ObserveRequest(libhttp.RequestLabels{Host: "a.test", Route: "/users/{id}", Method: http.MethodGet, Status: 200}, time.Millisecond)
+	m.ObserveRequest(libhttp.RequestLabels{Host: "a.test", Method: http.MethodPost}, time.Millisecond)
+	m.ObserveQuery("query", time.Millisecond, sql.ErrNoRows)
+	m.ObserveQuery("exec", time.Millisecond, errors.New("deadlock"))
+	m.ObserveCommand("get", time.Millisecond, nil)
+	m.ObserveProduce("orders", nil)
+	m.ObserveProduce("orders", errors.New("timeout"))
+	m.ObserveConsume("g", "orders", 0, 5, nil)
+	m.ObserveConsume("g", "orders", 1, 3, errors.New("boom"))
+	m.ObserveStall("g", "orders", 1)
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "test_http_requests_total", want: `
+# HELP test_http_requests_total Total number of HTTP requests by route and response code.
+# TYPE test_http_requests_total counter
+test_http_requests_total{code="0",method="GET",route="/api/users",status="200"} 2
+`},
+		{name: "test_kafka_produced_messages_total", want: `
+# HELP test_kafka_produced_messages_total Total number of Kafka messages delivered by topic and result.
+# TYPE test_kafka_produced_messages_total counter
+test_kafka_produced_messages_total{status="error",topic="orders"} 1
+test_kafka_produced_messages_total{status="ok",topic="orders"} 1
+`},
+		{name: "test_kafka_consumer_lag", want: `
+# HELP test_kafka_consumer_lag Number of messages between the partition high water mark and the last handled message.
+# TYPE test_kafka_consumer_lag gauge
+test_kafka_consumer_lag{group="g",partition="0",topic="orders"} 5
+test_kafka_consumer_lag{group="g",partition="1",topic="orders"} 3
+`},
+		{name: "test_kafka_consumer_stalls_total", want: `
+# HELP test_kafka_consumer_stalls_total Total number of failed handling rounds of messages that could not be forwarded and block their partition.
+# TYPE test_kafka_consumer_stalls_total counter
+test_kafka_consumer_stalls_total{group="g",partition="1",topic="orders"} 1
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Nil(t, testutil.GatherAndCompare(m.Registry(), strings.NewReader(tt.want), tt.name))
+		})
+	}
+
+	// 直方图按标签计数, 查询单行没有结果不视为错误, 请求失败时status为0
+	counts := []struct {
+		name string
+		want int
+	}{
+		{name: "test_http_request_duration_seconds", want: 1},
+		{name: "test_http_client_request_duration_seconds", want: 2},
+		{name: "test_mysql_query_duration_seconds", want: 2},
+		{name: "test_redis_command_duration_seconds", want: 1},
+		{name: "test_kafka_consumed_messages_total", want: 2},
+	}
+	for _, c := range counts {
+		n, err := testutil.GatherAndCount(m.Registry(), c.name)
+		assert.Nil(t, err)
+		assert.Equal(t, c.want, n, c.name)
+	}
+
+	out := scrape(t, m)
+	assert.Contains(t, out, `test_mysql_query_duration_seconds_count{op="query",status="ok"} 1`)
+	assert.Contains(t, out, `test_mysql_query_duration_seconds_count{op="exec",status="error"} 1`)
+	assert.Contains(t, out, `test_http_client_request_duration_seconds_count{host="a.test",method="POST",route="",status="0"} 1`)
+	assert.Contains(t, out, "go_goroutines")
+	assert.Contains(t, out, "test_process_")
+}
+
+func TestObserveRevoke(t *testing.T) {
+	m := New("")
+	m.ObserveConsume("g", "orders", 0, 5, nil)
+	m.ObserveConsume("g", "orders", 1, 3, nil)
+	m.ObserveStall("g", "orders", 1)
+
+	// 释放的分区不再输出积压, 计数类指标保留
+	m.ObserveRevoke("g", "orders", 1)
+	m.ObserveRevoke("g", "other", 0)
+	assert.Nil(t, testutil.GatherAndCompare(m.Registry(), strings.NewReader(`
+# HELP kafka_consumer_lag Number of messages between the partition high water mark and the last handled message.
+# TYPE kafka_consumer_lag gauge
+kafka_consumer_lag{group="g",partition="0",topic="orders"} 5
+`), "kafka_consumer_lag"))
+
+	n, err := testutil.GatherAndCount(m.Registry(), "kafka_consumer_stalls_total")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestRegisterDB(t *testing.T) {
+	m := New("")
+
+	var open int32 = 2
+	err := m.RegisterDB("default", func() sql.DBStats {
+		return sql.DBStats{MaxOpenConnections: 16, OpenConnections: int(atomic.LoadInt32(&open)), InUse: 1, Idle: 1, WaitCount: 3, WaitDuration: 1500 * time.Millisecond}
+	})
+	assert.Nil(t, err)
+
+	// 同名重复注册返回错误
+	assert.NotNil(t, m.RegisterDB("default", func() sql.DBStats { return sql.DBStats{} }))
+
+	want := `
+# HELP mysql_open_connections Number of established connections both in use and idle.
+# TYPE mysql_open_connections gauge
+mysql_open_connections{db="default"} %d
+# HELP mysql_wait_duration_seconds_total Total time blocked waiting for a new connection.
+# TYPE mysql_wait_duration_seconds_total counter
+mysql_wait_duration_seconds_total{db="default"} 1.5
+`
+	names := []string{"mysql_open_connections", "mysql_wait_duration_seconds_total"}
+	assert.Nil(t, testutil.GatherAndCompare(m.Registry(), strings.NewReader(strings.Replace(want, "%d", "2", 1)), names...))
+
+	// 每次拉取时重新读取统计
+	atomic.StoreInt32(&open, 5)
+	assert.Nil(t, testutil.GatherAndCompare(m.Registry(), strings.NewReader(strings.Replace(want, "%d", "5", 1)), names...))
+
+	n, err := testutil.GatherAndCount(m.Registry())
+	assert.Nil(t, err)
+	assert.Greater(t, n, 8)
+}
+
+func TestRegisterContainer(t *testing.T) {
+	tests := []struct {
+		name   string
+		status conf.ContainerStatus
+		want   string
+	}{
+		{name: "healthy", status: conf.ContainerStatus{Generation: 3, Resets: 2}, want: "2 2 1"},
+		{name: "not created", status: conf.ContainerStatus{}, want: "0 0 1"},
+		{name: "update failed", status: conf.ContainerStatus{Generation: 1, LastError: "dial"}, want: "0 0 0"},
+		{name: "closed", status: conf.ContainerStatus{Generation: 1, Closed: true}, want: "0 0 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New("")
+			assert.Nil(t, m.RegisterContainer("db", &statusContainer{status: tt.status}))
+
+			v := strings.Fields(tt.want)
+			assert.Nil(t, testutil.GatherAndCompare(m.Registry(), strings.NewReader(`
+# HELP container_healthy Whether the container is open and its last update succeeded.
+# TYPE container_healthy gauge
+container_healthy{container="db"} `+v[2]+`
+# HELP container_replacements_total Total number of object replacements caused by config changes.
+# TYPE container_replacements_total counter
+container_replacements_total{container="db"} `+v[0]+`
+# HELP container_resets_total Total number of in-place object resets caused by config changes.
+# TYPE container_resets_total counter
+container_resets_total{container="db"} `+v[1]+`
+`), "container_healthy", "container_replacements_total", "container_resets_total"))
+		})
+	}
+}
+
+// updater fail不为0时更新失败的配置更新者
+type updater struct {
+	fail int32
+}
+
+func (u *updater) Update() error {
+	if atomic.LoadInt32(&u.fail) != 0 {
+		return errors.New("dial tcp: refused")
+	}
+	return nil
+}
+
+func TestObserveReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), ".env")
+	write := func(content string) {
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+	}
+	write("A = 1\n")
+
+	cf, err := conf.NewConf(file)
+	if err != nil {
+		t.Fatalf("conf.NewConf: %v", err)
+	}
+	assert.Nil(t, cf.Load())
+
+	m := New("")
+	u := &updater{}
+	cf.RegisterReloadHook(m.ObserveReload)
+	cf.PushUpdater(u)
+	assert.Nil(t, cf.Watch())
+	defer cf.Close()
+
+	reloads := func(status string) float64 {
+		return testutil.ToFloat64(m.confReloads.WithLabelValues(status))
+	}
+
+	// 重载成功和更新失败分别计数
+	write("A = 2\n")
+	assert.Eventually(t, func() bool { return reloads(StatusOK) >= 1 }, 5*time.Second, 10*time.Millisecond)
+
+	atomic.StoreInt32(&u.fail, 1)
+	write("A = 3\n")
+	assert.Eventually(t, func() bool { return reloads(StatusError) >= 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// 配置文件无法读取时计为失败
+	atomic.StoreInt32(&u.fail, 0)
+	errs := reloads(StatusError)
+	assert.Nil(t, os.Remove(file))
+	assert.Nil(t, os.Mkdir(file, 0755))
+	assert.Eventually(t, func() bool { return reloads(StatusError) > errs }, 5*time.Second, 10*time.Millisecond)
+}
+
+func scrape(t *testing.T, m *Metrics) string {
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	return w.Body.String()
+}
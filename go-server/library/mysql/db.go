@@ -1,56 +1,69 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 const (
 	dataSourceNameFormat = "%s:%s@tcp(%s:%s)/%s"
-	driverName           = "mysql"
+)
+
+const (
+	DriverMySQL  = "mysql"   // MySQL驱动, 默认驱动
+	DriverSQLite = "sqlite3" // SQLite驱动, 用于本地开发和封闭测试
 )
 
 // DBConf 创建数据库连接池所需的配置
 type DBConf struct {
-	Name        string
-	Host        string
-	Port        string
-	UserName    string
-	Password    string
-	MaxLifeTime int
-	MaxOpenConn int
-	MaxIdleConn int
+	Driver       string // 驱动名称, 为空时使用MySQL, 使用SQLite时Name为数据库文件路径, 使用":memory:"时MaxOpenConn应为1
+	Name         string
+	Host         string
+	Port         string
+	UserName     string
+	Password     string
+	MaxLifeTime  int
+	MaxOpenConn  int
+	MaxIdleConn  int
+	MigrationDir string // 迁移文件目录, 不为空时连接池创建后自动执行目录下未执行过的迁移
 }
 
 // DB 对sql.DB进行装饰, 对常用的操作方法进行封装
 type DB struct {
 	*sql.DB
+	driver  string
 	stmts   sync.Map
 	stmtsmu sync.Mutex
+
+	// 数据库操作观测者, 存储observerHolder
+	observer atomic.Value
 }
 
-// NewDB 返回包装了指定配置创建的DB连接池的DB实例
+// NewDB 返回包装了指定配置创建的DB连接池的DB实例,
+// 配置了迁移目录时会在返回前执行迁移, 迁移失败将关闭连接池并返回错误
 func NewDB(cf *DBConf) (db *DB, err error) {
-	dsn := fmt.Sprintf(dataSourceNameFormat,
-		cf.UserName,
-		cf.Password,
-		cf.Host,
-		cf.Port,
-		cf.Name,
-	)
-
-	odb, err := sql.Open(driverName, dsn)
+	driver, dsn, err := datasource(cf)
+	if err != nil {
+		err = fmt.Errorf("data source: %w", err)
+		return
+	}
+
+	odb, err := sql.Open(driver, dsn)
 	if err != nil {
 		err = fmt.Errorf("sql open: %w", err)
 		return
 	}
 
 	if err = odb.Ping(); err != nil {
+		_ = odb.Close()
 		err = fmt.Errorf("db ping: %w", err)
 		return
 	}
@@ -59,14 +72,52 @@ func NewDB(cf *DBConf) (db *DB, err error) {
 	odb.SetMaxOpenConns(cf.MaxOpenConn)
 	odb.SetMaxIdleConns(cf.MaxIdleConn)
 
-	db = &DB{
-		DB:    odb,
-		stmts: sync.Map{},
+	ndb := &DB{
+		DB:     odb,
+		driver: driver,
+		stmts:  sync.Map{},
+	}
+
+	if cf.MigrationDir != "" {
+		if err = ndb.Migrate(cf.MigrationDir); err != nil {
+			_ = ndb.Close()
+			err = fmt.Errorf("migrate %s: %w", cf.MigrationDir, err)
+			return
+		}
+	}
+
+	db = ndb
+
+	return
+}
+
+// datasource 根据配置的驱动返回sql.Open所需的驱动名称和数据源
+func datasource(cf *DBConf) (driver, dsn string, err error) {
+	switch cf.Driver {
+	case "", DriverMySQL:
+		driver = DriverMySQL
+		dsn = fmt.Sprintf(dataSourceNameFormat,
+			cf.UserName,
+			cf.Password,
+			cf.Host,
+			cf.Port,
+			cf.Name,
+		)
+	case DriverSQLite:
+		driver = DriverSQLite
+		dsn = cf.Name
+	default:
+		err = fmt.Errorf("unsupported driver %q", cf.Driver)
 	}
 
 	return
 }
 
+// Driver 返回连接池使用的驱动名称
+func (db *DB) Driver() string {
+	return db.driver
+}
+
 // Prepare 缓存预处理语句，避免频繁的预处理调度
 func (db *DB) Prepare(qs string) (stmt *sql.Stmt, err error) {
 	val, ok := db.stmts.Load(qs)
@@ -100,6 +151,14 @@ func (db *DB) Close() (err error) {
 
 // Query 查询多行记录
 func (db *DB) Query(qs string, st interface{}, args ...interface{}) (err error) {
+	return db.QueryContext(context.Background(), qs, st, args...)
+}
+
+// QueryContext 以ctx查询多行记录, ctx携带的链路信息用于创建操作的span
+func (db *DB) QueryContext(ctx context.Context, qs string, st interface{}, args ...interface{}) (err error) {
+	_, end := begin(ctx, db.loadObserver(), db.driver, OpQuery, qs)
+	defer end(&err)
+
 	if ok := isstlist(st); !ok {
 		err = NewErrInvalidScanTo("non-nil *[]*struct")
 		return
@@ -111,12 +170,19 @@ func (db *DB) Query(qs string, st interface{}, args ...interface{}) (err error)
 		return
 	}
 
-	rows, err := stmt.Query(args...)
+	rows, err := stmt.QueryContext(ctx, args...)
 	if err != nil {
 		err = fmt.Errorf("sql query: %w", err)
 		return
 	}
 
+	err = scanlist(rows, st)
+
+	return
+}
+
+// scanlist 将查询结果的所有行扫描到st并关闭rows, st需为*[]*struct
+func scanlist(rows *sql.Rows, st interface{}) (err error) {
 	defer rows.Close()
 
 	cols, err := rows.Columns()
@@ -146,6 +212,14 @@ func (db *DB) Query(qs string, st interface{}, args ...interface{}) (err error)
 
 // QueryRow 查询单行
 func (db *DB) QueryRow(qs string, st interface{}, args ...interface{}) (err error) {
+	return db.QueryRowContext(context.Background(), qs, st, args...)
+}
+
+// QueryRowContext 以ctx查询单行
+func (db *DB) QueryRowContext(ctx context.Context, qs string, st interface{}, args ...interface{}) (err error) {
+	_, end := begin(ctx, db.loadObserver(), db.driver, OpQueryRow, qs)
+	defer end(&err)
+
 	if ok := isstrecord(st); !ok {
 		return NewErrInvalidScanTo("non-nil *struct")
 	}
@@ -156,12 +230,19 @@ func (db *DB) QueryRow(qs string, st interface{}, args ...interface{}) (err erro
 		return
 	}
 
-	rows, err := stmt.Query(args...)
+	rows, err := stmt.QueryContext(ctx, args...)
 	if err != nil {
 		err = fmt.Errorf("sql query: %w", err)
 		return
 	}
 
+	err = scanrecord(rows, st)
+
+	return
+}
+
+// scanrecord 将查询结果的第一行扫描到st并关闭rows, st需为*struct, 没有结果时返回包装了sql.ErrNoRows的错误
+func scanrecord(rows *sql.Rows, st interface{}) (err error) {
 	defer rows.Close()
 
 	cols, err := rows.Columns()
@@ -189,13 +270,21 @@ func (db *DB) QueryRow(qs string, st interface{}, args ...interface{}) (err erro
 
 // QueryRowAndScan 查询单行并将值填充到对应变量上
 func (db *DB) QueryRowAndScan(qs string, args []interface{}, st ...interface{}) (err error) {
+	return db.QueryRowAndScanContext(context.Background(), qs, args, st...)
+}
+
+// QueryRowAndScanContext 以ctx查询单行并将值填充到对应变量上
+func (db *DB) QueryRowAndScanContext(ctx context.Context, qs string, args []interface{}, st ...interface{}) (err error) {
+	_, end := begin(ctx, db.loadObserver(), db.driver, OpQueryRowAndScan, qs)
+	defer end(&err)
+
 	stmt, err := db.Prepare(qs)
 	if err != nil {
 		err = fmt.Errorf("sql prepare: %w", err)
 		return
 	}
 
-	if err = stmt.QueryRow(args...).Scan(st...); err != nil {
+	if err = stmt.QueryRowContext(ctx, args...).Scan(st...); err != nil {
 		err = fmt.Errorf("sql query and scan: %w", err)
 		return
 	}
@@ -205,13 +294,21 @@ func (db *DB) QueryRowAndScan(qs string, args []interface{}, st ...interface{})
 
 // Exec 执行sql语句
 func (db *DB) Exec(qs string, args ...interface{}) (affected, lastID int64, err error) {
+	return db.ExecContext(context.Background(), qs, args...)
+}
+
+// ExecContext 以ctx执行sql语句
+func (db *DB) ExecContext(ctx context.Context, qs string, args ...interface{}) (affected, lastID int64, err error) {
+	_, end := begin(ctx, db.loadObserver(), db.driver, OpExec, qs)
+	defer end(&err)
+
 	stmt, err := db.Prepare(qs)
 	if err != nil {
 		err = fmt.Errorf("sql prepare: %w", err)
 		return
 	}
 
-	rst, err := stmt.Exec(args...)
+	rst, err := stmt.ExecContext(ctx, args...)
 	if err != nil {
 		err = fmt.Errorf("sql exec: %w", err)
 		return
@@ -4,11 +4,15 @@ import (
 	"errors"
 	"fmt"
 	"go-sever/library/conf"
+	"sync"
 	"time"
 )
 
 type DBContainer struct {
 	*conf.Container
+
+	mu       sync.Mutex
+	observer QueryObserver // SetObserver设置的观测者, 替换连接池后仍然生效
 }
 
 var ErrGetDBConfFuncIsNil = errors.New("get db conf func is nil")
@@ -30,19 +34,45 @@ func NewDBContainer(getDBConf GetDBConfFunc) (ct *DBContainer, err error) {
 		return
 	}
 
-	ict, err := conf.NewContainer(getObjConf, compareDBConf, newDBObj, resetDBObj)
+	c := &DBContainer{}
+	newObj := func(icf conf.IConf) (iobj conf.IObject, err error) {
+		if iobj, err = newDBObj(icf); err != nil {
+			return
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.observer != nil {
+			iobj.(*DB).SetObserver(c.observer)
+		}
+
+		return
+	}
+
+	ict, err := conf.NewContainer(getObjConf, compareDBConf, newObj, resetDBObj)
 	if err != nil {
 		err = fmt.Errorf("new conf container: %w", err)
 		return
 	}
 
-	ct = &DBContainer{
-		Container: ict,
-	}
+	c.Container = ict
+	ct = c
 
 	return
 }
 
+// SetObserver 设置容器中连接池的数据库操作观测者, 之后因配置变化创建的连接池同样使用obs
+func (ct *DBContainer) SetObserver(obs QueryObserver) {
+	ct.mu.Lock()
+	ct.observer = obs
+	ct.mu.Unlock()
+
+	db := ct.MustGetDB()
+	defer ct.PutDB(db)
+
+	db.SetObserver(obs)
+}
+
 func compareDBConf(iocf, incf conf.IConf) (rst conf.CompareObjConfRst, err error) {
 	ocf, ok := iocf.(*DBConf)
 	if !ok {
@@ -58,6 +88,8 @@ func compareDBConf(iocf, incf conf.IConf) (rst conf.CompareObjConfRst, err error
 
 	switch {
 	case
+		ncf.Driver != ocf.Driver,
+		ncf.MigrationDir != ocf.MigrationDir,
 		ncf.UserName != ocf.UserName,
 		ncf.Password != ocf.Password,
 		ncf.Host != ocf.Host,
@@ -3,6 +3,12 @@
 
 package mysql
 
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
 func (ct *DBContainer) Query(qs string, to interface{}, args ...interface{}) (err error) {
 	db := ct.MustGetDB()
 	defer ct.PutDB(db)
@@ -12,6 +18,15 @@ func (ct *DBContainer) Query(qs string, to interface{}, args ...interface{}) (er
 	return
 }
 
+func (ct *DBContainer) QueryContext(ctx context.Context, qs string, to interface{}, args ...interface{}) (err error) {
+	db := ct.MustGetDB()
+	defer ct.PutDB(db)
+
+	err = db.QueryContext(ctx, qs, to, args...)
+
+	return
+}
+
 func (ct *DBContainer) QueryRow(qs string, to interface{}, args ...interface{}) (err error) {
 	db := ct.MustGetDB()
 	defer ct.PutDB(db)
@@ -20,6 +35,14 @@ func (ct *DBContainer) QueryRow(qs string, to interface{}, args ...interface{})
 	return
 }
 
+func (ct *DBContainer) QueryRowContext(ctx context.Context, qs string, to interface{}, args ...interface{}) (err error) {
+	db := ct.MustGetDB()
+	defer ct.PutDB(db)
+
+	err = db.QueryRowContext(ctx, qs, to, args...)
+	return
+}
+
 func (ct *DBContainer) QueryRowAndScan(qs string, args []interface{}, to ...interface{}) (err error) {
 	db := ct.MustGetDB()
 	defer ct.PutDB(db)
@@ -29,6 +52,15 @@ func (ct *DBContainer) QueryRowAndScan(qs string, args []interface{}, to ...inte
 	return
 }
 
+func (ct *DBContainer) QueryRowAndScanContext(ctx context.Context, qs string, args []interface{}, to ...interface{}) (err error) {
+	db := ct.MustGetDB()
+	defer ct.PutDB(db)
+
+	err = db.QueryRowAndScanContext(ctx, qs, args, to...)
+
+	return
+}
+
 func (ct *DBContainer) Exec(qs string, args ...interface{}) (affected, lastID int64, err error) {
 	db := ct.MustGetDB()
 	defer ct.PutDB(db)
@@ -37,3 +69,42 @@ func (ct *DBContainer) Exec(qs string, args ...interface{}) (affected, lastID in
 
 	return
 }
+
+func (ct *DBContainer) ExecContext(ctx context.Context, qs string, args ...interface{}) (affected, lastID int64, err error) {
+	db := ct.MustGetDB()
+	defer ct.PutDB(db)
+
+	affected, lastID, err = db.ExecContext(ctx, qs, args...)
+
+	return
+}
+
+func (ct *DBContainer) Transaction(ctx context.Context, f func(tx *Tx) error) (err error) {
+	db := ct.MustGetDB()
+	defer ct.PutDB(db)
+
+	err = db.Transaction(ctx, f)
+
+	return
+}
+
+// Ping 检查数据库连接是否可用, 用于健康检查
+func (ct *DBContainer) Ping(ctx context.Context) (err error) {
+	db := ct.MustGetDB()
+	defer ct.PutDB(db)
+
+	if err = db.PingContext(ctx); err != nil {
+		err = fmt.Errorf("db ping: %w", err)
+		return
+	}
+
+	return
+}
+
+// Stats 返回当前连接池的统计信息
+func (ct *DBContainer) Stats() sql.DBStats {
+	db := ct.MustGetDB()
+	defer ct.PutDB(db)
+
+	return db.Stats()
+}
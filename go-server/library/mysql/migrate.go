@@ -0,0 +1,248 @@
+package mysql
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	createMigrationTableSQL = `CREATE TABLE IF NOT EXISTS schema_migrations (version VARCHAR(255) NOT NULL PRIMARY KEY)`
+	queryMigrationSQL       = `SELECT version FROM schema_migrations`
+	insertMigrationSQL      = `INSERT INTO schema_migrations (version) VALUES (?)`
+)
+
+// Migrate 按文件名顺序执行dir目录下尚未执行过的.sql迁移文件, 已执行的文件名记录在schema_migrations表中,
+// 每个迁移文件在同一个事务中执行, 文件内的语句以';'分隔, BEGIN...END块(如SQLite触发器)内的';'不作为分隔符,
+// 需要注意MySQL的DDL语句会隐式提交事务, 因此包含DDL的迁移失败时可能需要人工修复
+func (db *DB) Migrate(dir string) (err error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		err = fmt.Errorf("glob migration files: %w", err)
+		return
+	}
+
+	sort.Strings(files)
+
+	if _, err = db.DB.Exec(createMigrationTableSQL); err != nil {
+		err = fmt.Errorf("create migration table: %w", err)
+		return
+	}
+
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		err = fmt.Errorf("applied migrations: %w", err)
+		return
+	}
+
+	for _, file := range files {
+		version := filepath.Base(file)
+		if applied[version] {
+			continue
+		}
+
+		if err = db.migrate(file, version); err != nil {
+			err = fmt.Errorf("migrate %s: %w", version, err)
+			return
+		}
+	}
+
+	return
+}
+
+// appliedMigrations 返回已执行的迁移版本集合
+func (db *DB) appliedMigrations() (applied map[string]bool, err error) {
+	rows, err := db.DB.Query(queryMigrationSQL)
+	if err != nil {
+		err = fmt.Errorf("sql query: %w", err)
+		return
+	}
+
+	defer rows.Close()
+
+	applied = make(map[string]bool)
+	for rows.Next() {
+		var version string
+		if err = rows.Scan(&version); err != nil {
+			err = fmt.Errorf("sql scan: %w", err)
+			return
+		}
+		applied[version] = true
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// migrate 在事务中执行单个迁移文件并记录版本
+func (db *DB) migrate(file, version string) (err error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		err = fmt.Errorf("read file: %w", err)
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		err = fmt.Errorf("sql begin: %w", err)
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for _, qs := range splitsql(string(content)) {
+		if _, err = tx.Exec(qs); err != nil {
+			err = fmt.Errorf("sql exec[sql=%s]: %w", qs, err)
+			return
+		}
+	}
+
+	if _, err = tx.Exec(insertMigrationSQL, version); err != nil {
+		err = fmt.Errorf("record version: %w", err)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = fmt.Errorf("sql commit: %w", err)
+		return
+	}
+
+	return
+}
+
+// splitsql 将迁移文件内容按';'拆分为单条语句, 去掉"--"注释, 字符串, 引号标识符和块注释中的';'不作为分隔符,
+// BEGIN...END和CASE...END块(可嵌套)内的';'不作为分隔符, END IF, END LOOP等流程控制的结束不影响块的层级
+func splitsql(content string) (stmts []string) {
+	var (
+		buf   strings.Builder
+		depth int
+	)
+
+	flush := func() {
+		if stmt := strings.TrimSpace(buf.String()); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+		buf.Reset()
+	}
+
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case strings.HasPrefix(content[i:], "--"):
+			// 注释内容不写入语句, 保留换行
+			i += lineCommentLen(content[i:])
+		case strings.HasPrefix(content[i:], "/*"):
+			n := blockCommentLen(content[i:])
+			buf.WriteString(content[i : i+n])
+			i += n
+		case c == '\'' || c == '"' || c == '`':
+			n := quotedLen(content[i:])
+			buf.WriteString(content[i : i+n])
+			i += n
+		case c == ';' && depth == 0:
+			flush()
+			i++
+		case isWordByte(c):
+			n := wordLen(content[i:])
+			word := strings.ToUpper(content[i : i+n])
+			switch {
+			case word == "CASE", word == "BEGIN" && !isTransactionBegin(content[i+n:]):
+				depth++
+			case word == "END" && depth > 0 && !endsControlFlow(content[i+n:]):
+				depth--
+			}
+			buf.WriteString(content[i : i+n])
+			i += n
+		default:
+			buf.WriteByte(c)
+			i++
+		}
+	}
+	flush()
+
+	return
+}
+
+// lineCommentLen 返回s开头的行注释长度, 不包含换行
+func lineCommentLen(s string) int {
+	if n := strings.IndexByte(s, '\n'); n >= 0 {
+		return n
+	}
+
+	return len(s)
+}
+
+// blockCommentLen 返回s开头的块注释长度, 块注释未结束时返回len(s)
+func blockCommentLen(s string) int {
+	if n := strings.Index(s[2:], "*/"); n >= 0 {
+		return n + 4
+	}
+
+	return len(s)
+}
+
+// quotedLen 返回s开头以s[0]为引号的字符串或标识符长度, 支持重复引号和反斜杠转义, 未结束时返回len(s)
+func quotedLen(s string) int {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if q != '`' {
+				i++
+			}
+		case q:
+			return i + 1
+		}
+	}
+
+	return len(s)
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func wordLen(s string) (n int) {
+	for n < len(s) && isWordByte(s[n]) {
+		n++
+	}
+
+	return
+}
+
+// nextWord 返回s中跳过空白后的单词的大写形式, 不是单词时返回空字符串
+func nextWord(s string) string {
+	s = strings.TrimLeft(s, " \t\r\n")
+	return strings.ToUpper(s[:wordLen(s)])
+}
+
+// isTransactionBegin 判断BEGIN之后的内容rest是否表示开始事务而不是语句块
+func isTransactionBegin(rest string) bool {
+	if strings.HasPrefix(strings.TrimLeft(rest, " \t\r\n"), ";") {
+		return true
+	}
+
+	switch nextWord(rest) {
+	case "TRANSACTION", "WORK", "DEFERRED", "IMMEDIATE", "EXCLUSIVE":
+		return true
+	}
+
+	return false
+}
+
+// endsControlFlow 判断END之后的内容rest是否表示流程控制语句的结束, 如END IF
+func endsControlFlow(rest string) bool {
+	switch nextWord(rest) {
+	case "IF", "LOOP", "WHILE", "REPEAT":
+		return true
+	}
+
+	return false
+}
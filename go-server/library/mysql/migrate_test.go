@@ -0,0 +1,124 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitSQL(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "statements",
+			content: "CREATE TABLE a (id INT);\n\nCREATE TABLE b (id INT);  INSERT INTO b VALUES (1);\n",
+			want:    []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)", "INSERT INTO b VALUES (1)"},
+		},
+		{
+			name:    "without trailing semicolon",
+			content: "INSERT INTO a VALUES (1);\nINSERT INTO a VALUES (2)\n",
+			want:    []string{"INSERT INTO a VALUES (1)", "INSERT INTO a VALUES (2)"},
+		},
+		{
+			name:    "empty statements",
+			content: ";\n ; INSERT INTO a VALUES (1);;",
+			want:    []string{"INSERT INTO a VALUES (1)"},
+		},
+		{
+			name:    "semicolon in string",
+			content: "INSERT INTO a VALUES ('x;y', \"z;\");\nINSERT INTO a VALUES ('multi;\nline;');",
+			want:    []string{"INSERT INTO a VALUES ('x;y', \"z;\")", "INSERT INTO a VALUES ('multi;\nline;')"},
+		},
+		{
+			name:    "escaped quote in string",
+			content: `INSERT INTO a VALUES ('it''s;', 'it\'s;');INSERT INTO a VALUES ('\\');`,
+			want:    []string{`INSERT INTO a VALUES ('it''s;', 'it\'s;')`, `INSERT INTO a VALUES ('\\')`},
+		},
+		{
+			name:    "quoted identifier",
+			content: "CREATE TABLE `a;b` (`end` INT, \"begin\" INT);\nSELECT 1;",
+			want:    []string{"CREATE TABLE `a;b` (`end` INT, \"begin\" INT)", "SELECT 1"},
+		},
+		{
+			name:    "line comments",
+			content: "-- 创建表; 不拆分\nCREATE TABLE a (\n    id INT -- 主键;\n);\n-- 结尾注释",
+			want:    []string{"CREATE TABLE a (\n    id INT \n)"},
+		},
+		{
+			name:    "comment marker in string",
+			content: "INSERT INTO a VALUES ('--;');",
+			want:    []string{"INSERT INTO a VALUES ('--;')"},
+		},
+		{
+			name:    "block comment",
+			content: "/* 初始化; 数据 */ INSERT INTO a VALUES (1);",
+			want:    []string{"/* 初始化; 数据 */ INSERT INTO a VALUES (1)"},
+		},
+		{
+			name: "trigger",
+			content: "CREATE TRIGGER t AFTER UPDATE ON a FOR EACH ROW BEGIN\n" +
+				"    UPDATE a SET n = 1 WHERE id = NEW.id;\n" +
+				"END;\n" +
+				"SELECT 1;",
+			want: []string{
+				"CREATE TRIGGER t AFTER UPDATE ON a FOR EACH ROW BEGIN\n    UPDATE a SET n = 1 WHERE id = NEW.id;\nEND",
+				"SELECT 1",
+			},
+		},
+		{
+			name: "nested begin end",
+			content: "CREATE PROCEDURE p() BEGIN\n" +
+				"    DECLARE n INT;\n" +
+				"    BEGIN\n" +
+				"        SET n = 1;\n" +
+				"    END;\n" +
+				"    IF n > 0 THEN\n" +
+				"        SET n = CASE WHEN n > 1 THEN 2 ELSE 1 END;\n" +
+				"    END IF;\n" +
+				"    WHILE n > 0 DO\n" +
+				"        SET n = n - 1;\n" +
+				"    END WHILE;\n" +
+				"END;\n" +
+				"CALL p();",
+			want: []string{
+				"CREATE PROCEDURE p() BEGIN\n" +
+					"    DECLARE n INT;\n" +
+					"    BEGIN\n" +
+					"        SET n = 1;\n" +
+					"    END;\n" +
+					"    IF n > 0 THEN\n" +
+					"        SET n = CASE WHEN n > 1 THEN 2 ELSE 1 END;\n" +
+					"    END IF;\n" +
+					"    WHILE n > 0 DO\n" +
+					"        SET n = n - 1;\n" +
+					"    END WHILE;\n" +
+					"END",
+				"CALL p()",
+			},
+		},
+		{
+			name:    "case expression",
+			content: "SELECT CASE WHEN a THEN 1 END FROM t;\nSELECT 2;",
+			want:    []string{"SELECT CASE WHEN a THEN 1 END FROM t", "SELECT 2"},
+		},
+		{
+			name:    "transaction begin",
+			content: "BEGIN;\nINSERT INTO a VALUES (1);\nBEGIN TRANSACTION;\nCOMMIT;",
+			want:    []string{"BEGIN", "INSERT INTO a VALUES (1)", "BEGIN TRANSACTION", "COMMIT"},
+		},
+		{
+			name:    "keyword in identifier",
+			content: "UPDATE a SET begin_at = 1, t_end = 2;\nSELECT 1;",
+			want:    []string{"UPDATE a SET begin_at = 1, t_end = 2", "SELECT 1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, splitsql(tt.content))
+		})
+	}
+}
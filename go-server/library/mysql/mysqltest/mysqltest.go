@@ -0,0 +1,58 @@
+// mysqltest 包提供基于SQLite的封闭测试辅助函数, 每个测试使用独立的临时数据库文件,
+// 数据库在创建时自动执行迁移, 并在测试结束时关闭
+package mysqltest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"go-server/library/mysql"
+)
+
+// Conf 返回指向当前测试临时目录下独立SQLite数据库的配置, migrationDir为空时不执行迁移
+func Conf(tb testing.TB, migrationDir string) *mysql.DBConf {
+	tb.Helper()
+
+	return &mysql.DBConf{
+		Driver:       mysql.DriverSQLite,
+		Name:         filepath.Join(tb.TempDir(), "test.db") + "?_busy_timeout=5000",
+		MaxOpenConn:  1,
+		MaxIdleConn:  1,
+		MigrationDir: migrationDir,
+	}
+}
+
+// NewDB 创建当前测试独立的DB实例, 创建失败时测试立即失败
+func NewDB(tb testing.TB, migrationDir string) *mysql.DB {
+	tb.Helper()
+
+	db, err := mysql.NewDB(Conf(tb, migrationDir))
+	if err != nil {
+		tb.Fatalf("mysql.NewDB: %v", err)
+	}
+
+	tb.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	return db
+}
+
+// NewDBContainer 创建当前测试独立的DBContainer实例, 可直接替换component.DBContainer用于测试model和logic
+func NewDBContainer(tb testing.TB, migrationDir string) *mysql.DBContainer {
+	tb.Helper()
+
+	cf := Conf(tb, migrationDir)
+	ct, err := mysql.NewDBContainer(func() (*mysql.DBConf, error) {
+		return cf, nil
+	})
+	if err != nil {
+		tb.Fatalf("mysql.NewDBContainer: %v", err)
+	}
+
+	tb.Cleanup(func() {
+		_ = ct.Close()
+	})
+
+	return ct
+}
@@ -0,0 +1,83 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-server/library/trace"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// 观测的数据库操作名称
+const (
+	OpQuery           = "query"
+	OpQueryRow        = "query_row"
+	OpQueryRowAndScan = "query_row_and_scan"
+	OpExec            = "exec"
+	OpTransaction     = "transaction"
+)
+
+// QueryObserver 观测数据库操作的耗时和结果, 用于统计, 实现需并发安全,
+// 查询单行没有结果时err包装了sql.ErrNoRows
+type QueryObserver interface {
+	ObserveQuery(op string, elapsed time.Duration, err error)
+}
+
+// observerHolder 使atomic.Value中存储的类型保持一致
+type observerHolder struct {
+	obs QueryObserver
+}
+
+// SetObserver 设置数据库操作观测者, 为nil时不再观测, 事务中的操作使用开始事务时的观测者
+func (db *DB) SetObserver(obs QueryObserver) {
+	db.observer.Store(observerHolder{obs: obs})
+}
+
+func (db *DB) loadObserver() QueryObserver {
+	holder, _ := db.observer.Load().(observerHolder)
+	return holder.obs
+}
+
+// begin 开始一个数据库操作, ctx携带span上下文时创建操作的span, 返回携带span的ctx和以defer方式调用的结束函数,
+// 结束时以errp指向的错误设置span状态并记录观测结果, 没有结果的查询不视为span错误, qs为空时不记录语句
+func begin(ctx context.Context, obs QueryObserver, driver, op, qs string) (sctx context.Context, end func(errp *error)) {
+	sctx = ctx
+
+	var span oteltrace.Span
+	if trace.Traced(ctx) {
+		attrs := []attribute.KeyValue{
+			semconv.DBSystemKey.String(driver),
+			semconv.DBOperationKey.String(op),
+		}
+		if qs != "" {
+			attrs = append(attrs, semconv.DBStatementKey.String(qs))
+		}
+
+		sctx, span = trace.StartSpan(ctx, driver+" "+op,
+			oteltrace.WithSpanKind(oteltrace.SpanKindClient),
+			oteltrace.WithAttributes(attrs...),
+		)
+	}
+
+	start := time.Now()
+	end = func(errp *error) {
+		if span != nil {
+			serr := *errp
+			if errors.Is(serr, sql.ErrNoRows) {
+				serr = nil
+			}
+			trace.EndSpan(span, &serr)
+		}
+
+		if obs != nil {
+			obs.ObserveQuery(op, time.Since(start), *errp)
+		}
+	}
+
+	return
+}
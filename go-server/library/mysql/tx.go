@@ -0,0 +1,122 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Tx 对sql.Tx进行装饰, 提供与DB一致的常用操作方法
+type Tx struct {
+	*sql.Tx
+	ctx      context.Context // 开始事务的ctx, 携带事务的span, 事务中的操作均以此ctx执行
+	driver   string
+	observer QueryObserver
+}
+
+// Transaction 在事务中执行f, f返回错误或panic时回滚事务, 否则提交事务,
+// ctx携带span上下文时为事务创建span, 事务中操作的span均为其子span
+func (db *DB) Transaction(ctx context.Context, f func(tx *Tx) error) (err error) {
+	obs := db.loadObserver()
+	ctx, end := begin(ctx, obs, db.driver, OpTransaction, "")
+	defer end(&err)
+
+	stx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		err = fmt.Errorf("sql begin: %w", err)
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			_ = stx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err = f(&Tx{Tx: stx, ctx: ctx, driver: db.driver, observer: obs}); err != nil {
+		if rerr := stx.Rollback(); rerr != nil {
+			err = fmt.Errorf("%w, sql rollback: %v", err, rerr)
+		}
+		return
+	}
+
+	if err = stx.Commit(); err != nil {
+		err = fmt.Errorf("sql commit: %w", err)
+		return
+	}
+
+	return
+}
+
+// Driver 返回事务所属连接池使用的驱动名称
+func (tx *Tx) Driver() string {
+	return tx.driver
+}
+
+// Query 在事务中查询多行记录
+func (tx *Tx) Query(qs string, st interface{}, args ...interface{}) (err error) {
+	_, end := begin(tx.ctx, tx.observer, tx.driver, OpQuery, qs)
+	defer end(&err)
+
+	if ok := isstlist(st); !ok {
+		err = NewErrInvalidScanTo("non-nil *[]*struct")
+		return
+	}
+
+	rows, err := tx.Tx.QueryContext(tx.ctx, qs, args...)
+	if err != nil {
+		err = fmt.Errorf("sql query: %w", err)
+		return
+	}
+
+	err = scanlist(rows, st)
+
+	return
+}
+
+// QueryRow 在事务中查询单行
+func (tx *Tx) QueryRow(qs string, st interface{}, args ...interface{}) (err error) {
+	_, end := begin(tx.ctx, tx.observer, tx.driver, OpQueryRow, qs)
+	defer end(&err)
+
+	if ok := isstrecord(st); !ok {
+		return NewErrInvalidScanTo("non-nil *struct")
+	}
+
+	rows, err := tx.Tx.QueryContext(tx.ctx, qs, args...)
+	if err != nil {
+		err = fmt.Errorf("sql query: %w", err)
+		return
+	}
+
+	err = scanrecord(rows, st)
+
+	return
+}
+
+// Exec 在事务中执行sql语句
+func (tx *Tx) Exec(qs string, args ...interface{}) (affected, lastID int64, err error) {
+	_, end := begin(tx.ctx, tx.observer, tx.driver, OpExec, qs)
+	defer end(&err)
+
+	rst, err := tx.Tx.ExecContext(tx.ctx, qs, args...)
+	if err != nil {
+		err = fmt.Errorf("sql exec: %w", err)
+		return
+	}
+
+	affected, err = rst.RowsAffected()
+	if err != nil {
+		err = fmt.Errorf("sql rows affected: %w", err)
+		return
+	}
+
+	lastID, err = rst.LastInsertId()
+	if err != nil {
+		err = fmt.Errorf("sql last id: %w", err)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,74 @@
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go-server/library/mysql"
+)
+
+// 投递到Kafka时附加的消息头
+const (
+	HeaderEventID       = "x-event-id"
+	HeaderEventType     = "x-event-type"
+	HeaderAggregateType = "x-aggregate-type"
+	HeaderAggregateID   = "x-aggregate-id"
+)
+
+// Event 发件箱事件, 与业务数据在同一事务中写入t_outbox表, 由Relay异步投递到Kafka,
+// 同一聚合(AggregateType, AggregateID)的事件按写入顺序投递
+type Event struct {
+	AggregateType string            // 聚合类型, 如product_category
+	AggregateID   string            // 聚合ID
+	EventType     string            // 事件类型, 如created
+	Topic         string            // 投递的Kafka主题
+	Key           string            // Kafka消息键, 为空时使用AggregateID, 保证同一聚合的消息进入同一分区
+	Payload       []byte            // 消息内容
+	Headers       map[string]string // 附加的消息头, 可以为nil
+}
+
+const insertEventSQL = `INSERT INTO t_outbox (aggregate_type, aggregate_id, event_type, topic, msg_key, payload, headers)
+VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+// Add 在业务事务tx中写入事件, 事务提交后事件才对Relay可见, 事务回滚时事件一并丢弃
+func Add(tx *mysql.Tx, ev *Event) (id int64, err error) {
+	key := ev.Key
+	if key == "" {
+		key = ev.AggregateID
+	}
+
+	headers := ev.Headers
+	if headers == nil {
+		headers = map[string]string{}
+	}
+
+	hb, err := json.Marshal(headers)
+	if err != nil {
+		err = fmt.Errorf("marshal headers: %w", err)
+		return
+	}
+
+	payload := ev.Payload
+	if payload == nil {
+		payload = []byte{}
+	}
+
+	if _, id, err = tx.Exec(
+		insertEventSQL, ev.AggregateType, ev.AggregateID, ev.EventType, ev.Topic, key, payload, string(hb),
+	); err != nil {
+		err = fmt.Errorf("tx.Exec[sql=%s]: %w", insertEventSQL, err)
+		return
+	}
+
+	return
+}
+
+// AddJSON 将v编码为JSON作为Payload后写入事件
+func AddJSON(tx *mysql.Tx, ev *Event, v interface{}) (id int64, err error) {
+	if ev.Payload, err = json.Marshal(v); err != nil {
+		err = fmt.Errorf("marshal payload: %w", err)
+		return
+	}
+
+	return Add(tx, ev)
+}
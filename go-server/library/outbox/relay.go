@@ -0,0 +1,295 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+
+	"go-server/library/kafka"
+	"go-server/library/mysql"
+)
+
+const (
+	defaultRelayBatchSize     = 100
+	defaultRelayPollInterval  = time.Second
+	defaultRelayLeaseDuration = time.Minute
+	defaultRelayMaxAttempts   = 10
+
+	// maxLastErrorLen 与t_outbox.last_error的列宽一致
+	maxLastErrorLen = 1024
+)
+
+const (
+	queryPendingSQL = `SELECT id, aggregate_type, aggregate_id, event_type, topic, msg_key, payload, headers, attempts,
+locked_until IS NOT NULL AND locked_until > CURRENT_TIMESTAMP AS leased
+FROM t_outbox WHERE sent_at IS NULL AND parked_at IS NULL ORDER BY id LIMIT ?`
+	markSentSQL   = `UPDATE t_outbox SET sent_at = CURRENT_TIMESTAMP, attempts = attempts + 1, locked_until = NULL WHERE id = ?`
+	markFailedSQL = `UPDATE t_outbox SET attempts = attempts + 1, last_error = ?, locked_until = NULL WHERE id = ?`
+	markParkedSQL = `UPDATE t_outbox SET attempts = attempts + 1, last_error = ?, locked_until = NULL, parked_at = CURRENT_TIMESTAMP WHERE id = ?`
+	releaseSQL    = `UPDATE t_outbox SET locked_until = NULL WHERE id = ?`
+)
+
+// RelayOptions 发件箱投递选项, 零值成员使用默认值
+type RelayOptions struct {
+	BatchSize     int           // 每批读取的最大事件数, 默认100
+	PollInterval  time.Duration // 没有待投递事件时的轮询间隔, 默认1s
+	LeaseDuration time.Duration // 领取事件的租约时长, 应大于投递一批事件的耗时, 默认1m
+	MaxAttempts   int           // 单个事件的最大投递次数, 达到后搁置该事件不再投递, 默认10
+	HandleErr     func(error)   // 处理投递过程中的错误, 可以为nil
+}
+
+// Relay 轮询t_outbox表, 将未投递的事件同步发送到Kafka并标记为已投递,
+// 每批事件先在一个短事务中以SELECT ... FOR UPDATE锁定并写入租约到期时间后提交, 再在事务外按ID顺序投递,
+// 租约未到期的事件不会被其他实例领取, 同一聚合存在租约未到期的事件时, 该聚合的后续事件也不会被领取,
+// 某个事件投递失败时, 本批中同一聚合的后续事件不再投递并释放租约, 以保证同一聚合的事件按顺序投递,
+// 投递次数达到MaxAttempts的事件被搁置(parked_at非空), 不再投递也不再阻塞同一聚合的后续事件, 需人工处理.
+// 投递语义为至少一次: Kafka确认后标记失败或进程退出时, 事件会在租约到期后被再次投递, 因此消费方需要按x-event-id消息头幂等处理
+type Relay struct {
+	db       *mysql.DBContainer
+	producer kafka.MessageSender
+	opts     RelayOptions
+
+	mu      sync.Mutex
+	started bool
+	closed  bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// outboxRow t_outbox表中待投递的事件
+type outboxRow struct {
+	ID            int64  `db:"id"`
+	AggregateType string `db:"aggregate_type"`
+	AggregateID   string `db:"aggregate_id"`
+	EventType     string `db:"event_type"`
+	Topic         string `db:"topic"`
+	Key           string `db:"msg_key"`
+	Payload       []byte `db:"payload"`
+	Headers       string `db:"headers"`
+	Attempts      int    `db:"attempts"`
+	Leased        bool   `db:"leased"`
+}
+
+// NewRelay 创建发件箱投递者, producer通常为SyncProducerContainer, opts为nil时使用默认选项
+func NewRelay(db *mysql.DBContainer, producer kafka.MessageSender, opts *RelayOptions) *Relay {
+	r := &Relay{
+		db:       db,
+		producer: producer,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	if opts != nil {
+		r.opts = *opts
+	}
+	if r.opts.BatchSize <= 0 {
+		r.opts.BatchSize = defaultRelayBatchSize
+	}
+	if r.opts.PollInterval <= 0 {
+		r.opts.PollInterval = defaultRelayPollInterval
+	}
+	if r.opts.LeaseDuration < time.Second {
+		r.opts.LeaseDuration = defaultRelayLeaseDuration
+	}
+	if r.opts.MaxAttempts <= 0 {
+		r.opts.MaxAttempts = defaultRelayMaxAttempts
+	}
+
+	return r
+}
+
+// Start 启动投递协程, 重复调用或关闭后调用时不做任何处理
+func (r *Relay) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.started || r.closed {
+		return
+	}
+	r.started = true
+
+	go func() {
+		defer close(r.done)
+		r.run()
+	}()
+}
+
+// Close 实现io.Closer接口, 停止投递并等待当前批次完成
+func (r *Relay) Close() (err error) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+	r.closed = true
+	started := r.started
+	close(r.stop)
+	r.mu.Unlock()
+
+	if started {
+		<-r.done
+	}
+
+	return
+}
+
+func (r *Relay) run() {
+	for {
+		n, err := r.RelayOnce(context.Background())
+		if err != nil && r.opts.HandleErr != nil {
+			r.opts.HandleErr(err)
+		}
+
+		// 整批投递成功时可能还有待投递的事件, 立即处理下一批
+		wait := r.opts.PollInterval
+		if err == nil && n == r.opts.BatchSize {
+			wait = 0
+		}
+
+		select {
+		case <-r.stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// RelayOnce 领取并投递一批事件, 返回成功投递的事件数, 部分事件投递失败时返回的错误包含第一个失败事件的错误
+func (r *Relay) RelayOnce(ctx context.Context) (sent int, err error) {
+	rows, err := r.claim(ctx)
+	if err != nil {
+		err = fmt.Errorf("claim: %w", err)
+		return
+	}
+
+	var failed error
+	blocked := make(map[string]bool)
+	for _, row := range rows {
+		aggregate := row.AggregateType + "/" + row.AggregateID
+		if blocked[aggregate] {
+			if _, _, err = r.db.ExecContext(ctx, releaseSQL, row.ID); err != nil {
+				err = fmt.Errorf("db.Exec[sql=%s]: %w", releaseSQL, err)
+				return
+			}
+			continue
+		}
+
+		perr := r.publish(row)
+		if perr == nil {
+			if _, _, err = r.db.ExecContext(ctx, markSentSQL, row.ID); err != nil {
+				err = fmt.Errorf("db.Exec[sql=%s]: %w", markSentSQL, err)
+				return
+			}
+			sent++
+			continue
+		}
+
+		msg := perr.Error()
+		if len(msg) > maxLastErrorLen {
+			msg = msg[:maxLastErrorLen]
+		}
+
+		// 达到最大投递次数时搁置事件, 不再阻塞同一聚合的后续事件
+		qs := markFailedSQL
+		if row.Attempts+1 >= r.opts.MaxAttempts {
+			qs = markParkedSQL
+			perr = fmt.Errorf("%w, parked after %d attempts", perr, row.Attempts+1)
+		} else {
+			blocked[aggregate] = true
+		}
+		if failed == nil {
+			failed = fmt.Errorf("publish event %d: %w", row.ID, perr)
+		}
+
+		if _, _, err = r.db.ExecContext(ctx, qs, msg, row.ID); err != nil {
+			err = fmt.Errorf("db.Exec[sql=%s]: %w", qs, err)
+			return
+		}
+	}
+
+	err = failed
+
+	return
+}
+
+// claim 在短事务中锁定一批待投递事件并写入租约, 跳过租约未到期的事件及同一聚合中位于其后的事件
+func (r *Relay) claim(ctx context.Context) (claimed []*outboxRow, err error) {
+	err = r.db.Transaction(ctx, func(tx *mysql.Tx) (err error) {
+		qs := queryPendingSQL
+		leaseSQL := `UPDATE t_outbox SET locked_until = CURRENT_TIMESTAMP + INTERVAL ? SECOND WHERE id = ?`
+		if tx.Driver() == mysql.DriverMySQL {
+			qs += " FOR UPDATE"
+		} else {
+			leaseSQL = `UPDATE t_outbox SET locked_until = datetime('now', '+' || ? || ' seconds') WHERE id = ?`
+		}
+
+		var rows []*outboxRow
+		if err = tx.Query(qs, &rows, r.opts.BatchSize); err != nil {
+			err = fmt.Errorf("tx.Query[sql=%s]: %w", qs, err)
+			return
+		}
+
+		lease := int64(r.opts.LeaseDuration / time.Second)
+		leased := make(map[string]bool)
+		for _, row := range rows {
+			aggregate := row.AggregateType + "/" + row.AggregateID
+			if row.Leased || leased[aggregate] {
+				leased[aggregate] = true
+				continue
+			}
+
+			if _, _, err = tx.Exec(leaseSQL, lease, row.ID); err != nil {
+				err = fmt.Errorf("tx.Exec[sql=%s]: %w", leaseSQL, err)
+				return
+			}
+			claimed = append(claimed, row)
+		}
+
+		return
+	})
+	if err != nil {
+		claimed = nil
+		err = fmt.Errorf("transaction: %w", err)
+		return
+	}
+
+	return
+}
+
+func (r *Relay) publish(row *outboxRow) (err error) {
+	headers := map[string]string{}
+	if row.Headers != "" {
+		if err = json.Unmarshal([]byte(row.Headers), &headers); err != nil {
+			err = fmt.Errorf("unmarshal headers: %w", err)
+			return
+		}
+	}
+
+	headers[HeaderEventID] = strconv.FormatInt(row.ID, 10)
+	headers[HeaderEventType] = row.EventType
+	headers[HeaderAggregateType] = row.AggregateType
+	headers[HeaderAggregateID] = row.AggregateID
+
+	msg := &sarama.ProducerMessage{
+		Topic: row.Topic,
+		Value: sarama.ByteEncoder(row.Payload),
+	}
+	if row.Key != "" {
+		msg.Key = sarama.StringEncoder(row.Key)
+	}
+	for k, v := range headers {
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(k), Value: []byte(v)})
+	}
+
+	if _, _, err = r.producer.SendMessage(msg); err != nil {
+		err = fmt.Errorf("send message: %w", err)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,174 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+
+	"go-server/library/kafka/kafkatest"
+	"go-server/library/mysql"
+	"go-server/library/mysql/mysqltest"
+)
+
+const testTopic = "test.events"
+
+func newTestDB(t *testing.T) *mysql.DBContainer {
+	return mysqltest.NewDBContainer(t, "../../model/migrations/sqlite")
+}
+
+// addEvents 在一个事务中为每个聚合ID依次写入一个事件, 返回事件ID
+func addEvents(t *testing.T, db *mysql.DBContainer, aggregateIDs ...string) (ids []int64) {
+	t.Helper()
+
+	err := db.Transaction(context.Background(), func(tx *mysql.Tx) (err error) {
+		for _, aid := range aggregateIDs {
+			var id int64
+			if id, err = Add(tx, &Event{AggregateType: "order", AggregateID: aid, EventType: "changed", Topic: testTopic}); err != nil {
+				return
+			}
+			ids = append(ids, id)
+		}
+		return
+	})
+	if err != nil {
+		t.Fatalf("add events: %v", err)
+	}
+
+	return
+}
+
+// sentIDs 返回主题中已投递消息的x-event-id
+func sentIDs(broker *kafkatest.Broker) (ids []string) {
+	for _, msg := range broker.Messages(testTopic) {
+		for _, h := range msg.Headers {
+			if string(h.Key) == HeaderEventID {
+				ids = append(ids, string(h.Value))
+			}
+		}
+	}
+
+	return
+}
+
+func failIDs(broker *kafkatest.Broker, ids ...string) {
+	broker.SetProduceErrFunc(func(msg *sarama.ProducerMessage) error {
+		for _, h := range msg.Headers {
+			if string(h.Key) != HeaderEventID {
+				continue
+			}
+			for _, id := range ids {
+				if string(h.Value) == id {
+					return errors.New("broker unavailable")
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func TestRelay(t *testing.T) {
+	db := newTestDB(t)
+	broker := kafkatest.NewBroker()
+	relay := NewRelay(db, broker, nil)
+
+	addEvents(t, db, "a", "b", "a")
+
+	// 投递失败时同一聚合的后续事件不投递, 其他聚合不受影响
+	failIDs(broker, "1")
+	sent, err := relay.RelayOnce(context.Background())
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, []string{"2"}, sentIDs(broker))
+
+	broker.SetProduceErrFunc(nil)
+	sent, err = relay.RelayOnce(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, sent)
+	assert.Equal(t, []string{"2", "1", "3"}, sentIDs(broker))
+
+	sent, err = relay.RelayOnce(context.Background())
+	assert.Nil(t, err)
+	assert.Zero(t, sent)
+}
+
+func TestRelayOutsideTransaction(t *testing.T) {
+	db := newTestDB(t)
+	addEvents(t, db, "a")
+
+	// 测试数据库只有一个连接, 投递时仍持有领取事务会导致这里的写入超时
+	broker := kafkatest.NewBroker()
+	broker.SetProduceErrFunc(func(*sarama.ProducerMessage) error {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, _, err := db.ExecContext(ctx, `UPDATE t_outbox SET last_error = '' WHERE id = 0`)
+		return err
+	})
+
+	sent, err := NewRelay(db, broker, nil).RelayOnce(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, sent)
+}
+
+func TestRelayLease(t *testing.T) {
+	db := newTestDB(t)
+	broker := kafkatest.NewBroker()
+	relay := NewRelay(db, broker, nil)
+
+	addEvents(t, db, "a", "a", "b")
+
+	// 模拟其他实例领取了事件1, 租约到期前不投递该事件及同一聚合的后续事件
+	_, _, err := db.Exec(`UPDATE t_outbox SET locked_until = datetime('now', '+60 seconds') WHERE id = 1`)
+	assert.Nil(t, err)
+
+	sent, err := relay.RelayOnce(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, []string{"3"}, sentIDs(broker))
+
+	// 租约到期后重新领取
+	_, _, err = db.Exec(`UPDATE t_outbox SET locked_until = datetime('now', '-1 seconds') WHERE id = 1`)
+	assert.Nil(t, err)
+
+	sent, err = relay.RelayOnce(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, sent)
+	assert.Equal(t, []string{"3", "1", "2"}, sentIDs(broker))
+}
+
+func TestRelayPark(t *testing.T) {
+	db := newTestDB(t)
+	broker := kafkatest.NewBroker()
+	relay := NewRelay(db, broker, &RelayOptions{MaxAttempts: 2})
+
+	addEvents(t, db, "a", "a")
+	failIDs(broker, "1")
+
+	sent, err := relay.RelayOnce(context.Background())
+	assert.NotNil(t, err)
+	assert.Zero(t, sent)
+
+	// 达到最大投递次数后搁置事件1, 同一聚合的后续事件继续投递
+	sent, err = relay.RelayOnce(context.Background())
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, []string{"2"}, sentIDs(broker))
+
+	var row struct {
+		Attempts  int    `db:"attempts"`
+		LastError string `db:"last_error"`
+		Parked    bool   `db:"parked"`
+	}
+	assert.Nil(t, db.QueryRow(`SELECT attempts, last_error, parked_at IS NOT NULL AS parked FROM t_outbox WHERE id = 1`, &row))
+	assert.Equal(t, 2, row.Attempts)
+	assert.Contains(t, row.LastError, "broker unavailable")
+	assert.True(t, row.Parked)
+
+	broker.SetProduceErrFunc(nil)
+	sent, err = relay.RelayOnce(context.Background())
+	assert.Nil(t, err)
+	assert.Zero(t, sent)
+}
@@ -0,0 +1,486 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis"
+
+	"go-server/library/trace"
+)
+
+// ErrCacheNotFound 加载函数以该错误表示数据不存在, 配置了NegativeTTL时不存在的结果也会被缓存,
+// 此时GetOrLoad直接返回该错误而不再调用加载函数
+var ErrCacheNotFound = errors.New("cache: not found")
+
+const (
+	cacheFlagValue    byte = 'v' // 缓存值标记, 后接编码后的数据
+	cacheFlagNotFound byte = 'n' // 未找到结果标记
+
+	cacheTagKeyFmt        = "cache:tag:{%s}"            // 标签集合的键, 使用hash tag保证与其失效中的快照位于同一slot
+	cacheTagPendingKeyFmt = "cache:tag:{%s}:pending:%s" // 失效中的标签集合快照的键, 第二个参数为随机令牌
+
+	defaultCacheJitter   = 0.1
+	defaultCacheLocalTTL = time.Minute
+)
+
+// tagScript 将缓存键加入标签集合, 并保证标签集合的过期时间不短于缓存键
+var tagScript = redis.NewScript(`
+redis.call("SADD", KEYS[1], ARGV[1])
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl < tonumber(ARGV[2]) then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+// tagSnapshotScript 将标签集合原子地改名为快照, 之后加载写入的缓存键会进入新的标签集合而不会随快照被删除,
+// 标签集合不存在时返回0
+var tagSnapshotScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+redis.call("RENAME", KEYS[1], KEYS[2])
+return 1
+`)
+
+// tagRestoreScript 删除缓存键失败时将快照中的成员合并回标签集合, 保证之后的失效仍能删除这些键
+var tagRestoreScript = redis.NewScript(`
+redis.call("SUNIONSTORE", KEYS[1], KEYS[1], KEYS[2])
+local ttl = redis.call("PTTL", KEYS[2])
+if ttl > 0 and redis.call("PTTL", KEYS[1]) < ttl then
+	redis.call("PEXPIRE", KEYS[1], ttl)
+end
+redis.call("DEL", KEYS[2])
+return 1
+`)
+
+// LoadFunc 缓存未命中时的数据加载函数, 数据不存在时应返回ErrCacheNotFound
+type LoadFunc func(ctx context.Context) (val interface{}, err error)
+
+// CacheConf 缓存辅助方法的配置
+type CacheConf struct {
+	Codec       Codec         // 编解码, 为nil时使用JSON
+	Jitter      float64       // TTL随机抖动比例, 如0.1表示在ttl基础上随机增加至多10%, 用于避免缓存集中失效
+	NegativeTTL time.Duration // 未找到结果的缓存时长, 为0时不缓存未找到结果
+	HandleErr   func(error)   // 缓存读写失败的错误处理函数, 读写缓存失败时GetOrLoad会降级为直接加载
+
+	// 进程内本地缓存, LocalSize大于0时在redis前增加一层有界LRU缓存,
+	// 本实例写入或删除缓存时通过InvalidateChannel频道通知其它实例删除对应的本地缓存
+	LocalSize         int           // 本地缓存最大条目数, 为0时不启用本地缓存
+	LocalTTL          time.Duration // 本地缓存最大存活时长, 条目实际存活时长不超过其redis缓存时长, 默认1分钟
+	InvalidateChannel string        // 本地缓存失效广播频道, 默认cache:invalidate
+}
+
+// CacheTierStats 单层缓存的统计数据
+type CacheTierStats struct {
+	Hits      uint64 // 命中次数
+	Misses    uint64 // 未命中次数
+	Evictions uint64 // 容量淘汰次数, 仅本地缓存有效
+	Size      int    // 当前条目数, 仅本地缓存有效
+}
+
+// CacheStats 缓存统计数据
+type CacheStats struct {
+	Local      CacheTierStats // 本地缓存统计, 未启用时为零值
+	Redis      CacheTierStats // redis缓存统计
+	Loads      uint64         // 调用加载函数的次数
+	LoadErrors uint64         // 加载函数返回错误(不含ErrCacheNotFound)的次数
+}
+
+// cacheCounters redis层及加载函数的统计计数
+type cacheCounters struct {
+	hits       uint64
+	misses     uint64
+	loads      uint64
+	loadErrors uint64
+}
+
+type loadOptions struct {
+	tags []string
+}
+
+// LoadOption 定义GetOrLoad的单次调用选项
+type LoadOption func(*loadOptions)
+
+// WithTags 为本次加载写入的缓存键打上标签, 之后可以通过InvalidateTags批量删除
+func WithTags(tags ...string) LoadOption {
+	return func(o *loadOptions) {
+		o.tags = append(o.tags, tags...)
+	}
+}
+
+// SetCacheConf 设置容器缓存辅助方法的配置, 应在使用GetOrLoad前调用,
+// 启用本地缓存时将同时启动失效广播的订阅, 再次设置时已有的订阅会被停止, 订阅在容器关闭时停止,
+// 重复设置会清空已有的本地缓存
+func (ct *ClientContainer) SetCacheConf(cf *CacheConf) {
+	ct.cachemu.Lock()
+
+	ct.cachecf = *cf
+	if ct.cachecf.Codec == nil {
+		ct.cachecf.Codec = CodecJSON
+	}
+	if ct.cachecf.LocalTTL <= 0 {
+		ct.cachecf.LocalTTL = defaultCacheLocalTTL
+	}
+	if ct.cachecf.InvalidateChannel == "" {
+		ct.cachecf.InvalidateChannel = defaultCacheInvalidateChannel
+	}
+
+	// 旧的订阅可能订阅在旧的频道上, 新的订阅按当前配置重新启动
+	stopSync := ct.stopSync
+	ct.stopSync = nil
+
+	ct.local = nil
+	if ct.cachecf.LocalSize > 0 {
+		ct.local = newLocalCache(ct.cachecf.LocalSize, ct.cachecf.LocalTTL)
+		ct.startCacheSync()
+	}
+
+	ct.cachemu.Unlock()
+
+	// 订阅协程会读取缓存配置, 需在释放cachemu后停止
+	if stopSync != nil {
+		stopSync()
+	}
+}
+
+func (ct *ClientContainer) getCacheConf() CacheConf {
+	ct.cachemu.RLock()
+	defer ct.cachemu.RUnlock()
+
+	return ct.cachecf
+}
+
+func (ct *ClientContainer) getLocalCache() *localCache {
+	ct.cachemu.RLock()
+	defer ct.cachemu.RUnlock()
+
+	return ct.local
+}
+
+// CacheStats 返回本地缓存与redis缓存各层的统计数据
+func (ct *ClientContainer) CacheStats() (st CacheStats) {
+	if local := ct.getLocalCache(); local != nil {
+		st.Local = local.stats()
+	}
+
+	st.Redis = CacheTierStats{
+		Hits:   atomic.LoadUint64(&ct.counters.hits),
+		Misses: atomic.LoadUint64(&ct.counters.misses),
+	}
+	st.Loads = atomic.LoadUint64(&ct.counters.loads)
+	st.LoadErrors = atomic.LoadUint64(&ct.counters.loadErrors)
+
+	return
+}
+
+// GetOrLoad 实现缓存旁路读取: 命中缓存时将缓存值解码到dst, 未命中时调用loader加载数据,
+// 以抖动后的ttl写入缓存后再解码到dst, dst必须是非nil指针,
+// 同一进程内对同一key的并发加载会被合并为一次, 合并的加载在只携带首个调用者链路信息的ctx上执行,
+// 不受任何调用者取消的影响, 调用者的ctx取消时该调用者立即返回ctx的错误, 加载继续完成并写入缓存,
+// 数据不存在时返回ErrCacheNotFound, 读写缓存失败时降级为直接加载且错误交由HandleErr处理
+func (ct *ClientContainer) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoadFunc, dst interface{}, opts ...LoadOption) (err error) {
+	cf := ct.getCacheConf()
+	local := ct.getLocalCache()
+
+	var epoch uint64
+	if local != nil {
+		if data, ok := local.get(key); ok {
+			return decodeCache(cf.Codec, data, dst)
+		}
+		epoch = local.version()
+	}
+
+	data, remain, err := ct.getCache(ctx, key, local != nil)
+	switch {
+	case err == nil:
+		atomic.AddUint64(&ct.counters.hits, 1)
+		if local != nil {
+			local.fill(key, data, remain, epoch)
+		}
+		return decodeCache(cf.Codec, data, dst)
+	case errors.Is(err, redis.Nil):
+		atomic.AddUint64(&ct.counters.misses, 1)
+	default:
+		atomic.AddUint64(&ct.counters.misses, 1)
+		ct.handleCacheErr(cf, fmt.Errorf("get cache %s: %w", key, err))
+	}
+
+	lo := &loadOptions{}
+	for _, opt := range opts {
+		opt(lo)
+	}
+
+	lctx := trace.Detach(ctx)
+	ch := ct.loads.DoChan(key, func() (interface{}, error) {
+		return ct.load(lctx, cf, local, epoch, key, ttl, loader, lo)
+	})
+
+	select {
+	case <-ctx.Done():
+		err = fmt.Errorf("load: %w", ctx.Err())
+		return
+	case rst := <-ch:
+		if rst.Err != nil {
+			err = fmt.Errorf("load: %w", rst.Err)
+			return
+		}
+		return decodeCache(cf.Codec, rst.Val.([]byte), dst)
+	}
+}
+
+// SetCache 编码val并以抖动后的ttl写入缓存, 启用本地缓存时同时写入本地并通知其它实例删除旧的本地缓存
+func (ct *ClientContainer) SetCache(ctx context.Context, key string, val interface{}, ttl time.Duration, opts ...LoadOption) (err error) {
+	cf := ct.getCacheConf()
+
+	encoded, err := cf.Codec.Marshal(val)
+	if err != nil {
+		err = fmt.Errorf("codec marshal: %w", err)
+		return
+	}
+
+	lo := &loadOptions{}
+	for _, opt := range opts {
+		opt(lo)
+	}
+
+	data := append([]byte{cacheFlagValue}, encoded...)
+	ttl = jitterTTL(ttl, cf.Jitter)
+
+	if err = ct.setCache(ctx, key, data, ttl, lo.tags); err != nil {
+		err = fmt.Errorf("set cache: %w", err)
+		return
+	}
+
+	if local := ct.getLocalCache(); local != nil {
+		local.set(key, data, ttl)
+	}
+	ct.publishInvalidate(cf, key)
+
+	return
+}
+
+// DeleteCache 删除指定的缓存键, 启用本地缓存时在redis删除之后再删除本地缓存并通知其它实例,
+// 保证与之并发的GetOrLoad不会将删除前从redis读取的旧值回填到本地缓存
+func (ct *ClientContainer) DeleteCache(ctx context.Context, keys ...string) (err error) {
+	if len(keys) == 0 {
+		return
+	}
+
+	cf := ct.getCacheConf()
+	defer func() {
+		if local := ct.getLocalCache(); local != nil {
+			local.del(keys...)
+		}
+		ct.publishInvalidate(cf, keys...)
+	}()
+
+	err = ct.withClient(ctx, func(cli redis.Cmdable) (err error) {
+		_, err = cli.Pipelined(func(pipe redis.Pipeliner) error {
+			for _, key := range keys {
+				pipe.Del(key)
+			}
+			return nil
+		})
+		return
+	})
+	if err != nil {
+		err = fmt.Errorf("del: %w", err)
+		return
+	}
+
+	return
+}
+
+// InvalidateTags 删除带有指定标签的所有缓存键及标签集合本身,
+// 标签集合先被原子地改名为快照再删除快照中的键, 与之并发的加载写入的键会进入新的标签集合, 不会因标签集合被删除而无法失效
+func (ct *ClientContainer) InvalidateTags(ctx context.Context, tags ...string) (err error) {
+	for _, tag := range tags {
+		if err = ct.invalidateTag(ctx, tag); err != nil {
+			err = fmt.Errorf("invalidate tag %s: %w", tag, err)
+			return
+		}
+	}
+
+	return
+}
+
+func (ct *ClientContainer) invalidateTag(ctx context.Context, tag string) (err error) {
+	token, err := newToken()
+	if err != nil {
+		err = fmt.Errorf("new token: %w", err)
+		return
+	}
+
+	tagKey := fmt.Sprintf(cacheTagKeyFmt, tag)
+	pendingKey := fmt.Sprintf(cacheTagPendingKeyFmt, tag, token)
+
+	var keys []string
+	err = ct.withClient(ctx, func(cli redis.Cmdable) (err error) {
+		n, err := tagSnapshotScript.Run(cli, []string{tagKey, pendingKey}).Int64()
+		if err != nil || n == 0 {
+			return
+		}
+
+		keys, err = cli.SMembers(pendingKey).Result()
+		return
+	})
+	if err != nil {
+		err = fmt.Errorf("snapshot %s: %w", tagKey, err)
+		return
+	}
+
+	if len(keys) == 0 {
+		return
+	}
+
+	if err = ct.DeleteCache(ctx, append(keys, pendingKey)...); err != nil {
+		rerr := ct.withClient(context.Background(), func(cli redis.Cmdable) error {
+			return tagRestoreScript.Run(cli, []string{tagKey, pendingKey}).Err()
+		})
+		if rerr != nil {
+			err = fmt.Errorf("%w, restore %s: %v", err, tagKey, rerr)
+		}
+		return
+	}
+
+	return
+}
+
+// load 调用加载函数并写入缓存, 返回带标记的缓存数据, 本地缓存仅在失效版本仍为epoch时写入,
+// 未命中时redis中没有该键, 其它实例的本地缓存也不会有有效的旧值, 因此回填不广播失效
+func (ct *ClientContainer) load(ctx context.Context, cf CacheConf, local *localCache, epoch uint64, key string, ttl time.Duration, loader LoadFunc, lo *loadOptions) (data []byte, err error) {
+	atomic.AddUint64(&ct.counters.loads, 1)
+
+	val, err := loader(ctx)
+	switch {
+	case errors.Is(err, ErrCacheNotFound):
+		data = []byte{cacheFlagNotFound}
+		ttl = cf.NegativeTTL
+	case err != nil:
+		atomic.AddUint64(&ct.counters.loadErrors, 1)
+		return
+	default:
+		encoded, merr := cf.Codec.Marshal(val)
+		if merr != nil {
+			err = fmt.Errorf("codec marshal: %w", merr)
+			return
+		}
+		data = append([]byte{cacheFlagValue}, encoded...)
+		ttl = jitterTTL(ttl, cf.Jitter)
+	}
+
+	err = nil
+	if ttl <= 0 {
+		return
+	}
+
+	if serr := ct.setCache(ctx, key, data, ttl, lo.tags); serr != nil {
+		ct.handleCacheErr(cf, fmt.Errorf("set cache %s: %w", key, serr))
+		return
+	}
+
+	if local != nil {
+		local.fill(key, data, ttl, epoch)
+	}
+
+	return
+}
+
+// getCache 读取缓存数据, withTTL为true时同时返回剩余过期时间, 用于限制本地缓存的存活时长
+func (ct *ClientContainer) getCache(ctx context.Context, key string, withTTL bool) (data []byte, ttl time.Duration, err error) {
+	err = ct.withClient(ctx, func(cli redis.Cmdable) (err error) {
+		if !withTTL {
+			data, err = cli.Get(key).Bytes()
+			return
+		}
+
+		var (
+			getCmd  *redis.StringCmd
+			pttlCmd *redis.DurationCmd
+		)
+		_, err = cli.Pipelined(func(pipe redis.Pipeliner) error {
+			getCmd = pipe.Get(key)
+			pttlCmd = pipe.PTTL(key)
+			return nil
+		})
+		if err != nil {
+			return
+		}
+
+		data, err = getCmd.Bytes()
+		ttl = pttlCmd.Val()
+
+		return
+	})
+
+	return
+}
+
+func (ct *ClientContainer) setCache(ctx context.Context, key string, data []byte, ttl time.Duration, tags []string) (err error) {
+	return ct.withClient(ctx, func(cli redis.Cmdable) (err error) {
+		if err = cli.Set(key, data, ttl).Err(); err != nil {
+			err = fmt.Errorf("set: %w", err)
+			return
+		}
+
+		for _, tag := range tags {
+			if err = tagScript.Run(cli, []string{fmt.Sprintf(cacheTagKeyFmt, tag)}, key, ttl.Milliseconds()).Err(); err != nil {
+				err = fmt.Errorf("tag %s: %w", tag, err)
+				return
+			}
+		}
+
+		return
+	})
+}
+
+func (ct *ClientContainer) handleCacheErr(cf CacheConf, err error) {
+	if cf.HandleErr != nil {
+		cf.HandleErr(err)
+	}
+}
+
+// withClient 以绑定了ctx的容器当前客户端执行f
+func (ct *ClientContainer) withClient(ctx context.Context, f func(cli redis.Cmdable) error) error {
+	cli := ct.MustGetClient()
+	defer ct.PutClient(cli)
+
+	return f(cli.WithContext(ctx))
+}
+
+// decodeCache 解码带标记的缓存数据到dst
+func decodeCache(codec Codec, data []byte, dst interface{}) (err error) {
+	if len(data) == 0 {
+		err = fmt.Errorf("invalid cache data")
+		return
+	}
+
+	switch data[0] {
+	case cacheFlagNotFound:
+		err = ErrCacheNotFound
+	case cacheFlagValue:
+		if err = codec.Unmarshal(data[1:], dst); err != nil {
+			err = fmt.Errorf("codec unmarshal: %w", err)
+		}
+	default:
+		err = fmt.Errorf("invalid cache flag %q", data[0])
+	}
+
+	return
+}
+
+// jitterTTL 在ttl的基础上随机增加[0, ttl*ratio)的时长
+func jitterTTL(ttl time.Duration, ratio float64) time.Duration {
+	max := int64(float64(ttl) * ratio)
+	if max <= 0 {
+		return ttl
+	}
+
+	return ttl + time.Duration(rand.Int63n(max))
+}
@@ -0,0 +1,138 @@
+package redis
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultCacheInvalidateChannel = "cache:invalidate"
+
+	cacheSyncMinBackoff = 100 * time.Millisecond
+	cacheSyncMaxBackoff = 5 * time.Second
+)
+
+// invalidateMessage 本地缓存失效广播消息
+type invalidateMessage struct {
+	Origin string   `json:"origin"` // 发送实例标识, 实例忽略自己发出的消息
+	Keys   []string `json:"keys"`
+}
+
+// publishInvalidate 向其它实例广播需要从本地缓存删除的键, 未启用本地缓存时不广播
+func (ct *ClientContainer) publishInvalidate(cf CacheConf, keys ...string) {
+	if cf.LocalSize <= 0 || len(keys) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(&invalidateMessage{
+		Origin: ct.origin,
+		Keys:   keys,
+	})
+	if err != nil {
+		ct.handleCacheErr(cf, fmt.Errorf("marshal invalidate message: %w", err))
+		return
+	}
+
+	cli := ct.MustGetClient()
+	defer ct.PutClient(cli)
+
+	if err = cli.Publish(cf.InvalidateChannel, payload).Err(); err != nil {
+		ct.handleCacheErr(cf, fmt.Errorf("publish %s: %w", cf.InvalidateChannel, err))
+	}
+}
+
+// startCacheSync 启动订阅失效广播的协程, 重复调用时不会重复启动
+func (ct *ClientContainer) startCacheSync() {
+	if ct.stopSync != nil {
+		return
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ct.syncCache(stop)
+	}()
+
+	ct.stopSync = func() {
+		close(stop)
+		<-done
+	}
+}
+
+// syncCache 订阅失效广播并删除本地缓存, 订阅中断(如连接断开或客户端因配置热更新被替换)时,
+// 期间的消息可能丢失, 因此清空本地缓存后以退避间隔从容器获取当前客户端重新订阅, 直到stop关闭
+func (ct *ClientContainer) syncCache(stop chan struct{}) {
+	backoff := cacheSyncMinBackoff
+
+	for {
+		cf := ct.getCacheConf()
+
+		cli := ct.MustGetClient()
+		ps := cli.Subscribe(cf.InvalidateChannel)
+		ct.PutClient(cli)
+
+		// 等待订阅确认, 确认成功后重置退避间隔
+		if _, err := ps.ReceiveTimeout(cacheSyncMaxBackoff); err != nil {
+			_ = ps.Close()
+			ct.handleCacheErr(cf, fmt.Errorf("subscribe %s: %w", cf.InvalidateChannel, err))
+		} else {
+			backoff = cacheSyncMinBackoff
+
+			errc := make(chan error, 1)
+			go func() {
+				for {
+					msg, err := ps.ReceiveMessage()
+					if err != nil {
+						errc <- err
+						return
+					}
+
+					ct.handleInvalidate(msg.Payload)
+				}
+			}()
+
+			select {
+			case <-stop:
+				_ = ps.Close()
+				<-errc
+				return
+			case err := <-errc:
+				_ = ps.Close()
+				ct.handleCacheErr(cf, fmt.Errorf("receive %s: %w", cf.InvalidateChannel, err))
+			}
+		}
+
+		if local := ct.getLocalCache(); local != nil {
+			local.purge()
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(jitter(backoff)):
+		}
+
+		if backoff *= 2; backoff > cacheSyncMaxBackoff {
+			backoff = cacheSyncMaxBackoff
+		}
+	}
+}
+
+func (ct *ClientContainer) handleInvalidate(payload string) {
+	msg := &invalidateMessage{}
+	if err := json.Unmarshal([]byte(payload), msg); err != nil {
+		ct.handleCacheErr(ct.getCacheConf(), fmt.Errorf("unmarshal invalidate message: %w", err))
+		return
+	}
+
+	if msg.Origin == ct.origin {
+		return
+	}
+
+	if local := ct.getLocalCache(); local != nil {
+		local.del(msg.Keys...)
+	}
+}
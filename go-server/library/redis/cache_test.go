@@ -0,0 +1,259 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+
+	"go-server/library/trace"
+)
+
+func TestInvalidateTags(t *testing.T) {
+	ct, mr := newTestContainer(t)
+
+	var calls int
+	loader := func(ctx context.Context) (interface{}, error) {
+		calls++
+		return calls, nil
+	}
+
+	var v int
+	for _, key := range []string{"a", "b"} {
+		assert.Nil(t, ct.GetOrLoad(context.Background(), key, time.Minute, loader, &v, WithTags("t")))
+	}
+	assert.Nil(t, ct.SetCache(context.Background(), "c", 0, time.Minute, WithTags("t", "u")))
+
+	members, err := mr.SMembers("cache:tag:{t}")
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, members)
+
+	assert.Nil(t, ct.InvalidateTags(context.Background(), "t"))
+	for _, key := range []string{"a", "b", "c", "cache:tag:{t}"} {
+		assert.False(t, mr.Exists(key), key)
+	}
+	assert.Len(t, mr.Keys(), 1) // 仅剩标签u的集合, 没有残留的快照
+
+	// 失效后写入的键进入新的标签集合, 下次失效时仍能删除
+	assert.Nil(t, ct.GetOrLoad(context.Background(), "a", time.Minute, loader, &v, WithTags("t")))
+	assert.Equal(t, 3, v)
+	assert.Nil(t, ct.InvalidateTags(context.Background(), "t", "none"))
+	assert.False(t, mr.Exists("a"))
+}
+
+func TestInvalidateTagsRestore(t *testing.T) {
+	ct, mr := newTestContainer(t)
+
+	assert.Nil(t, ct.SetCache(context.Background(), "a", 1, time.Minute, WithTags("t")))
+
+	mr.SetError("LOADING")
+	err := ct.InvalidateTags(context.Background(), "t")
+	mr.SetError("")
+	assert.NotNil(t, err)
+
+	// 删除失败时快照中的成员合并回标签集合
+	assert.Nil(t, tagSnapshotScript.Run(ct.MustGetClient(), []string{"cache:tag:{t}", "cache:tag:{t}:pending:x"}).Err())
+	assert.Nil(t, tagRestoreScript.Run(ct.MustGetClient(), []string{"cache:tag:{t}", "cache:tag:{t}:pending:x"}).Err())
+	members, err := mr.SMembers("cache:tag:{t}")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a"}, members)
+	assert.False(t, mr.Exists("cache:tag:{t}:pending:x"))
+
+	assert.Nil(t, ct.InvalidateTags(context.Background(), "t"))
+	assert.False(t, mr.Exists("a"))
+}
+
+func TestLazyConnect(t *testing.T) {
+	_, err := NewClient(&ClientConf{Addr: "127.0.0.1:1"})
+	assert.NotNil(t, err)
+
+	cli, err := NewClient(&ClientConf{Addr: "127.0.0.1:1", LazyConnect: true})
+	if assert.Nil(t, err) {
+		assert.NotNil(t, cli.Ping().Err())
+		assert.Nil(t, cli.Close())
+	}
+
+	// redis不可用时GetOrLoad降级为直接加载
+	ct, err := NewContainer(func() (*ClientConf, error) {
+		return &ClientConf{Addr: "127.0.0.1:1", LazyConnect: true}, nil
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer ct.Close()
+
+	var handled []error
+	ct.SetCacheConf(&CacheConf{HandleErr: func(err error) { handled = append(handled, err) }})
+
+	var v string
+	err = ct.GetOrLoad(context.Background(), "k", time.Minute, func(ctx context.Context) (interface{}, error) {
+		return "v", nil
+	}, &v)
+	assert.Nil(t, err)
+	assert.Equal(t, "v", v)
+	assert.Len(t, handled, 2)
+
+	err = ct.GetOrLoad(context.Background(), "nf", time.Minute, func(ctx context.Context) (interface{}, error) {
+		return nil, ErrCacheNotFound
+	}, &v)
+	assert.True(t, errors.Is(err, ErrCacheNotFound))
+}
+
+func TestGetOrLoadCanceled(t *testing.T) {
+	ct, mr := newTestContainer(t)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var calls int32
+	var requestID string
+	loader := func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		requestID = trace.RequestID(ctx)
+		close(started)
+
+		select {
+		case <-release:
+			return "v", nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	ctx, cancel := context.WithCancel(trace.WithRequestID(context.Background(), "req-1"))
+	leader := make(chan error, 1)
+	go func() {
+		var v string
+		leader <- ct.GetOrLoad(ctx, "k", time.Minute, loader, &v)
+	}()
+	<-started
+
+	follower := make(chan error, 1)
+	var got string
+	go func() {
+		follower <- ct.GetOrLoad(context.Background(), "k", time.Minute, loader, &got)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// 首个调用者取消时立即返回, 合并的加载不受影响
+	cancel()
+	select {
+	case err := <-leader:
+		assert.True(t, errors.Is(err, context.Canceled), err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("canceled caller did not return")
+	}
+
+	close(release)
+	select {
+	case err := <-follower:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("load did not finish")
+	}
+	assert.Equal(t, "v", got)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, "req-1", requestID)
+	assert.True(t, mr.Exists("k"))
+}
+
+func TestLocalCacheFill(t *testing.T) {
+	lc := newLocalCache(2, time.Minute)
+
+	// 读取redis期间发生删除时不回填旧值
+	epoch := lc.version()
+	lc.del("a")
+	lc.fill("a", []byte("old"), time.Minute, epoch)
+	_, ok := lc.get("a")
+	assert.False(t, ok)
+
+	epoch = lc.version()
+	lc.fill("a", []byte("new"), time.Minute, epoch)
+	data, ok := lc.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "new", string(data))
+
+	epoch = lc.version()
+	lc.purge()
+	lc.fill("b", []byte("old"), time.Minute, epoch)
+	_, ok = lc.get("b")
+	assert.False(t, ok)
+}
+
+func TestDeleteCacheLocal(t *testing.T) {
+	ct, mr := newTestContainer(t)
+	ct.SetCacheConf(&CacheConf{LocalSize: 10})
+	defer ct.Close()
+
+	assert.Nil(t, ct.SetCache(context.Background(), "k", "v", time.Minute))
+	assert.Nil(t, ct.DeleteCache(context.Background(), "k"))
+	assert.False(t, mr.Exists("k"))
+
+	var v string
+	err := ct.GetOrLoad(context.Background(), "k", time.Minute, func(ctx context.Context) (interface{}, error) {
+		return "loaded", nil
+	}, &v)
+	assert.Nil(t, err)
+	assert.Equal(t, "loaded", v)
+
+	// redis删除失败时本地缓存仍被删除
+	mr.SetError("LOADING")
+	assert.NotNil(t, ct.DeleteCache(context.Background(), "k"))
+	mr.SetError("")
+	_, ok := ct.getLocalCache().get("k")
+	assert.False(t, ok)
+}
+
+func TestCacheSync(t *testing.T) {
+	ct, mr := newTestContainer(t)
+	defer ct.Close()
+
+	other, err := NewContainer(func() (*ClientConf, error) { return &ClientConf{Addr: mr.Addr()}, nil })
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer other.Close()
+
+	ct.SetCacheConf(&CacheConf{LocalSize: 10})
+	other.SetCacheConf(&CacheConf{LocalSize: 10})
+	assert.Eventually(t, func() bool {
+		return mr.PubSubNumSub(defaultCacheInvalidateChannel)[defaultCacheInvalidateChannel] == 2
+	}, time.Second, 10*time.Millisecond)
+
+	cli := ct.MustGetClient()
+	ps := cli.Subscribe(defaultCacheInvalidateChannel)
+	ct.PutClient(cli)
+	defer ps.Close()
+	_, err = ps.ReceiveTimeout(time.Second)
+	assert.Nil(t, err)
+
+	// 未命中回填不广播失效
+	var v string
+	loader := func(ctx context.Context) (interface{}, error) { return "v", nil }
+	assert.Nil(t, ct.GetOrLoad(context.Background(), "k", time.Minute, loader, &v))
+	assert.Nil(t, other.GetOrLoad(context.Background(), "k", time.Minute, loader, &v))
+	_, ok := other.getLocalCache().get("k")
+	assert.True(t, ok)
+
+	// 删除时广播失效, 其它实例删除本地缓存
+	assert.Nil(t, ct.DeleteCache(context.Background(), "k"))
+	msg, err := ps.ReceiveTimeout(time.Second)
+	if assert.Nil(t, err) {
+		assert.Contains(t, msg.(*redis.Message).Payload, `"keys":["k"]`)
+	}
+	assert.Eventually(t, func() bool {
+		_, ok := other.getLocalCache().get("k")
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+
+	// 关闭本地缓存后停止订阅
+	ct.SetCacheConf(&CacheConf{})
+	other.SetCacheConf(&CacheConf{})
+	assert.Eventually(t, func() bool {
+		return mr.PubSubNumSub(defaultCacheInvalidateChannel)[defaultCacheInvalidateChannel] == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Nil(t, ct.stopSync)
+}
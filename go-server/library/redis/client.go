@@ -1,47 +1,147 @@
 package redis
 
 import (
+	"context"
 	"fmt"
+	"strings"
+	"sync/atomic"
 
 	"github.com/go-redis/redis"
+
+	"go-server/library/trace"
+)
+
+const (
+	ModeStandalone = "standalone" // 单节点模式, 默认模式
+	ModeSentinel   = "sentinel"   // 哨兵模式
+	ModeCluster    = "cluster"    // 集群模式
 )
 
 type ClientConf struct {
-	Addr     string
-	Password string
-	DB       int
-	PoolSize int
+	Mode       string // 部署模式, 可选standalone, sentinel, cluster, 为空时为standalone
+	Addr       string // 节点地址, sentinel模式为哨兵地址, cluster模式为集群种子节点地址, 多个用英文','号隔开
+	MasterName string // sentinel模式的主节点名称
+	Password   string
+	DB         int // cluster模式不支持选择DB, 该配置将被忽略
+	PoolSize   int
+
+	// LazyConnect为true时创建客户端不检查连通性, redis暂不可用时不会导致创建失败, 命令在redis恢复后自动成功
+	LazyConnect bool
 }
 
+// Client 对三种部署模式的go-redis客户端进行统一封装, standalone和sentinel模式底层为*redis.Client, cluster模式底层为*redis.ClusterClient
 type Client struct {
-	*redis.Client
+	redis.UniversalClient
+
+	// 命令观测者, 存储observerHolder
+	observer atomic.Value
 }
 
 func NewClient(cf *ClientConf) (cli *Client, err error) {
-	ocli := redis.NewClient(&redis.Options{
-		Addr:     cf.Addr,
-		Password: cf.Password,
-		DB:       cf.DB,
-		PoolSize: cf.PoolSize,
-	})
-
-	if _, err = ocli.Ping().Result(); err != nil {
-		err = fmt.Errorf("client ping: %w", err)
+	ocli, err := newUniversalClient(cf)
+	if err != nil {
+		err = fmt.Errorf("new universal client: %w", err)
 		return
 	}
 
+	if !cf.LazyConnect {
+		if _, err = ocli.Ping().Result(); err != nil {
+			_ = ocli.Close()
+			err = fmt.Errorf("client ping: %w", err)
+			return
+		}
+	}
+
 	cli = &Client{
-		Client: ocli,
+		UniversalClient: ocli,
+	}
+	cli.wrapProcess()
+
+	return
+}
+
+// newUniversalClient 根据配置的部署模式创建对应的go-redis客户端
+func newUniversalClient(cf *ClientConf) (ocli redis.UniversalClient, err error) {
+	addrs := splitAddrs(cf.Addr)
+
+	switch strings.ToLower(cf.Mode) {
+	case "", ModeStandalone:
+		if len(addrs) != 1 {
+			err = fmt.Errorf("standalone mode requires exactly one addr, got %q", cf.Addr)
+			return
+		}
+		ocli = redis.NewClient(&redis.Options{
+			Addr:     addrs[0],
+			Password: cf.Password,
+			DB:       cf.DB,
+			PoolSize: cf.PoolSize,
+		})
+	case ModeSentinel:
+		if cf.MasterName == "" {
+			err = fmt.Errorf("sentinel mode requires master name")
+			return
+		}
+		if len(addrs) == 0 {
+			err = fmt.Errorf("sentinel mode requires sentinel addrs")
+			return
+		}
+		ocli = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    cf.MasterName,
+			SentinelAddrs: addrs,
+			Password:      cf.Password,
+			DB:            cf.DB,
+			PoolSize:      cf.PoolSize,
+		})
+	case ModeCluster:
+		if len(addrs) == 0 {
+			err = fmt.Errorf("cluster mode requires cluster addrs")
+			return
+		}
+		ocli = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:    addrs,
+			Password: cf.Password,
+			PoolSize: cf.PoolSize,
+		})
+	default:
+		err = fmt.Errorf("unsupported mode %q", cf.Mode)
+	}
+
+	return
+}
+
+// WithContext 返回绑定了ctx的底层客户端, ctx携带span上下文时通过返回的客户端执行的命令将创建span
+func (cli *Client) WithContext(ctx context.Context) (ocli redis.UniversalClient) {
+	switch c := cli.UniversalClient.(type) {
+	case *redis.Client:
+		ocli = c.WithContext(ctx)
+	case *redis.ClusterClient:
+		ocli = c.WithContext(ctx)
+	default:
+		return c
+	}
+
+	if trace.Traced(ctx) {
+		wrapTrace(ctx, ocli)
 	}
 
 	return
 }
 
 func (cli *Client) Close() (err error) {
-	if err = cli.Client.Close(); err != nil {
+	if err = cli.UniversalClient.Close(); err != nil {
 		err = fmt.Errorf("client close: %w", err)
 		return
 	}
 
 	return
 }
+
+func splitAddrs(addr string) (addrs []string) {
+	for _, a := range strings.Split(addr, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			addrs = append(addrs, a)
+		}
+	}
+
+	return
+}
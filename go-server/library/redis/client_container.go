@@ -1,9 +1,13 @@
 package redis
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"go-server/library/conf"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
 )
 
 var (
@@ -12,6 +16,29 @@ var (
 
 type ClientContainer struct {
 	*conf.Container
+
+	// 缓存辅助方法配置
+	cachemu sync.RWMutex
+	cachecf CacheConf
+
+	// 进程内本地缓存, 未启用时为nil
+	local *localCache
+
+	// 停止本地缓存失效广播订阅
+	stopSync func()
+
+	// 本实例标识, 用于忽略自己发出的失效广播
+	origin string
+
+	// 合并同一进程内对同一缓存键的并发加载
+	loads singleflight.Group
+
+	// 缓存统计计数
+	counters cacheCounters
+
+	// SetObserver设置的命令观测者, 替换客户端后仍然生效
+	obsmu    sync.Mutex
+	observer CommandObserver
 }
 
 type GetClientConfFunc func() (*ClientConf, error)
@@ -32,19 +59,73 @@ func NewContainer(getCliCf GetClientConfFunc) (ct *ClientContainer, err error) {
 		return
 	}
 
-	ict, err := conf.NewContainer(getObjConf, compareClientConf, newClientObj, nil)
+	c := &ClientContainer{}
+	newObj := func(icf conf.IConf) (iobj conf.IObject, err error) {
+		if iobj, err = newClientObj(icf); err != nil {
+			return
+		}
+
+		c.obsmu.Lock()
+		defer c.obsmu.Unlock()
+		if c.observer != nil {
+			iobj.(*Client).SetObserver(c.observer)
+		}
+
+		return
+	}
+
+	ict, err := conf.NewContainer(getObjConf, compareClientConf, newObj, nil)
 	if err != nil {
 		err = fmt.Errorf("new conf container: %w", err)
 		return
 	}
 
-	ct = &ClientContainer{
-		Container: ict,
+	origin, err := newToken()
+	if err != nil {
+		_ = ict.Close()
+		err = fmt.Errorf("new origin: %w", err)
+		return
+	}
+
+	c.Container = ict
+	c.cachecf = CacheConf{
+		Codec:             CodecJSON,
+		Jitter:            defaultCacheJitter,
+		LocalTTL:          defaultCacheLocalTTL,
+		InvalidateChannel: defaultCacheInvalidateChannel,
 	}
+	c.origin = origin
+	ct = c
 
 	return
 }
 
+// SetObserver 设置容器中客户端的命令观测者, 之后因配置变化创建的客户端同样使用obs
+func (ct *ClientContainer) SetObserver(obs CommandObserver) {
+	ct.obsmu.Lock()
+	ct.observer = obs
+	ct.obsmu.Unlock()
+
+	cli := ct.MustGetClient()
+	defer ct.PutClient(cli)
+
+	cli.SetObserver(obs)
+}
+
+// Close 停止本地缓存失效广播的订阅, 然后关闭容器
+func (ct *ClientContainer) Close() (err error) {
+	ct.cachemu.Lock()
+	stopSync := ct.stopSync
+	ct.stopSync = nil
+	ct.cachemu.Unlock()
+
+	if stopSync != nil {
+		stopSync()
+	}
+
+	return ct.Container.Close()
+}
+
 func newClientObj(icf conf.IConf) (iobj conf.IObject, err error) {
 	cf, ok := icf.(*ClientConf)
 	if !ok {
@@ -99,3 +180,16 @@ func (ct *ClientContainer) PutClient(cli *Client) {
 	ct.PutObj(cli)
 	return
 }
+
+// Ping 检查redis连接是否可用, 用于健康检查
+func (ct *ClientContainer) Ping(ctx context.Context) (err error) {
+	cli := ct.MustGetClient()
+	defer ct.PutClient(cli)
+
+	if err = cli.WithContext(ctx).Ping().Err(); err != nil {
+		err = fmt.Errorf("redis ping: %w", err)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,108 @@
+package redis
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewUniversalClient(t *testing.T) {
+	tests := []struct {
+		name    string
+		cf      *ClientConf
+		cluster bool // 是否为*redis.ClusterClient
+		err     bool
+	}{
+		{name: "default", cf: &ClientConf{Addr: "127.0.0.1:6379"}},
+		{name: "standalone", cf: &ClientConf{Mode: "Standalone", Addr: " 127.0.0.1:6379 "}},
+		{name: "sentinel", cf: &ClientConf{Mode: ModeSentinel, Addr: "127.0.0.1:26379,127.0.0.1:26380", MasterName: "mymaster"}},
+		{name: "cluster", cf: &ClientConf{Mode: ModeCluster, Addr: "127.0.0.1:7000,127.0.0.1:7001"}, cluster: true},
+		{name: "standalone without addr", cf: &ClientConf{}, err: true},
+		{name: "standalone with multiple addrs", cf: &ClientConf{Addr: "127.0.0.1:6379,127.0.0.1:6380"}, err: true},
+		{name: "sentinel without master name", cf: &ClientConf{Mode: ModeSentinel, Addr: "127.0.0.1:26379"}, err: true},
+		{name: "sentinel without addrs", cf: &ClientConf{Mode: ModeSentinel, Addr: " , ", MasterName: "mymaster"}, err: true},
+		{name: "cluster without addrs", cf: &ClientConf{Mode: ModeCluster}, err: true},
+		{name: "unsupported mode", cf: &ClientConf{Mode: "replica", Addr: "127.0.0.1:6379"}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ocli, err := newUniversalClient(tt.cf)
+			if tt.err {
+				assert.NotNil(t, err)
+				assert.Nil(t, ocli)
+				return
+			}
+			if !assert.Nil(t, err) {
+				return
+			}
+			defer ocli.Close()
+
+			if tt.cluster {
+				assert.IsType(t, &redis.ClusterClient{}, ocli)
+				return
+			}
+			assert.IsType(t, &redis.Client{}, ocli)
+		})
+	}
+}
+
+func TestContainerReplaceClient(t *testing.T) {
+	mr1, mr2 := miniredis.RunT(t), miniredis.RunT(t)
+
+	var mu sync.Mutex
+	cf := ClientConf{Addr: mr1.Addr()}
+	set := func(f func(cf *ClientConf)) {
+		mu.Lock()
+		defer mu.Unlock()
+		f(&cf)
+	}
+	ct, err := NewContainer(func() (*ClientConf, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		c := cf
+		return &c, nil
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer ct.Close()
+
+	current := func() *Client {
+		cli := ct.MustGetClient()
+		defer ct.PutClient(cli)
+		return cli
+	}
+	first := current()
+	assert.Nil(t, first.Set("k", "1", 0).Err())
+
+	// 地址变化时替换客户端, 命令发往新地址
+	set(func(cf *ClientConf) { cf.Addr = mr2.Addr() })
+	assert.Nil(t, ct.Update())
+	second := current()
+	assert.NotSame(t, first, second)
+	assert.Nil(t, second.Set("k", "2", 0).Err())
+	v, _ := mr1.Get("k")
+	assert.Equal(t, "1", v)
+	v, _ = mr2.Get("k")
+	assert.Equal(t, "2", v)
+
+	// 部署模式变化时替换为对应模式的客户端
+	set(func(cf *ClientConf) { cf.Mode = ModeCluster })
+	assert.Nil(t, ct.Update())
+	third := current()
+	assert.NotSame(t, second, third)
+	assert.IsType(t, &redis.ClusterClient{}, third.UniversalClient)
+	assert.Nil(t, third.Set("k", "3", 0).Err())
+	v, _ = mr2.Get("k")
+	assert.Equal(t, "3", v)
+
+	// 新配置无效时保留当前客户端
+	set(func(cf *ClientConf) { cf.Addr = "" })
+	assert.NotNil(t, ct.Update())
+	assert.Same(t, third, current())
+	assert.Equal(t, uint64(3), ct.Status().Generation)
+}
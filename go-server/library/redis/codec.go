@@ -0,0 +1,58 @@
+package redis
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec 缓存值编解码接口
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	CodecJSON    Codec = jsonCodec{}    // JSON编解码, 默认编解码
+	CodecMsgpack Codec = msgpackCodec{} // msgpack编解码
+	CodecGob     Codec = gobCodec{}     // gob编解码, 接口类型的值需要事先调用gob.Register注册
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v interface{}) (data []byte, err error) {
+	buf := &bytes.Buffer{}
+	if err = gob.NewEncoder(buf).Encode(v); err != nil {
+		return
+	}
+
+	data = buf.Bytes()
+
+	return
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
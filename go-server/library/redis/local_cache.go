@@ -0,0 +1,161 @@
+package redis
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// localCache 进程内有界LRU缓存, 每个条目带有独立的过期时间, 保存的是带标记的缓存数据
+type localCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	ll    *list.List
+	items map[string]*list.Element
+
+	// 每次删除或清空条目时递增, 读取redis前记录的版本与写入时不一致说明期间发生过失效, 此时不写入以免回填旧值
+	epoch uint64
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+type localEntry struct {
+	key    string
+	data   []byte
+	expire time.Time
+}
+
+func newLocalCache(size int, ttl time.Duration) *localCache {
+	return &localCache{
+		size:  size,
+		ttl:   ttl,
+		ll:    list.New(),
+		items: make(map[string]*list.Element, size),
+	}
+}
+
+// get 返回未过期的缓存数据, 命中时将条目移动到队首
+func (lc *localCache) get(key string) (data []byte, ok bool) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	el, ok := lc.items[key]
+	if !ok {
+		lc.misses++
+		return
+	}
+
+	ent := el.Value.(*localEntry)
+	if time.Now().After(ent.expire) {
+		lc.remove(el)
+		lc.misses++
+		ok = false
+		return
+	}
+
+	lc.ll.MoveToFront(el)
+	lc.hits++
+	data = ent.data
+
+	return
+}
+
+// version 返回当前的失效版本, 用于fill判断读取redis期间是否发生过失效
+func (lc *localCache) version() uint64 {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	return lc.epoch
+}
+
+// fill 仅当失效版本仍为epoch时写入从redis读取或加载得到的数据, 避免与之并发的删除被旧值覆盖
+func (lc *localCache) fill(key string, data []byte, ttl time.Duration, epoch uint64) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if lc.epoch != epoch {
+		return
+	}
+
+	lc.setLocked(key, data, ttl)
+}
+
+// set 写入缓存数据, 过期时间取ttl与本地缓存ttl中较小者, 超出容量时淘汰最久未使用的条目
+func (lc *localCache) set(key string, data []byte, ttl time.Duration) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	lc.setLocked(key, data, ttl)
+}
+
+// setLocked 写入缓存数据, 调用方需持有lc.mu
+func (lc *localCache) setLocked(key string, data []byte, ttl time.Duration) {
+	if ttl <= 0 || ttl > lc.ttl {
+		ttl = lc.ttl
+	}
+
+	expire := time.Now().Add(ttl)
+	if el, ok := lc.items[key]; ok {
+		ent := el.Value.(*localEntry)
+		ent.data = data
+		ent.expire = expire
+		lc.ll.MoveToFront(el)
+		return
+	}
+
+	lc.items[key] = lc.ll.PushFront(&localEntry{
+		key:    key,
+		data:   data,
+		expire: expire,
+	})
+
+	for lc.ll.Len() > lc.size {
+		lc.remove(lc.ll.Back())
+		lc.evictions++
+	}
+}
+
+// del 删除指定的缓存条目
+func (lc *localCache) del(keys ...string) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	lc.epoch++
+	for _, key := range keys {
+		if el, ok := lc.items[key]; ok {
+			lc.remove(el)
+		}
+	}
+}
+
+// purge 清空所有缓存条目, 用于失效消息可能丢失的场景
+func (lc *localCache) purge() {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	lc.epoch++
+	lc.ll.Init()
+	lc.items = make(map[string]*list.Element, lc.size)
+}
+
+func (lc *localCache) stats() (st CacheTierStats) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	st = CacheTierStats{
+		Hits:      lc.hits,
+		Misses:    lc.misses,
+		Evictions: lc.evictions,
+		Size:      lc.ll.Len(),
+	}
+
+	return
+}
+
+func (lc *localCache) remove(el *list.Element) {
+	lc.ll.Remove(el)
+	delete(lc.items, el.Value.(*localEntry).key)
+}
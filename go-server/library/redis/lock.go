@@ -0,0 +1,358 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	mrand "math/rand"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+var (
+	ErrLockNotObtained = errors.New("lock not obtained") // 等待超时仍未获取到锁
+	ErrLockNotHeld     = errors.New("lock not held")     // 锁未持有或已被其它持有者获取
+)
+
+const (
+	defaultLockTTL             = 10 * time.Second
+	defaultLockRetryMinBackoff = 10 * time.Millisecond
+	defaultLockRetryMaxBackoff = 500 * time.Millisecond
+)
+
+// acquireScript 原子地执行SET NX PX, 成功时递增并返回栅栏令牌, 失败时返回0
+var acquireScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0
+`)
+
+// releaseScript 仅当锁的值与持有者令牌一致时删除锁
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// refreshScript 仅当锁的值与持有者令牌一致时延长租约
+var refreshScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// LockOptions 分布式锁选项, 零值成员使用默认值
+type LockOptions struct {
+	TTL             time.Duration // 租约时长, 默认10s
+	RetryMinBackoff time.Duration // 等待锁时的最小重试间隔, 默认10ms
+	RetryMaxBackoff time.Duration // 等待锁时的最大重试间隔, 默认500ms
+	AutoRenew       bool          // 持有期间是否每TTL/3自动续约
+}
+
+// Lock 基于SET NX PX实现的分布式互斥锁, 释放和续约通过Lua脚本校验持有者令牌,
+// 每次获取成功都会得到一个单调递增的栅栏令牌, 下游存储可以据此拒绝过期持有者的写入,
+// Lock的每次操作都从ClientContainer获取当前客户端, 因此配置热更新替换客户端后锁仍可正常使用
+type Lock struct {
+	ct       *ClientContainer
+	key      string
+	fenceKey string
+	opts     LockOptions
+
+	mu        sync.Mutex
+	token     string
+	fence     int64
+	lost      chan struct{}
+	stopRenew func()
+}
+
+// NewLock 创建名称为name的分布式锁, 锁在redis中的键为lock:{name}, 栅栏计数器的键为lock:{name}:fence,
+// 使用hash tag保证两个键在集群模式下位于同一slot, opts为nil时使用默认选项
+func (ct *ClientContainer) NewLock(name string, opts *LockOptions) *Lock {
+	l := &Lock{
+		ct:       ct,
+		key:      "lock:{" + name + "}",
+		fenceKey: "lock:{" + name + "}:fence",
+	}
+
+	if opts != nil {
+		l.opts = *opts
+	}
+	if l.opts.TTL <= 0 {
+		l.opts.TTL = defaultLockTTL
+	}
+	if l.opts.RetryMinBackoff <= 0 {
+		l.opts.RetryMinBackoff = defaultLockRetryMinBackoff
+	}
+	if l.opts.RetryMaxBackoff <= 0 {
+		l.opts.RetryMaxBackoff = defaultLockRetryMaxBackoff
+	}
+	if l.opts.RetryMaxBackoff < l.opts.RetryMinBackoff {
+		l.opts.RetryMaxBackoff = l.opts.RetryMinBackoff
+	}
+
+	return l
+}
+
+// TryLock 尝试获取一次锁, 锁已被其它持有者获取时返回false和nil错误,
+// ctx在获取完成前结束时返回ctx的错误, 若获取脚本在此之后仍执行成功, 获取到的锁将被立即释放
+func (l *Lock) TryLock(ctx context.Context) (ok bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.token != "" {
+		err = fmt.Errorf("lock %s: already held", l.key)
+		return
+	}
+
+	token, err := newToken()
+	if err != nil {
+		err = fmt.Errorf("new lock token: %w", err)
+		return
+	}
+
+	fence, err := l.acquire(ctx, token)
+	if err != nil {
+		return
+	}
+
+	if fence == 0 {
+		return
+	}
+
+	l.token = token
+	l.fence = fence
+	l.lost = make(chan struct{})
+
+	if l.opts.AutoRenew {
+		l.startRenew(token, l.lost)
+	}
+
+	ok = true
+
+	return
+}
+
+// acquire 以token执行获取脚本, 成功时返回栅栏令牌, 锁已被其它持有者获取时返回0,
+// ctx先结束时不再等待脚本结果, 并在脚本随后获取成功时释放该锁, 避免锁在TTL内无人持有却无法获取
+func (l *Lock) acquire(ctx context.Context, token string) (fence int64, err error) {
+	type result struct {
+		fence int64
+		err   error
+	}
+
+	rc := make(chan result, 1)
+	go func() {
+		fence, err := l.ct.runScript(ctx, acquireScript, []string{l.key, l.fenceKey}, token, l.opts.TTL.Milliseconds()).Int64()
+		rc <- result{fence: fence, err: err}
+	}()
+
+	select {
+	case r := <-rc:
+		if fence, err = r.fence, r.err; err != nil {
+			err = fmt.Errorf("acquire script: %w", err)
+		}
+	case <-ctx.Done():
+		err = ctx.Err()
+		go func() {
+			if r := <-rc; r.err == nil && r.fence != 0 {
+				_ = l.ct.runScript(context.Background(), releaseScript, []string{l.key}, token).Err()
+			}
+		}()
+	}
+
+	return
+}
+
+// Lock 在ctx结束前以指数退避加随机抖动的间隔重试获取锁, ctx在等待或获取过程中结束时返回包装了ctx错误的ErrLockNotObtained
+func (l *Lock) Lock(ctx context.Context) (err error) {
+	backoff := l.opts.RetryMinBackoff
+
+	for {
+		ok, err := l.TryLock(ctx)
+		if err != nil {
+			if cerr := ctx.Err(); cerr != nil {
+				return fmt.Errorf("%w: %v", ErrLockNotObtained, cerr)
+			}
+			return err
+		}
+		if ok {
+			return nil
+		}
+
+		timer := time.NewTimer(jitter(backoff))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w: %v", ErrLockNotObtained, ctx.Err())
+		case <-timer.C:
+		}
+
+		if backoff *= 2; backoff > l.opts.RetryMaxBackoff {
+			backoff = l.opts.RetryMaxBackoff
+		}
+	}
+}
+
+// Unlock 释放持有的锁并停止自动续约, 锁已过期或被其它持有者获取时返回ErrLockNotHeld
+func (l *Lock) Unlock() (err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.token == "" {
+		err = ErrLockNotHeld
+		return
+	}
+
+	token := l.token
+	l.reset()
+
+	n, err := l.ct.runScript(context.Background(), releaseScript, []string{l.key}, token).Int64()
+	if err != nil {
+		err = fmt.Errorf("release script: %w", err)
+		return
+	}
+
+	if n == 0 {
+		err = ErrLockNotHeld
+		return
+	}
+
+	return
+}
+
+// Refresh 将持有锁的租约重置为TTL, 锁已过期或被其它持有者获取时返回ErrLockNotHeld
+func (l *Lock) Refresh() (err error) {
+	l.mu.Lock()
+	token := l.token
+	l.mu.Unlock()
+
+	if token == "" {
+		err = ErrLockNotHeld
+		return
+	}
+
+	return l.refresh(token)
+}
+
+// Fence 返回本次持有锁时获得的栅栏令牌, 未持有锁时返回0
+func (l *Lock) Fence() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.fence
+}
+
+// Lost 返回一个在自动续约确认锁已丢失时关闭的信道, 未持有锁时返回nil,
+// 持有者应在该信道关闭后停止受保护的操作
+func (l *Lock) Lost() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.lost
+}
+
+func (l *Lock) refresh(token string) (err error) {
+	n, err := l.ct.runScript(context.Background(), refreshScript, []string{l.key}, token, l.opts.TTL.Milliseconds()).Int64()
+	if err != nil {
+		err = fmt.Errorf("refresh script: %w", err)
+		return
+	}
+
+	if n == 0 {
+		err = ErrLockNotHeld
+		return
+	}
+
+	return
+}
+
+// startRenew 启动自动续约协程, 每TTL/3续约一次, 确认锁已被他人获取或连续续约失败超过TTL时关闭lost
+func (l *Lock) startRenew(token string, lost chan struct{}) {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(l.opts.TTL / 3)
+		defer ticker.Stop()
+
+		renewed := time.Now()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			err := l.refresh(token)
+			if err == nil {
+				renewed = time.Now()
+				continue
+			}
+
+			if errors.Is(err, ErrLockNotHeld) || time.Since(renewed) >= l.opts.TTL {
+				close(lost)
+				return
+			}
+		}
+	}()
+
+	l.stopRenew = func() {
+		close(stop)
+		<-done
+	}
+}
+
+// reset 停止自动续约并清理持有状态, 调用方需持有l.mu
+func (l *Lock) reset() {
+	if l.stopRenew != nil {
+		l.stopRenew()
+		l.stopRenew = nil
+	}
+
+	l.token = ""
+	l.fence = 0
+	l.lost = nil
+}
+
+// runScript 使用容器当前的客户端以ctx执行Lua脚本
+func (ct *ClientContainer) runScript(ctx context.Context, script *redis.Script, keys []string, args ...interface{}) *redis.Cmd {
+	cli := ct.MustGetClient()
+	defer ct.PutClient(cli)
+
+	return script.Run(cli.WithContext(ctx), keys, args...)
+}
+
+func newToken() (token string, err error) {
+	b := make([]byte, 16)
+	if _, err = rand.Read(b); err != nil {
+		return
+	}
+
+	token = hex.EncodeToString(b)
+
+	return
+}
+
+// jitter 返回[d/2, d)范围内的随机时长
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+
+	half := d / 2
+
+	return half + time.Duration(mrand.Int63n(int64(half)))
+}
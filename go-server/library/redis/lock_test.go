@@ -0,0 +1,131 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockExpiry(t *testing.T) {
+	ct, mr := newTestContainer(t)
+
+	a := ct.NewLock("job", &LockOptions{TTL: time.Second})
+	b := ct.NewLock("job", &LockOptions{TTL: time.Second})
+
+	ok, err := a.TryLock(context.Background())
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), a.Fence())
+
+	ok, err = b.TryLock(context.Background())
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	// 租约到期后其它持有者可以获取, 并得到更大的栅栏令牌
+	mr.FastForward(time.Second)
+
+	ok, err = b.TryLock(context.Background())
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), b.Fence())
+
+	// 过期的持有者不能释放他人的锁
+	assert.True(t, errors.Is(a.Unlock(), ErrLockNotHeld))
+	assert.True(t, mr.Exists("lock:{job}"))
+	assert.True(t, errors.Is(a.Refresh(), ErrLockNotHeld))
+
+	assert.Nil(t, b.Unlock())
+	assert.False(t, mr.Exists("lock:{job}"))
+}
+
+func TestLockRenew(t *testing.T) {
+	ct, mr := newTestContainer(t)
+
+	ttl := 300 * time.Millisecond
+	l := ct.NewLock("job", &LockOptions{TTL: ttl, AutoRenew: true})
+
+	ok, err := l.TryLock(context.Background())
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	// 消耗部分租约后等待自动续约将其重置为TTL
+	mr.FastForward(ttl * 2 / 3)
+	assert.Eventually(t, func() bool {
+		return mr.TTL("lock:{job}") == ttl
+	}, time.Second, 10*time.Millisecond)
+
+	mr.FastForward(ttl * 2 / 3)
+	assert.True(t, mr.Exists("lock:{job}"))
+
+	select {
+	case <-l.Lost():
+		t.Fatal("lock lost while renewing")
+	default:
+	}
+
+	assert.Nil(t, l.Unlock())
+	assert.Nil(t, l.Lost())
+}
+
+func TestLockLost(t *testing.T) {
+	ct, mr := newTestContainer(t)
+
+	l := ct.NewLock("job", &LockOptions{TTL: 150 * time.Millisecond, AutoRenew: true})
+
+	ok, err := l.TryLock(context.Background())
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	lost := l.Lost()
+	mr.Del("lock:{job}")
+
+	select {
+	case <-lost:
+	case <-time.After(time.Second):
+		t.Fatal("lost not closed after lock deleted")
+	}
+
+	assert.True(t, errors.Is(l.Unlock(), ErrLockNotHeld))
+}
+
+func TestLockContext(t *testing.T) {
+	ct, _ := newTestContainer(t)
+
+	a := ct.NewLock("job", nil)
+	b := ct.NewLock("job", nil)
+
+	assert.Nil(t, a.Lock(context.Background()))
+
+	// 等待期间ctx超时
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := b.Lock(ctx)
+	assert.True(t, errors.Is(err, ErrLockNotObtained))
+	assert.Less(t, time.Since(start), time.Second)
+
+	// ctx已结束时不再尝试获取
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	ok, err := b.TryLock(ctx)
+	assert.False(t, ok)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.True(t, errors.Is(b.Lock(ctx), ErrLockNotObtained))
+
+	// 锁释放后可以在ctx结束前获取
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = a.Unlock()
+	}()
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	assert.Nil(t, b.Lock(ctx))
+	assert.Nil(t, b.Unlock())
+}
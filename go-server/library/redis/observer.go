@@ -0,0 +1,74 @@
+package redis
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// CommandPipeline 管道和事务管道中的命令整体观测时使用的命令名称
+const CommandPipeline = "pipeline"
+
+// CommandObserver 观测redis命令的耗时和结果, 用于统计, 实现需并发安全,
+// 键不存在返回的redis.Nil不视为错误, 观测时err为nil
+type CommandObserver interface {
+	ObserveCommand(cmd string, elapsed time.Duration, err error)
+}
+
+// observerHolder 使atomic.Value中存储的类型保持一致
+type observerHolder struct {
+	obs CommandObserver
+}
+
+// SetObserver 设置命令观测者, 为nil时不再观测
+func (cli *Client) SetObserver(obs CommandObserver) {
+	cli.observer.Store(observerHolder{obs: obs})
+}
+
+func (cli *Client) loadObserver() CommandObserver {
+	holder, _ := cli.observer.Load().(observerHolder)
+	return holder.obs
+}
+
+// wrapProcess 包装底层客户端的命令处理函数, 在设置了观测者时记录命令耗时,
+// 包装在客户端创建时完成, 对WithContext返回的客户端同样生效
+func (cli *Client) wrapProcess() {
+	cli.UniversalClient.WrapProcess(func(old func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+		return func(cmd redis.Cmder) (err error) {
+			obs := cli.loadObserver()
+			if obs == nil {
+				return old(cmd)
+			}
+
+			start := time.Now()
+			err = old(cmd)
+			obs.ObserveCommand(strings.ToLower(cmd.Name()), time.Since(start), ignoreNil(err))
+
+			return
+		}
+	})
+
+	cli.UniversalClient.WrapProcessPipeline(func(old func([]redis.Cmder) error) func([]redis.Cmder) error {
+		return func(cmds []redis.Cmder) (err error) {
+			obs := cli.loadObserver()
+			if obs == nil {
+				return old(cmds)
+			}
+
+			start := time.Now()
+			err = old(cmds)
+			obs.ObserveCommand(CommandPipeline, time.Since(start), ignoreNil(err))
+
+			return
+		}
+	})
+}
+
+func ignoreNil(err error) error {
+	if err == redis.Nil {
+		return nil
+	}
+
+	return err
+}
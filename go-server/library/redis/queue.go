@@ -0,0 +1,633 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+var (
+	ErrQueueStarted = errors.New("queue already started") // 队列已经启动消费
+	ErrQueueClosed  = errors.New("queue closed")          // 队列已经停止
+)
+
+const (
+	defaultQueueGroup             = "default"
+	defaultQueueWorkers           = 1
+	defaultQueueVisibilityTimeout = 30 * time.Second
+	defaultQueueMaxAttempts       = 3
+	defaultQueueRetryBackoff      = time.Second
+	defaultQueuePollInterval      = time.Second
+	defaultQueueDeadMaxLen        = 10000
+
+	// queuePromoteBatch 每次从延迟集合转移到流的最大任务数
+	queuePromoteBatch = 100
+
+	queueFieldJob = "job"
+)
+
+// queueAckScript 确认并删除流中的任务
+var queueAckScript = redis.NewScript(`
+redis.call("XACK", KEYS[1], ARGV[1], ARGV[2])
+redis.call("XDEL", KEYS[1], ARGV[2])
+return 1
+`)
+
+// queueRetryScript 将任务放入延迟集合等待重试, 并确认删除流中的原任务
+var queueRetryScript = redis.NewScript(`
+redis.call("ZADD", KEYS[2], ARGV[3], ARGV[4])
+redis.call("XACK", KEYS[1], ARGV[1], ARGV[2])
+redis.call("XDEL", KEYS[1], ARGV[2])
+return 1
+`)
+
+// queueDeadScript 将任务写入死信流, 并确认删除流中的原任务
+var queueDeadScript = redis.NewScript(`
+redis.call("XADD", KEYS[2], "MAXLEN", "~", ARGV[7], "*", "job", ARGV[3], "error", ARGV[4], "failed_at", ARGV[5], "id", ARGV[6])
+redis.call("XACK", KEYS[1], ARGV[1], ARGV[2])
+redis.call("XDEL", KEYS[1], ARGV[2])
+return 1
+`)
+
+// queuePromoteScript 将已到期的延迟任务转移到流中, 返回转移的任务数
+var queuePromoteScript = redis.NewScript(`
+local jobs = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+for _, job in ipairs(jobs) do
+	redis.call("XADD", KEYS[2], "*", "job", job)
+	redis.call("ZREM", KEYS[1], job)
+end
+return #jobs
+`)
+
+// QueueOptions 任务队列选项, 零值成员使用默认值
+type QueueOptions struct {
+	Group             string        // 消费组名称, 默认default
+	Consumer          string        // 消费者名称, 默认为主机名加随机后缀, 同一消费组内的消费者名称不能重复
+	Workers           int           // 并发处理任务的协程数, 默认1, 每个协程阻塞读取时占用一个连接, 连接池大小应大于该值
+	VisibilityTimeout time.Duration // 任务处理超时, 超时未确认的任务将被其它消费者重新认领, 默认30s
+	MaxAttempts       int           // 任务最大尝试次数, 超过后写入死信流, 默认3
+	RetryBackoff      time.Duration // 首次重试的延迟, 之后每次重试延迟加倍, 默认1s
+	PollInterval      time.Duration // 阻塞读取任务的最长时间, 以及转移延迟任务和认领超时任务的间隔, 默认1s
+	DeadMaxLen        int64         // 死信流的近似最大长度, 默认10000
+	HandleErr         func(error)   // 处理消费过程中的redis错误, 可以为nil
+}
+
+// Job 队列中的任务
+type Job struct {
+	ID         string    // 任务在流中的消息ID, 重试时会变化
+	Payload    []byte    // 任务内容
+	Attempt    int       // 本次是第几次尝试, 从1开始
+	EnqueuedAt time.Time // 首次入队时间
+}
+
+// JobHandler 任务处理函数, 返回nil时确认任务, 返回错误或panic时按重试策略重试或写入死信流,
+// ctx在VisibilityTimeout后超时, 超时后任务可能已被其它消费者认领
+type JobHandler func(ctx context.Context, job *Job) error
+
+// queueJob 任务在流和延迟集合中的编码格式
+type queueJob struct {
+	Nonce      string `json:"nonce"` // 保证延迟集合中内容相同的任务互不覆盖
+	Payload    []byte `json:"payload"`
+	Retries    int    `json:"retries"` // 此前失败后重新入队的次数
+	EnqueuedAt int64  `json:"enqueued_at"`
+}
+
+// Queue 基于redis Streams的任务队列, 任务保存在流queue:{name}中, 延迟和待重试的任务保存在有序集合queue:{name}:delayed中,
+// 超过最大尝试次数的任务写入死信流queue:{name}:dead, 所有键使用hash tag保证在集群模式下位于同一slot,
+// 任务确认后从流中删除, 因此一个队列只应由一个消费组消费, Queue实现了io.Closer, 可以注册到clean中优雅停止
+type Queue struct {
+	ct         *ClientContainer
+	streamKey  string
+	delayedKey string
+	deadKey    string
+	opts       QueueOptions
+
+	mu        sync.Mutex
+	started   bool
+	closed    bool
+	stop      chan struct{}
+	reclaimed chan redis.XMessage
+	wg        sync.WaitGroup
+
+	// XAUTOCLAIM的扫描游标, 仅由maintain协程读写
+	claimCursor string
+}
+
+// NewQueue 创建名称为name的任务队列, opts为nil时使用默认选项
+func (ct *ClientContainer) NewQueue(name string, opts *QueueOptions) (q *Queue, err error) {
+	q = &Queue{
+		ct:         ct,
+		streamKey:  "queue:{" + name + "}",
+		delayedKey: "queue:{" + name + "}:delayed",
+		deadKey:    "queue:{" + name + "}:dead",
+		stop:       make(chan struct{}),
+		reclaimed:  make(chan redis.XMessage),
+
+		claimCursor: "0-0",
+	}
+
+	if opts != nil {
+		q.opts = *opts
+	}
+	if q.opts.Group == "" {
+		q.opts.Group = defaultQueueGroup
+	}
+	if q.opts.Consumer == "" {
+		if q.opts.Consumer, err = newConsumerName(); err != nil {
+			err = fmt.Errorf("new consumer name: %w", err)
+			return
+		}
+	}
+	if q.opts.Workers <= 0 {
+		q.opts.Workers = defaultQueueWorkers
+	}
+	if q.opts.VisibilityTimeout <= 0 {
+		q.opts.VisibilityTimeout = defaultQueueVisibilityTimeout
+	}
+	if q.opts.MaxAttempts <= 0 {
+		q.opts.MaxAttempts = defaultQueueMaxAttempts
+	}
+	if q.opts.RetryBackoff <= 0 {
+		q.opts.RetryBackoff = defaultQueueRetryBackoff
+	}
+	if q.opts.PollInterval <= 0 {
+		q.opts.PollInterval = defaultQueuePollInterval
+	}
+	if q.opts.DeadMaxLen <= 0 {
+		q.opts.DeadMaxLen = defaultQueueDeadMaxLen
+	}
+
+	return
+}
+
+// Enqueue 将任务立即放入队列, 返回任务在流中的消息ID
+func (q *Queue) Enqueue(ctx context.Context, payload []byte) (id string, err error) {
+	data, err := encodeQueueJob(payload, 0, time.Now())
+	if err != nil {
+		err = fmt.Errorf("encode job: %w", err)
+		return
+	}
+
+	err = q.ct.withClient(ctx, func(cli redis.Cmdable) (err error) {
+		id, err = cli.XAdd(&redis.XAddArgs{
+			Stream: q.streamKey,
+			Values: map[string]interface{}{queueFieldJob: data},
+		}).Result()
+		return
+	})
+	if err != nil {
+		err = fmt.Errorf("xadd %s: %w", q.streamKey, err)
+		return
+	}
+
+	return
+}
+
+// EnqueueDelay 将任务放入队列, 任务在delay后才能被消费, delay不大于0时等同于Enqueue,
+// 延迟任务在到期后由正在消费的Queue转移到流中, 因此实际执行时间可能晚于到期时间一个PollInterval
+func (q *Queue) EnqueueDelay(ctx context.Context, payload []byte, delay time.Duration) (err error) {
+	if delay <= 0 {
+		_, err = q.Enqueue(ctx, payload)
+		return
+	}
+
+	now := time.Now()
+	data, err := encodeQueueJob(payload, 0, now)
+	if err != nil {
+		err = fmt.Errorf("encode job: %w", err)
+		return
+	}
+
+	err = q.ct.withClient(ctx, func(cli redis.Cmdable) error {
+		return cli.ZAdd(q.delayedKey, redis.Z{
+			Score:  float64(msec(now.Add(delay))),
+			Member: data,
+		}).Err()
+	})
+	if err != nil {
+		err = fmt.Errorf("zadd %s: %w", q.delayedKey, err)
+		return
+	}
+
+	return
+}
+
+// Start 创建消费组并启动Workers个协程以handler处理任务, 每个Queue只能启动一次
+func (q *Queue) Start(handler JobHandler) (err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		err = ErrQueueClosed
+		return
+	}
+	if q.started {
+		err = ErrQueueStarted
+		return
+	}
+
+	if err = q.createGroup(); err != nil {
+		err = fmt.Errorf("create group: %w", err)
+		return
+	}
+
+	q.started = true
+
+	q.wg.Add(q.opts.Workers + 1)
+	for i := 0; i < q.opts.Workers; i++ {
+		go func() {
+			defer q.wg.Done()
+			q.work(handler)
+		}()
+	}
+	go func() {
+		defer q.wg.Done()
+		q.maintain()
+	}()
+
+	return
+}
+
+// Close 停止读取新任务, 并等待处理中的任务完成, 未确认的任务将在VisibilityTimeout后被其它消费者认领
+func (q *Queue) Close() (err error) {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	close(q.stop)
+	q.mu.Unlock()
+
+	q.wg.Wait()
+
+	return
+}
+
+// work 优先处理被认领的超时任务, 否则阻塞读取新任务
+func (q *Queue) work(handler JobHandler) {
+	for {
+		select {
+		case <-q.stop:
+			return
+		case msg := <-q.reclaimed:
+			q.process(handler, msg, 0)
+			continue
+		default:
+		}
+
+		msgs, err := q.read()
+		if err != nil {
+			q.handleErr(fmt.Errorf("read %s: %w", q.streamKey, err))
+			if !q.sleep(q.opts.PollInterval) {
+				return
+			}
+			continue
+		}
+
+		for _, msg := range msgs {
+			q.process(handler, msg, 1)
+		}
+	}
+}
+
+func (q *Queue) read() (msgs []redis.XMessage, err error) {
+	cli := q.ct.MustGetClient()
+	defer q.ct.PutClient(cli)
+
+	streams, err := cli.XReadGroup(&redis.XReadGroupArgs{
+		Group:    q.opts.Group,
+		Consumer: q.opts.Consumer,
+		Streams:  []string{q.streamKey, ">"},
+		Count:    1,
+		Block:    q.opts.PollInterval,
+	}).Result()
+	if err == redis.Nil {
+		err = nil
+		return
+	}
+	if err != nil {
+		// 配置热更新切换到新的redis实例后消费组可能不存在
+		if strings.HasPrefix(err.Error(), "NOGROUP") {
+			if gerr := q.createGroup(); gerr != nil {
+				err = fmt.Errorf("%v, create group: %w", err, gerr)
+			}
+		}
+		return
+	}
+
+	for _, stream := range streams {
+		msgs = append(msgs, stream.Messages...)
+	}
+
+	return
+}
+
+// process 处理一条任务消息, deliveries为该消息的投递次数, 为0时从待确认列表查询
+func (q *Queue) process(handler JobHandler, msg redis.XMessage, deliveries int64) {
+	data, _ := msg.Values[queueFieldJob].(string)
+	qj := &queueJob{}
+	if err := json.Unmarshal([]byte(data), qj); err != nil {
+		q.dead(msg.ID, data, fmt.Errorf("decode job: %w", err))
+		return
+	}
+
+	if deliveries <= 0 {
+		var err error
+		if deliveries, err = q.deliveries(msg.ID); err != nil {
+			q.handleErr(fmt.Errorf("query deliveries of %s: %w", msg.ID, err))
+			return
+		}
+	}
+
+	job := &Job{
+		ID:         msg.ID,
+		Payload:    qj.Payload,
+		Attempt:    qj.Retries + int(deliveries),
+		EnqueuedAt: time.Unix(0, qj.EnqueuedAt*int64(time.Millisecond)),
+	}
+
+	// 多次处理超时未确认的任务, 通常是处理过程中进程崩溃
+	if job.Attempt > q.opts.MaxAttempts {
+		q.dead(msg.ID, data, fmt.Errorf("visibility timeout exceeded %d times", deliveries))
+		return
+	}
+
+	err := q.call(handler, job)
+	if err == nil {
+		if err = q.runScript(queueAckScript, []string{q.streamKey}, q.opts.Group, msg.ID); err != nil {
+			q.handleErr(fmt.Errorf("ack %s: %w", msg.ID, err))
+		}
+		return
+	}
+
+	if job.Attempt >= q.opts.MaxAttempts {
+		q.dead(msg.ID, data, err)
+		return
+	}
+
+	q.retry(msg.ID, qj, job.Attempt)
+}
+
+// call 调用handler, 将panic转换为错误
+func (q *Queue) call(handler JobHandler, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), q.opts.VisibilityTimeout)
+	defer cancel()
+
+	return handler(ctx, job)
+}
+
+// retry 将失败的任务放入延迟集合, 第n次尝试失败后的延迟为RetryBackoff*2^(n-1)
+func (q *Queue) retry(id string, qj *queueJob, attempt int) {
+	data, err := encodeQueueJob(qj.Payload, attempt, time.Unix(0, qj.EnqueuedAt*int64(time.Millisecond)))
+	if err != nil {
+		q.handleErr(fmt.Errorf("encode job %s: %w", id, err))
+		return
+	}
+
+	delay := q.opts.RetryBackoff << uint(attempt-1)
+	err = q.runScript(queueRetryScript, []string{q.streamKey, q.delayedKey},
+		q.opts.Group, id, msec(time.Now().Add(delay)), data)
+	if err != nil {
+		q.handleErr(fmt.Errorf("retry %s: %w", id, err))
+	}
+}
+
+// dead 将任务写入死信流
+func (q *Queue) dead(id, data string, cause error) {
+	err := q.runScript(queueDeadScript, []string{q.streamKey, q.deadKey},
+		q.opts.Group, id, data, cause.Error(), msec(time.Now()), id, q.opts.DeadMaxLen)
+	if err != nil {
+		q.handleErr(fmt.Errorf("dead letter %s: %w", id, err))
+	}
+}
+
+// maintain 每PollInterval转移到期的延迟任务, 并认领超时未确认的任务交给工作协程处理
+func (q *Queue) maintain() {
+	ticker := time.NewTicker(q.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-ticker.C:
+		}
+
+		if err := q.promote(); err != nil {
+			q.handleErr(fmt.Errorf("promote %s: %w", q.delayedKey, err))
+		}
+
+		msgs, err := q.autoClaim()
+		if err != nil {
+			q.handleErr(fmt.Errorf("autoclaim %s: %w", q.streamKey, err))
+			continue
+		}
+
+		for _, msg := range msgs {
+			select {
+			case <-q.stop:
+				return
+			case q.reclaimed <- msg:
+			}
+		}
+	}
+}
+
+func (q *Queue) promote() (err error) {
+	var n int64
+	for {
+		cli := q.ct.MustGetClient()
+		n, err = queuePromoteScript.Run(cli, []string{q.delayedKey, q.streamKey}, msec(time.Now()), queuePromoteBatch).Int64()
+		q.ct.PutClient(cli)
+		if err != nil || n < queuePromoteBatch {
+			return
+		}
+	}
+}
+
+// autoClaim 通过XAUTOCLAIM从上次返回的游标处继续认领空闲超过VisibilityTimeout的任务, 每次最多扫描Workers个,
+// 扫描到待确认列表末尾时游标回到0-0重新开始, 已从流中删除但仍在待确认列表中的消息直接确认
+func (q *Queue) autoClaim() (msgs []redis.XMessage, err error) {
+	cli := q.ct.MustGetClient()
+	defer q.ct.PutClient(cli)
+
+	cmd := redis.NewSliceCmd("xautoclaim", q.streamKey, q.opts.Group, q.opts.Consumer,
+		q.opts.VisibilityTimeout.Milliseconds(), q.claimCursor, "count", q.opts.Workers)
+	if err = cli.Process(cmd); err != nil {
+		return
+	}
+
+	vals := cmd.Val()
+	if len(vals) < 2 {
+		err = fmt.Errorf("unexpected xautoclaim reply length %d", len(vals))
+		return
+	}
+
+	cursor, ok := vals[0].(string)
+	if !ok {
+		err = fmt.Errorf("unexpected xautoclaim cursor %T", vals[0])
+		return
+	}
+	q.claimCursor = cursor
+
+	entries, _ := vals[1].([]interface{})
+	for _, entry := range entries {
+		msg, ok := parseXMessage(entry)
+		if !ok {
+			continue
+		}
+		if msg.Values == nil {
+			if err = cli.XAck(q.streamKey, q.opts.Group, msg.ID).Err(); err != nil {
+				return
+			}
+			continue
+		}
+		msgs = append(msgs, msg)
+	}
+
+	return
+}
+
+// deliveries 查询消息的投递次数
+func (q *Queue) deliveries(id string) (n int64, err error) {
+	cli := q.ct.MustGetClient()
+	defer q.ct.PutClient(cli)
+
+	pending, err := cli.XPendingExt(&redis.XPendingExtArgs{
+		Stream: q.streamKey,
+		Group:  q.opts.Group,
+		Start:  id,
+		End:    id,
+		Count:  1,
+	}).Result()
+	if err != nil {
+		return
+	}
+
+	if len(pending) == 0 {
+		err = fmt.Errorf("message not pending")
+		return
+	}
+
+	n = pending[0].RetryCount
+
+	return
+}
+
+func (q *Queue) createGroup() (err error) {
+	cli := q.ct.MustGetClient()
+	defer q.ct.PutClient(cli)
+
+	err = cli.XGroupCreateMkStream(q.streamKey, q.opts.Group, "0").Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		err = nil
+	}
+
+	return
+}
+
+func (q *Queue) runScript(script *redis.Script, keys []string, args ...interface{}) error {
+	return q.ct.runScript(context.Background(), script, keys, args...).Err()
+}
+
+func (q *Queue) handleErr(err error) {
+	if q.opts.HandleErr != nil {
+		q.opts.HandleErr(err)
+	}
+}
+
+// sleep 等待d, 期间队列停止时返回false
+func (q *Queue) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-q.stop:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func encodeQueueJob(payload []byte, retries int, enqueuedAt time.Time) (data string, err error) {
+	nonce, err := newToken()
+	if err != nil {
+		err = fmt.Errorf("new nonce: %w", err)
+		return
+	}
+
+	b, err := json.Marshal(&queueJob{
+		Nonce:      nonce,
+		Payload:    payload,
+		Retries:    retries,
+		EnqueuedAt: msec(enqueuedAt),
+	})
+	if err != nil {
+		return
+	}
+
+	data = string(b)
+
+	return
+}
+
+// parseXMessage 解析XAUTOCLAIM返回的消息, 消息已被删除时Values为nil
+func parseXMessage(entry interface{}) (msg redis.XMessage, ok bool) {
+	fields, ok := entry.([]interface{})
+	if !ok || len(fields) < 2 {
+		ok = false
+		return
+	}
+
+	if msg.ID, ok = fields[0].(string); !ok {
+		return
+	}
+
+	kvs, _ := fields[1].([]interface{})
+	if len(kvs) == 0 {
+		return
+	}
+
+	msg.Values = make(map[string]interface{}, len(kvs)/2)
+	for i := 0; i+1 < len(kvs); i += 2 {
+		if k, isStr := kvs[i].(string); isStr {
+			msg.Values[k] = kvs[i+1]
+		}
+	}
+
+	return
+}
+
+func newConsumerName() (name string, err error) {
+	host, err := os.Hostname()
+	if err != nil {
+		return
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return
+	}
+
+	name = host + "-" + token[:8]
+
+	return
+}
+
+func msec(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
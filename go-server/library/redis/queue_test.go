@@ -0,0 +1,129 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueue(t *testing.T) {
+	ct, mr := newTestContainer(t)
+
+	q, err := ct.NewQueue("jobs", &QueueOptions{
+		Workers:           2,
+		PollInterval:      50 * time.Millisecond,
+		RetryBackoff:      20 * time.Millisecond,
+		VisibilityTimeout: 200 * time.Millisecond,
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	ctx := context.Background()
+	_, err = q.Enqueue(ctx, []byte("ok"))
+	assert.Nil(t, err)
+	_, err = q.Enqueue(ctx, []byte("fail"))
+	assert.Nil(t, err)
+	assert.Nil(t, q.EnqueueDelay(ctx, []byte("delayed"), 150*time.Millisecond))
+
+	var (
+		mu   sync.Mutex
+		seen = map[string][]int{}
+	)
+	start := time.Now()
+	var delayedAt time.Duration
+	err = q.Start(func(ctx context.Context, job *Job) error {
+		mu.Lock()
+		seen[string(job.Payload)] = append(seen[string(job.Payload)], job.Attempt)
+		if string(job.Payload) == "delayed" {
+			delayedAt = time.Since(start)
+		}
+		mu.Unlock()
+
+		switch string(job.Payload) {
+		case "fail":
+			return errors.New("boom")
+		case "panic":
+			panic("boom")
+		}
+		return nil
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	_, err = q.Enqueue(ctx, []byte("panic"))
+	assert.Nil(t, err)
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		dead, _ := mr.Stream("queue:{jobs}:dead")
+		return len(dead) == 2 && len(seen["delayed"]) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Nil(t, q.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []int{1}, seen["ok"])
+	assert.Equal(t, []int{1, 2, 3}, seen["fail"])
+	assert.Equal(t, []int{1, 2, 3}, seen["panic"])
+	assert.Equal(t, []int{1}, seen["delayed"])
+	assert.True(t, delayedAt >= 150*time.Millisecond)
+
+	stream, _ := mr.Stream("queue:{jobs}")
+	assert.Len(t, stream, 0)
+}
+
+func TestQueueAutoClaimCursor(t *testing.T) {
+	ct, _ := newTestContainer(t)
+
+	q, err := ct.NewQueue("jobs", &QueueOptions{Consumer: "self", VisibilityTimeout: time.Millisecond})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Nil(t, q.createGroup())
+
+	var ids []string
+	for _, payload := range []string{"a", "b", "c"} {
+		id, err := q.Enqueue(context.Background(), []byte(payload))
+		assert.Nil(t, err)
+		ids = append(ids, id)
+	}
+
+	// 其它消费者读取后未确认
+	cli := ct.MustGetClient()
+	_, err = cli.XReadGroup(&redis.XReadGroupArgs{
+		Group:    "default",
+		Consumer: "crashed",
+		Streams:  []string{"queue:{jobs}", ">"},
+		Count:    3,
+	}).Result()
+	ct.PutClient(cli)
+	assert.Nil(t, err)
+	time.Sleep(5 * time.Millisecond)
+
+	// 每次从上次的游标处继续认领, 而不是重复扫描待确认列表的开头
+	var claimed []string
+	for range ids {
+		msgs, err := q.autoClaim()
+		assert.Nil(t, err)
+		if assert.Len(t, msgs, 1) {
+			claimed = append(claimed, msgs[0].ID)
+		}
+	}
+	assert.Equal(t, ids, claimed)
+	assert.Equal(t, "0-0", q.claimCursor)
+
+	// 扫描到末尾后从头开始
+	time.Sleep(5 * time.Millisecond)
+	msgs, err := q.autoClaim()
+	assert.Nil(t, err)
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, ids[0], msgs[0].ID)
+	}
+}
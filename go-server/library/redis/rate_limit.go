@@ -0,0 +1,260 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	LimitSlidingWindow = "sliding_window" // 滑动窗口限流, 任意Period时长内最多允许Rate次请求
+	LimitTokenBucket   = "token_bucket"   // 令牌桶限流, 每Period补充Rate个令牌, 桶容量为Burst
+)
+
+// slidingWindowScript 以有序集合记录窗口内的请求时间, 返回{是否允许, 剩余次数, 窗口重置毫秒数}
+var slidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local count = redis.call("ZCARD", KEYS[1])
+local allowed = 0
+if count < limit then
+	redis.call("ZADD", KEYS[1], now, ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+redis.call("PEXPIRE", KEYS[1], window)
+local reset = window
+local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, limit - count, reset}
+`)
+
+// tokenBucketScript 以哈希记录桶内令牌数和上次补充时间, 返回{是否允许, 剩余令牌数, 桶补满毫秒数, 重试等待毫秒数}
+var tokenBucketScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local rate = tonumber(ARGV[3])
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+redis.call("HMSET", KEYS[1], "tokens", tokens, "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(capacity / rate))
+return {allowed, math.floor(tokens), math.ceil((capacity - tokens) / rate), retry}
+`)
+
+// Limit 限流规则
+type Limit struct {
+	Algorithm string        // 限流算法, 可选sliding_window, token_bucket, 为空时为sliding_window
+	Rate      int           // 每Period允许的请求数
+	Period    time.Duration // 限流周期
+	Burst     int           // 令牌桶容量, 仅token_bucket有效, 为0时等于Rate
+}
+
+// LimitResult 限流结果
+type LimitResult struct {
+	Allowed    bool          // 是否允许本次请求
+	Limit      int           // 周期内允许的请求数
+	Remaining  int           // 剩余可用请求数
+	ResetAfter time.Duration // 额度完全恢复所需时长
+	RetryAfter time.Duration // 被拒绝时建议的重试等待时长
+}
+
+// limiterBreakDuration redis出错后直接使用进程内限流的时长, 期满后由单个请求探测redis是否恢复
+const limiterBreakDuration = 5 * time.Second
+
+// Limiter 基于redis Lua脚本的原子限流器, redis不可用时降级为进程内限流,
+// 降级期间各实例独立计数, 因此总体允许的请求数可能超过限制,
+// redis出错后的limiterBreakDuration内不再访问redis, 避免每个请求都等待redis超时
+type Limiter struct {
+	ct        *ClientContainer
+	prefix    string
+	fallback  *memLimiter
+	handleErr func(error)
+
+	// 熔断截止时间的UnixNano, 为0时表示redis可用
+	brokenUntil   int64
+	breakDuration time.Duration
+}
+
+// NewLimiter 创建限流器, 限流键在redis中的键为{prefix}:{key}, handleErr用于处理降级时的redis错误, 可以为nil
+func (ct *ClientContainer) NewLimiter(prefix string, handleErr func(error)) *Limiter {
+	return &Limiter{
+		ct:        ct,
+		prefix:    prefix,
+		fallback:  newMemLimiter(),
+		handleErr: handleErr,
+
+		breakDuration: limiterBreakDuration,
+	}
+}
+
+// Allow 按限流规则limit判断key的本次请求是否允许, 规则的Rate或Period不大于0时总是允许
+func (l *Limiter) Allow(ctx context.Context, key string, limit Limit) (rst LimitResult, err error) {
+	if limit.Rate <= 0 || limit.Period <= 0 {
+		rst = LimitResult{Allowed: true, Limit: limit.Rate, Remaining: limit.Rate}
+		return
+	}
+
+	switch limit.Algorithm {
+	case "", LimitSlidingWindow, LimitTokenBucket:
+	default:
+		err = fmt.Errorf("unsupported algorithm %q", limit.Algorithm)
+		return
+	}
+
+	now := time.Now()
+	rkey := l.prefix + ":" + key
+
+	if !l.acquireRedis(now) {
+		rst, err = l.fallback.allow(rkey, limit, now)
+	} else if rst, err = l.allowRedis(ctx, rkey, limit, now); err != nil {
+		// 请求自身的ctx结束不代表redis不可用
+		if ctx.Err() == nil {
+			atomic.StoreInt64(&l.brokenUntil, time.Now().Add(l.breakDuration).UnixNano())
+		}
+		if l.handleErr != nil {
+			l.handleErr(fmt.Errorf("limit %s: %w", rkey, err))
+		}
+		rst, err = l.fallback.allow(rkey, limit, now)
+	} else if atomic.LoadInt64(&l.brokenUntil) != 0 {
+		atomic.StoreInt64(&l.brokenUntil, 0)
+	}
+
+	// 规则热更新调低Rate后已有计数可能超过新的限制
+	if rst.Remaining < 0 {
+		rst.Remaining = 0
+	}
+
+	return
+}
+
+func (l *Limiter) allowRedis(ctx context.Context, key string, limit Limit, now time.Time) (rst LimitResult, err error) {
+	if limit.Algorithm == LimitTokenBucket {
+		return l.tokenBucket(ctx, key, limit, now)
+	}
+
+	return l.slidingWindow(ctx, key, limit, now)
+}
+
+// acquireRedis 判断本次请求是否访问redis, 熔断期间返回false,
+// 熔断期满后只有一个请求获得探测机会, 其余请求在探测结果返回前继续使用进程内限流
+func (l *Limiter) acquireRedis(now time.Time) bool {
+	until := atomic.LoadInt64(&l.brokenUntil)
+	if until == 0 {
+		return true
+	}
+
+	if now.UnixNano() < until {
+		return false
+	}
+
+	return atomic.CompareAndSwapInt64(&l.brokenUntil, until, now.Add(l.breakDuration).UnixNano())
+}
+
+func (l *Limiter) slidingWindow(ctx context.Context, key string, limit Limit, now time.Time) (rst LimitResult, err error) {
+	member, err := newToken()
+	if err != nil {
+		err = fmt.Errorf("new member: %w", err)
+		return
+	}
+
+	var vals []interface{}
+	err = l.ct.withClient(ctx, func(cli redis.Cmdable) (err error) {
+		vals, err = redisSlice(slidingWindowScript.Run(
+			cli, []string{key}, now.UnixNano()/int64(time.Millisecond), limit.Period.Milliseconds(), limit.Rate, member,
+		))
+		return
+	})
+	if err != nil {
+		err = fmt.Errorf("sliding window script: %w", err)
+		return
+	}
+
+	rst = LimitResult{
+		Allowed:    vals[0].(int64) == 1,
+		Limit:      limit.Rate,
+		Remaining:  int(vals[1].(int64)),
+		ResetAfter: time.Duration(vals[2].(int64)) * time.Millisecond,
+	}
+	if !rst.Allowed {
+		rst.RetryAfter = rst.ResetAfter
+	}
+
+	return
+}
+
+func (l *Limiter) tokenBucket(ctx context.Context, key string, limit Limit, now time.Time) (rst LimitResult, err error) {
+	capacity := limit.Burst
+	if capacity <= 0 {
+		capacity = limit.Rate
+	}
+
+	// 每毫秒补充的令牌数
+	rate := float64(limit.Rate) / float64(limit.Period.Milliseconds())
+
+	var vals []interface{}
+	err = l.ct.withClient(ctx, func(cli redis.Cmdable) (err error) {
+		vals, err = redisSlice(tokenBucketScript.Run(
+			cli, []string{key}, now.UnixNano()/int64(time.Millisecond), capacity, strconv.FormatFloat(rate, 'f', -1, 64),
+		))
+		return
+	})
+	if err != nil {
+		err = fmt.Errorf("token bucket script: %w", err)
+		return
+	}
+
+	rst = LimitResult{
+		Allowed:    vals[0].(int64) == 1,
+		Limit:      capacity,
+		Remaining:  int(vals[1].(int64)),
+		ResetAfter: time.Duration(vals[2].(int64)) * time.Millisecond,
+		RetryAfter: time.Duration(vals[3].(int64)) * time.Millisecond,
+	}
+
+	return
+}
+
+// redisSlice 校验脚本返回的整数数组
+func redisSlice(cmd *redis.Cmd) (vals []interface{}, err error) {
+	v, err := cmd.Result()
+	if err != nil {
+		return
+	}
+
+	vals, ok := v.([]interface{})
+	if !ok {
+		err = fmt.Errorf("unexpected script result %T", v)
+		return
+	}
+
+	for _, val := range vals {
+		if _, ok := val.(int64); !ok {
+			err = fmt.Errorf("unexpected script result element %T", val)
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,146 @@
+package redis
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// memLimiterSweepInterval 进程内限流器清理空闲限流键的间隔
+const memLimiterSweepInterval = time.Minute
+
+// memLimiter 进程内限流器, 算法与redis脚本一致, 用于redis不可用时降级
+type memLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*memWindow
+	buckets map[string]*memBucket
+	swept   time.Time
+}
+
+type memWindow struct {
+	hits   []time.Time // 窗口内的请求时间, 按时间升序
+	period time.Duration
+}
+
+type memBucket struct {
+	tokens float64
+	ts     time.Time
+	period time.Duration
+}
+
+func newMemLimiter() *memLimiter {
+	return &memLimiter{
+		windows: make(map[string]*memWindow),
+		buckets: make(map[string]*memBucket),
+		swept:   time.Now(),
+	}
+}
+
+func (ml *memLimiter) allow(key string, limit Limit, now time.Time) (rst LimitResult, err error) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	ml.sweep(now)
+
+	switch limit.Algorithm {
+	case "", LimitSlidingWindow:
+		rst = ml.slidingWindow(key, limit, now)
+	case LimitTokenBucket:
+		rst = ml.tokenBucket(key, limit, now)
+	default:
+		err = fmt.Errorf("unsupported algorithm %q", limit.Algorithm)
+	}
+
+	return
+}
+
+func (ml *memLimiter) slidingWindow(key string, limit Limit, now time.Time) (rst LimitResult) {
+	w, ok := ml.windows[key]
+	if !ok {
+		w = &memWindow{}
+		ml.windows[key] = w
+	}
+	w.period = limit.Period
+
+	start := now.Add(-limit.Period)
+	i := 0
+	for i < len(w.hits) && !w.hits[i].After(start) {
+		i++
+	}
+	w.hits = w.hits[i:]
+
+	rst.Limit = limit.Rate
+	if len(w.hits) < limit.Rate {
+		w.hits = append(w.hits, now)
+		rst.Allowed = true
+	}
+
+	rst.Remaining = limit.Rate - len(w.hits)
+	rst.ResetAfter = w.hits[0].Add(limit.Period).Sub(now)
+	if !rst.Allowed {
+		rst.RetryAfter = rst.ResetAfter
+	}
+
+	return
+}
+
+func (ml *memLimiter) tokenBucket(key string, limit Limit, now time.Time) (rst LimitResult) {
+	capacity := limit.Burst
+	if capacity <= 0 {
+		capacity = limit.Rate
+	}
+	rate := float64(limit.Rate) / float64(limit.Period.Milliseconds())
+
+	b, ok := ml.buckets[key]
+	if !ok {
+		b = &memBucket{tokens: float64(capacity), ts: now}
+		ml.buckets[key] = b
+	}
+	b.period = limit.Period
+
+	elapsed := float64(now.Sub(b.ts).Milliseconds())
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	b.tokens = math.Min(float64(capacity), b.tokens+elapsed*rate)
+	b.ts = now
+
+	rst.Limit = capacity
+	if b.tokens >= 1 {
+		b.tokens--
+		rst.Allowed = true
+	} else {
+		rst.RetryAfter = ceilDuration((1 - b.tokens) / rate)
+	}
+
+	rst.Remaining = int(b.tokens)
+	rst.ResetAfter = ceilDuration((float64(capacity) - b.tokens) / rate)
+
+	return
+}
+
+// sweep 定期删除已超过一个周期未使用的限流键, 调用方需持有ml.mu
+func (ml *memLimiter) sweep(now time.Time) {
+	if now.Sub(ml.swept) < memLimiterSweepInterval {
+		return
+	}
+	ml.swept = now
+
+	for key, w := range ml.windows {
+		if len(w.hits) == 0 || now.Sub(w.hits[len(w.hits)-1]) > w.period {
+			delete(ml.windows, key)
+		}
+	}
+
+	for key, b := range ml.buckets {
+		if now.Sub(b.ts) > b.period {
+			delete(ml.buckets, key)
+		}
+	}
+}
+
+// ceilDuration 将毫秒数向上取整为时长
+func ceilDuration(ms float64) time.Duration {
+	return time.Duration(math.Ceil(ms)) * time.Millisecond
+}
@@ -0,0 +1,78 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	ct, _ := newTestContainer(t)
+	l := ct.NewLimiter("rl", nil)
+
+	for _, algorithm := range []string{LimitSlidingWindow, LimitTokenBucket} {
+		limit := Limit{Algorithm: algorithm, Rate: 2, Period: time.Minute}
+		for i := 0; i < 2; i++ {
+			rst, err := l.Allow(context.Background(), algorithm, limit)
+			assert.Nil(t, err)
+			assert.True(t, rst.Allowed, algorithm)
+			assert.Equal(t, 1-i, rst.Remaining, algorithm)
+		}
+
+		rst, err := l.Allow(context.Background(), algorithm, limit)
+		assert.Nil(t, err)
+		assert.False(t, rst.Allowed, algorithm)
+		assert.True(t, rst.RetryAfter > 0, algorithm)
+	}
+
+	_, err := l.Allow(context.Background(), "k", Limit{Algorithm: "unknown", Rate: 1, Period: time.Second})
+	assert.NotNil(t, err)
+}
+
+func TestLimiterBreak(t *testing.T) {
+	ct, mr := newTestContainer(t)
+
+	var errs int
+	l := ct.NewLimiter("rl", func(error) { errs++ })
+	l.breakDuration = 100 * time.Millisecond
+
+	limit := Limit{Rate: 1, Period: time.Minute}
+
+	// redis出错时降级为进程内限流, 熔断期间不再访问redis
+	mr.SetError("LOADING")
+	rst, err := l.Allow(context.Background(), "k", limit)
+	assert.Nil(t, err)
+	assert.True(t, rst.Allowed)
+	assert.Equal(t, 1, errs)
+
+	start := mr.CommandCount()
+	rst, err = l.Allow(context.Background(), "k", limit)
+	assert.Nil(t, err)
+	assert.False(t, rst.Allowed)
+	assert.Equal(t, 1, errs)
+	assert.Equal(t, start, mr.CommandCount())
+
+	// 熔断期满后探测失败继续熔断
+	time.Sleep(l.breakDuration)
+	_, err = l.Allow(context.Background(), "k", limit)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, errs)
+	assert.False(t, l.acquireRedis(time.Now()))
+
+	// 探测成功后恢复使用redis
+	mr.SetError("")
+	time.Sleep(l.breakDuration)
+	rst, err = l.Allow(context.Background(), "k", limit)
+	assert.Nil(t, err)
+	assert.True(t, rst.Allowed)
+	assert.Equal(t, int64(0), l.brokenUntil)
+	assert.True(t, mr.Exists("rl:k"))
+
+	// 熔断期满后只有一个请求获得探测机会
+	l.brokenUntil = time.Now().UnixNano()
+	now := time.Now()
+	assert.True(t, l.acquireRedis(now))
+	assert.False(t, l.acquireRedis(now))
+}
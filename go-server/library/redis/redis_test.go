@@ -0,0 +1,21 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestContainer 创建连接到内存redis的客户端容器, 测试结束时自动关闭
+func newTestContainer(t *testing.T) (*ClientContainer, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+
+	ct, err := NewContainer(func() (*ClientConf, error) {
+		return &ClientConf{Addr: mr.Addr(), PoolSize: 4}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ct, mr
+}
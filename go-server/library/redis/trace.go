@@ -0,0 +1,51 @@
+package redis
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-redis/redis"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	"go-server/library/trace"
+)
+
+// wrapTrace 包装WithContext返回的底层客户端的命令处理函数, 为每个命令和管道创建以ctx中的span为上游的span,
+// 底层客户端在WithContext时复制, 包装仅对该客户端生效, span不记录命令参数, 键不存在不视为span错误
+func wrapTrace(ctx context.Context, ocli redis.UniversalClient) {
+	start := func(op string) oteltrace.Span {
+		_, span := trace.StartSpan(ctx, "redis "+op,
+			oteltrace.WithSpanKind(oteltrace.SpanKindClient),
+			oteltrace.WithAttributes(
+				semconv.DBSystemRedis,
+				semconv.DBOperationKey.String(op),
+			),
+		)
+		return span
+	}
+
+	ocli.WrapProcess(func(old func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+		return func(cmd redis.Cmder) (err error) {
+			span := start(strings.ToLower(cmd.Name()))
+			err = old(cmd)
+
+			serr := ignoreNil(err)
+			trace.EndSpan(span, &serr)
+
+			return
+		}
+	})
+
+	ocli.WrapProcessPipeline(func(old func([]redis.Cmder) error) func([]redis.Cmder) error {
+		return func(cmds []redis.Cmder) (err error) {
+			span := start(CommandPipeline)
+			err = old(cmds)
+
+			serr := ignoreNil(err)
+			trace.EndSpan(span, &serr)
+
+			return
+		}
+	})
+}
@@ -0,0 +1,38 @@
+package trace
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Carrier 链路信息的载体, 如HTTP请求头, Kafka消息头和dubbo附件
+type Carrier = propagation.TextMapCarrier
+
+// Propagator 传递链路信息的传播器, 以W3C traceparent传递span上下文, 以X-Request-ID传递请求ID,
+// 组件库直接使用而不依赖全局传播器, 未配置TracerProvider时同样传递上游的链路信息
+var Propagator propagation.TextMapPropagator = propagation.NewCompositeTextMapPropagator(
+	propagation.TraceContext{},
+	requestIDPropagator{},
+)
+
+// requestIDPropagator 以X-Request-ID传递请求ID
+type requestIDPropagator struct{}
+
+func (requestIDPropagator) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	if id := RequestID(ctx); id != "" {
+		carrier.Set(HeaderRequestID, id)
+	}
+}
+
+func (requestIDPropagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	if id := carrier.Get(HeaderRequestID); id != "" {
+		return WithRequestID(ctx, id)
+	}
+
+	return ctx
+}
+
+func (requestIDPropagator) Fields() []string {
+	return []string{HeaderRequestID}
+}
@@ -0,0 +1,34 @@
+package trace
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// TracerName 创建span使用的Tracer名称
+const TracerName = "go-server"
+
+// StartSpan 开始一个OpenTelemetry span, ctx中有span上下文(包括由Extract得到的远程span上下文)时作为其子span,
+// 返回的ctx携带新span, 日志和向下游传递的链路信息随之以新span为当前操作, 请求ID不变
+func StartSpan(ctx context.Context, name string, opts ...oteltrace.SpanStartOption) (context.Context, oteltrace.Span) {
+	return otel.Tracer(TracerName).Start(ctx, name, opts...)
+}
+
+// Traced 返回ctx是否携带有效的span上下文, 组件库仅为链路中的操作创建span,
+// 避免健康检查, 后台任务等不属于任何请求的操作各自产生单独的链路
+func Traced(ctx context.Context) bool {
+	return oteltrace.SpanContextFromContext(ctx).IsValid()
+}
+
+// EndSpan 以errp指向的错误设置span的状态后结束span, 用于以defer方式调用
+func EndSpan(span oteltrace.Span, errp *error) {
+	if errp != nil && *errp != nil {
+		span.RecordError(*errp)
+		span.SetStatus(codes.Error, (*errp).Error())
+	}
+
+	span.End()
+}
@@ -0,0 +1,115 @@
+// trace 包以OpenTelemetry span上下文作为链路ID和操作ID的唯一来源, 并在context中传递请求ID,
+// 通过Propagator以W3C traceparent和X-Request-ID在HTTP请求头, Kafka消息头和dubbo附件中传递链路信息,
+// 用于将HTTP请求, Kafka消息和日志关联到同一链路
+package trace
+
+import (
+	"context"
+
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	"go-server/util/uuid"
+)
+
+// 传递链路信息的HTTP请求头, Kafka消息头使用对应的小写形式
+const (
+	HeaderTraceparent = "traceparent"
+	HeaderRequestID   = "X-Request-ID"
+)
+
+// 日志中链路信息的字段名
+const (
+	FieldTraceID   = "trace_id"
+	FieldSpanID    = "span_id"
+	FieldRequestID = "request_id"
+)
+
+// SpanContext 当前操作的链路信息
+type SpanContext struct {
+	TraceID   string // 链路ID, 32位十六进制, ctx没有有效的span上下文时为空
+	SpanID    string // 当前操作ID, 16位十六进制, ctx没有有效的span上下文时为空
+	RequestID string // 请求ID, 同一链路内保持不变
+}
+
+type requestIDKey struct{}
+
+// WithRequestID 返回携带请求ID的ctx
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID 返回ctx携带的请求ID, 没有时返回空字符串
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	id, _ := ctx.Value(requestIDKey{}).(string)
+
+	return id
+}
+
+// FromContext 返回ctx中span上下文的链路ID和操作ID以及请求ID, 两者都没有时ok为false
+func FromContext(ctx context.Context) (sc SpanContext, ok bool) {
+	if ctx == nil {
+		return
+	}
+
+	sc.RequestID = RequestID(ctx)
+	if ssc := oteltrace.SpanContextFromContext(ctx); ssc.IsValid() {
+		sc.TraceID = ssc.TraceID().String()
+		sc.SpanID = ssc.SpanID().String()
+	}
+	ok = sc.TraceID != "" || sc.RequestID != ""
+
+	return
+}
+
+// Extract 返回携带carrier中链路信息的ctx, 其中的span上下文作为之后创建的span的远程父span,
+// carrier中没有请求ID时生成新的请求ID
+func Extract(ctx context.Context, carrier Carrier) context.Context {
+	ctx = Propagator.Extract(ctx, carrier)
+	if RequestID(ctx) == "" {
+		ctx = WithRequestID(ctx, uuid.NewUUID().String())
+	}
+
+	return ctx
+}
+
+// Inject 将ctx的span上下文和请求ID写入carrier, 覆盖已有的同名键
+func Inject(ctx context.Context, carrier Carrier) {
+	Propagator.Inject(ctx, carrier)
+}
+
+// Detach 返回只携带ctx中span上下文和请求ID的ctx, 不继承ctx的取消, 超时和其他值,
+// 用于请求结束或取消后仍需完成的操作, 如写入数据库后的缓存失效, 以及多个调用方共享的加载
+func Detach(ctx context.Context) context.Context {
+	dctx := context.Background()
+	if sc := oteltrace.SpanContextFromContext(ctx); sc.IsValid() {
+		dctx = oteltrace.ContextWithSpanContext(dctx, sc)
+	}
+	if id := RequestID(ctx); id != "" {
+		dctx = WithRequestID(dctx, id)
+	}
+
+	return dctx
+}
+
+// LogFields 返回ctx携带的链路信息对应的日志字段, 没有链路信息时返回nil
+func LogFields(ctx context.Context) (fields map[string]interface{}) {
+	sc, ok := FromContext(ctx)
+	if !ok {
+		return
+	}
+
+	fields = make(map[string]interface{}, 3)
+	if sc.RequestID != "" {
+		fields[FieldRequestID] = sc.RequestID
+	}
+	if sc.TraceID != "" {
+		fields[FieldTraceID] = sc.TraceID
+		fields[FieldSpanID] = sc.SpanID
+	}
+
+	return
+}
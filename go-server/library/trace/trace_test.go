@@ -0,0 +1,114 @@
+package trace
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/propagation"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+const (
+	testTraceID = "0af7651916cd43dd8448eb211c80319c"
+	testSpanID  = "b7ad6b7169203331"
+)
+
+func testSpanContext(t *testing.T) oteltrace.SpanContext {
+	tid, err := oteltrace.TraceIDFromHex(testTraceID)
+	assert.Nil(t, err)
+	sid, err := oteltrace.SpanIDFromHex(testSpanID)
+	assert.Nil(t, err)
+
+	return oteltrace.NewSpanContext(oteltrace.SpanContextConfig{TraceID: tid, SpanID: sid, TraceFlags: oteltrace.FlagsSampled})
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name        string
+		traceparent string
+		requestID   string
+		traced      bool
+	}{
+		{name: "traceparent and request id", traceparent: "00-" + testTraceID + "-" + testSpanID + "-01", requestID: "req-1", traced: true},
+		{name: "request id only", requestID: "req-1"},
+		{name: "invalid traceparent", traceparent: "00-00000000000000000000000000000000-" + testSpanID + "-01"},
+		{name: "none"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.traceparent != "" {
+				header.Set(HeaderTraceparent, tt.traceparent)
+			}
+			if tt.requestID != "" {
+				header.Set(HeaderRequestID, tt.requestID)
+			}
+
+			ctx := Extract(context.Background(), propagation.HeaderCarrier(header))
+			assert.Equal(t, tt.traced, Traced(ctx))
+
+			// 没有请求ID时生成新的请求ID
+			sc, ok := FromContext(ctx)
+			assert.True(t, ok)
+			assert.NotEmpty(t, sc.RequestID)
+			if tt.requestID != "" {
+				assert.Equal(t, tt.requestID, sc.RequestID)
+			}
+			if tt.traced {
+				assert.Equal(t, testTraceID, sc.TraceID)
+				assert.Equal(t, testSpanID, sc.SpanID)
+				assert.True(t, oteltrace.SpanContextFromContext(ctx).IsRemote())
+			}
+		})
+	}
+}
+
+func TestInject(t *testing.T) {
+	ctx := oteltrace.ContextWithSpanContext(context.Background(), testSpanContext(t))
+	ctx = WithRequestID(ctx, "req-1")
+
+	// 覆盖已有的同名请求头
+	header := http.Header{}
+	header.Set(HeaderTraceparent, "00-"+testTraceID+"-0000000000000001-01")
+	Inject(ctx, propagation.HeaderCarrier(header))
+	assert.Equal(t, "00-"+testTraceID+"-"+testSpanID+"-01", header.Get(HeaderTraceparent))
+	assert.Equal(t, "req-1", header.Get(HeaderRequestID))
+
+	// 没有链路信息时不写入
+	header = http.Header{}
+	Inject(context.Background(), propagation.HeaderCarrier(header))
+	assert.Empty(t, header)
+}
+
+func TestDetach(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	ctx = oteltrace.ContextWithSpanContext(ctx, testSpanContext(t))
+	ctx = WithRequestID(ctx, "req-1")
+	cancel()
+
+	// 只保留链路信息, 不继承取消和超时
+	dctx := Detach(ctx)
+	assert.Nil(t, dctx.Err())
+	_, ok := dctx.Deadline()
+	assert.False(t, ok)
+
+	sc, _ := FromContext(dctx)
+	assert.Equal(t, SpanContext{TraceID: testTraceID, SpanID: testSpanID, RequestID: "req-1"}, sc)
+	assert.Equal(t, context.Background(), Detach(context.Background()))
+}
+
+func TestLogFields(t *testing.T) {
+	assert.Nil(t, LogFields(context.Background()))
+	assert.Equal(t, map[string]interface{}{FieldRequestID: "req-1"}, LogFields(WithRequestID(context.Background(), "req-1")))
+
+	ctx := WithRequestID(oteltrace.ContextWithSpanContext(context.Background(), testSpanContext(t)), "req-1")
+	assert.Equal(t, map[string]interface{}{
+		FieldTraceID:   testTraceID,
+		FieldSpanID:    testSpanID,
+		FieldRequestID: "req-1",
+	}, LogFields(ctx))
+}
@@ -0,0 +1,144 @@
+package tracesdk
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// FileClient 实现了otlptrace.Client, 将每批span以一行OTLP JSON格式的ExportTraceServiceRequest追加写入文件,
+// 链路ID和操作ID按OTLP JSON规范以十六进制编码, 所有方法并发安全
+type FileClient struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileClient 创建写入path的文件客户端, 文件在Start时打开
+func NewFileClient(path string) *FileClient {
+	return &FileClient{path: path}
+}
+
+// Start 实现otlptrace.Client, 以追加方式打开文件, 目录不存在时创建
+func (c *FileClient) Start(ctx context.Context) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err = os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		err = fmt.Errorf("mkdir %s: %w", filepath.Dir(c.path), err)
+		return
+	}
+
+	if c.file, err = os.OpenFile(c.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+		err = fmt.Errorf("open %s: %w", c.path, err)
+		return
+	}
+
+	return
+}
+
+// Stop 实现otlptrace.Client, 关闭文件
+func (c *FileClient) Stop(ctx context.Context) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return
+	}
+
+	err = c.file.Close()
+	c.file = nil
+
+	return
+}
+
+// UploadTraces 实现otlptrace.Client, 写入一行OTLP JSON
+func (c *FileClient) UploadTraces(ctx context.Context, protoSpans []*tracepb.ResourceSpans) (err error) {
+	line, err := marshalRequest(&coltracepb.ExportTraceServiceRequest{ResourceSpans: protoSpans})
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		err = os.ErrClosed
+		return
+	}
+
+	if _, err = c.file.Write(append(line, '\n')); err != nil {
+		err = fmt.Errorf("write %s: %w", c.path, err)
+		return
+	}
+
+	return
+}
+
+// idFields 以十六进制编码的ID字段, protojson默认以base64编码bytes类型字段
+var idFields = map[string]bool{"traceId": true, "spanId": true, "parentSpanId": true}
+
+// marshalRequest 以OTLP JSON格式编码req, 枚举值编码为整数, ID字段编码为十六进制
+func marshalRequest(req *coltracepb.ExportTraceServiceRequest) (data []byte, err error) {
+	data, err = protojson.MarshalOptions{UseEnumNumbers: true}.Marshal(req)
+	if err != nil {
+		err = fmt.Errorf("protojson.Marshal: %w", err)
+		return
+	}
+
+	var v interface{}
+	if err = json.Unmarshal(data, &v); err != nil {
+		err = fmt.Errorf("json.Unmarshal: %w", err)
+		return
+	}
+
+	if err = hexIDs(v); err != nil {
+		return
+	}
+
+	if data, err = json.Marshal(v); err != nil {
+		err = fmt.Errorf("json.Marshal: %w", err)
+		return
+	}
+
+	return
+}
+
+// hexIDs 将v中所有ID字段的值由base64转换为十六进制
+func hexIDs(v interface{}) (err error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, fv := range v {
+			if s, ok := fv.(string); ok && idFields[k] {
+				b, derr := base64.StdEncoding.DecodeString(s)
+				if derr != nil {
+					err = fmt.Errorf("decode %s: %w", k, derr)
+					return
+				}
+				v[k] = hex.EncodeToString(b)
+				continue
+			}
+			if err = hexIDs(fv); err != nil {
+				return
+			}
+		}
+	case []interface{}:
+		for _, ev := range v {
+			if err = hexIDs(ev); err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
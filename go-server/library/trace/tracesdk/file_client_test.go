@@ -0,0 +1,100 @@
+package tracesdk
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// fileSpan 导出文件中span的ID字段
+type fileSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Kind         int    `json:"kind"`
+}
+
+// readFileSpans 读取导出文件中所有的span, 每行为一个ExportTraceServiceRequest
+func readFileSpans(t *testing.T, file string) (spans []fileSpan) {
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatalf("open %s: %v", file, err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var req struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []fileSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if err = json.Unmarshal(sc.Bytes(), &req); err != nil {
+			t.Fatalf("unmarshal %q: %v", sc.Text(), err)
+		}
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}
+	assert.Nil(t, sc.Err())
+
+	return
+}
+
+func TestFileExporter(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "trace", "spans.json")
+	p, err := NewProvider(&ProviderConf{ServiceName: "go-server", Exporter: ExporterFile, File: file, SampleRatio: 1})
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer otel.SetTracerProvider(oteltrace.NewNoopTracerProvider())
+
+	tracer := otel.Tracer("test")
+	ctx, parent := tracer.Start(context.Background(), "parent", oteltrace.WithSpanKind(oteltrace.SpanKindServer))
+	_, child := tracer.Start(ctx, "child", oteltrace.WithSpanKind(oteltrace.SpanKindClient))
+	child.End()
+	parent.End()
+
+	assert.Nil(t, p.Close())
+
+	spans := readFileSpans(t, file)
+	if !assert.Len(t, spans, 2) {
+		return
+	}
+	got := map[string]fileSpan{}
+	for _, s := range spans {
+		got[s.Name] = s
+	}
+
+	// ID按十六进制编码, 与span的ID一致
+	psc, csc := parent.SpanContext(), child.SpanContext()
+	assert.Equal(t, fileSpan{
+		TraceID: psc.TraceID().String(),
+		SpanID:  psc.SpanID().String(),
+		Name:    "parent",
+		Kind:    int(oteltrace.SpanKindServer),
+	}, got["parent"])
+	assert.Equal(t, fileSpan{
+		TraceID:      csc.TraceID().String(),
+		SpanID:       csc.SpanID().String(),
+		ParentSpanID: psc.SpanID().String(),
+		Name:         "child",
+		Kind:         int(oteltrace.SpanKindClient),
+	}, got["child"])
+	assert.Equal(t, psc.TraceID(), csc.TraceID())
+
+	_, err = NewProvider(&ProviderConf{Exporter: ExporterFile})
+	assert.NotNil(t, err)
+}
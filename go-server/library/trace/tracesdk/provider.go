@@ -0,0 +1,133 @@
+// tracesdk 包按配置创建OpenTelemetry TracerProvider并设置为全局TracerProvider,
+// 支持输出到标准输出, 写入OTLP JSON文件和通过OTLP/HTTP发送到采集器, 未配置导出器时仍生成链路ID和操作ID但不导出span
+package tracesdk
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+
+	"go-server/library/trace"
+)
+
+// 导出器类型
+const (
+	ExporterNone     = "none"     // 不导出, span只用于生成日志和向下游传递的链路信息
+	ExporterStdout   = "stdout"   // 以JSON格式输出到标准输出, 用于本地开发
+	ExporterFile     = "file"     // 以OTLP JSON格式逐行写入文件, 用于离线环境, 文件可由采集器的otlpjsonfile接收器读取
+	ExporterOTLPHTTP = "otlphttp" // 通过OTLP/HTTP发送到采集器
+)
+
+const defaultShutdownTimeout = 5 * time.Second
+
+// ProviderConf 创建TracerProvider所需的配置
+type ProviderConf struct {
+	ServiceName string  // 服务名称, 作为span的service.name资源属性
+	Exporter    string  // 导出器类型, 为空或none时不导出span
+	File        string  // file导出器写入的文件路径
+	Endpoint    string  // otlphttp导出器的采集器地址, 如localhost:4318
+	Insecure    bool    // otlphttp导出器是否使用HTTP而不是HTTPS
+	SampleRatio float64 // 链路起点的采样比例, 取值0到1, 有上游链路时跟随上游的采样决定
+}
+
+// Provider 封装了OpenTelemetry TracerProvider
+type Provider struct {
+	tp *sdktrace.TracerProvider
+}
+
+// NewProvider 按配置创建TracerProvider并设置为全局TracerProvider, 同时将trace.Propagator设置为全局传播器
+func NewProvider(cf *ProviderConf) (p *Provider, err error) {
+	exporter, err := newExporter(cf)
+	if err != nil {
+		return
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceNameKey.String(cf.ServiceName),
+	))
+	if err != nil {
+		if exporter != nil {
+			_ = exporter.Shutdown(context.Background())
+		}
+		err = fmt.Errorf("resource.Merge: %w", err)
+		return
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cf.SampleRatio))),
+	}
+	if exporter != nil {
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	tp := sdktrace.NewTracerProvider(opts...)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(trace.Propagator)
+
+	p = &Provider{tp: tp}
+
+	return
+}
+
+func newExporter(cf *ProviderConf) (exporter sdktrace.SpanExporter, err error) {
+	switch strings.ToLower(cf.Exporter) {
+	case "", ExporterNone:
+	case ExporterStdout:
+		if exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout)); err != nil {
+			err = fmt.Errorf("stdouttrace.New: %w", err)
+			return
+		}
+	case ExporterFile:
+		if cf.File == "" {
+			err = fmt.Errorf("file exporter requires file path")
+			return
+		}
+		if exporter, err = otlptrace.New(context.Background(), NewFileClient(cf.File)); err != nil {
+			err = fmt.Errorf("otlptrace.New: %w", err)
+			return
+		}
+	case ExporterOTLPHTTP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cf.Endpoint)}
+		if cf.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if exporter, err = otlptracehttp.New(context.Background(), opts...); err != nil {
+			err = fmt.Errorf("otlptracehttp.New: %w", err)
+			return
+		}
+	default:
+		err = fmt.Errorf("unsupported exporter %q", cf.Exporter)
+	}
+
+	return
+}
+
+// ForceFlush 导出所有已结束但尚未导出的span
+func (p *Provider) ForceFlush(ctx context.Context) error {
+	return p.tp.ForceFlush(ctx)
+}
+
+// Close 实现io.Closer接口, 导出剩余的span后关闭TracerProvider和导出器, 最多等待5秒
+func (p *Provider) Close() (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+	defer cancel()
+
+	if err = p.tp.Shutdown(ctx); err != nil {
+		err = fmt.Errorf("TracerProvider.Shutdown: %w", err)
+		return
+	}
+
+	return
+}
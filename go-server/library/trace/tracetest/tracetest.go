@@ -0,0 +1,43 @@
+// tracetest 包提供链路追踪的测试辅助函数, 将结束的span记录在内存中, 用于检查span树和链路信息的传递
+package tracetest
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Recorder 记录测试期间结束的span
+type Recorder struct {
+	*tracetest.SpanRecorder
+}
+
+// Record 设置记录所有span的全局TracerProvider, 测试结束时恢复为不记录的TracerProvider,
+// 修改了全局状态, 使用它的测试不能并行执行
+func Record(tb testing.TB) *Recorder {
+	tb.Helper()
+
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	otel.SetTracerProvider(tp)
+	tb.Cleanup(func() {
+		otel.SetTracerProvider(oteltrace.NewNoopTracerProvider())
+	})
+
+	return &Recorder{SpanRecorder: sr}
+}
+
+// Span 返回最后结束的名称为name的span, 没有时返回nil
+func (r *Recorder) Span(name string) sdktrace.ReadOnlySpan {
+	spans := r.Ended()
+	for i := len(spans) - 1; i >= 0; i-- {
+		if spans[i].Name() == name {
+			return spans[i]
+		}
+	}
+
+	return nil
+}
@@ -1,4 +1,4 @@
-CREATE TABLE `t_product_category` (
+CREATE TABLE IF NOT EXISTS `t_product_category` (
     `id` INT(11) NOT NULL AUTO_INCREMENT COMMENT '主键ID',
     `parent_id` INT(11)  NOT NULL DEFAULT '0' COMMENT '父ID',
     `category_name` VARCHAR(128) NOT NULL DEFAULT '' COMMENT '类目名称',
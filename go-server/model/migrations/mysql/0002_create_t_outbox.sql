@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS `t_outbox` (
+    `id` BIGINT(20) NOT NULL AUTO_INCREMENT COMMENT '主键ID, 同一聚合的事件按ID顺序投递',
+    `aggregate_type` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '聚合类型',
+    `aggregate_id` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '聚合ID',
+    `event_type` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '事件类型',
+    `topic` VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'Kafka主题',
+    `msg_key` VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'Kafka消息键',
+    `payload` MEDIUMBLOB NOT NULL COMMENT '消息内容',
+    `headers` TEXT NOT NULL COMMENT '消息头, JSON对象',
+    `attempts` INT(11) NOT NULL DEFAULT '0' COMMENT '投递尝试次数',
+    `last_error` VARCHAR(1024) NOT NULL DEFAULT '' COMMENT '最后一次投递失败的错误',
+    `locked_until` TIMESTAMP NULL DEFAULT NULL COMMENT '投递租约到期时间, 到期前其他投递者不会领取该事件',
+    `parked_at` TIMESTAMP NULL DEFAULT NULL COMMENT '达到最大投递次数后搁置的时间, 搁置的事件不再投递',
+    `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    `sent_at` TIMESTAMP NULL DEFAULT NULL COMMENT '投递成功时间',
+    PRIMARY KEY (`id`),
+    KEY (`sent_at`, `id`)
+) ENGINE=InnoDB CHARSET=utf8mb4 COMMENT='事务发件箱表';
@@ -0,0 +1,21 @@
+-- 产品类目表, 与mysql/0001_create_t_product_category.sql保持结构一致
+CREATE TABLE IF NOT EXISTS t_product_category (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    parent_id INTEGER NOT NULL DEFAULT 0,
+    category_name VARCHAR(128) NOT NULL DEFAULT '',
+    category_name_en VARCHAR(128) NOT NULL DEFAULT '',
+    image VARCHAR(128) NOT NULL DEFAULT '',
+    detail VARCHAR(1024) NOT NULL DEFAULT '',
+    detail_en VARCHAR(1024) NOT NULL DEFAULT '',
+    is_deleted TINYINT NOT NULL DEFAULT 0,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_t_product_category_parent_id ON t_product_category (parent_id);
+
+-- 模拟MySQL的ON UPDATE CURRENT_TIMESTAMP
+CREATE TRIGGER IF NOT EXISTS trg_t_product_category_updated_at AFTER UPDATE ON t_product_category
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at BEGIN
+    UPDATE t_product_category SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
@@ -0,0 +1,19 @@
+-- 事务发件箱表, 与mysql/0002_create_t_outbox.sql保持结构一致
+CREATE TABLE IF NOT EXISTS t_outbox (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    aggregate_type VARCHAR(64) NOT NULL DEFAULT '',
+    aggregate_id VARCHAR(64) NOT NULL DEFAULT '',
+    event_type VARCHAR(64) NOT NULL DEFAULT '',
+    topic VARCHAR(255) NOT NULL DEFAULT '',
+    msg_key VARCHAR(255) NOT NULL DEFAULT '',
+    payload BLOB NOT NULL,
+    headers TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error VARCHAR(1024) NOT NULL DEFAULT '',
+    locked_until TEXT NULL DEFAULT NULL,
+    parked_at TEXT NULL DEFAULT NULL,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at TEXT NULL DEFAULT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_t_outbox_sent_at_id ON t_outbox (sent_at, id);
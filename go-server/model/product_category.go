@@ -4,9 +4,13 @@
 package model
 
 import (
+	"context"
 	"fmt"
+	"strconv"
 
 	"go-server/component"
+	"go-server/library/mysql"
+	"go-server/library/outbox"
 )
 
 // ProductCategory 定义产品类目结构
@@ -23,16 +27,50 @@ type ProductCategory struct {
 	UpdatedAt      string `db:"updated_at" json:"updated_at"`
 }
 
+const (
+	ProductCategoryEventTopic   = "product_category_event" // 产品类目变更事件主题
+	productCategoryAggregate    = "product_category"
+	productCategoryEventCreated = "created"
+	productCategoryEventDeleted = "deleted"
+	productCategoryEventUpdated = "updated"
+)
+
+// addProductCategoryEvent 在事务中写入产品类目变更事件
+func addProductCategoryEvent(tx *mysql.Tx, id int64, eventType string, payload interface{}) (err error) {
+	if _, err = outbox.AddJSON(tx, &outbox.Event{
+		AggregateType: productCategoryAggregate,
+		AggregateID:   strconv.FormatInt(id, 10),
+		EventType:     eventType,
+		Topic:         ProductCategoryEventTopic,
+	}, payload); err != nil {
+		err = fmt.Errorf("outbox.AddJSON[event=%s]: %w", eventType, err)
+		return
+	}
+
+	return
+}
+
 const addProductCategorySQL = `INSERT INTO t_product_category (parent_id, category_name, 
 category_name_en, image, detail, detail_en) VALUES (?, ?, ?, ?, ?, ?)`
 
-// AddProductCategory 新增产品类目
-func AddProductCategory(cate *ProductCategory) (id int64, err error) {
-	if _, id, err = component.DBContainer.Exec(
-		addProductCategorySQL, cate.ParentID, cate.CategoryName, cate.CategoryNameEN,
-		cate.Image, cate.Detail, cate.DetailEN,
-	); err != nil {
-		err = fmt.Errorf("component.DBContainer.Exec[sql=%s]: %w", addProductCategorySQL, err)
+// AddProductCategory 新增产品类目, 并在同一事务中写入created事件
+func AddProductCategory(ctx context.Context, cate *ProductCategory) (id int64, err error) {
+	err = component.DBContainer.Transaction(ctx, func(tx *mysql.Tx) (err error) {
+		if _, id, err = tx.Exec(
+			addProductCategorySQL, cate.ParentID, cate.CategoryName, cate.CategoryNameEN,
+			cate.Image, cate.Detail, cate.DetailEN,
+		); err != nil {
+			err = fmt.Errorf("tx.Exec[sql=%s]: %w", addProductCategorySQL, err)
+			return
+		}
+
+		event := *cate
+		event.ID = id
+
+		return addProductCategoryEvent(tx, id, productCategoryEventCreated, &event)
+	})
+	if err != nil {
+		err = fmt.Errorf("component.DBContainer.Transaction: %w", err)
 		return
 	}
 
@@ -41,10 +79,18 @@ func AddProductCategory(cate *ProductCategory) (id int64, err error) {
 
 const deleteProductCategorySQL = `UPDATE t_product_category SET is_deleted = 1 WHERE id = ?`
 
-// DeleteProductCategory 删除产品类目
-func DeleteProductCategory(id int64) (err error) {
-	if _, _, err = component.DBContainer.Exec(deleteProductCategorySQL, id); err != nil {
-		err = fmt.Errorf("component.DBContainer.Exec[sql=%s]: %w", deleteProductCategorySQL, err)
+// DeleteProductCategory 删除产品类目, 并在同一事务中写入deleted事件
+func DeleteProductCategory(ctx context.Context, id int64) (err error) {
+	err = component.DBContainer.Transaction(ctx, func(tx *mysql.Tx) (err error) {
+		if _, _, err = tx.Exec(deleteProductCategorySQL, id); err != nil {
+			err = fmt.Errorf("tx.Exec[sql=%s]: %w", deleteProductCategorySQL, err)
+			return
+		}
+
+		return addProductCategoryEvent(tx, id, productCategoryEventDeleted, &ProductCategory{ID: id})
+	})
+	if err != nil {
+		err = fmt.Errorf("component.DBContainer.Transaction: %w", err)
 		return
 	}
 
@@ -54,13 +100,21 @@ func DeleteProductCategory(id int64) (err error) {
 const updateProductCategorySQL = `UPDATE t_product_category SET parent_id = ?, category_name = ?, category_name_en = ?, 
 image = ?, detail = ?, detail_en = ? WHERE id = ?`
 
-// UpdateProductCategory 更新产品类目
-func UpdateProductCategory(cate *ProductCategory) (err error) {
-	if _, _, err = component.DBContainer.Exec(
-		updateProductCategorySQL, cate.ParentID, cate.CategoryName, cate.CategoryNameEN, cate.Image,
-		cate.Detail, cate.DetailEN, cate.ID,
-	); err != nil {
-		err = fmt.Errorf("component.DBContainer.Exec[sql=%s]: %w", updateProductCategorySQL, err)
+// UpdateProductCategory 更新产品类目, 并在同一事务中写入updated事件
+func UpdateProductCategory(ctx context.Context, cate *ProductCategory) (err error) {
+	err = component.DBContainer.Transaction(ctx, func(tx *mysql.Tx) (err error) {
+		if _, _, err = tx.Exec(
+			updateProductCategorySQL, cate.ParentID, cate.CategoryName, cate.CategoryNameEN, cate.Image,
+			cate.Detail, cate.DetailEN, cate.ID,
+		); err != nil {
+			err = fmt.Errorf("tx.Exec[sql=%s]: %w", updateProductCategorySQL, err)
+			return
+		}
+
+		return addProductCategoryEvent(tx, cate.ID, productCategoryEventUpdated, cate)
+	})
+	if err != nil {
+		err = fmt.Errorf("component.DBContainer.Transaction: %w", err)
 		return
 	}
 
@@ -71,9 +125,9 @@ const queryProductCategoryListSQL = `SELECT id, parent_id, category_name, catego
 detail_en, is_deleted, created_at, updated_at FROM t_product_category WHERE is_deleted = 0 AND parent_id = ?`
 
 // QueryProductCategoryList 查询产品类目列表
-func QueryProductCategoryList(parentID int64) (list []*ProductCategory, err error) {
-	if err = component.DBContainer.Query(queryProductCategoryListSQL, &list, parentID); err != nil {
-		err = fmt.Errorf("component.DBContainer.Query[sql=%s]: %w", queryProductCategoryListSQL, err)
+func QueryProductCategoryList(ctx context.Context, parentID int64) (list []*ProductCategory, err error) {
+	if err = component.DBContainer.QueryContext(ctx, queryProductCategoryListSQL, &list, parentID); err != nil {
+		err = fmt.Errorf("component.DBContainer.QueryContext[sql=%s]: %w", queryProductCategoryListSQL, err)
 		return
 	}
 	return
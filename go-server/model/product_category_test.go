@@ -0,0 +1,101 @@
+package model
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+
+	"go-server/component"
+	"go-server/library/mysql/mysqltest"
+	"go-server/library/outbox"
+)
+
+// setupDB 将component.DBContainer替换为测试数据库, 测试结束时恢复
+func setupDB(t *testing.T) {
+	old := component.DBContainer
+	component.DBContainer = mysqltest.NewDBContainer(t, "migrations/sqlite")
+	t.Cleanup(func() {
+		component.DBContainer = old
+	})
+}
+
+func TestProductCategory(t *testing.T) {
+	setupDB(t)
+
+	id, err := AddProductCategory(context.Background(), &ProductCategory{
+		ParentID:       1,
+		CategoryName:   "手机",
+		CategoryNameEN: "Phone",
+	})
+	assert.Nil(t, err)
+	assert.NotZero(t, id)
+
+	err = UpdateProductCategory(context.Background(), &ProductCategory{
+		ID:             id,
+		ParentID:       1,
+		CategoryName:   "智能手机",
+		CategoryNameEN: "Smart Phone",
+	})
+	assert.Nil(t, err)
+
+	list, err := QueryProductCategoryList(context.Background(), 1)
+	assert.Nil(t, err)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, id, list[0].ID)
+		assert.Equal(t, "智能手机", list[0].CategoryName)
+		assert.Equal(t, "0", list[0].IsDeleted)
+		assert.NotEmpty(t, list[0].CreatedAt)
+	}
+
+	assert.Nil(t, DeleteProductCategory(context.Background(), id))
+
+	list, err = QueryProductCategoryList(context.Background(), 1)
+	assert.Nil(t, err)
+	assert.Empty(t, list)
+}
+
+type recordSender struct {
+	msgs []*sarama.ProducerMessage
+}
+
+func (s *recordSender) SendMessage(msg *sarama.ProducerMessage) (partition int32, offset int64, err error) {
+	s.msgs = append(s.msgs, msg)
+	return
+}
+
+func TestProductCategoryEvents(t *testing.T) {
+	setupDB(t)
+
+	id, err := AddProductCategory(context.Background(), &ProductCategory{ParentID: 1, CategoryName: "手机"})
+	assert.Nil(t, err)
+	assert.Nil(t, UpdateProductCategory(context.Background(), &ProductCategory{ID: id, ParentID: 1, CategoryName: "智能手机"}))
+	assert.Nil(t, DeleteProductCategory(context.Background(), id))
+
+	sender := &recordSender{}
+	relay := outbox.NewRelay(component.DBContainer, sender, nil)
+
+	sent, err := relay.RelayOnce(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 3, sent)
+
+	var types []string
+	for _, msg := range sender.msgs {
+		assert.Equal(t, ProductCategoryEventTopic, msg.Topic)
+		key, _ := msg.Key.Encode()
+		assert.Equal(t, strconv.FormatInt(id, 10), string(key))
+		for _, h := range msg.Headers {
+			if string(h.Key) == outbox.HeaderEventType {
+				types = append(types, string(h.Value))
+			}
+		}
+	}
+	assert.Equal(t, []string{"created", "updated", "deleted"}, types)
+
+	// 已投递的事件不会重复投递
+	sent, err = relay.RelayOnce(context.Background())
+	assert.Nil(t, err)
+	assert.Zero(t, sent)
+}
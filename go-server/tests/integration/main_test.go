@@ -39,6 +39,10 @@ import (
 	_ "github.com/apache/dubbo-go/registry/zookeeper"
 )
 
+import (
+	_ "go-server/library/dubbo"
+)
+
 var userProvider = new(UserProvider)
 
 func TestMain(m *testing.M) {